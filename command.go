@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/WindowsSov8forUs/glyccat/config"
+	"github.com/WindowsSov8forUs/glyccat/database"
+	"github.com/WindowsSov8forUs/glyccat/log"
+)
+
+const dbCommandUsage = `用法:
+  glyccat db export [目录]   将消息数据库导出为每个频道一个 JSON Lines 文件，默认目录为 data/export
+  glyccat db import <路径>   从 JSON Lines 文件或目录导入消息`
+
+// runCommand 执行子命令，没有子命令时返回 false
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "db":
+		os.Exit(runDBCommand(args[1:]))
+	default:
+		fmt.Printf("%s 未知的命令: %s\n", log.FailMark, args[0])
+		os.Exit(2)
+	}
+	return true
+}
+
+// runDBCommand 执行消息数据库相关的子命令，返回退出码
+func runDBCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(dbCommandUsage)
+		return 2
+	}
+
+	var path string
+	switch args[0] {
+	case "export":
+		path = "data/export"
+		if len(args) > 1 {
+			path = args[1]
+		}
+	case "import":
+		if len(args) < 2 {
+			fmt.Println(dbCommandUsage)
+			return 2
+		}
+		path = args[1]
+	default:
+		fmt.Println(dbCommandUsage)
+		return 2
+	}
+
+	// 没有配置文件时使用默认配置，避免进入首次配置流程
+	conf := config.DefaultConfig()
+	if _, err := os.Stat("config.yml"); err == nil {
+		if conf, err = config.LoadConfig("config.yml"); err != nil {
+			fmt.Printf("%s 加载配置文件时出错: %v\n", log.FailMark, log.Red(fmt.Sprint(err)))
+			return 1
+		}
+	}
+
+	if err := database.OpenMessageDB(conf); err != nil {
+		fmt.Printf("%s 打开消息数据库时出错: %v\n", log.FailMark, log.Red(fmt.Sprint(err)))
+		return 1
+	}
+	defer database.CloseMessageDB()
+
+	var count int
+	var err error
+	if args[0] == "export" {
+		count, err = database.ExportMessages(path)
+	} else {
+		count, err = database.ImportMessages(path)
+	}
+	if err != nil {
+		fmt.Printf("%s 已处理 %d 条消息后出错: %v\n", log.FailMark, count, log.Red(fmt.Sprint(err)))
+		return 1
+	}
+
+	if args[0] == "export" {
+		fmt.Printf("%s 已导出 %d 条消息到 %s\n", log.SuccessMark, count, path)
+	} else {
+		fmt.Printf("%s 已从 %s 导入 %d 条消息\n", log.SuccessMark, path, count)
+	}
+	return 0
+}
@@ -55,29 +55,76 @@ type QQWebHook struct {
 
 // FileServer 本地文件服务器配置
 type FileServer struct {
-	Enable      bool   `yaml:"enable"`       // 是否启用对外本地文件服务器
-	ExternalURL string `yaml:"external_url"` // 本地文件服务器公网地址 {{ .Host }}:{{ .Port }}
-	TTL         uint64 `yaml:"ttl"`          // 文件存储时间，单位秒
+	Enable        bool        `yaml:"enable"`         // 是否启用对外本地文件服务器
+	ExternalURL   string      `yaml:"external_url"`   // 本地文件服务器公网地址 {{ .Host }}:{{ .Port }}
+	TTL           uint64      `yaml:"ttl"`            // 文件存储时间，单位秒
+	SweepInterval uint64      `yaml:"sweep_interval"` // 过期文件清理间隔，单位秒
+	URLTTL        uint64      `yaml:"url_ttl"`        // 签名链接有效期，单位秒
+	SignKey       string      `yaml:"sign_key"`       // 链接签名密钥
+	MaxFileSize   uint64      `yaml:"max_file_size"`  // 单个文件大小上限，单位 MB
+	MaxTotalSize  uint64      `yaml:"max_total_size"` // 文件存储空间上限，单位 MB
+	Storage       FileStorage `yaml:"storage"`        // 文件存储配置
+}
+
+// FileStorage 文件存储配置
+type FileStorage struct {
+	Type string        `yaml:"type"` // 存储类型，可选 local 、s3
+	S3   FileStorageS3 `yaml:"s3"`   // S3 兼容存储配置
+}
+
+// FileStorageS3 S3 兼容存储配置
+type FileStorageS3 struct {
+	Endpoint       string `yaml:"endpoint"`        // 服务地址
+	PublicEndpoint string `yaml:"public_endpoint"` // 生成直链时使用的公网地址
+	Region         string `yaml:"region"`          // 区域
+	Bucket         string `yaml:"bucket"`          // 存储桶
+	AccessKey      string `yaml:"access_key"`      // 访问密钥 ID
+	SecretKey      string `yaml:"secret_key"`      // 访问密钥
+	PathStyle      bool   `yaml:"path_style"`      // 是否使用路径形式访问存储桶
+	Presign        bool   `yaml:"presign"`         // 是否向 QQ 提供预签名直链
 }
 
 // Database 数据库配置
 type Database struct {
 	MessageDatabase MessageDatabase `yaml:"message_database"` // 消息数据库配置
+	EntityCache     EntityCache     `yaml:"entity_cache"`     // 实体缓存配置
 }
 
 // MessageDatabase 消息数据库配置
 type MessageDatabase struct {
-	Enable bool `yaml:"enable"` // 是否启用消息数据库
-	Limit  int  `yaml:"limit"`  // 消息获取数量限制
+	Enable           bool             `yaml:"enable"`            // 是否启用消息数据库
+	Type             string           `yaml:"type"`              // 存储类型，可选 leveldb 、sqlite
+	Limit            int              `yaml:"limit"`             // 消息获取数量限制
+	Retention        MessageRetention `yaml:"retention"`         // 消息保留策略
+	ExcludedChannels []string         `yaml:"excluded_channels"` // 不存储消息的频道 ID
+}
+
+// EntityCache 群组、频道、成员与角色的缓存配置
+type EntityCache struct {
+	Enable bool `yaml:"enable"`  // 是否启用实体缓存
+	MaxAge int  `yaml:"max_age"` // 缓存最长有效时间，单位秒
+}
+
+// MessageRetention 消息保留策略配置
+type MessageRetention struct {
+	MaxAge        int `yaml:"max_age"`         // 消息最长保留天数
+	MaxPerChannel int `yaml:"max_per_channel"` // 每个频道最多保留的消息数
+	MaxSize       int `yaml:"max_size"`        // 消息数据库大小上限，单位 MB
+	Interval      int `yaml:"interval"`        // 清理间隔，单位分钟
 }
 
 // Satori Satori 配置
 type Satori struct {
-	Version uint8   `yaml:"version"` // Satori 版本，目前只有 1
-	Path    string  `yaml:"path"`    // Satori 部署路径，可以为空
-	Token   string  `yaml:"token"`   // 鉴权令牌
-	Server  Server  `yaml:"server"`  // 服务器配置
-	WebHook WebHook `yaml:"webhook"` // WebHook 客户端配置
+	Version      uint8        `yaml:"version"`       // Satori 版本，目前只有 1
+	Path         string       `yaml:"path"`          // Satori 部署路径，可以为空
+	Token        string       `yaml:"token"`         // 鉴权令牌
+	Server       Server       `yaml:"server"`        // 服务器配置
+	WebHook      WebHook      `yaml:"webhook"`       // WebHook 客户端配置
+	Proxy        Proxy        `yaml:"proxy"`         // 代理路由配置
+	LocalFile    LocalFile    `yaml:"local_file"`    // 本地文件访问配置
+	RemoteMedia  RemoteMedia  `yaml:"remote_media"`  // 远程媒体资源配置
+	InboundVoice InboundVoice `yaml:"inbound_voice"` // 接收语音转码配置
+	Transcode    Transcode    `yaml:"transcode"`     // 媒体转码配置
 }
 
 // Server 服务器配置
@@ -91,6 +138,63 @@ type WebHook struct {
 	Timeout uint32 `yaml:"timeout"` // 超时时间
 }
 
+// Proxy 代理路由配置
+type Proxy struct {
+	Urls      []string `yaml:"urls"`       // 允许代理的 URL 前缀
+	CacheSize uint64   `yaml:"cache_size"` // 代理缓存大小上限，单位 MB
+}
+
+// LocalFile 本地文件访问配置
+type LocalFile struct {
+	Enable      bool     `yaml:"enable"`       // 是否允许通过 file:// 读取本地文件
+	AllowedDirs []string `yaml:"allowed_dirs"` // 允许读取的目录
+	MaxSize     uint64   `yaml:"max_size"`     // 单个文件大小上限，单位 MB
+}
+
+// RemoteMedia 远程媒体资源配置
+type RemoteMedia struct {
+	Enable       bool   `yaml:"enable"`        // 是否下载远程媒体资源并按需转码
+	MaxSize      uint64 `yaml:"max_size"`      // 单个资源大小上限，单位 MB
+	Timeout      uint64 `yaml:"timeout"`       // 下载超时时间，单位秒
+	MaxRedirects int    `yaml:"max_redirects"` // 最大重定向次数
+}
+
+// InboundVoice 接收语音转码配置
+type InboundVoice struct {
+	Enable bool   `yaml:"enable"` // 是否将接收到的语音转码为通用格式
+	Format string `yaml:"format"` // 转码格式，可选 wav 、 mp3 与 ogg
+}
+
+// Transcode 媒体转码配置
+type Transcode struct {
+	Workers   int            `yaml:"workers"`    // 同时进行的转码任务数
+	Timeout   uint64         `yaml:"timeout"`    // 单个转码任务超时时间，单位秒
+	CacheSize uint64         `yaml:"cache_size"` // 转码缓存大小上限，单位 MB
+	CacheTTL  uint64         `yaml:"cache_ttl"`  // 转码缓存有效期，单位秒
+	Audio     TranscodeAudio `yaml:"audio"`      // 音频转码配置
+	Image     TranscodeImage `yaml:"image"`      // 图片转码配置
+	Video     TranscodeVideo `yaml:"video"`      // 视频转码配置
+}
+
+// TranscodeAudio 音频转码配置
+type TranscodeAudio struct {
+	InProcessEncoder bool `yaml:"in_process_encoder"` // 是否优先使用进程内 SILK 编码器
+}
+
+// TranscodeImage 图片转码配置
+type TranscodeImage struct {
+	MaxSize      uint64 `yaml:"max_size"`      // 图片大小上限，单位 KB
+	MaxDimension int    `yaml:"max_dimension"` // 图片边长上限，单位像素
+	Quality      int    `yaml:"quality"`       // JPEG 编码质量
+}
+
+// TranscodeVideo 视频转码配置
+type TranscodeVideo struct {
+	MaxSize    uint64 `yaml:"max_size"`    // 视频大小上限，单位 MB
+	MaxBitrate uint64 `yaml:"max_bitrate"` // 视频码率上限，单位 kbps
+	Thumbnail  bool   `yaml:"thumbnail"`   // 是否为接收的视频生成封面
+}
+
 // GetSatoriToken 获取 Satori 鉴权令牌
 func GetSatoriToken() string {
 	return instance.Satori.Token
@@ -100,16 +204,81 @@ func GetSatoriToken() string {
 func DefaultConfig() *Config {
 	return &Config{
 		LogLevel: log.INFO,
+		FileServer: FileServer{
+			SweepInterval: 60,    // 默认每 60 秒清理一次过期文件
+			URLTTL:        3600,  // 默认签名链接有效期为 1 小时
+			MaxFileSize:   100,   // 默认单个文件大小上限为 100 MB
+			MaxTotalSize:  10240, // 默认文件存储空间上限为 10 GB
+			Storage: FileStorage{
+				Type: "local",
+				S3: FileStorageS3{
+					Region:    "us-east-1",
+					PathStyle: true,
+					Presign:   true,
+				},
+			},
+		},
 		Database: Database{
 			MessageDatabase: MessageDatabase{
 				Enable: true,
+				Type:   "leveldb",
 				Limit:  50, // 默认消息获取数量限制
+				Retention: MessageRetention{
+					Interval: 60, // 默认每 60 分钟清理一次，保留策略默认不启用
+				},
+			},
+			EntityCache: EntityCache{
+				Enable: true,
+				MaxAge: 600, // 默认缓存 10 分钟
 			},
 		},
 		Satori: Satori{
 			WebHook: WebHook{
 				Timeout: 10, // 默认 WebHook 超时时间为 10 秒
 			},
+			Proxy: Proxy{
+				Urls: []string{
+					"https://gchat.qpic.cn/",
+					"http://gchat.qpic.cn/",
+					"https://multimedia.nt.qq.com.cn/",
+					"http://multimedia.nt.qq.com.cn/",
+				},
+				CacheSize: 256, // 默认代理缓存上限为 256 MB
+			},
+			LocalFile: LocalFile{
+				Enable:      true,
+				AllowedDirs: []string{},
+				MaxSize:     50, // 默认单个本地文件上限为 50 MB
+			},
+			RemoteMedia: RemoteMedia{
+				Enable:       false,
+				MaxSize:      50, // 默认单个远程资源上限为 50 MB
+				Timeout:      30, // 默认下载超时时间为 30 秒
+				MaxRedirects: 3,
+			},
+			InboundVoice: InboundVoice{
+				Enable: false,
+				Format: "wav",
+			},
+			Transcode: Transcode{
+				Workers:   2,
+				Timeout:   60,     // 默认单个转码任务超时时间为 60 秒
+				CacheSize: 256,    // 默认转码缓存上限为 256 MB
+				CacheTTL:  604800, // 默认转码缓存有效期为 7 天
+				Audio: TranscodeAudio{
+					InProcessEncoder: false,
+				},
+				Image: TranscodeImage{
+					MaxSize:      10240, // 默认图片大小上限为 10 MB
+					MaxDimension: 4096,
+					Quality:      85,
+				},
+				Video: TranscodeVideo{
+					MaxSize:    100,  // 默认视频大小上限为 100 MB
+					MaxBitrate: 5000, // 默认视频码率上限为 5000 kbps
+					Thumbnail:  false,
+				},
+			},
 		},
 	}
 }
@@ -141,14 +310,58 @@ func DumpConfig(conf *Config) string {
 		conf.FileServer.Enable,
 		conf.FileServer.ExternalURL,
 		conf.FileServer.TTL,
+		conf.FileServer.SweepInterval,
+		conf.FileServer.URLTTL,
+		conf.FileServer.SignKey,
+		conf.FileServer.MaxFileSize,
+		conf.FileServer.MaxTotalSize,
+		conf.FileServer.Storage.Type,
+		conf.FileServer.Storage.S3.Endpoint,
+		conf.FileServer.Storage.S3.PublicEndpoint,
+		conf.FileServer.Storage.S3.Region,
+		conf.FileServer.Storage.S3.Bucket,
+		conf.FileServer.Storage.S3.AccessKey,
+		conf.FileServer.Storage.S3.SecretKey,
+		conf.FileServer.Storage.S3.PathStyle,
+		conf.FileServer.Storage.S3.Presign,
 		conf.Database.MessageDatabase.Enable,
+		conf.Database.MessageDatabase.Type,
 		conf.Database.MessageDatabase.Limit,
+		conf.Database.MessageDatabase.Retention.MaxAge,
+		conf.Database.MessageDatabase.Retention.MaxPerChannel,
+		conf.Database.MessageDatabase.Retention.MaxSize,
+		conf.Database.MessageDatabase.Retention.Interval,
+		dumpStringList(conf.Database.MessageDatabase.ExcludedChannels, 6),
+		conf.Database.EntityCache.Enable,
+		conf.Database.EntityCache.MaxAge,
 		conf.Satori.Version,
 		conf.Satori.Path,
 		conf.Satori.Token,
 		conf.Satori.Server.Host,
 		conf.Satori.Server.Port,
 		conf.Satori.WebHook.Timeout,
+		dumpStringList(conf.Satori.Proxy.Urls, 6),
+		conf.Satori.Proxy.CacheSize,
+		conf.Satori.LocalFile.Enable,
+		dumpStringList(conf.Satori.LocalFile.AllowedDirs, 6),
+		conf.Satori.LocalFile.MaxSize,
+		conf.Satori.RemoteMedia.Enable,
+		conf.Satori.RemoteMedia.MaxSize,
+		conf.Satori.RemoteMedia.Timeout,
+		conf.Satori.RemoteMedia.MaxRedirects,
+		conf.Satori.InboundVoice.Enable,
+		conf.Satori.InboundVoice.Format,
+		conf.Satori.Transcode.Workers,
+		conf.Satori.Transcode.Timeout,
+		conf.Satori.Transcode.CacheSize,
+		conf.Satori.Transcode.CacheTTL,
+		conf.Satori.Transcode.Audio.InProcessEncoder,
+		conf.Satori.Transcode.Image.MaxSize,
+		conf.Satori.Transcode.Image.MaxDimension,
+		conf.Satori.Transcode.Image.Quality,
+		conf.Satori.Transcode.Video.MaxSize,
+		conf.Satori.Transcode.Video.MaxBitrate,
+		conf.Satori.Transcode.Video.Thumbnail,
 	)
 }
 
@@ -783,11 +996,19 @@ func mergeConfigWithTemplate(originalData []byte) ([]byte, error) {
 		return nil, fmt.Errorf("解析原配置失败: %w", err)
 	}
 
+	// 解析原配置中存在的配置键，用于区分未填写与填写为 false 的布尔项
+	var originalMap map[string]interface{}
+	if err := yaml.Unmarshal(originalData, &originalMap); err != nil {
+		return nil, fmt.Errorf("解析原配置失败: %w", err)
+	}
+	keys := make(configKeys)
+	keys.collect("", originalMap)
+
 	// 获取默认配置
 	defaultConfig := DefaultConfig()
 
 	// 合并配置：用原配置的非零值覆盖默认配置
-	mergedConfig := mergeConfigStructs(defaultConfig, &originalConfig)
+	mergedConfig := mergeConfigStructs(defaultConfig, &originalConfig, keys)
 
 	// 使用 DumpConfig 方法导出配置
 	mergedData := DumpConfig(mergedConfig)
@@ -795,8 +1016,34 @@ func mergeConfigWithTemplate(originalData []byte) ([]byte, error) {
 	return []byte(mergedData), nil
 }
 
+// configKeys 配置文件中存在的配置键，以 . 连接各级键名
+type configKeys map[string]bool
+
+// collect 递归收集配置键
+func (keys configKeys) collect(prefix string, values map[string]interface{}) {
+	for key, value := range values {
+		fullKey := key
+		if prefix != "" {
+			fullKey = prefix + "." + key
+		}
+		keys[fullKey] = true
+		if nested, ok := value.(map[string]interface{}); ok {
+			keys.collect(fullKey, nested)
+		}
+	}
+}
+
+// mergeBool 仅在原配置中存在对应配置键时覆盖布尔项，缺失时保留模板的默认值
+func (keys configKeys) mergeBool(key string, result *bool, original bool) {
+	if keys[key] {
+		*result = original
+	}
+}
+
 // mergeConfigStructs 合并配置结构体
-func mergeConfigStructs(template, original *Config) *Config {
+//
+// 默认值为 true 的布尔项需要根据 keys 判断原配置中是否填写
+func mergeConfigStructs(template, original *Config, keys configKeys) *Config {
 	result := *template // 复制模板配置
 
 	// 合并基本字段（只有非零值才覆盖）
@@ -848,12 +1095,72 @@ func mergeConfigStructs(template, original *Config) *Config {
 	if original.FileServer.TTL != 0 {
 		result.FileServer.TTL = original.FileServer.TTL
 	}
+	if original.FileServer.SweepInterval != 0 {
+		result.FileServer.SweepInterval = original.FileServer.SweepInterval
+	}
+	if original.FileServer.URLTTL != 0 {
+		result.FileServer.URLTTL = original.FileServer.URLTTL
+	}
+	if original.FileServer.SignKey != "" {
+		result.FileServer.SignKey = original.FileServer.SignKey
+	}
+	if original.FileServer.MaxFileSize != 0 {
+		result.FileServer.MaxFileSize = original.FileServer.MaxFileSize
+	}
+	if original.FileServer.MaxTotalSize != 0 {
+		result.FileServer.MaxTotalSize = original.FileServer.MaxTotalSize
+	}
+	if original.FileServer.Storage.Type != "" {
+		result.FileServer.Storage.Type = original.FileServer.Storage.Type
+	}
+	if original.FileServer.Storage.S3.Endpoint != "" {
+		result.FileServer.Storage.S3.Endpoint = original.FileServer.Storage.S3.Endpoint
+	}
+	if original.FileServer.Storage.S3.PublicEndpoint != "" {
+		result.FileServer.Storage.S3.PublicEndpoint = original.FileServer.Storage.S3.PublicEndpoint
+	}
+	if original.FileServer.Storage.S3.Region != "" {
+		result.FileServer.Storage.S3.Region = original.FileServer.Storage.S3.Region
+	}
+	if original.FileServer.Storage.S3.Bucket != "" {
+		result.FileServer.Storage.S3.Bucket = original.FileServer.Storage.S3.Bucket
+	}
+	if original.FileServer.Storage.S3.AccessKey != "" {
+		result.FileServer.Storage.S3.AccessKey = original.FileServer.Storage.S3.AccessKey
+	}
+	if original.FileServer.Storage.S3.SecretKey != "" {
+		result.FileServer.Storage.S3.SecretKey = original.FileServer.Storage.S3.SecretKey
+	}
+	keys.mergeBool("file_server.storage.s3.path_style", &result.FileServer.Storage.S3.PathStyle, original.FileServer.Storage.S3.PathStyle)
+	keys.mergeBool("file_server.storage.s3.presign", &result.FileServer.Storage.S3.Presign, original.FileServer.Storage.S3.Presign)
 
 	// 合并 Database 配置
 	result.Database.MessageDatabase.Enable = original.Database.MessageDatabase.Enable
+	if original.Database.MessageDatabase.Type != "" {
+		result.Database.MessageDatabase.Type = original.Database.MessageDatabase.Type
+	}
 	if original.Database.MessageDatabase.Limit != 0 {
 		result.Database.MessageDatabase.Limit = original.Database.MessageDatabase.Limit
 	}
+	if original.Database.MessageDatabase.Retention.MaxAge != 0 {
+		result.Database.MessageDatabase.Retention.MaxAge = original.Database.MessageDatabase.Retention.MaxAge
+	}
+	if original.Database.MessageDatabase.Retention.MaxPerChannel != 0 {
+		result.Database.MessageDatabase.Retention.MaxPerChannel = original.Database.MessageDatabase.Retention.MaxPerChannel
+	}
+	if original.Database.MessageDatabase.Retention.MaxSize != 0 {
+		result.Database.MessageDatabase.Retention.MaxSize = original.Database.MessageDatabase.Retention.MaxSize
+	}
+	if original.Database.MessageDatabase.Retention.Interval != 0 {
+		result.Database.MessageDatabase.Retention.Interval = original.Database.MessageDatabase.Retention.Interval
+	}
+	if len(original.Database.MessageDatabase.ExcludedChannels) > 0 {
+		result.Database.MessageDatabase.ExcludedChannels = original.Database.MessageDatabase.ExcludedChannels
+	}
+	keys.mergeBool("database.entity_cache.enable", &result.Database.EntityCache.Enable, original.Database.EntityCache.Enable)
+	if original.Database.EntityCache.MaxAge != 0 {
+		result.Database.EntityCache.MaxAge = original.Database.EntityCache.MaxAge
+	}
 
 	// 合并 Satori 配置
 	if original.Satori.Version != 0 {
@@ -874,6 +1181,62 @@ func mergeConfigStructs(template, original *Config) *Config {
 	if original.Satori.WebHook.Timeout != 0 {
 		result.Satori.WebHook.Timeout = original.Satori.WebHook.Timeout
 	}
+	if len(original.Satori.Proxy.Urls) > 0 {
+		result.Satori.Proxy.Urls = original.Satori.Proxy.Urls
+	}
+	if original.Satori.Proxy.CacheSize != 0 {
+		result.Satori.Proxy.CacheSize = original.Satori.Proxy.CacheSize
+	}
+	keys.mergeBool("satori.local_file.enable", &result.Satori.LocalFile.Enable, original.Satori.LocalFile.Enable)
+	if len(original.Satori.LocalFile.AllowedDirs) > 0 {
+		result.Satori.LocalFile.AllowedDirs = original.Satori.LocalFile.AllowedDirs
+	}
+	if original.Satori.LocalFile.MaxSize != 0 {
+		result.Satori.LocalFile.MaxSize = original.Satori.LocalFile.MaxSize
+	}
+	result.Satori.RemoteMedia.Enable = original.Satori.RemoteMedia.Enable
+	if original.Satori.RemoteMedia.MaxSize != 0 {
+		result.Satori.RemoteMedia.MaxSize = original.Satori.RemoteMedia.MaxSize
+	}
+	if original.Satori.RemoteMedia.Timeout != 0 {
+		result.Satori.RemoteMedia.Timeout = original.Satori.RemoteMedia.Timeout
+	}
+	if original.Satori.RemoteMedia.MaxRedirects != 0 {
+		result.Satori.RemoteMedia.MaxRedirects = original.Satori.RemoteMedia.MaxRedirects
+	}
+	result.Satori.InboundVoice.Enable = original.Satori.InboundVoice.Enable
+	if original.Satori.InboundVoice.Format != "" {
+		result.Satori.InboundVoice.Format = original.Satori.InboundVoice.Format
+	}
+	if original.Satori.Transcode.Workers != 0 {
+		result.Satori.Transcode.Workers = original.Satori.Transcode.Workers
+	}
+	if original.Satori.Transcode.Timeout != 0 {
+		result.Satori.Transcode.Timeout = original.Satori.Transcode.Timeout
+	}
+	if original.Satori.Transcode.CacheSize != 0 {
+		result.Satori.Transcode.CacheSize = original.Satori.Transcode.CacheSize
+	}
+	if original.Satori.Transcode.CacheTTL != 0 {
+		result.Satori.Transcode.CacheTTL = original.Satori.Transcode.CacheTTL
+	}
+	result.Satori.Transcode.Audio.InProcessEncoder = original.Satori.Transcode.Audio.InProcessEncoder // bool 类型直接覆盖
+	if original.Satori.Transcode.Image.MaxSize != 0 {
+		result.Satori.Transcode.Image.MaxSize = original.Satori.Transcode.Image.MaxSize
+	}
+	if original.Satori.Transcode.Image.MaxDimension != 0 {
+		result.Satori.Transcode.Image.MaxDimension = original.Satori.Transcode.Image.MaxDimension
+	}
+	if original.Satori.Transcode.Image.Quality != 0 {
+		result.Satori.Transcode.Image.Quality = original.Satori.Transcode.Image.Quality
+	}
+	if original.Satori.Transcode.Video.MaxSize != 0 {
+		result.Satori.Transcode.Video.MaxSize = original.Satori.Transcode.Video.MaxSize
+	}
+	if original.Satori.Transcode.Video.MaxBitrate != 0 {
+		result.Satori.Transcode.Video.MaxBitrate = original.Satori.Transcode.Video.MaxBitrate
+	}
+	result.Satori.Transcode.Video.Thumbnail = original.Satori.Transcode.Video.Thumbnail // bool 类型直接覆盖
 
 	return &result
 }
@@ -1121,3 +1484,19 @@ func dumpIntents(intents []string) string {
 		sharp(set["PUBLIC_GUILD_MESSAGES"]),
 	)
 }
+
+// dumpStringList 将字符串列表转换为 YAML 列表
+func dumpStringList(list []string, indent int) string {
+	if len(list) == 0 {
+		return " []"
+	}
+
+	var builder strings.Builder
+	for _, item := range list {
+		builder.WriteString("\n")
+		builder.WriteString(strings.Repeat(" ", indent))
+		builder.WriteString("- ")
+		builder.WriteString(strconv.Quote(item))
+	}
+	return builder.String()
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDumpConfigRoundTrip(t *testing.T) {
+	conf := DefaultConfig()
+	conf.Satori.LocalFile.AllowedDirs = []string{"/data/a", `C:\data\b`}
+	conf.Database.MessageDatabase.ExcludedChannels = []string{"123"}
+
+	var parsed Config
+	if err := yaml.Unmarshal([]byte(DumpConfig(conf)), &parsed); err != nil {
+		t.Fatalf("yaml.Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(parsed.Satori.Proxy.Urls, conf.Satori.Proxy.Urls) {
+		t.Errorf("proxy urls = %v, want %v", parsed.Satori.Proxy.Urls, conf.Satori.Proxy.Urls)
+	}
+	if !reflect.DeepEqual(parsed.Satori.LocalFile.AllowedDirs, conf.Satori.LocalFile.AllowedDirs) {
+		t.Errorf("allowed dirs = %v, want %v", parsed.Satori.LocalFile.AllowedDirs, conf.Satori.LocalFile.AllowedDirs)
+	}
+	if !reflect.DeepEqual(parsed.Database.MessageDatabase.ExcludedChannels, conf.Database.MessageDatabase.ExcludedChannels) {
+		t.Errorf("excluded channels = %v, want %v", parsed.Database.MessageDatabase.ExcludedChannels, conf.Database.MessageDatabase.ExcludedChannels)
+	}
+	if parsed.Satori.Transcode != conf.Satori.Transcode {
+		t.Errorf("transcode = %+v, want %+v", parsed.Satori.Transcode, conf.Satori.Transcode)
+	}
+}
@@ -50,7 +50,26 @@ account: # QQ 机器人配置
 file_server:
   enable: %t # 是否使用本地文件服务器
   external_url: "%s" # 本地文件服务器公网地址 {{ .Host }}:{{ .Port }}
-  ttl: %d # 文件存储时间，单位秒
+  ttl: %d # 文件存储时间，单位秒，修改后只对之后保存的文件生效
+  sweep_interval: %d # 过期文件与文件信息的清理间隔，单位秒
+  url_ttl: %d # 交由 QQ 获取文件的链接签名有效期，单位秒，不会超过文件本身的有效期；返回给 Satori 应用的链接不会过期
+  sign_key: "%s" # 文件链接签名密钥，为空时自动生成并保存在 data/files/.sign_key
+  max_file_size: %d # 单个文件大小上限，单位 MB ，设置为 0 则不限制
+  max_total_size: %d # 文件存储空间上限，单位 MB ，设置为 0 则不限制
+
+  # 文件存储配置
+  # 使用 S3 兼容存储时，多个实例可以共享文件，并可以直接向 QQ 提供预签名链接
+  storage:
+    type: "%s" # 存储类型，可选 local(本地磁盘 data/files) 、s3(S3 兼容对象存储)
+    s3:
+      endpoint: "%s" # 服务地址，如 https://s3.amazonaws.com 或 http://127.0.0.1:9000
+      public_endpoint: "%s" # 生成直链时使用的公网地址，为空则使用 endpoint
+      region: "%s" # 区域
+      bucket: "%s" # 存储桶
+      access_key: "%s" # 访问密钥 ID
+      secret_key: "%s" # 访问密钥
+      path_style: %t # 是否使用路径形式访问存储桶，MinIO 等自建服务通常需要开启
+      presign: %t # 是否直接向 QQ 提供预签名直链，关闭时由本地文件服务器转发
 
 # 数据库配置
 # 关联到部分单聊/群聊 API 的使用以及程序的空间占用
@@ -63,8 +82,30 @@ database:
     # 是否启用消息数据库
     # 如果不启用消息数据库，将无法通过消息 ID 获取单聊/群聊消息
     enable: %t
+
+    # 更换存储类型后可以使用 db export 与 db import 命令迁移已存储的消息
+    type: "%s" # 存储类型，可选 leveldb(data/db/messages) 、sqlite(data/db/messages.db ，需要以 CGO 编译)
     limit: %d # 消息获取数量限制，决定每次使用 API 可以获取多少消息，设置为 0 则无上限
 
+    # 消息保留策略
+    # 超出任意一项限制的消息都会在定期清理时被删除，设置为 0 则不限制该项
+    retention:
+      max_age: %d # 消息最长保留天数，设置为 0 则不限制
+      max_per_channel: %d # 每个频道最多保留的消息数，设置为 0 则不限制
+      max_size: %d # 消息数据库大小上限，单位 MB ，超出时从最早的消息开始删除，设置为 0 则不限制
+      interval: %d # 清理间隔，单位分钟
+
+    # 不存储消息的频道 ID
+    # 适用于对隐私较为敏感的群聊或频道，已存储的消息会在下次清理时被删除
+    excluded_channels:%s
+
+  # 实体缓存配置
+  # 缓存频道平台的群组、频道、成员与角色信息，用于减少 API 调用并补全事件中缺少的名称等信息
+  # 缓存会在收到对应的更新与删除事件时立即失效
+  entity_cache:
+    enable: %t # 是否启用实体缓存
+    max_age: %d # 缓存最长有效时间，单位秒，设置为 0 则只在收到事件时失效
+
 satori: # Satori 配置
   version: %d # Satori 版本，目前只有 1
   path: "%s" # Satori 部署路径，可以为空，如果不为空需要以 / 开头
@@ -77,4 +118,61 @@ satori: # Satori 配置
 
   # WebHook 配置
   webhook:
-    timeout: %d # WebHook 事件推送超时时间，单位为秒，设置为 0 则时间为无限`
+    timeout: %d # WebHook 事件推送超时时间，单位为秒，设置为 0 则时间为无限
+
+  # 代理路由配置
+  # 列出的 URL 前缀会通过 proxy_urls 告知 Satori 应用，并可通过 /v1/proxy/ 路由访问
+  proxy:
+    urls:%s
+    cache_size: %d # 代理缓存大小上限，单位 MB ，设置为 0 则不进行缓存
+
+  # 本地文件访问配置
+  # 控制 Satori 应用能否通过 file:// 链接让 GlycCat 读取本机文件
+  # 只有位于 allowed_dirs 中的文件才能被读取，列表为空时将拒绝所有本地文件
+  local_file:
+    enable: %t # 是否允许读取本地文件
+    allowed_dirs:%s
+    max_size: %d # 单个文件大小上限，单位 MB
+
+  # 远程媒体资源配置
+  # 启用后，GlycCat 会先下载 http(s) 链接指向的图片、音频与视频
+  # 若其格式无法被 QQ 接受，则转码后再上传；指向内网地址的链接不会被下载
+  remote_media:
+    enable: %t # 是否下载并转码远程媒体资源
+    max_size: %d # 单个资源大小上限，单位 MB
+    timeout: %d # 下载超时时间，单位秒
+    max_redirects: %d # 最大重定向次数
+
+  # 接收语音转码配置
+  # QQ 发来的语音为 SILK/AMR 格式，多数 Satori 应用无法直接播放
+  # 启用后将下载语音并转码，保存至本地文件服务器后以 internal: 链接提供，需要启用本地文件服务器
+  inbound_voice:
+    enable: %t # 是否转码接收到的语音，需要文件服务器，语音仅从代理路由中列出的 QQ 链接下载，无需启用远程媒体资源下载
+    format: "%s" # 转码格式，可选 wav 、 mp3 与 ogg ，其中 mp3 与 ogg 需要 ffmpeg
+
+  # 媒体转码配置
+  # 发送的音频、视频与图片在格式不受 QQ 支持时会被转码，音频与视频转码需要 ffmpeg
+  transcode:
+    workers: %d # 同时进行的转码任务数，超出的任务将排队等待
+    timeout: %d # 单个转码任务超时时间，单位秒，设置为 0 则不限时
+    cache_size: %d # 转码结果缓存大小上限，单位 MB ，设置为 0 则不进行缓存
+    cache_ttl: %d # 转码结果缓存有效期，单位秒，设置为 0 则不会过期
+
+    # 音频转码配置
+    # 默认使用内置的外部 SILK 编码器，当前平台未内置或其执行失败时使用进程内编码器
+    audio:
+      in_process_encoder: %t # 是否优先使用进程内 SILK 编码器，速度更快、无需释放外部程序，但只生成清音帧，语音音质不及外部编码器
+
+    # 图片转码配置
+    # 超出限制的图片会被缩放与压缩，WebP 动图会被转换为 GIF ，HEIC/AVIF 需要 ffmpeg
+    image:
+      max_size: %d # 图片大小上限，单位 KB ，设置为 0 则不限制
+      max_dimension: %d # 图片边长上限，单位像素，设置为 0 则不限制
+      quality: %d # JPEG 编码质量，范围 1-100
+
+    # 视频转码配置
+    # 非 H.264/AAC 编码或超出限制的视频会被重新编码，moov 位于末尾的视频会被重新封装
+    video:
+      max_size: %d # 视频大小上限，单位 MB ，设置为 0 则不限制
+      max_bitrate: %d # 视频码率上限，单位 kbps ，设置为 0 则不限制
+      thumbnail: %t # 是否截取接收到的视频首帧作为封面并保存至文件服务器，需要 ffmpeg 与文件服务器，视频仅从代理路由中列出的 QQ 链接下载，无需启用远程媒体资源下载`
@@ -0,0 +1,357 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/WindowsSov8forUs/glyccat/log"
+	"github.com/satori-protocol-go/satori-model-go/pkg/message"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const messageDBPath string = "data/db/messages"
+
+// 消息数据库的键前缀
+//
+// 主索引键的格式为 msg:类型:频道:8 字节大端序毫秒时间戳 + 消息 ID ，按时间顺序排列；
+// 二级索引键的格式为 id:类型:频道:消息 ID ，值为对应的主索引键；
+// 墓碑键的格式为 del:类型:频道:消息 ID ，值为 8 字节大端序毫秒删除时间
+var (
+	messagePrefix   = []byte("msg:")
+	idIndexPrefix   = []byte("id:")
+	tombstonePrefix = []byte("del:")
+)
+
+// levelDBStore 基于 LevelDB 的消息存储
+type levelDBStore struct {
+	storeOptions
+	db   *leveldb.DB
+	path string
+	mu   sync.Mutex
+}
+
+// openLevelDBStore 创建或打开消息缓存数据库并迁移旧版本的数据
+func openLevelDBStore(path string, options storeOptions) (*levelDBStore, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrateMessageDB(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &levelDBStore{storeOptions: options, db: db, path: path}, nil
+}
+
+// Name 存储后端名称
+func (s *levelDBStore) Name() string {
+	return "leveldb"
+}
+
+// Close 关闭数据库
+func (s *levelDBStore) Close() error {
+	return s.db.Close()
+}
+
+// channelPrefix 生成频道的主索引键前缀
+func channelPrefix(channelId, channelType string) []byte {
+	return fmt.Appendf(append([]byte(nil), messagePrefix...), "%s:%s:", channelType, channelId)
+}
+
+// messageKey 生成消息的主索引键
+func messageKey(channelId, channelType string, createAt int64, messageId string) []byte {
+	key := channelPrefix(channelId, channelType)
+	key = binary.BigEndian.AppendUint64(key, uint64(createAt))
+	return append(key, messageId...)
+}
+
+// idIndexKey 生成消息的二级索引键
+func idIndexKey(channelId, channelType, messageId string) []byte {
+	return fmt.Appendf(append([]byte(nil), idIndexPrefix...), "%s:%s:%s", channelType, channelId, messageId)
+}
+
+// tombstoneKey 生成消息的墓碑键
+func tombstoneKey(channelId, channelType, messageId string) []byte {
+	return fmt.Appendf(append([]byte(nil), tombstonePrefix...), "%s:%s:%s", channelType, channelId, messageId)
+}
+
+// Save 保存消息
+func (s *levelDBStore) Save(data *message.Message, channelId, channelType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 不存储已排除的频道的消息
+	if s.isExcluded(channelId) {
+		return nil
+	}
+
+	// 删除事件可能先于消息到达
+	if s.deleted(channelId, channelType, data.Id) {
+		return ErrMessageDeleted
+	}
+
+	// 没有发送时间的消息以保存时间为准
+	if data.CreateAt == 0 {
+		data.CreateAt = time.Now().UnixMilli()
+	}
+
+	batch := new(leveldb.Batch)
+	if err := s.put(batch, data, channelId, channelType); err != nil {
+		return err
+	}
+	return s.db.Write(batch, nil)
+}
+
+// put 将消息的主索引、二级索引与全文索引加入写入批次
+func (s *levelDBStore) put(batch *leveldb.Batch, data *message.Message, channelId, channelType string) error {
+	value, err := encodeMessage(data)
+	if err != nil {
+		return err
+	}
+
+	key := messageKey(channelId, channelType, data.CreateAt, data.Id)
+	indexKey := idIndexKey(channelId, channelType, data.Id)
+
+	// 消息已存在时需要删除旧的全文索引，时间不同时还需要删除旧的主索引
+	if oldKey, err := s.db.Get(indexKey, nil); err == nil {
+		if oldValue, err := s.db.Get(oldKey, nil); err == nil {
+			unindexMessage(batch, oldKey, oldValue)
+		}
+		if !bytes.Equal(oldKey, key) {
+			batch.Delete(oldKey)
+		}
+	}
+	batch.Put(key, value)
+	batch.Put(indexKey, key)
+	indexMessage(batch, key, value)
+	return nil
+}
+
+// Delete 将消息标记为已删除
+func (s *levelDBStore) Delete(channelId, channelType, messageId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	batch := new(leveldb.Batch)
+	batch.Put(tombstoneKey(channelId, channelType, messageId), binary.BigEndian.AppendUint64(nil, uint64(now)))
+
+	key, err := s.lookup(channelId, channelType, messageId)
+	if err == nil {
+		if data, err := s.db.Get(key, nil); err == nil {
+			unindexMessage(batch, key, data)
+			if message, err := decodeMessage(data); err == nil {
+				message.Content = ""
+				message.UpdateAt = now
+				if value, err := encodeMessage(message); err == nil {
+					batch.Put(key, value)
+				}
+			}
+		}
+	} else if err != ErrMessageNotFound {
+		return err
+	}
+
+	return s.db.Write(batch, nil)
+}
+
+// deleted 判断消息是否已被删除
+func (s *levelDBStore) deleted(channelId, channelType, messageId string) bool {
+	ok, err := s.db.Has(tombstoneKey(channelId, channelType, messageId), nil)
+	return err == nil && ok
+}
+
+// Get 获取消息
+func (s *levelDBStore) Get(channelId, channelType, messageId string) (*message.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.deleted(channelId, channelType, messageId) {
+		return nil, ErrMessageDeleted
+	}
+
+	key, err := s.lookup(channelId, channelType, messageId)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeMessage(data)
+}
+
+// lookup 通过二级索引获取消息的主索引键
+func (s *levelDBStore) lookup(channelId, channelType, messageId string) ([]byte, error) {
+	key, err := s.db.Get(idIndexKey(channelId, channelType, messageId), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ErrMessageNotFound
+	}
+	return key, err
+}
+
+// List 获取消息列表
+func (s *levelDBStore) List(channelId, channelType, next string, direction QueryDirection, limit int) (*MessagePage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit = s.capLimit(limit)
+
+	iter := s.db.NewIterator(util.BytesPrefix(channelPrefix(channelId, channelType)), nil)
+	defer iter.Release()
+
+	var cursor []byte
+	if next != "" {
+		key, err := s.lookup(channelId, channelType, next)
+		if err != nil {
+			return nil, err
+		}
+		cursor = key
+	} else {
+		direction = QueryDirectionBefore
+	}
+
+	var before, after []*message.Message
+	var hasBefore, hasAfter bool
+	var err error
+	switch direction {
+	case QueryDirectionBefore:
+		before, hasBefore, err = s.collect(iter, channelId, channelType, cursor, limit, false)
+		hasAfter = cursor != nil
+	case QueryDirectionAfter:
+		after, hasAfter, err = s.collect(iter, channelId, channelType, cursor, limit, true)
+		hasBefore = true
+	case QueryDirectionAround:
+		// 基准消息本身占一个位置，剩余数量尽量平均分配到两侧
+		rest := max(limit-1, 0)
+		before, hasBefore, err = s.collect(iter, channelId, channelType, cursor, rest/2, false)
+		if err == nil {
+			after, hasAfter, err = s.collect(iter, channelId, channelType, cursor, rest-rest/2, true)
+		}
+		if err == nil && iter.Seek(cursor) && bytes.Equal(iter.Key(), cursor) && limit > 0 {
+			if current, err := decodeMessage(iter.Value()); err == nil && !s.deleted(channelId, channelType, current.Id) {
+				after = append([]*message.Message{current}, after...)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown query direction: %s", direction)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newMessagePage(before, after, hasBefore, hasAfter, next), nil
+}
+
+// collect 从基准位置开始向一个方向获取消息，不包含基准消息本身
+//
+// cursor 为空时从最新的消息开始向前获取，返回值 more 表示该方向是否还有更多消息
+func (s *levelDBStore) collect(iter iterator.Iterator, channelId, channelType string, cursor []byte, limit int, forward bool) (messages []*message.Message, more bool, err error) {
+	var ok bool
+	switch {
+	case cursor == nil:
+		ok = iter.Last()
+	case forward:
+		ok = iter.Seek(cursor)
+		if ok && bytes.Equal(iter.Key(), cursor) {
+			ok = iter.Next()
+		}
+	default:
+		// Seek 定位到第一个不小于基准的键，其前一个键即为更早的消息
+		if iter.Seek(cursor) {
+			ok = iter.Prev()
+		} else {
+			ok = iter.Last()
+		}
+	}
+
+	for ; ok; ok = step(iter, forward) {
+		if len(messages) >= limit {
+			return messages, true, nil
+		}
+		message, err := decodeMessage(iter.Value())
+		if err != nil {
+			log.Warnf("解码消息记录 %q 失败: %v", iter.Key(), err)
+			continue
+		}
+		if s.deleted(channelId, channelType, message.Id) {
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages, false, iter.Error()
+}
+
+// step 将迭代器向指定方向移动一步
+func step(iter iterator.Iterator, forward bool) bool {
+	if forward {
+		return iter.Next()
+	}
+	return iter.Prev()
+}
+
+// Each 按频道分组、时间升序遍历全部消息
+func (s *levelDBStore) Each(fn func(record *ExportRecord) error) error {
+	// 同一频道的消息在主索引中是连续的，且按时间升序排列
+	snapshot, err := s.db.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+	iter := snapshot.NewIterator(util.BytesPrefix(messagePrefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		m, ok := parseMessageKey(iter.Key())
+		if !ok {
+			continue
+		}
+		data, err := decodeMessage(iter.Value())
+		if err != nil {
+			return fmt.Errorf("decode message %q: %w", iter.Key(), err)
+		}
+
+		record := &ExportRecord{
+			Version:     recordVersion,
+			ChannelType: m.channelType,
+			ChannelId:   m.channelId,
+			Message:     data,
+		}
+		if value, err := snapshot.Get(tombstoneKey(m.channelId, m.channelType, m.messageId), nil); err == nil && len(value) == 8 {
+			record.DeletedAt = int64(binary.BigEndian.Uint64(value))
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// Restore 恢复一条导出的消息记录
+func (s *levelDBStore) Restore(record *ExportRecord) error {
+	if s.isExcluded(record.ChannelId) {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch := new(leveldb.Batch)
+	if err := s.put(batch, record.Message, record.ChannelId, record.ChannelType); err != nil {
+		return err
+	}
+	tombstone := tombstoneKey(record.ChannelId, record.ChannelType, record.Message.Id)
+	if record.DeletedAt > 0 {
+		batch.Put(tombstone, binary.BigEndian.AppendUint64(nil, uint64(record.DeletedAt)))
+	} else {
+		batch.Delete(tombstone)
+	}
+	return s.db.Write(batch, nil)
+}
@@ -1,17 +1,30 @@
 package database
 
 import (
-	"bytes"
-	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"sync"
 
+	"github.com/satori-protocol-go/satori-model-go/pkg/channel"
+	"github.com/satori-protocol-go/satori-model-go/pkg/guild"
+	"github.com/satori-protocol-go/satori-model-go/pkg/guildmember"
 	"github.com/satori-protocol-go/satori-model-go/pkg/message"
-	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/satori-protocol-go/satori-model-go/pkg/user"
 )
 
-const messageDBPath string = "data/db/messages"
+// 消息数据库中的频道类型
+const (
+	ChannelTypeGroup   = "group"   // QQ 群聊
+	ChannelTypePrivate = "private" // QQ 单聊
+	ChannelTypeGuild   = "channel" // 频道子频道
+	ChannelTypeDirect  = "direct"  // 频道私信
+)
+
+var (
+	ErrMessageDBDisabled = errors.New("message database is not enabled")
+	ErrMessageNotFound   = errors.New("message not found")
+	ErrMessageDeleted    = errors.New("message has been deleted")
+)
 
 // QueryDirection 查询方向
 type QueryDirection string
@@ -25,178 +38,176 @@ const (
 	QueryDirectionAround QueryDirection = "around"
 )
 
-// MessageDB 消息数据库
-type MessageDB struct {
-	DB    *leveldb.DB
-	mu    sync.Mutex
-	limit int
+// MessagePage 消息分页查询结果
+type MessagePage struct {
+	Data []*message.Message // 按时间升序排列的消息列表
+	Prev string             // 更早的消息的分页令牌，没有更早的消息时为空
+	Next string             // 更晚的消息的分页令牌，没有更晚的消息时为空
 }
 
-var messageDBInstance *MessageDB
+// recordVersion 消息记录格式版本
+//
+// 版本 1 的记录字段名与 Satori 消息对象一致，由 GlycCat 自有的结构体定义，
+// 上游结构体的变化不会影响已存储的记录
+const recordVersion = 1
 
-// StartMessageDB 启动消息数据库
-func StartMessageDB(messageLimit int) error {
-	// 创建或打开消息缓存数据库
-	db, err := leveldb.OpenFile(messageDBPath, nil)
-	if err != nil {
-		return err
-	}
+// messageRecord 消息记录，以 JSON 格式存储
+type messageRecord struct {
+	Version int            `json:"v"`       // 记录格式版本
+	Message *messageFields `json:"message"` // 消息
+}
 
-	messageDBInstance = &MessageDB{
-		DB:    db,
-		limit: messageLimit,
-	}
+// messageFields 存储的消息字段
+type messageFields struct {
+	Id       string        `json:"id"`
+	Content  string        `json:"content"`
+	Channel  *channelField `json:"channel,omitempty"`
+	Guild    *guildField   `json:"guild,omitempty"`
+	Member   *memberField  `json:"member,omitempty"`
+	User     *userField    `json:"user,omitempty"`
+	CreateAt int64         `json:"create_at,omitempty"`
+	UpdateAt int64         `json:"update_at,omitempty"`
+}
+
+// channelField 存储的频道字段
+type channelField struct {
+	Id       string `json:"id"`
+	Type     uint8  `json:"type"`
+	Name     string `json:"name,omitempty"`
+	ParentId string `json:"parent_id,omitempty"`
+}
 
-	return nil
+// guildField 存储的群组字段
+type guildField struct {
+	Id     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
 }
 
-// SaveMessage 保存消息
-func SaveMessage(data *message.Message, channelId, channelType string) error {
-	messageDBInstance.mu.Lock()
-	defer messageDBInstance.mu.Unlock()
+// memberField 存储的群组成员字段
+type memberField struct {
+	User     *userField `json:"user,omitempty"`
+	Nick     string     `json:"nick,omitempty"`
+	Avatar   string     `json:"avatar,omitempty"`
+	JoinedAt int64      `json:"joined_at,omitempty"`
+}
 
-	// 保存消息
-	var buf bytes.Buffer
-	enc := gob.NewEncoder(&buf)
-	if err := enc.Encode(data); err != nil {
-		return err
-	}
+// userField 存储的用户字段
+type userField struct {
+	Id     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Nick   string `json:"nick,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+	IsBot  bool   `json:"is_bot,omitempty"`
+}
 
-	// 保存消息
-	key := fmt.Sprintf("%s:%s:%s", channelType, channelId, data.Id)
-	if err := messageDBInstance.DB.Put([]byte(key), buf.Bytes(), nil); err != nil {
-		return err
+// newUserField 转换用户对象
+func newUserField(u *user.User) *userField {
+	if u == nil {
+		return nil
 	}
-
-	return nil
+	return &userField{Id: u.Id, Name: u.Name, Nick: u.Nick, Avatar: u.Avatar, IsBot: u.IsBot}
 }
 
-// GetMessage 获取消息
-func GetMessage(channelId, channelType, messageId string) (*message.Message, error) {
-	messageDBInstance.mu.Lock()
-	defer messageDBInstance.mu.Unlock()
+// toUser 转换为用户对象
+func (f *userField) toUser() *user.User {
+	if f == nil {
+		return nil
+	}
+	return &user.User{Id: f.Id, Name: f.Name, Nick: f.Nick, Avatar: f.Avatar, IsBot: f.IsBot}
+}
 
-	// 获取消息
-	key := fmt.Sprintf("%s:%s:%s", channelType, channelId, messageId)
-	data, err := messageDBInstance.DB.Get([]byte(key), nil)
-	if err != nil {
-		return nil, err
+// newMessageFields 转换消息对象
+func newMessageFields(data *message.Message) *messageFields {
+	fields := &messageFields{
+		Id:       data.Id,
+		Content:  data.Content,
+		User:     newUserField(data.User),
+		CreateAt: data.CreateAt,
+		UpdateAt: data.UpdateAt,
+	}
+	if c := data.Channel; c != nil {
+		fields.Channel = &channelField{Id: c.Id, Type: uint8(c.Type), Name: c.Name, ParentId: c.ParentId}
 	}
+	if g := data.Guild; g != nil {
+		fields.Guild = &guildField{Id: g.Id, Name: g.Name, Avatar: g.Avatar}
+	}
+	if m := data.Member; m != nil {
+		fields.Member = &memberField{User: newUserField(m.User), Nick: m.Nick, Avatar: m.Avatar, JoinedAt: m.JoinedAt}
+	}
+	return fields
+}
 
-	// 解码消息
-	var message message.Message
-	dec := gob.NewDecoder(bytes.NewReader(data))
-	if err := dec.Decode(&message); err != nil {
-		return nil, err
+// toMessage 转换为消息对象
+func (f *messageFields) toMessage() *message.Message {
+	data := &message.Message{
+		Id:       f.Id,
+		Content:  f.Content,
+		User:     f.User.toUser(),
+		CreateAt: f.CreateAt,
+		UpdateAt: f.UpdateAt,
+	}
+	if c := f.Channel; c != nil {
+		data.Channel = &channel.Channel{Id: c.Id, Type: channel.ChannelType(c.Type), Name: c.Name, ParentId: c.ParentId}
 	}
+	if g := f.Guild; g != nil {
+		data.Guild = &guild.Guild{Id: g.Id, Name: g.Name, Avatar: g.Avatar}
+	}
+	if m := f.Member; m != nil {
+		data.Member = &guildmember.GuildMember{User: m.User.toUser(), Nick: m.Nick, Avatar: m.Avatar, JoinedAt: m.JoinedAt}
+	}
+	return data
+}
 
-	return &message, nil
+// encodeMessage 编码消息
+func encodeMessage(data *message.Message) ([]byte, error) {
+	return json.Marshal(messageRecord{
+		Version: recordVersion,
+		Message: newMessageFields(data),
+	})
 }
 
-// GetMessageList 获取消息列表
-func GetMessageList(channelId, channelType, next string, direction QueryDirection, limit int) ([]*message.Message, []*message.Message, error) {
-	messageDBInstance.mu.Lock()
-	defer messageDBInstance.mu.Unlock()
-
-	// 获取消息列表
-	prefix := []byte(fmt.Sprintf("%s:%s", channelType, channelId))
-	iter := messageDBInstance.DB.NewIterator(util.BytesPrefix(prefix), nil)
-	defer iter.Release()
-
-	var prevMessageList []*message.Message
-	var nextMessageList []*message.Message
-
-	// 初始化迭代器位置
-	if next != "" {
-		nextKey := fmt.Appendf(nil, "%s:%s:%s", channelType, channelId, next)
-		iter.Seek(nextKey)
-	} else {
-		iter.Last()
+// decodeMessage 解码消息
+func decodeMessage(data []byte) (*message.Message, error) {
+	var record messageRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
 	}
-
-	// 根据不同情况分别获取两个方向的消息列表
-	// 当 next 为空或 direction 包括 before 时，获取之前的消息列表
-	if next == "" || (direction == QueryDirectionBefore || direction == QueryDirectionAround) {
-		for iter.Prev() {
-			if len(prevMessageList) >= limit {
-				break
-			}
-			// 解码消息
-			var message message.Message
-			dec := gob.NewDecoder(bytes.NewReader(iter.Value()))
-			if err := dec.Decode(&message); err != nil {
-				continue
-			}
-			prevMessageList = append(prevMessageList, &message)
-		}
+	if record.Version != recordVersion {
+		return nil, fmt.Errorf("unsupported message record version: %d", record.Version)
 	}
+	if record.Message == nil {
+		return nil, errors.New("message record is empty")
+	}
+	return record.Message.toMessage(), nil
+}
 
-	// 重置迭代器
-	if next != "" {
-		nextKey := []byte(fmt.Sprintf("%s:%s:%s", channelType, channelId, next))
-		iter.Seek(nextKey)
-	} else {
-		// 若未指定 next ，则此时不应当继续获取了
-		return prevMessageList, nextMessageList, nil
+// newMessagePage 由基准两侧的消息组成分页结果
+//
+// before 为从基准开始向前获取的倒序消息，after 为向后获取的升序消息，
+// hasBefore 与 hasAfter 表示两侧是否还有更多消息，next 为查询时使用的分页令牌
+func newMessagePage(before, after []*message.Message, hasBefore, hasAfter bool, next string) *MessagePage {
+	// 向前获取的消息为倒序，需要翻转为升序
+	for i, j := 0, len(before)-1; i < j; i, j = i+1, j-1 {
+		before[i], before[j] = before[j], before[i]
 	}
 
-	// 当 next 不为空且 direction 包括 after 时，获取之后的消息列表
-	if direction == QueryDirectionAfter || direction == QueryDirectionAround {
-		for iter.Next() {
-			if len(nextMessageList) >= limit {
-				break
-			}
-			// 解码消息
-			var message message.Message
-			dec := gob.NewDecoder(bytes.NewReader(iter.Value()))
-			if err := dec.Decode(&message); err != nil {
-				continue
-			}
-			nextMessageList = append(nextMessageList, &message)
+	page := &MessagePage{Data: append(append(make([]*message.Message, 0, len(before)+len(after)), before...), after...)}
+	if len(page.Data) > 0 {
+		if hasBefore {
+			page.Prev = page.Data[0].Id
+		}
+		if hasAfter {
+			page.Next = page.Data[len(page.Data)-1].Id
+		}
+	} else if next != "" {
+		// 没有获取到消息时保留原有令牌，以便之后继续查询
+		if hasBefore {
+			page.Prev = next
+		}
+		if hasAfter {
+			page.Next = next
 		}
 	}
-
-	return prevMessageList, nextMessageList, nil
-
-	// if next != "" {
-	// 	// 查找 next 指定的消息
-	// 	nextKey := []byte(fmt.Sprintf("%s:%s:%s", channelType, channelId, next))
-	// 	nextValue, err := messageDBInstance.DB.Get(nextKey, nil)
-	// 	if err != nil {
-	// 		return nil, "", err
-	// 	}
-	// 	dec := gob.NewDecoder(bytes.NewReader(nextValue))
-	// 	if err := dec.Decode(&nextMessage); err != nil {
-	// 		return nil, "", err
-	// 	}
-	// }
-
-	// for iter.Next() {
-	// 	// 解码消息
-	// 	var message message.Message
-	// 	dec := gob.NewDecoder(bytes.NewReader(iter.Value()))
-	// 	if err := dec.Decode(&message); err != nil {
-	// 		continue
-	// 	}
-
-	// 	// 将 CreateAt 大于 next 的消息添加到 messageList
-	// 	if nextMessage == nil || message.CreateAt > nextMessage.CreateAt {
-	// 		messageList = append(messageList, &message)
-	// 	}
-	// }
-
-	// // 按 CreateAt 对 messageList 进行排序
-	// sort.Slice(messageList, func(i, j int) bool {
-	// 	return messageList[i].CreateAt < messageList[j].CreateAt
-	// })
-
-	// next = "" // 重置 next
-
-	// // 如果消息列表已满，则设置 next 为最后一条消息的 Id
-	// if messageDBInstance.limit > 0 && len(messageList) > messageDBInstance.limit {
-	// 	messageList = messageList[:messageDBInstance.limit]
-	// 	next = messageList[messageDBInstance.limit-1].Id
-	// }
-
-	// return messageList, next, nil
+	return page
 }
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/satori-protocol-go/satori-model-go/pkg/channel"
+	"github.com/satori-protocol-go/satori-model-go/pkg/guild"
+	"github.com/satori-protocol-go/satori-model-go/pkg/guildmember"
+	"github.com/satori-protocol-go/satori-model-go/pkg/message"
+	"github.com/satori-protocol-go/satori-model-go/pkg/user"
+)
+
+func TestMessageRecord(t *testing.T) {
+	messages := []*message.Message{
+		{Id: "1", Content: "hello"},
+		{
+			Id:       "2",
+			Content:  `<at id="3"/>你好`,
+			Channel:  &channel.Channel{Id: "c", Type: channel.ChannelTypeDirect, Name: "channel", ParentId: "p"},
+			Guild:    &guild.Guild{Id: "g", Name: "guild", Avatar: "https://example.com/g.png"},
+			Member:   &guildmember.GuildMember{User: &user.User{Id: "u"}, Nick: "nick", Avatar: "https://example.com/m.png", JoinedAt: 10},
+			User:     &user.User{Id: "u", Name: "name", Nick: "nick", Avatar: "https://example.com/u.png", IsBot: true},
+			CreateAt: 1000,
+			UpdateAt: 2000,
+		},
+	}
+	for _, want := range messages {
+		data, err := encodeMessage(want)
+		if err != nil {
+			t.Fatalf("encodeMessage() error = %v", err)
+		}
+		got, err := decodeMessage(data)
+		if err != nil {
+			t.Fatalf("decodeMessage() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("decodeMessage(encodeMessage(%+v)) = %+v", want, got)
+		}
+
+		// 之前直接序列化上游结构体的记录仍然可以读取
+		legacy, err := json.Marshal(map[string]any{"v": 1, "message": want})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err = decodeMessage(legacy)
+		if err != nil {
+			t.Fatalf("decodeMessage() legacy record error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("decodeMessage() legacy record = %+v, want %+v", got, want)
+		}
+	}
+
+	invalid := []string{`{"v":2,"message":{"id":"1"}}`, `{"v":1}`, `not json`}
+	for _, data := range invalid {
+		if _, err := decodeMessage([]byte(data)); err == nil {
+			t.Errorf("decodeMessage(%s) error = nil, want error", data)
+		}
+	}
+}
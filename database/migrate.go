@@ -0,0 +1,199 @@
+package database
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/WindowsSov8forUs/glyccat/log"
+	"github.com/satori-protocol-go/satori-model-go/pkg/message"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// 消息数据库的存储格式版本
+//
+//  1. 以 类型:频道:消息 ID 为键
+//  2. 按时间排序的主索引与消息 ID 二级索引
+//  3. 增加全文索引
+//  4. 消息记录由 gob 编码改为带版本号的 JSON 编码
+const messageDBVersion = 4
+
+var versionKey = []byte("_version")
+
+// migrationBatchSize 迁移时单次写入的最大记录数
+const migrationBatchSize = 1000
+
+// migrateMessageDB 将消息数据库迁移至当前版本
+func migrateMessageDB(db *leveldb.DB) error {
+	version := 1
+	data, err := db.Get(versionKey, nil)
+	if err == nil {
+		if version, err = strconv.Atoi(string(data)); err != nil {
+			return fmt.Errorf("无法识别的消息数据库版本: %q", data)
+		}
+	} else if err != leveldb.ErrNotFound {
+		return err
+	}
+	if version > messageDBVersion {
+		return fmt.Errorf("消息数据库版本 %d 高于当前支持的版本 %d", version, messageDBVersion)
+	}
+
+	if version < 2 {
+		if err := migrateLegacyKeys(db); err != nil {
+			return err
+		}
+	}
+	// 建立全文索引需要解码消息，因此先转换消息记录格式
+	if version < 4 {
+		if err := convertGobRecords(db); err != nil {
+			return err
+		}
+	}
+	if version < 3 {
+		if err := buildSearchIndex(db); err != nil {
+			return err
+		}
+	}
+	if version < messageDBVersion {
+		return db.Put(versionKey, []byte(strconv.Itoa(messageDBVersion)), nil)
+	}
+	return nil
+}
+
+// migrateLegacyKeys 将以 类型:频道:消息 ID 为键的消息迁移至按时间排序的索引
+func migrateLegacyKeys(db *leveldb.DB) error {
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	batch := new(leveldb.Batch)
+	var migrated, skipped int
+	for iter.Next() {
+		key := iter.Key()
+		if bytes.HasPrefix(key, messagePrefix) || bytes.HasPrefix(key, idIndexPrefix) ||
+			bytes.HasPrefix(key, tombstonePrefix) || bytes.HasPrefix(key, searchIndexPrefix) || bytes.HasPrefix(key, []byte("_")) {
+			continue
+		}
+
+		parts := strings.SplitN(string(key), ":", 3)
+		message, err := decodeGobMessage(iter.Value())
+		if len(parts) != 3 || err != nil {
+			log.Warnf("无法迁移消息记录 %q ，已丢弃。", key)
+			batch.Delete(append([]byte(nil), key...))
+			skipped++
+			continue
+		}
+		channelType, channelId, messageId := parts[0], parts[1], parts[2]
+
+		newKey := messageKey(channelId, channelType, message.CreateAt, messageId)
+		batch.Put(newKey, append([]byte(nil), iter.Value()...))
+		batch.Put(idIndexKey(channelId, channelType, messageId), newKey)
+		batch.Delete(append([]byte(nil), key...))
+		migrated++
+
+		if batch.Len() >= migrationBatchSize {
+			if err := db.Write(batch, nil); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	if err := db.Write(batch, nil); err != nil {
+		return err
+	}
+	if migrated > 0 || skipped > 0 {
+		log.Infof("消息数据库迁移完成，共迁移 %d 条消息，丢弃 %d 条无法解析的记录。", migrated, skipped)
+	}
+	return nil
+}
+
+// decodeGobMessage 解码旧版本以 gob 编码的消息
+func decodeGobMessage(data []byte) (*message.Message, error) {
+	var message message.Message
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// convertGobRecords 将以 gob 编码的消息记录转换为 JSON 编码
+func convertGobRecords(db *leveldb.DB) error {
+	iter := db.NewIterator(util.BytesPrefix(messagePrefix), nil)
+	defer iter.Release()
+
+	batch := new(leveldb.Batch)
+	var converted, skipped int
+	for iter.Next() {
+		key := append([]byte(nil), iter.Key()...)
+		message, err := decodeGobMessage(iter.Value())
+		if err != nil {
+			// 无法解码的记录无法恢复，同时删除其二级索引
+			log.Warnf("无法转换消息记录 %q ，已丢弃。", key)
+			if m, ok := parseMessageKey(key); ok {
+				batch.Delete(idIndexKey(m.channelId, m.channelType, m.messageId))
+			}
+			batch.Delete(key)
+			skipped++
+			continue
+		}
+		value, err := encodeMessage(message)
+		if err != nil {
+			return err
+		}
+		batch.Put(key, value)
+		converted++
+
+		if batch.Len() >= migrationBatchSize {
+			if err := db.Write(batch, nil); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	if err := db.Write(batch, nil); err != nil {
+		return err
+	}
+	if converted > 0 || skipped > 0 {
+		log.Infof("消息记录格式转换完成，共转换 %d 条消息，丢弃 %d 条无法解析的记录。", converted, skipped)
+	}
+	return nil
+}
+
+// buildSearchIndex 为已有的消息建立全文索引
+func buildSearchIndex(db *leveldb.DB) error {
+	iter := db.NewIterator(util.BytesPrefix(messagePrefix), nil)
+	defer iter.Release()
+
+	log.Info("正在为消息建立全文索引...")
+	batch := new(leveldb.Batch)
+	var indexed int
+	for iter.Next() {
+		indexMessage(batch, append([]byte(nil), iter.Key()...), iter.Value())
+		indexed++
+
+		if batch.Len() >= migrationBatchSize {
+			if err := db.Write(batch, nil); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	if err := db.Write(batch, nil); err != nil {
+		return err
+	}
+	log.Infof("全文索引建立完成，共 %d 条消息。", indexed)
+	return nil
+}
@@ -0,0 +1,292 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/WindowsSov8forUs/glyccat/config"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	defaultPruneInterval = time.Hour // 默认每小时清理一次
+	pruneBatchSize       = 1000      // 清理时单次写入的最大记录数
+)
+
+// RetentionPolicy 消息保留策略，各项为 0 时不限制
+type RetentionPolicy struct {
+	MaxAge        time.Duration // 消息最长保留时间
+	MaxPerChannel int           // 每个频道最多保留的消息数
+	MaxSize       int64         // 消息数据库大小上限，单位字节
+	Interval      time.Duration // 清理间隔
+}
+
+// PruneResult 清理结果
+type PruneResult struct {
+	Expired  int `json:"expired"`  // 因超出保留时间删除的消息数
+	Overflow int `json:"overflow"` // 因超出频道消息数上限删除的消息数
+	Oversize int `json:"oversize"` // 因超出数据库大小上限删除的消息数
+	Excluded int `json:"excluded"` // 因频道不存储消息删除的消息数
+}
+
+// Total 删除的消息总数
+func (r PruneResult) Total() int {
+	return r.Expired + r.Overflow + r.Oversize + r.Excluded
+}
+
+// newRetentionPolicy 根据配置创建消息保留策略
+func newRetentionPolicy(conf config.MessageRetention) RetentionPolicy {
+	policy := RetentionPolicy{
+		MaxAge:        time.Duration(conf.MaxAge) * 24 * time.Hour,
+		MaxPerChannel: conf.MaxPerChannel,
+		MaxSize:       int64(conf.MaxSize) * 1024 * 1024,
+		Interval:      time.Duration(conf.Interval) * time.Minute,
+	}
+	if policy.Interval <= 0 {
+		policy.Interval = defaultPruneInterval
+	}
+	return policy
+}
+
+// storedMessage 主索引中的一条消息记录
+type storedMessage struct {
+	channelType string
+	channelId   string
+	createAt    int64
+	messageId   string
+	size        int64 // 主索引键与值的大小
+}
+
+// parseMessageKey 解析主索引键
+func parseMessageKey(key []byte) (*storedMessage, bool) {
+	rest := key[len(messagePrefix):]
+	typeEnd := bytes.IndexByte(rest, ':')
+	if typeEnd < 0 {
+		return nil, false
+	}
+	channelEnd := bytes.IndexByte(rest[typeEnd+1:], ':')
+	if channelEnd < 0 {
+		return nil, false
+	}
+	channelEnd += typeEnd + 1
+	if len(rest) < channelEnd+1+8 {
+		return nil, false
+	}
+	return &storedMessage{
+		channelType: string(rest[:typeEnd]),
+		channelId:   string(rest[typeEnd+1 : channelEnd]),
+		createAt:    int64(binary.BigEndian.Uint64(rest[channelEnd+1:])),
+		messageId:   string(rest[channelEnd+1+8:]),
+	}, true
+}
+
+// deleteStored 将消息的全部记录加入删除批次
+func deleteStored(batch *leveldb.Batch, m *storedMessage, value []byte) {
+	key := messageKey(m.channelId, m.channelType, m.createAt, m.messageId)
+	unindexMessage(batch, key, value)
+	batch.Delete(key)
+	batch.Delete(idIndexKey(m.channelId, m.channelType, m.messageId))
+	batch.Delete(tombstoneKey(m.channelId, m.channelType, m.messageId))
+}
+
+// batchWriter 分批写入删除操作，避免长时间占用数据库锁
+type batchWriter struct {
+	s     *levelDBStore
+	batch *leveldb.Batch
+	err   error
+}
+
+// delete 删除消息，value 为空时从数据库读取
+func (w *batchWriter) delete(m *storedMessage, value []byte) {
+	if value == nil {
+		value, _ = w.s.db.Get(messageKey(m.channelId, m.channelType, m.createAt, m.messageId), nil)
+	}
+	deleteStored(w.batch, m, value)
+	if w.batch.Len() >= pruneBatchSize {
+		w.flush()
+	}
+}
+
+func (w *batchWriter) flush() {
+	if w.batch.Len() == 0 || w.err != nil {
+		return
+	}
+	w.s.mu.Lock()
+	w.err = w.s.db.Write(w.batch, nil)
+	w.s.mu.Unlock()
+	w.batch.Reset()
+}
+
+// Prune 按照保留策略清理消息并压缩数据库
+func (s *levelDBStore) Prune() (PruneResult, error) {
+	var result PruneResult
+	policy := s.retention
+	cutoff := int64(0)
+	if policy.MaxAge > 0 {
+		cutoff = time.Now().Add(-policy.MaxAge).UnixMilli()
+	}
+
+	// 从最新的消息开始倒序遍历，同一频道的消息在主索引中是连续的
+	w := &batchWriter{s: s, batch: new(leveldb.Batch)}
+	var kept []*storedMessage
+	var channel string
+	var count int
+
+	iter := s.db.NewIterator(util.BytesPrefix(messagePrefix), nil)
+	for ok := iter.Last(); ok; ok = iter.Prev() {
+		m, valid := parseMessageKey(iter.Key())
+		if !valid {
+			continue
+		}
+		m.size = int64(len(iter.Key()) + len(iter.Value()))
+
+		if current := m.channelType + ":" + m.channelId; current != channel {
+			channel = current
+			count = 0
+		}
+		count++
+
+		switch {
+		case s.isExcluded(m.channelId):
+			result.Excluded++
+		case cutoff > 0 && m.createAt < cutoff:
+			result.Expired++
+		case policy.MaxPerChannel > 0 && count > policy.MaxPerChannel:
+			result.Overflow++
+		default:
+			if policy.MaxSize > 0 {
+				kept = append(kept, m)
+			}
+			continue
+		}
+		w.delete(m, iter.Value())
+	}
+	err := iter.Error()
+	iter.Release()
+	if err != nil {
+		return result, err
+	}
+
+	// 清理没有对应消息的过期墓碑
+	if cutoff > 0 {
+		iter := s.db.NewIterator(util.BytesPrefix(tombstonePrefix), nil)
+		for iter.Next() {
+			if len(iter.Value()) == 8 && int64(binary.BigEndian.Uint64(iter.Value())) < cutoff {
+				w.batch.Delete(append([]byte(nil), iter.Key()...))
+			}
+		}
+		iter.Release()
+	}
+	w.flush()
+	if w.err != nil {
+		return result, w.err
+	}
+
+	// 超出大小上限时从最早的消息开始删除
+	if policy.MaxSize > 0 {
+		if excess := s.size() - policy.MaxSize; excess > 0 {
+			sort.Slice(kept, func(i, j int) bool {
+				return kept[i].createAt < kept[j].createAt
+			})
+			var freed int64
+			for _, m := range kept {
+				if freed >= excess {
+					break
+				}
+				w.delete(m, nil)
+				freed += m.size
+				result.Oversize++
+			}
+			w.flush()
+			if w.err != nil {
+				return result, w.err
+			}
+		}
+	}
+
+	if result.Total() > 0 {
+		if err := s.db.CompactRange(util.Range{}); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// size 获取数据库在磁盘上占用的空间
+func (s *levelDBStore) size() int64 {
+	var total int64
+	filepath.WalkDir(s.path, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
+
+// PurgeChannel 删除频道的全部消息，返回删除的消息数
+func (s *levelDBStore) PurgeChannel(channelId string) (int, error) {
+	return s.purge(func(m *storedMessage, value []byte) bool {
+		return m.channelId == channelId
+	}, channelId)
+}
+
+// PurgeUser 删除用户发送的全部消息，channelId 不为空时只删除该频道中的消息，返回删除的消息数
+func (s *levelDBStore) PurgeUser(userId, channelId string) (int, error) {
+	return s.purge(func(m *storedMessage, value []byte) bool {
+		if channelId != "" && m.channelId != channelId {
+			return false
+		}
+		message, err := decodeMessage(value)
+		return err == nil && message.User != nil && message.User.Id == userId
+	}, "")
+}
+
+// purge 删除满足条件的全部消息并压缩数据库，channelId 不为空时同时删除该频道的全部墓碑
+func (s *levelDBStore) purge(match func(m *storedMessage, value []byte) bool, channelId string) (int, error) {
+	w := &batchWriter{s: s, batch: new(leveldb.Batch)}
+	var count int
+	iter := s.db.NewIterator(util.BytesPrefix(messagePrefix), nil)
+	for iter.Next() {
+		m, valid := parseMessageKey(iter.Key())
+		if valid && match(m, iter.Value()) {
+			w.delete(m, iter.Value())
+			count++
+		}
+	}
+	err := iter.Error()
+	iter.Release()
+	if err != nil {
+		return count, err
+	}
+
+	if channelId != "" {
+		iter := s.db.NewIterator(util.BytesPrefix(tombstonePrefix), nil)
+		for iter.Next() {
+			parts := bytes.SplitN(iter.Key()[len(tombstonePrefix):], []byte(":"), 3)
+			if len(parts) == 3 && string(parts[1]) == channelId {
+				w.batch.Delete(append([]byte(nil), iter.Key()...))
+			}
+		}
+		iter.Release()
+	}
+	w.flush()
+	if w.err != nil {
+		return count, w.err
+	}
+
+	if count > 0 {
+		if err := s.db.CompactRange(util.Range{}); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,408 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const rosterDBPath string = "data/db/roster"
+
+// rosterTouchInterval 同一用户两次更新最后出现时间的最小间隔，单位毫秒，避免每条消息都写入数据库
+const rosterTouchInterval int64 = 60 * 1000
+
+// 用户名册数据库的键前缀
+//
+// 群成员键的格式为 member:群组:用户 ，同一群组的成员按用户 ID 排列；
+// 用户键的格式为 user:用户 ；好友键的格式为 friend:用户 ；频道私信用户键的格式为 direct:用户 ；
+// 开放 ID 类型键的格式为 openid:开放ID
+var (
+	groupMemberPrefix = []byte("member:")
+	userPrefix        = []byte("user:")
+	friendPrefix      = []byte("friend:")
+	directUserPrefix  = []byte("direct:")
+	openIdPrefix      = []byte("openid:")
+)
+
+var (
+	ErrRosterDBDisabled = errors.New("用户名册数据库未启动")
+	ErrMemberNotFound   = errors.New("member not found")
+	ErrUserNotFound     = errors.New("user not found")
+	ErrOpenIdNotFound   = errors.New("openid not found")
+)
+
+// RosterUser 从消息与事件中观察到的用户
+type RosterUser struct {
+	UserId    string `json:"user_id"`          // 用户 ID
+	Name      string `json:"name,omitempty"`   // 用户名称，QQ 平台不提供
+	Avatar    string `json:"avatar,omitempty"` // 头像链接
+	FirstSeen int64  `json:"first_seen"`       // 首次出现时间，毫秒时间戳
+	LastSeen  int64  `json:"last_seen"`        // 最后出现时间，毫秒时间戳
+}
+
+// RosterMember 从消息与事件中观察到的群成员
+//
+// QQ 群不提供成员列表接口，只能记录曾经出现过的成员
+type RosterMember struct {
+	GroupId string `json:"group_id"` // 群组 ID
+	RosterUser
+}
+
+// RosterFriend 好友记录，来自私聊消息与添加好友事件
+type RosterFriend struct {
+	UserId string `json:"user_id"` // 用户 ID
+	Since  int64  `json:"since"`   // 成为好友的时间，毫秒时间戳
+}
+
+// touch 更新用户的出现记录，返回记录是否发生了需要写入的变化
+func (u *RosterUser) touch(name, avatar string, seenAt int64) bool {
+	if u.LastSeen > 0 && seenAt-u.LastSeen < rosterTouchInterval &&
+		(name == "" || name == u.Name) && (avatar == "" || avatar == u.Avatar) {
+		return false
+	}
+	if name != "" {
+		u.Name = name
+	}
+	if avatar != "" {
+		u.Avatar = avatar
+	}
+	if u.FirstSeen == 0 || seenAt < u.FirstSeen {
+		u.FirstSeen = seenAt
+	}
+	u.LastSeen = max(u.LastSeen, seenAt)
+	return true
+}
+
+// rosterDatabase 用户名册数据库
+type rosterDatabase struct {
+	db *leveldb.DB
+	mu sync.Mutex
+}
+
+var roster *rosterDatabase
+
+// StartRosterDB 启动用户名册数据库
+func StartRosterDB() error {
+	db, err := leveldb.OpenFile(rosterDBPath, nil)
+	if err != nil {
+		return err
+	}
+	roster = &rosterDatabase{db: db}
+	return nil
+}
+
+// CloseRosterDB 关闭用户名册数据库
+func CloseRosterDB() error {
+	if roster == nil {
+		return nil
+	}
+	db := roster.db
+	roster = nil
+	return db.Close()
+}
+
+// rosterKey 生成用户名册中的键，各部分以 : 分隔
+func rosterKey(prefix []byte, parts ...string) []byte {
+	key := append([]byte(nil), prefix...)
+	for i, part := range parts {
+		if i > 0 {
+			key = append(key, ':')
+		}
+		key = append(key, part...)
+	}
+	return key
+}
+
+// get 读取记录，不存在时返回 notFound
+func (r *rosterDatabase) get(key []byte, value any, notFound error) error {
+	data, err := r.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return notFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+// put 写入记录
+func (r *rosterDatabase) put(key []byte, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return r.db.Put(key, data, nil)
+}
+
+// list 按键的顺序分页读取前缀下的记录
+//
+// 从分页令牌 next 对应的记录之后开始读取，返回的 next 为空时表示没有更多数据
+func list[T any](r *rosterDatabase, prefix []byte, next string, limit int, id func(*T) string) ([]*T, string, error) {
+	iter := r.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+
+	var ok bool
+	if next == "" {
+		ok = iter.First()
+	} else {
+		// 跳过分页令牌对应的记录本身
+		cursor := append(append([]byte(nil), prefix...), next...)
+		ok = iter.Seek(cursor)
+		if ok && string(iter.Key()) == string(cursor) {
+			ok = iter.Next()
+		}
+	}
+
+	var values []*T
+	for ; ok; ok = iter.Next() {
+		if len(values) >= limit {
+			return values, id(values[len(values)-1]), nil
+		}
+		value := new(T)
+		if err := json.Unmarshal(iter.Value(), value); err != nil {
+			return nil, "", fmt.Errorf("decode roster record %q: %w", iter.Key(), err)
+		}
+		values = append(values, value)
+	}
+	return values, "", iter.Error()
+}
+
+// ObserveUser 记录出现的用户，seenAt 为出现时间的毫秒时间戳
+func ObserveUser(userId, name, avatar string, seenAt int64) error {
+	if roster == nil {
+		return ErrRosterDBDisabled
+	}
+	if userId == "" {
+		return nil
+	}
+	roster.mu.Lock()
+	defer roster.mu.Unlock()
+
+	key := rosterKey(userPrefix, userId)
+	user := RosterUser{UserId: userId}
+	if err := roster.get(key, &user, ErrUserNotFound); err != nil && err != ErrUserNotFound {
+		return err
+	}
+	if !user.touch(name, avatar, seenAt) {
+		return nil
+	}
+	return roster.put(key, &user)
+}
+
+// GetUser 获取出现过的用户
+func GetUser(userId string) (*RosterUser, error) {
+	if roster == nil {
+		return nil, ErrRosterDBDisabled
+	}
+	roster.mu.Lock()
+	defer roster.mu.Unlock()
+
+	var user RosterUser
+	if err := roster.get(rosterKey(userPrefix, userId), &user, ErrUserNotFound); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ObserveGroupMember 记录在群组中出现的成员，seenAt 为出现时间的毫秒时间戳
+func ObserveGroupMember(groupId, userId, avatar string, seenAt int64) error {
+	if roster == nil {
+		return ErrRosterDBDisabled
+	}
+	if groupId == "" || userId == "" {
+		return nil
+	}
+	roster.mu.Lock()
+	defer roster.mu.Unlock()
+
+	key := rosterKey(groupMemberPrefix, groupId, userId)
+	member := RosterMember{GroupId: groupId, RosterUser: RosterUser{UserId: userId}}
+	if err := roster.get(key, &member, ErrMemberNotFound); err != nil && err != ErrMemberNotFound {
+		return err
+	}
+	if !member.touch("", avatar, seenAt) {
+		return nil
+	}
+	return roster.put(key, &member)
+}
+
+// GetGroupMember 获取群成员
+func GetGroupMember(groupId, userId string) (*RosterMember, error) {
+	if roster == nil {
+		return nil, ErrRosterDBDisabled
+	}
+	roster.mu.Lock()
+	defer roster.mu.Unlock()
+
+	var member RosterMember
+	if err := roster.get(rosterKey(groupMemberPrefix, groupId, userId), &member, ErrMemberNotFound); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// ListGroupMembers 按用户 ID 顺序分页获取群成员
+//
+// 从 next 之后开始获取，返回的 next 为空时表示没有更多数据
+func ListGroupMembers(groupId, next string, limit int) ([]*RosterMember, string, error) {
+	if roster == nil {
+		return nil, "", ErrRosterDBDisabled
+	}
+	roster.mu.Lock()
+	defer roster.mu.Unlock()
+
+	return list(roster, rosterKey(groupMemberPrefix, groupId, ""), next, limit, func(member *RosterMember) string {
+		return member.UserId
+	})
+}
+
+// DeleteGroupRoster 删除群组的全部成员记录
+func DeleteGroupRoster(groupId string) error {
+	if roster == nil {
+		return ErrRosterDBDisabled
+	}
+	roster.mu.Lock()
+	defer roster.mu.Unlock()
+
+	iter := roster.db.NewIterator(util.BytesPrefix(rosterKey(groupMemberPrefix, groupId, "")), nil)
+	defer iter.Release()
+	batch := new(leveldb.Batch)
+	for iter.Next() {
+		batch.Delete(append([]byte(nil), iter.Key()...))
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return roster.db.Write(batch, nil)
+}
+
+// AddFriend 记录好友，已经是好友时不会更新时间
+func AddFriend(userId string, since int64) error {
+	return addContact(friendPrefix, userId, since)
+}
+
+// RemoveFriend 删除好友记录
+func RemoveFriend(userId string) error {
+	if roster == nil {
+		return ErrRosterDBDisabled
+	}
+	roster.mu.Lock()
+	defer roster.mu.Unlock()
+
+	return roster.db.Delete(rosterKey(friendPrefix, userId), nil)
+}
+
+// ListFriends 按用户 ID 顺序分页获取好友，同时返回好友的用户记录
+//
+// 从 next 之后开始获取，返回的 next 为空时表示没有更多数据；没有用户记录的好友只包含用户 ID
+func ListFriends(next string, limit int) ([]*RosterUser, string, error) {
+	return listContacts(friendPrefix, next, limit)
+}
+
+// AddDirectUser 记录私信过机器人的频道用户，已有记录时不会更新时间
+func AddDirectUser(userId string, since int64) error {
+	return addContact(directUserPrefix, userId, since)
+}
+
+// ListDirectUsers 按用户 ID 顺序分页获取私信过机器人的频道用户，同时返回用户记录
+func ListDirectUsers(next string, limit int) ([]*RosterUser, string, error) {
+	return listContacts(directUserPrefix, next, limit)
+}
+
+// addContact 在前缀下记录联系人，已有记录时不会更新时间
+func addContact(prefix []byte, userId string, since int64) error {
+	if roster == nil {
+		return ErrRosterDBDisabled
+	}
+	if userId == "" {
+		return nil
+	}
+	roster.mu.Lock()
+	defer roster.mu.Unlock()
+
+	key := rosterKey(prefix, userId)
+	if ok, err := roster.db.Has(key, nil); err != nil || ok {
+		return err
+	}
+	return roster.put(key, &RosterFriend{UserId: userId, Since: since})
+}
+
+// listContacts 分页获取前缀下的联系人及其用户记录
+func listContacts(prefix []byte, next string, limit int) ([]*RosterUser, string, error) {
+	if roster == nil {
+		return nil, "", ErrRosterDBDisabled
+	}
+	roster.mu.Lock()
+	defer roster.mu.Unlock()
+
+	contacts, next, err := list(roster, prefix, next, limit, func(contact *RosterFriend) string {
+		return contact.UserId
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	users := make([]*RosterUser, 0, len(contacts))
+	for _, contact := range contacts {
+		user := RosterUser{UserId: contact.UserId}
+		if err := roster.get(rosterKey(userPrefix, contact.UserId), &user, ErrUserNotFound); err != nil && err != ErrUserNotFound {
+			return nil, "", err
+		}
+		users = append(users, &user)
+	}
+	return users, next, nil
+}
+
+// SetOpenIdType 记录开放 ID 的类型，类型为 group 或 private
+func SetOpenIdType(openId, openIdType string) error {
+	if roster == nil {
+		return ErrRosterDBDisabled
+	}
+	if openId == "" {
+		return nil
+	}
+	roster.mu.Lock()
+	defer roster.mu.Unlock()
+
+	return roster.put(rosterKey(openIdPrefix, openId), openIdType)
+}
+
+// GetOpenIdType 获取开放 ID 的类型
+//
+// 没有类型记录但有好友记录的开放 ID 视为单聊，以兼容记录类型之前添加的好友
+func GetOpenIdType(openId string) (string, error) {
+	if roster == nil {
+		return "", ErrRosterDBDisabled
+	}
+	roster.mu.Lock()
+	defer roster.mu.Unlock()
+
+	var openIdType string
+	err := roster.get(rosterKey(openIdPrefix, openId), &openIdType, ErrOpenIdNotFound)
+	if err != ErrOpenIdNotFound {
+		return openIdType, err
+	}
+	ok, err := roster.db.Has(rosterKey(friendPrefix, openId), nil)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrOpenIdNotFound
+	}
+	return "private", nil
+}
+
+// DeleteOpenIdType 删除开放 ID 的类型记录
+func DeleteOpenIdType(openId string) error {
+	if roster == nil {
+		return ErrRosterDBDisabled
+	}
+	roster.mu.Lock()
+	defer roster.mu.Unlock()
+
+	return roster.db.Delete(rosterKey(openIdPrefix, openId), nil)
+}
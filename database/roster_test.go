@@ -0,0 +1,340 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// openTestRoster 在临时目录中打开用户名册数据库
+func openTestRoster(t *testing.T) {
+	t.Helper()
+	db, err := leveldb.OpenFile(filepath.Join(t.TempDir(), "roster"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roster = &rosterDatabase{db: db}
+	t.Cleanup(func() {
+		roster = nil
+		db.Close()
+	})
+}
+
+// memberIds 获取群成员的用户 ID 列表
+func memberIds(members []*RosterMember) []string {
+	ids := make([]string, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.UserId)
+	}
+	return ids
+}
+
+func TestListGroupMembers(t *testing.T) {
+	openTestRoster(t)
+	for _, userId := range []string{"3", "1", "5", "2", "4"} {
+		if err := ObserveGroupMember("g", userId, "", 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 其他群组的成员不会出现在列表中，包括 ID 以当前群组 ID 为前缀的群组
+	for _, groupId := range []string{"g2", "f", "h"} {
+		if err := ObserveGroupMember(groupId, "0", "", 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		next     string
+		limit    int
+		want     []string
+		wantNext string
+	}{
+		{"first page", "", 2, []string{"1", "2"}, "2"},
+		{"middle page", "2", 2, []string{"3", "4"}, "4"},
+		{"last page", "4", 2, []string{"5"}, ""},
+		{"exact last page", "3", 2, []string{"4", "5"}, ""},
+		{"all", "", 10, []string{"1", "2", "3", "4", "5"}, ""},
+		{"token between members", "25", 2, []string{"3", "4"}, "4"},
+		{"token after last member", "5", 2, []string{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			members, next, err := ListGroupMembers("g", tt.next, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := memberIds(members); !reflect.DeepEqual(got, tt.want) || next != tt.wantNext {
+				t.Errorf("ListGroupMembers(g, %q, %d) = %v, %q, want %v, %q", tt.next, tt.limit, got, next, tt.want, tt.wantNext)
+			}
+		})
+	}
+
+	// 逐页读取可以得到全部成员
+	var all []string
+	next := ""
+	for page := 0; page == 0 || next != ""; page++ {
+		if page > 5 {
+			t.Fatal("pagination did not terminate")
+		}
+		members, n, err := ListGroupMembers("g", next, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, memberIds(members)...)
+		next = n
+	}
+	if want := []string{"1", "2", "3", "4", "5"}; !reflect.DeepEqual(all, want) {
+		t.Errorf("paged members = %v, want %v", all, want)
+	}
+}
+
+func TestObserveGroupMember(t *testing.T) {
+	openTestRoster(t)
+	steps := []struct {
+		avatar    string
+		seenAt    int64
+		wantFirst int64
+		wantLast  int64
+		avatarNow string
+	}{
+		{"a", 100000, 100000, 100000, "a"},
+		{"", 110000, 100000, 100000, "a"},  // 间隔过短且头像未变化，不更新
+		{"b", 120000, 100000, 120000, "b"}, // 头像变化时立即更新
+		{"", 200000, 100000, 200000, "b"},  // 空头像保留原有头像
+	}
+	for i, step := range steps {
+		if err := ObserveGroupMember("g", "u", step.avatar, step.seenAt); err != nil {
+			t.Fatal(err)
+		}
+		member, err := GetGroupMember("g", "u")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if member.FirstSeen != step.wantFirst || member.LastSeen != step.wantLast || member.Avatar != step.avatarNow {
+			t.Errorf("step %d: GetGroupMember() = %+v, want first %d, last %d, avatar %q",
+				i, member, step.wantFirst, step.wantLast, step.avatarNow)
+		}
+	}
+}
+
+func TestDeleteGroupRoster(t *testing.T) {
+	openTestRoster(t)
+	for i := 0; i < 3; i++ {
+		for _, groupId := range []string{"g", "g2"} {
+			if err := ObserveGroupMember(groupId, fmt.Sprint(i), "", 1000); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := DeleteGroupRoster("g"); err != nil {
+		t.Fatal(err)
+	}
+	if members, _, err := ListGroupMembers("g", "", 10); err != nil || len(members) != 0 {
+		t.Errorf("ListGroupMembers(g) after delete = %v, %v, want empty", memberIds(members), err)
+	}
+	if _, err := GetGroupMember("g", "0"); err != ErrMemberNotFound {
+		t.Errorf("GetGroupMember(g, 0) error = %v, want %v", err, ErrMemberNotFound)
+	}
+	if members, _, err := ListGroupMembers("g2", "", 10); err != nil || len(members) != 3 {
+		t.Errorf("ListGroupMembers(g2) after delete = %v, %v, want 3 members", memberIds(members), err)
+	}
+}
+
+func TestRosterDisabled(t *testing.T) {
+	roster = nil
+	if err := ObserveGroupMember("g", "u", "", 0); err != ErrRosterDBDisabled {
+		t.Errorf("ObserveGroupMember() error = %v, want %v", err, ErrRosterDBDisabled)
+	}
+	if _, _, err := ListGroupMembers("g", "", 10); err != ErrRosterDBDisabled {
+		t.Errorf("ListGroupMembers() error = %v, want %v", err, ErrRosterDBDisabled)
+	}
+}
+
+// userIds 获取用户 ID 列表
+func userIds(users []*RosterUser) []string {
+	ids := make([]string, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.UserId)
+	}
+	return ids
+}
+
+func TestListFriends(t *testing.T) {
+	openTestRoster(t)
+	for _, userId := range []string{"c", "a", "e", "b", "d"} {
+		if err := AddFriend(userId, 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 只是出现过的用户不是好友
+	if err := ObserveUser("x", "", "", 1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveFriend("d"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		next     string
+		limit    int
+		want     []string
+		wantNext string
+	}{
+		{"first page", "", 2, []string{"a", "b"}, "b"},
+		{"last page", "b", 2, []string{"c", "e"}, ""},
+		{"all", "", 10, []string{"a", "b", "c", "e"}, ""},
+		{"removed friend as token", "d", 2, []string{"e"}, ""},
+		{"token after last friend", "e", 2, []string{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, next, err := ListFriends(tt.next, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := userIds(users); !reflect.DeepEqual(got, tt.want) || next != tt.wantNext {
+				t.Errorf("ListFriends(%q, %d) = %v, %q, want %v, %q", tt.next, tt.limit, got, next, tt.want, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestListDirectUsers(t *testing.T) {
+	openTestRoster(t)
+	if err := ObserveUser("a", "name", "", 5000); err != nil {
+		t.Fatal(err)
+	}
+	for _, userId := range []string{"b", "a"} {
+		if err := AddDirectUser(userId, 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 私信用户与好友分开记录
+	if err := AddFriend("c", 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	users, next, err := ListDirectUsers("", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*RosterUser{
+		{UserId: "a", Name: "name", FirstSeen: 5000, LastSeen: 5000},
+		{UserId: "b"},
+	}
+	if !reflect.DeepEqual(users, want) || next != "" {
+		t.Errorf("ListDirectUsers() = %+v, %q, want %+v", users, next, want)
+	}
+	friends, _, err := ListFriends("", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := userIds(friends); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("ListFriends() = %v, want [c]", got)
+	}
+}
+
+func TestListFriendsWithUser(t *testing.T) {
+	openTestRoster(t)
+	if err := ObserveUser("a", "", "avatar", 5000); err != nil {
+		t.Fatal(err)
+	}
+	for _, userId := range []string{"a", "b"} {
+		if err := AddFriend(userId, 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 已经是好友时再次添加不会改变记录
+	if err := AddFriend("a", 2000); err != nil {
+		t.Fatal(err)
+	}
+
+	users, next, err := ListFriends("", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*RosterUser{
+		{UserId: "a", Avatar: "avatar", FirstSeen: 5000, LastSeen: 5000},
+		{UserId: "b"}, // 没有用户记录的好友只包含用户 ID
+	}
+	if !reflect.DeepEqual(users, want) || next != "" {
+		t.Errorf("ListFriends() = %+v, %q, want %+v", users, next, want)
+	}
+
+	var friend RosterFriend
+	if err := roster.get(rosterKey(friendPrefix, "a"), &friend, ErrUserNotFound); err != nil || friend.Since != 1000 {
+		t.Errorf("friend a = %+v, %v, want since 1000", friend, err)
+	}
+	if _, err := GetUser("b"); err != ErrUserNotFound {
+		t.Errorf("GetUser(b) error = %v, want %v", err, ErrUserNotFound)
+	}
+}
+
+func TestOpenIdType(t *testing.T) {
+	openTestRoster(t)
+	if err := SetOpenIdType("g", "group"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetOpenIdType("u", "private"); err != nil {
+		t.Fatal(err)
+	}
+	// 记录类型之前添加的好友
+	if err := AddFriend("f", 1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetOpenIdType("removed", "group"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteOpenIdType("removed"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		openId  string
+		want    string
+		wantErr error
+	}{
+		{"g", "group", nil},
+		{"u", "private", nil},
+		{"f", "private", nil},
+		{"removed", "", ErrOpenIdNotFound},
+		{"unknown", "", ErrOpenIdNotFound},
+	}
+	for _, tt := range tests {
+		got, err := GetOpenIdType(tt.openId)
+		if got != tt.want || err != tt.wantErr {
+			t.Errorf("GetOpenIdType(%s) = %q, %v, want %q, %v", tt.openId, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestObserveUserName(t *testing.T) {
+	openTestRoster(t)
+	steps := []struct {
+		name     string
+		seenAt   int64
+		wantName string
+		wantLast int64
+	}{
+		{"a", 100000, "a", 100000},
+		{"", 110000, "a", 100000},  // 间隔过短且名称未变化，不更新
+		{"b", 120000, "b", 120000}, // 名称变化时立即更新
+	}
+	for i, step := range steps {
+		if err := ObserveUser("u", step.name, "", step.seenAt); err != nil {
+			t.Fatal(err)
+		}
+		user, err := GetUser("u")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Name != step.wantName || user.LastSeen != step.wantLast {
+			t.Errorf("step %d: GetUser() = %+v, want name %q, last %d", i, user, step.wantName, step.wantLast)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package database
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"html"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/WindowsSov8forUs/glyccat/log"
+	"github.com/satori-protocol-go/satori-model-go/pkg/message"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// 全文索引键的格式为 idx:词元 + 0x00 + 主索引键
+var searchIndexPrefix = []byte("idx:")
+
+// ErrInvalidSearchCursor 分页令牌无效
+var ErrInvalidSearchCursor = errors.New("invalid search cursor")
+
+// tagPattern 匹配消息元素标签，用于无法解析的消息内容
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// SearchQuery 消息搜索条件，空值表示不限制
+type SearchQuery struct {
+	Keyword     string   // 关键词，以空白分隔的多个关键词需要同时匹配
+	ChannelId   string   // 频道 ID
+	ChannelType string   // 频道类型，指定频道 ID 时必须指定
+	Types       []string // 限定的频道类型
+	UserId      string   // 发送者 ID
+	Start       int64    // 起始时间，毫秒时间戳，包含
+	End         int64    // 结束时间，毫秒时间戳，不包含
+	Next        string   // 分页令牌
+	Limit       int      // 返回的最大消息数
+}
+
+// SearchResult 消息搜索结果
+type SearchResult struct {
+	Data []*message.Message // 按时间降序排列的消息列表
+	Next string             // 下一页的分页令牌，没有更多结果时为空
+}
+
+// isCJK 判断是否为中日韩文字
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// messageText 提取消息内容中的纯文本并转换为小写
+func messageText(content string) string {
+	elements, err := message.Parse(content)
+	if err != nil {
+		return strings.ToLower(html.UnescapeString(tagPattern.ReplaceAllString(content, " ")))
+	}
+
+	var builder strings.Builder
+	var walk func(elements []message.MessageElement)
+	walk = func(elements []message.MessageElement) {
+		for _, element := range elements {
+			if text, ok := element.(*message.MessageElementText); ok {
+				builder.WriteString(text.Content)
+			} else {
+				// 元素之间视为分隔
+				builder.WriteByte(' ')
+				walk(element.GetChildren())
+			}
+		}
+	}
+	walk(elements)
+	return strings.ToLower(builder.String())
+}
+
+// tokenize 将文本切分为词元
+//
+// 连续的字母与数字作为一个词元；中日韩文字没有明确的分隔，
+// 使用单字与相邻两字作为词元，以支持任意长度的关键词。
+// query 为 true 时只生成检索所需的最少词元
+func tokenize(text string, query bool) []string {
+	seen := make(map[string]struct{})
+	var tokens []string
+	add := func(token string) {
+		if _, ok := seen[token]; !ok {
+			seen[token] = struct{}{}
+			tokens = append(tokens, token)
+		}
+	}
+
+	var word, cjk []rune
+	flushWord := func() {
+		if len(word) > 0 {
+			add(string(word))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		for i := range cjk {
+			if !query || len(cjk) == 1 {
+				add(string(cjk[i]))
+			}
+			if i+1 < len(cjk) {
+				add(string(cjk[i : i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
+
+// searchIndexKey 生成全文索引键
+func searchIndexKey(token string, key []byte) []byte {
+	indexKey := make([]byte, 0, len(searchIndexPrefix)+len(token)+1+len(key))
+	indexKey = append(indexKey, searchIndexPrefix...)
+	indexKey = append(indexKey, token...)
+	indexKey = append(indexKey, 0)
+	return append(indexKey, key...)
+}
+
+// messageTokens 获取编码后消息的全部词元
+func messageTokens(value []byte) []string {
+	if value == nil {
+		return nil
+	}
+	message, err := decodeMessage(value)
+	if err != nil {
+		return nil
+	}
+	return tokenize(messageText(message.Content), false)
+}
+
+// indexMessage 将消息加入全文索引
+func indexMessage(batch *leveldb.Batch, key, value []byte) {
+	for _, token := range messageTokens(value) {
+		batch.Put(searchIndexKey(token, key), nil)
+	}
+}
+
+// unindexMessage 将消息移出全文索引
+func unindexMessage(batch *leveldb.Batch, key, value []byte) {
+	for _, token := range messageTokens(value) {
+		batch.Delete(searchIndexKey(token, key))
+	}
+}
+
+// parseSearchCursor 解析搜索的分页令牌
+//
+// 分页令牌为上一页最后一条消息的主索引键的十六进制编码，各存储后端通用
+func parseSearchCursor(next string) (*storedMessage, error) {
+	key, err := hex.DecodeString(next)
+	if err != nil || !bytes.HasPrefix(key, messagePrefix) {
+		return nil, ErrInvalidSearchCursor
+	}
+	cursor, ok := parseMessageKey(key)
+	if !ok {
+		return nil, ErrInvalidSearchCursor
+	}
+	return cursor, nil
+}
+
+// searchCursor 生成搜索的分页令牌
+func searchCursor(channelId, channelType string, createAt int64, messageId string) string {
+	return hex.EncodeToString(messageKey(channelId, channelType, createAt, messageId))
+}
+
+// matchTerms 判断消息内容是否包含全部关键词
+func matchTerms(content string, terms []string) bool {
+	if len(terms) == 0 {
+		return true
+	}
+	text := messageText(content)
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// postings 获取包含词元的全部消息的主索引键
+//
+// 字母与数字组成的词元按前缀匹配，中日韩文字词元按完整匹配
+func (s *levelDBStore) postings(token string, keyPrefix []byte) map[string]struct{} {
+	prefix := append(append([]byte(nil), searchIndexPrefix...), token...)
+	exact := false
+	for _, r := range token {
+		if isCJK(r) {
+			exact = true
+			break
+		}
+	}
+	if exact {
+		prefix = append(prefix, 0)
+		prefix = append(prefix, keyPrefix...)
+	}
+
+	keys := make(map[string]struct{})
+	iter := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		indexKey := iter.Key()[len(searchIndexPrefix):]
+		sep := bytes.IndexByte(indexKey, 0)
+		if sep < 0 {
+			continue
+		}
+		key := indexKey[sep+1:]
+		if bytes.HasPrefix(key, keyPrefix) {
+			keys[string(key)] = struct{}{}
+		}
+	}
+	return keys
+}
+
+// Search 搜索消息
+func (s *levelDBStore) Search(query *SearchQuery) (*SearchResult, error) {
+	limit := s.capLimit(query.Limit)
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	keyPrefix := messagePrefix
+	if query.ChannelId != "" {
+		keyPrefix = channelPrefix(query.ChannelId, query.ChannelType)
+	}
+
+	var cursor *storedMessage
+	var cursorKey string
+	if query.Next != "" {
+		var err error
+		if cursor, err = parseSearchCursor(query.Next); err != nil {
+			return nil, err
+		}
+		cursorKey = string(messageKey(cursor.channelId, cursor.channelType, cursor.createAt, cursor.messageId))
+	}
+
+	// 获取候选消息
+	var candidates map[string]struct{}
+	for _, token := range tokenize(query.Keyword, true) {
+		keys := s.postings(token, keyPrefix)
+		if candidates == nil {
+			candidates = keys
+		} else {
+			for key := range candidates {
+				if _, ok := keys[key]; !ok {
+					delete(candidates, key)
+				}
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+	}
+	if candidates == nil {
+		// 没有关键词时遍历全部消息
+		candidates = make(map[string]struct{})
+		iter := s.db.NewIterator(util.BytesPrefix(keyPrefix), nil)
+		for iter.Next() {
+			candidates[string(iter.Key())] = struct{}{}
+		}
+		iter.Release()
+	}
+
+	// 按时间范围与分页令牌筛选并按时间降序排列
+	type candidate struct {
+		key string
+		m   *storedMessage
+	}
+	var sorted []candidate
+	for key := range candidates {
+		m, ok := parseMessageKey([]byte(key))
+		if !ok {
+			continue
+		}
+		if len(query.Types) > 0 && !slices.Contains(query.Types, m.channelType) {
+			continue
+		}
+		if query.Start > 0 && m.createAt < query.Start {
+			continue
+		}
+		if query.End > 0 && m.createAt >= query.End {
+			continue
+		}
+		if cursor != nil && (m.createAt > cursor.createAt || (m.createAt == cursor.createAt && key >= cursorKey)) {
+			continue
+		}
+		sorted = append(sorted, candidate{key, m})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].m.createAt != sorted[j].m.createAt {
+			return sorted[i].m.createAt > sorted[j].m.createAt
+		}
+		return sorted[i].key > sorted[j].key
+	})
+
+	// 逐条校验内容与发送者
+	terms := strings.Fields(strings.ToLower(query.Keyword))
+	result := &SearchResult{Data: []*message.Message{}}
+	for i, c := range sorted {
+		if len(result.Data) >= limit {
+			result.Next = hex.EncodeToString([]byte(sorted[i-1].key))
+			break
+		}
+
+		value, err := s.db.Get([]byte(c.key), nil)
+		if err == leveldb.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		message, err := decodeMessage(value)
+		if err != nil {
+			log.Warnf("解码消息记录 %q 失败: %v", c.key, err)
+			continue
+		}
+		if s.deleted(c.m.channelId, c.m.channelType, c.m.messageId) {
+			continue
+		}
+		if query.UserId != "" && (message.User == nil || message.User.Id != query.UserId) {
+			continue
+		}
+		if !matchTerms(message.Content, terms) {
+			continue
+		}
+		result.Data = append(result.Data, message)
+	}
+	return result, nil
+}
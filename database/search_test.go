@@ -0,0 +1,203 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/satori-protocol-go/satori-model-go/pkg/message"
+	"github.com/satori-protocol-go/satori-model-go/pkg/user"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text  string
+		query bool
+		want  []string
+	}{
+		{"Hello, World", false, []string{"hello", "world"}},
+		{"abc123 def", false, []string{"abc123", "def"}},
+		{"今天天气", false, []string{"今", "今天", "天", "天天", "天气", "气"}},
+		{"今天天气", true, []string{"今天", "天天", "天气"}},
+		{"好", true, []string{"好"}},
+		{"hello世界", false, []string{"hello", "世", "世界", "界"}},
+		{"hello世界", true, []string{"hello", "世界"}},
+		{"こんにちは", true, []string{"こん", "んに", "にち", "ちは"}},
+		{"안녕 하세요", true, []string{"안녕", "하세", "세요"}},
+		{"  ,. !", false, nil},
+	}
+	for _, tt := range tests {
+		if got := tokenize(tt.text, tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("tokenize(%q, %v) = %q, want %q", tt.text, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestMessageText(t *testing.T) {
+	tests := map[string]string{
+		"Hello <b>World</b>":             "hello world",
+		`<at id="1"/>你好`:                 " 你好",
+		"a &amp; b":                      "a & b",
+		"<img src=\"https://x/a.png\"/>": " ",
+	}
+	for content, want := range tests {
+		if got := messageText(content); got != want {
+			t.Errorf("messageText(%q) = %q, want %q", content, got, want)
+		}
+	}
+}
+
+// openTestLevelDBStore 在临时目录中打开 LevelDB 消息存储
+func openTestLevelDBStore(t *testing.T, options storeOptions) *levelDBStore {
+	t.Helper()
+	s, err := openLevelDBStore(filepath.Join(t.TempDir(), "messages"), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// testMessage 创建测试消息
+func testMessage(id, userId, content string, createAt int64) *message.Message {
+	return &message.Message{Id: id, Content: content, User: &user.User{Id: userId}, CreateAt: createAt}
+}
+
+// messageIds 获取消息 ID 列表
+func messageIds(messages []*message.Message) []string {
+	ids := make([]string, 0, len(messages))
+	for _, m := range messages {
+		ids = append(ids, m.Id)
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	s := openTestLevelDBStore(t, storeOptions{})
+
+	messages := []struct {
+		channelId   string
+		channelType string
+		message     *message.Message
+	}{
+		{"g1", ChannelTypeGroup, testMessage("1", "alice", "今天天气很好", 1000)},
+		{"g1", ChannelTypeGroup, testMessage("2", "bob", "明天<b>天气</b>怎么样", 2000)},
+		{"g1", ChannelTypeGroup, testMessage("3", "alice", "Hello World", 3000)},
+		{"g2", ChannelTypeGroup, testMessage("4", "bob", "天气预报 hello", 4000)},
+		{"u1", ChannelTypePrivate, testMessage("5", "alice", "helloworld 天", 5000)},
+		{"g1", ChannelTypeGroup, testMessage("6", "alice", "被删除的天气", 6000)},
+	}
+	for _, m := range messages {
+		if err := s.Save(m.message, m.channelId, m.channelType); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Delete("g1", ChannelTypeGroup, "6"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query SearchQuery
+		want  []string
+	}{
+		{"cjk bigram", SearchQuery{Keyword: "天气"}, []string{"4", "2", "1"}},
+		{"cjk single character", SearchQuery{Keyword: "天"}, []string{"5", "4", "2", "1"}},
+		{"cjk phrase across tags", SearchQuery{Keyword: "明天天气"}, nil},
+		{"cjk three characters", SearchQuery{Keyword: "天气很"}, []string{"1"}},
+		{"word prefix", SearchQuery{Keyword: "hello"}, []string{"5", "4", "3"}},
+		{"case insensitive", SearchQuery{Keyword: "WORLD"}, []string{"3"}},
+		{"word prefix does not match inside words", SearchQuery{Keyword: "wor"}, []string{"3"}},
+		{"multiple keywords", SearchQuery{Keyword: "天气 hello"}, []string{"4"}},
+		{"no match", SearchQuery{Keyword: "下雨"}, nil},
+		{"channel", SearchQuery{Keyword: "天气", ChannelId: "g1", ChannelType: ChannelTypeGroup}, []string{"2", "1"}},
+		{"channel types", SearchQuery{Keyword: "hello", Types: []string{ChannelTypePrivate}}, []string{"5"}},
+		{"user", SearchQuery{Keyword: "天气", UserId: "alice"}, []string{"1"}},
+		{"time range", SearchQuery{Start: 2000, End: 4000}, []string{"3", "2"}},
+		{"without keyword", SearchQuery{}, []string{"5", "4", "3", "2", "1"}},
+	}
+	for _, tt := range tests {
+		tt.query.Limit = 10
+		result, err := s.Search(&tt.query)
+		if err != nil {
+			t.Errorf("%s: Search() error = %v", tt.name, err)
+			continue
+		}
+		if got := messageIds(result.Data); !slices.Equal(got, tt.want) && (len(got) != 0 || len(tt.want) != 0) {
+			t.Errorf("%s: Search() = %v, want %v", tt.name, got, tt.want)
+		}
+		if result.Next != "" {
+			t.Errorf("%s: Search() next = %q, want empty", tt.name, result.Next)
+		}
+	}
+}
+
+func TestSearchPagination(t *testing.T) {
+	s := openTestLevelDBStore(t, storeOptions{limit: 3})
+
+	// 相同时间的消息按主索引键排序
+	createAts := []int64{1000, 2000, 2000, 2000, 3000, 4000, 5000}
+	for i, createAt := range createAts {
+		m := testMessage(string(rune('a'+i)), "alice", "搜索分页", createAt)
+		if err := s.Save(m, "g1", ChannelTypeGroup); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Save(testMessage("other", "bob", "无关消息", 6000), "g1", ChannelTypeGroup); err != nil {
+		t.Fatal(err)
+	}
+
+	var pages [][]string
+	query := SearchQuery{Keyword: "分页", Limit: 100}
+	for {
+		result, err := s.Search(&query)
+		if err != nil {
+			t.Fatalf("Search() error = %v", err)
+		}
+		pages = append(pages, messageIds(result.Data))
+		if result.Next == "" {
+			break
+		}
+		if len(pages) > len(createAts) {
+			t.Fatal("Search() pagination does not terminate")
+		}
+		query.Next = result.Next
+	}
+
+	want := [][]string{{"g", "f", "e"}, {"d", "c", "b"}, {"a"}}
+	if len(pages) != len(want) {
+		t.Fatalf("Search() pages = %v, want %v", pages, want)
+	}
+	for i := range want {
+		if !slices.Equal(pages[i], want[i]) {
+			t.Errorf("Search() page %d = %v, want %v", i, pages[i], want[i])
+		}
+	}
+
+	// 分页令牌在消息被删除后仍然有效
+	query.Next = ""
+	first, err := s.Search(&query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("g1", ChannelTypeGroup, "e"); err != nil {
+		t.Fatal(err)
+	}
+	query.Next = first.Next
+	second, err := s.Search(&query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messageIds(second.Data); !slices.Equal(got, []string{"d", "c", "b"}) {
+		t.Errorf("Search() after delete = %v, want [d c b]", got)
+	}
+
+	invalid := []string{"zz", "00", searchCursor("g1", ChannelTypeGroup, 1000, "a")[2:]}
+	for _, next := range invalid {
+		query.Next = next
+		if _, err := s.Search(&query); !errors.Is(err, ErrInvalidSearchCursor) {
+			t.Errorf("Search() with cursor %q error = %v, want %v", next, err, ErrInvalidSearchCursor)
+		}
+	}
+}
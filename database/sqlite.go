@@ -0,0 +1,628 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/WindowsSov8forUs/glyccat/log"
+	"github.com/satori-protocol-go/satori-model-go/pkg/message"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteDBPath string = "data/db/messages.db"
+
+// sqliteSchemaVersion SQLite 消息数据库的表结构版本，记录在 user_version 中
+const sqliteSchemaVersion = 1
+
+// sqliteSchema SQLite 消息数据库的表结构
+//
+// 已删除的消息保留在 messages 表中以保证分页令牌有效，删除记录单独存放在 tombstones 表中，
+// 删除事件可能先于消息到达，因此删除记录不依赖于消息本身；消息被清理时触发器会同时移除其删除记录
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS messages (
+	channel_type TEXT NOT NULL,
+	channel_id   TEXT NOT NULL,
+	id           TEXT NOT NULL,
+	create_at    INTEGER NOT NULL,
+	user_id      TEXT NOT NULL DEFAULT '',
+	text         TEXT NOT NULL DEFAULT '',
+	data         BLOB NOT NULL,
+	PRIMARY KEY (channel_type, channel_id, id)
+);
+CREATE INDEX IF NOT EXISTS messages_channel_time ON messages (channel_type, channel_id, create_at, id);
+CREATE INDEX IF NOT EXISTS messages_time ON messages (create_at);
+CREATE INDEX IF NOT EXISTS messages_user ON messages (user_id, create_at);
+CREATE TABLE IF NOT EXISTS tombstones (
+	channel_type TEXT NOT NULL,
+	channel_id   TEXT NOT NULL,
+	id           TEXT NOT NULL,
+	deleted_at   INTEGER NOT NULL,
+	PRIMARY KEY (channel_type, channel_id, id)
+);
+CREATE INDEX IF NOT EXISTS tombstones_time ON tombstones (deleted_at);
+CREATE TRIGGER IF NOT EXISTS messages_purge AFTER DELETE ON messages BEGIN
+	DELETE FROM tombstones WHERE channel_type = old.channel_type AND channel_id = old.channel_id AND id = old.id;
+END;
+`
+
+// sqliteStore 基于 SQLite 的消息存储
+type sqliteStore struct {
+	storeOptions
+	db *sql.DB
+}
+
+// openSQLiteStore 创建或打开 SQLite 消息数据库
+func openSQLiteStore(path string, options storeOptions) (*sqliteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_busy_timeout=5000&_auto_vacuum=incremental")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite 同一时间只允许一个写入者，使用单个连接以保证事务串行执行
+	db.SetMaxOpenConns(1)
+
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("打开 SQLite 消息数据库失败: %w", err)
+	}
+	return &sqliteStore{storeOptions: options, db: db}, nil
+}
+
+// migrateSQLite 建立或升级表结构
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > sqliteSchemaVersion {
+		return fmt.Errorf("消息数据库版本 %d 高于当前支持的版本 %d", version, sqliteSchemaVersion)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return err
+	}
+	_, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", sqliteSchemaVersion))
+	return err
+}
+
+// Name 存储后端名称
+func (s *sqliteStore) Name() string {
+	return "sqlite"
+}
+
+// Close 关闭数据库
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// userId 获取消息发送者 ID
+func userId(data *message.Message) string {
+	if data.User == nil {
+		return ""
+	}
+	return data.User.Id
+}
+
+// upsert 插入或覆盖消息
+func (s *sqliteStore) upsert(tx *sql.Tx, data *message.Message, channelId, channelType string) error {
+	value, err := encodeMessage(data)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO messages (channel_type, channel_id, id, create_at, user_id, text, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (channel_type, channel_id, id) DO UPDATE SET
+			create_at = excluded.create_at, user_id = excluded.user_id, text = excluded.text, data = excluded.data`,
+		channelType, channelId, data.Id, data.CreateAt, userId(data), messageText(data.Content), value)
+	return err
+}
+
+// deletedIn 在事务中判断消息是否已被删除
+func deletedIn(tx *sql.Tx, channelId, channelType, messageId string) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM tombstones WHERE channel_type = ? AND channel_id = ? AND id = ?)`,
+		channelType, channelId, messageId).Scan(&exists)
+	return exists, err
+}
+
+// transaction 在事务中执行操作
+func (s *sqliteStore) transaction(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Save 保存消息
+func (s *sqliteStore) Save(data *message.Message, channelId, channelType string) error {
+	// 不存储已排除的频道的消息
+	if s.isExcluded(channelId) {
+		return nil
+	}
+
+	return s.transaction(func(tx *sql.Tx) error {
+		// 删除事件可能先于消息到达
+		deleted, err := deletedIn(tx, channelId, channelType, data.Id)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			return ErrMessageDeleted
+		}
+
+		// 没有发送时间的消息以保存时间为准
+		if data.CreateAt == 0 {
+			data.CreateAt = time.Now().UnixMilli()
+		}
+		return s.upsert(tx, data, channelId, channelType)
+	})
+}
+
+// Delete 将消息标记为已删除
+func (s *sqliteStore) Delete(channelId, channelType, messageId string) error {
+	now := time.Now().UnixMilli()
+	return s.transaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT OR REPLACE INTO tombstones (channel_type, channel_id, id, deleted_at) VALUES (?, ?, ?, ?)`,
+			channelType, channelId, messageId, now)
+		if err != nil {
+			return err
+		}
+
+		var value []byte
+		err = tx.QueryRow(`SELECT data FROM messages WHERE channel_type = ? AND channel_id = ? AND id = ?`,
+			channelType, channelId, messageId).Scan(&value)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		message, err := decodeMessage(value)
+		if err != nil {
+			return nil
+		}
+		message.Content = ""
+		message.UpdateAt = now
+		if value, err = encodeMessage(message); err != nil {
+			return nil
+		}
+		_, err = tx.Exec(`UPDATE messages SET text = '', data = ? WHERE channel_type = ? AND channel_id = ? AND id = ?`,
+			value, channelType, channelId, messageId)
+		return err
+	})
+}
+
+// Get 获取消息
+func (s *sqliteStore) Get(channelId, channelType, messageId string) (*message.Message, error) {
+	var value []byte
+	var deleted bool
+	err := s.db.QueryRow(`SELECT m.data, t.id IS NOT NULL FROM messages m
+		LEFT JOIN tombstones t USING (channel_type, channel_id, id)
+		WHERE m.channel_type = ? AND m.channel_id = ? AND m.id = ?`,
+		channelType, channelId, messageId).Scan(&value, &deleted)
+	if err == sql.ErrNoRows {
+		// 消息可能在保存前已被删除
+		var exists bool
+		if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM tombstones WHERE channel_type = ? AND channel_id = ? AND id = ?)`,
+			channelType, channelId, messageId).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrMessageDeleted
+		}
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deleted {
+		return nil, ErrMessageDeleted
+	}
+	return decodeMessage(value)
+}
+
+// List 获取消息列表
+func (s *sqliteStore) List(channelId, channelType, next string, direction QueryDirection, limit int) (*MessagePage, error) {
+	limit = s.capLimit(limit)
+
+	if next == "" {
+		before, hasBefore, err := s.collect(channelId, channelType, nil, limit, false)
+		if err != nil {
+			return nil, err
+		}
+		return newMessagePage(before, nil, hasBefore, false, next), nil
+	}
+
+	// 获取基准消息
+	var value []byte
+	var createAt int64
+	var deleted bool
+	err := s.db.QueryRow(`SELECT m.create_at, m.data, t.id IS NOT NULL FROM messages m
+		LEFT JOIN tombstones t USING (channel_type, channel_id, id)
+		WHERE m.channel_type = ? AND m.channel_id = ? AND m.id = ?`,
+		channelType, channelId, next).Scan(&createAt, &value, &deleted)
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	cursor := &storedMessage{channelType: channelType, channelId: channelId, createAt: createAt, messageId: next}
+
+	var before, after []*message.Message
+	var hasBefore, hasAfter bool
+	switch direction {
+	case QueryDirectionBefore:
+		before, hasBefore, err = s.collect(channelId, channelType, cursor, limit, false)
+		hasAfter = true
+	case QueryDirectionAfter:
+		after, hasAfter, err = s.collect(channelId, channelType, cursor, limit, true)
+		hasBefore = true
+	case QueryDirectionAround:
+		// 基准消息本身占一个位置，剩余数量尽量平均分配到两侧
+		rest := max(limit-1, 0)
+		before, hasBefore, err = s.collect(channelId, channelType, cursor, rest/2, false)
+		if err == nil {
+			after, hasAfter, err = s.collect(channelId, channelType, cursor, rest-rest/2, true)
+		}
+		if err == nil && !deleted && limit > 0 {
+			if current, err := decodeMessage(value); err == nil {
+				after = append([]*message.Message{current}, after...)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown query direction: %s", direction)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newMessagePage(before, after, hasBefore, hasAfter, next), nil
+}
+
+// collect 从基准位置开始向一个方向获取未删除的消息，不包含基准消息本身
+//
+// cursor 为空时从最新的消息开始向前获取，返回值 more 表示该方向是否还有更多消息
+func (s *sqliteStore) collect(channelId, channelType string, cursor *storedMessage, limit int, forward bool) ([]*message.Message, bool, error) {
+	query := `SELECT data FROM messages m WHERE channel_type = ? AND channel_id = ?
+		AND NOT EXISTS (SELECT 1 FROM tombstones t WHERE t.channel_type = m.channel_type AND t.channel_id = m.channel_id AND t.id = m.id)`
+	args := []any{channelType, channelId}
+	if cursor != nil {
+		if forward {
+			query += ` AND (create_at, id) > (?, ?)`
+		} else {
+			query += ` AND (create_at, id) < (?, ?)`
+		}
+		args = append(args, cursor.createAt, cursor.messageId)
+	}
+	if forward {
+		query += ` ORDER BY create_at, id`
+	} else {
+		query += ` ORDER BY create_at DESC, id DESC`
+	}
+	// 多获取一条用于判断是否还有更多消息
+	query += ` LIMIT ?`
+	args = append(args, limit+1)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var messages []*message.Message
+	for rows.Next() {
+		if len(messages) >= limit {
+			return messages, true, nil
+		}
+		var value []byte
+		if err := rows.Scan(&value); err != nil {
+			return nil, false, err
+		}
+		message, err := decodeMessage(value)
+		if err != nil {
+			log.Warnf("解码消息记录失败: %v", err)
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages, false, rows.Err()
+}
+
+// likePattern 生成匹配包含关键词的文本的 LIKE 模式
+func likePattern(term string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term) + "%"
+}
+
+// Search 搜索消息
+func (s *sqliteStore) Search(query *SearchQuery) (*SearchResult, error) {
+	limit := s.capLimit(query.Limit)
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	statement := `SELECT channel_type, channel_id, create_at, id, data FROM messages m
+		WHERE NOT EXISTS (SELECT 1 FROM tombstones t WHERE t.channel_type = m.channel_type AND t.channel_id = m.channel_id AND t.id = m.id)`
+	var args []any
+	if query.ChannelId != "" {
+		statement += ` AND channel_type = ? AND channel_id = ?`
+		args = append(args, query.ChannelType, query.ChannelId)
+	}
+	if len(query.Types) > 0 {
+		statement += ` AND channel_type IN (?` + strings.Repeat(`, ?`, len(query.Types)-1) + `)`
+		for _, channelType := range query.Types {
+			args = append(args, channelType)
+		}
+	}
+	if query.UserId != "" {
+		statement += ` AND user_id = ?`
+		args = append(args, query.UserId)
+	}
+	if query.Start > 0 {
+		statement += ` AND create_at >= ?`
+		args = append(args, query.Start)
+	}
+	if query.End > 0 {
+		statement += ` AND create_at < ?`
+		args = append(args, query.End)
+	}
+	for _, term := range strings.Fields(strings.ToLower(query.Keyword)) {
+		statement += ` AND text LIKE ? ESCAPE '\'`
+		args = append(args, likePattern(term))
+	}
+	if query.Next != "" {
+		cursor, err := parseSearchCursor(query.Next)
+		if err != nil {
+			return nil, err
+		}
+		statement += ` AND (create_at, channel_type, channel_id, id) < (?, ?, ?, ?)`
+		args = append(args, cursor.createAt, cursor.channelType, cursor.channelId, cursor.messageId)
+	}
+	statement += ` ORDER BY create_at DESC, channel_type DESC, channel_id DESC, id DESC LIMIT ?`
+	args = append(args, limit+1)
+
+	rows, err := s.db.Query(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := &SearchResult{Data: []*message.Message{}}
+	var last *storedMessage
+	for rows.Next() {
+		if len(result.Data) >= limit {
+			result.Next = searchCursor(last.channelId, last.channelType, last.createAt, last.messageId)
+			break
+		}
+		var m storedMessage
+		var value []byte
+		if err := rows.Scan(&m.channelType, &m.channelId, &m.createAt, &m.messageId, &value); err != nil {
+			return nil, err
+		}
+		last = &m
+		message, err := decodeMessage(value)
+		if err != nil {
+			log.Warnf("解码消息记录 %s 失败: %v", m.messageId, err)
+			continue
+		}
+		result.Data = append(result.Data, message)
+	}
+	return result, rows.Err()
+}
+
+// Prune 按照保留策略清理消息
+//
+// 数据库大小以已使用的页面计算，清理后释放的页面会归还给文件系统
+func (s *sqliteStore) Prune() (PruneResult, error) {
+	var result PruneResult
+	policy := s.retention
+
+	err := s.transaction(func(tx *sql.Tx) error {
+		if len(s.excluded) > 0 {
+			var args []any
+			for channelId := range s.excluded {
+				args = append(args, channelId)
+			}
+			n, err := execCount(tx, `DELETE FROM messages WHERE channel_id IN (?`+strings.Repeat(`, ?`, len(args)-1)+`)`, args...)
+			if err != nil {
+				return err
+			}
+			result.Excluded = n
+		}
+
+		if policy.MaxAge > 0 {
+			cutoff := time.Now().Add(-policy.MaxAge).UnixMilli()
+			n, err := execCount(tx, `DELETE FROM messages WHERE create_at < ?`, cutoff)
+			if err != nil {
+				return err
+			}
+			result.Expired = n
+
+			// 清理没有对应消息的过期删除记录
+			if _, err := tx.Exec(`DELETE FROM tombstones WHERE deleted_at < ?`, cutoff); err != nil {
+				return err
+			}
+		}
+
+		if policy.MaxPerChannel > 0 {
+			n, err := execCount(tx, `DELETE FROM messages WHERE rowid IN (
+				SELECT rowid FROM (
+					SELECT rowid, ROW_NUMBER() OVER (PARTITION BY channel_type, channel_id ORDER BY create_at DESC, id DESC) AS n
+					FROM messages
+				) WHERE n > ?
+			)`, policy.MaxPerChannel)
+			if err != nil {
+				return err
+			}
+			result.Overflow = n
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	// 超出大小上限时从最早的消息开始删除
+	if policy.MaxSize > 0 {
+		used, err := s.usedSize()
+		if err != nil {
+			return result, err
+		}
+		if excess := used - policy.MaxSize; excess > 0 {
+			n, err := s.pruneOldest(excess)
+			result.Oversize = n
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+
+	if result.Total() > 0 {
+		s.vacuum()
+	}
+	return result, nil
+}
+
+// usedSize 获取数据库已使用的空间
+func (s *sqliteStore) usedSize() (int64, error) {
+	var pageCount, freeCount, pageSize int64
+	if err := s.db.QueryRow("PRAGMA page_count").Scan(&pageCount); err != nil {
+		return 0, err
+	}
+	if err := s.db.QueryRow("PRAGMA freelist_count").Scan(&freeCount); err != nil {
+		return 0, err
+	}
+	if err := s.db.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, err
+	}
+	return (pageCount - freeCount) * pageSize, nil
+}
+
+// pruneOldest 从最早的消息开始删除，直到释放的空间不少于 excess
+func (s *sqliteStore) pruneOldest(excess int64) (int, error) {
+	var count int
+	err := s.transaction(func(tx *sql.Tx) error {
+		// 只删除此前累计释放的空间仍不足 excess 的消息
+		n, err := execCount(tx, `DELETE FROM messages WHERE rowid IN (
+			SELECT rowid FROM (
+				SELECT rowid, SUM(size) OVER (ORDER BY create_at, rowid ROWS UNBOUNDED PRECEDING) - size AS freed
+				FROM (SELECT rowid, create_at, length(data) + length(text) + length(channel_id) + length(id) AS size FROM messages)
+			) WHERE freed < ?
+		)`, excess)
+		count = n
+		return err
+	})
+	return count, err
+}
+
+// vacuum 将空闲页面归还给文件系统
+func (s *sqliteStore) vacuum() {
+	if _, err := s.db.Exec("PRAGMA incremental_vacuum"); err != nil {
+		log.Warnf("压缩消息数据库失败: %v", err)
+	}
+}
+
+// execCount 执行语句并返回影响的行数
+func execCount(tx *sql.Tx, query string, args ...any) (int, error) {
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// PurgeChannel 删除频道的全部消息，返回删除的消息数
+func (s *sqliteStore) PurgeChannel(channelId string) (int, error) {
+	var count int
+	err := s.transaction(func(tx *sql.Tx) error {
+		n, err := execCount(tx, `DELETE FROM messages WHERE channel_id = ?`, channelId)
+		if err != nil {
+			return err
+		}
+		count = n
+		_, err = tx.Exec(`DELETE FROM tombstones WHERE channel_id = ?`, channelId)
+		return err
+	})
+	if err == nil && count > 0 {
+		s.vacuum()
+	}
+	return count, err
+}
+
+// PurgeUser 删除用户发送的全部消息，channelId 不为空时只删除该频道中的消息，返回删除的消息数
+func (s *sqliteStore) PurgeUser(userId, channelId string) (int, error) {
+	var count int
+	err := s.transaction(func(tx *sql.Tx) error {
+		var err error
+		if channelId != "" {
+			count, err = execCount(tx, `DELETE FROM messages WHERE user_id = ? AND channel_id = ?`, userId, channelId)
+		} else {
+			count, err = execCount(tx, `DELETE FROM messages WHERE user_id = ?`, userId)
+		}
+		return err
+	})
+	if err == nil && count > 0 {
+		s.vacuum()
+	}
+	return count, err
+}
+
+// Each 按频道分组、时间升序遍历全部消息
+func (s *sqliteStore) Each(fn func(record *ExportRecord) error) error {
+	rows, err := s.db.Query(`SELECT m.channel_type, m.channel_id, m.data, COALESCE(t.deleted_at, 0) FROM messages m
+		LEFT JOIN tombstones t USING (channel_type, channel_id, id)
+		ORDER BY m.channel_type, m.channel_id, m.create_at, m.id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		record := &ExportRecord{Version: recordVersion}
+		var value []byte
+		if err := rows.Scan(&record.ChannelType, &record.ChannelId, &value, &record.DeletedAt); err != nil {
+			return err
+		}
+		if record.Message, err = decodeMessage(value); err != nil {
+			return fmt.Errorf("decode message in %s:%s: %w", record.ChannelType, record.ChannelId, err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Restore 恢复一条导出的消息记录
+func (s *sqliteStore) Restore(record *ExportRecord) error {
+	if s.isExcluded(record.ChannelId) {
+		return nil
+	}
+	return s.transaction(func(tx *sql.Tx) error {
+		if err := s.upsert(tx, record.Message, record.ChannelId, record.ChannelType); err != nil {
+			return err
+		}
+		var err error
+		if record.DeletedAt > 0 {
+			_, err = tx.Exec(`INSERT OR REPLACE INTO tombstones (channel_type, channel_id, id, deleted_at) VALUES (?, ?, ?, ?)`,
+				record.ChannelType, record.ChannelId, record.Message.Id, record.DeletedAt)
+		} else {
+			_, err = tx.Exec(`DELETE FROM tombstones WHERE channel_type = ? AND channel_id = ? AND id = ?`,
+				record.ChannelType, record.ChannelId, record.Message.Id)
+		}
+		return err
+	})
+}
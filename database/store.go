@@ -0,0 +1,268 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WindowsSov8forUs/glyccat/config"
+	"github.com/WindowsSov8forUs/glyccat/log"
+	"github.com/satori-protocol-go/satori-model-go/pkg/message"
+)
+
+// MessageStore 消息存储后端
+type MessageStore interface {
+	// Name 存储后端名称
+	Name() string
+	// Save 保存消息，已存在的消息会被覆盖，已被删除的消息返回 ErrMessageDeleted
+	Save(data *message.Message, channelId, channelType string) error
+	// Delete 将消息标记为已删除，消息尚未保存时同样会记录删除
+	Delete(channelId, channelType, messageId string) error
+	// Get 获取消息
+	Get(channelId, channelType, messageId string) (*message.Message, error)
+	// List 以消息 ID 为分页令牌获取消息列表
+	List(channelId, channelType, next string, direction QueryDirection, limit int) (*MessagePage, error)
+	// Search 搜索消息
+	Search(query *SearchQuery) (*SearchResult, error)
+	// Prune 按照保留策略清理消息
+	Prune() (PruneResult, error)
+	// PurgeChannel 删除频道的全部消息与删除记录
+	PurgeChannel(channelId string) (int, error)
+	// PurgeUser 删除用户发送的全部消息，channelId 不为空时只删除该频道中的消息
+	PurgeUser(userId, channelId string) (int, error)
+	// Each 按频道分组、时间升序遍历全部消息
+	Each(fn func(record *ExportRecord) error) error
+	// Restore 恢复一条导出的消息记录，包括其删除状态
+	Restore(record *ExportRecord) error
+	// Close 关闭存储
+	Close() error
+}
+
+var (
+	store      MessageStore = noopStore{} // 当前使用的消息存储，未启用消息数据库时为 noopStore
+	storeMutex sync.RWMutex
+	pruner     *prunerHandle // 正在运行的定期清理，未启动时为 nil
+)
+
+// getStore 获取当前使用的消息存储
+func getStore() MessageStore {
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+	return store
+}
+
+// storeOptions 各存储后端共用的配置
+type storeOptions struct {
+	limit     int
+	retention RetentionPolicy
+	excluded  map[string]struct{}
+}
+
+// newStoreOptions 根据配置创建存储配置
+func newStoreOptions(conf config.MessageDatabase) storeOptions {
+	excluded := make(map[string]struct{}, len(conf.ExcludedChannels))
+	for _, channelId := range conf.ExcludedChannels {
+		excluded[channelId] = struct{}{}
+	}
+	return storeOptions{
+		limit:     conf.Limit,
+		retention: newRetentionPolicy(conf.Retention),
+		excluded:  excluded,
+	}
+}
+
+// isExcluded 判断频道是否不存储消息
+func (o *storeOptions) isExcluded(channelId string) bool {
+	_, ok := o.excluded[channelId]
+	return ok
+}
+
+// capLimit 将请求的数量限制在配置的上限内
+func (o *storeOptions) capLimit(limit int) int {
+	if o.limit > 0 && (limit <= 0 || limit > o.limit) {
+		return o.limit
+	}
+	return limit
+}
+
+// newMessageStore 根据配置创建消息存储后端
+func newMessageStore(conf *config.Config) (MessageStore, error) {
+	dbConf := conf.Database.MessageDatabase
+	options := newStoreOptions(dbConf)
+	switch strings.ToLower(dbConf.Type) {
+	case "", "leveldb":
+		return openLevelDBStore(messageDBPath, options)
+	case "sqlite":
+		return openSQLiteStore(sqliteDBPath, options)
+	}
+	return nil, fmt.Errorf("未知的消息数据库类型: %s", dbConf.Type)
+}
+
+// StartMessageDB 启动消息数据库
+func StartMessageDB(conf *config.Config) error {
+	s, err := newMessageStore(conf)
+	if err != nil {
+		return err
+	}
+	useMessageStore(s, newRetentionPolicy(conf.Database.MessageDatabase.Retention).Interval)
+	log.Infof("消息数据库已启动，存储类型: %s", s.Name())
+	return nil
+}
+
+// OpenMessageDB 打开消息数据库用于导入导出，不会启动定期清理
+func OpenMessageDB(conf *config.Config) error {
+	s, err := newMessageStore(conf)
+	if err != nil {
+		return err
+	}
+	useMessageStore(s, 0)
+	return nil
+}
+
+// useMessageStore 切换当前使用的消息存储，interval 大于 0 时启动定期清理
+func useMessageStore(s MessageStore, interval time.Duration) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+	stopPruner()
+	store = s
+	if interval > 0 {
+		pruner = startPruner(s, interval)
+	}
+}
+
+// CloseMessageDB 关闭消息数据库，会等待正在进行的清理完成后再关闭
+func CloseMessageDB() error {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+	stopPruner()
+	s := store
+	store = noopStore{}
+	return s.Close()
+}
+
+// prunerHandle 定期清理的控制句柄
+type prunerHandle struct {
+	stop chan struct{} // 关闭以停止清理
+	done chan struct{} // 清理协程退出后关闭
+}
+
+// startPruner 启动周期性清理消息的协程
+func startPruner(s MessageStore, interval time.Duration) *prunerHandle {
+	handle := &prunerHandle{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go runPruner(s, interval, handle.stop, handle.done)
+	return handle
+}
+
+// stopPruner 停止正在运行的定期清理并等待其退出，调用时需持有 storeMutex
+func stopPruner() {
+	if pruner == nil {
+		return
+	}
+	close(pruner.stop)
+	<-pruner.done
+	pruner = nil
+}
+
+// runPruner 周期性清理消息，stop 被关闭后停止
+func runPruner(s MessageStore, interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		result, err := s.Prune()
+		if err != nil {
+			log.Errorf("清理消息数据库失败: %v", err)
+		} else if result.Total() > 0 {
+			log.Infof("已清理 %d 条消息，耗时 %s 。", result.Total(), time.Since(start).Round(time.Millisecond))
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SaveMessage 保存消息
+//
+// 已存在的消息会被覆盖，用于记录消息的编辑；已被删除的消息不会再次保存
+func SaveMessage(data *message.Message, channelId, channelType string) error {
+	return getStore().Save(data, channelId, channelType)
+}
+
+// DeleteMessage 将消息标记为已删除
+//
+// 消息内容会被清除，但仍保留在时间索引中，以保证以其为基准的分页令牌继续有效
+func DeleteMessage(channelId, channelType, messageId string) error {
+	return getStore().Delete(channelId, channelType, messageId)
+}
+
+// GetMessage 获取消息
+func GetMessage(channelId, channelType, messageId string) (*message.Message, error) {
+	return getStore().Get(channelId, channelType, messageId)
+}
+
+// GetMessageList 获取消息列表
+//
+// next 为空时获取最新的消息；否则以 next 指定的消息为基准，
+// before 与 after 不包含该消息本身， around 包含该消息本身，已删除的消息不会被返回
+func GetMessageList(channelId, channelType, next string, direction QueryDirection, limit int) (*MessagePage, error) {
+	return getStore().List(channelId, channelType, next, direction, limit)
+}
+
+// SearchMessages 搜索消息
+func SearchMessages(query *SearchQuery) (*SearchResult, error) {
+	return getStore().Search(query)
+}
+
+// Prune 立即按照保留策略清理消息
+func Prune() (PruneResult, error) {
+	return getStore().Prune()
+}
+
+// PurgeChannel 删除频道的全部消息，返回删除的消息数
+func PurgeChannel(channelId string) (int, error) {
+	return getStore().PurgeChannel(channelId)
+}
+
+// PurgeUser 删除用户发送的全部消息，channelId 不为空时只删除该频道中的消息，返回删除的消息数
+func PurgeUser(userId, channelId string) (int, error) {
+	return getStore().PurgeUser(userId, channelId)
+}
+
+// noopStore 未启用消息数据库时使用的存储，所有操作都返回 ErrMessageDBDisabled
+type noopStore struct{}
+
+func (noopStore) Name() string { return "disabled" }
+
+func (noopStore) Save(*message.Message, string, string) error { return ErrMessageDBDisabled }
+
+func (noopStore) Delete(string, string, string) error { return ErrMessageDBDisabled }
+
+func (noopStore) Get(string, string, string) (*message.Message, error) {
+	return nil, ErrMessageDBDisabled
+}
+
+func (noopStore) List(string, string, string, QueryDirection, int) (*MessagePage, error) {
+	return nil, ErrMessageDBDisabled
+}
+
+func (noopStore) Search(*SearchQuery) (*SearchResult, error) { return nil, ErrMessageDBDisabled }
+
+func (noopStore) Prune() (PruneResult, error) { return PruneResult{}, ErrMessageDBDisabled }
+
+func (noopStore) PurgeChannel(string) (int, error) { return 0, ErrMessageDBDisabled }
+
+func (noopStore) PurgeUser(string, string) (int, error) { return 0, ErrMessageDBDisabled }
+
+func (noopStore) Each(func(*ExportRecord) error) error { return ErrMessageDBDisabled }
+
+func (noopStore) Restore(*ExportRecord) error { return ErrMessageDBDisabled }
+
+func (noopStore) Close() error { return nil }
@@ -0,0 +1,493 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/satori-protocol-go/satori-model-go/pkg/message"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// testBackends 参与共用测试的存储后端
+var testBackends = []string{"leveldb", "sqlite"}
+
+// openTestStore 在临时目录中打开指定类型的消息存储
+//
+// 未启用 cgo 时 SQLite 存储无法使用，相关测试会被跳过
+func openTestStore(t *testing.T, backend string, options storeOptions) MessageStore {
+	t.Helper()
+	var s MessageStore
+	var err error
+	switch backend {
+	case "leveldb":
+		s, err = openLevelDBStore(filepath.Join(t.TempDir(), "messages"), options)
+	case "sqlite":
+		s, err = openSQLiteStore(filepath.Join(t.TempDir(), "messages.db"), options)
+		if err != nil {
+			t.Skipf("SQLite is not available: %v", err)
+		}
+	default:
+		t.Fatalf("unknown backend %s", backend)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// saveTestMessages 保存一个频道中按时间排列的多条消息，ID 为 1 到 count
+func saveTestMessages(t *testing.T, s MessageStore, channelId string, count int) {
+	t.Helper()
+	for i := 1; i <= count; i++ {
+		m := testMessage(strconv.Itoa(i), "alice", fmt.Sprintf("消息 %d", i), int64(i)*1000)
+		if err := s.Save(m, channelId, ChannelTypeGroup); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStoreSaveGetDelete(t *testing.T) {
+	for _, backend := range testBackends {
+		t.Run(backend, func(t *testing.T) {
+			s := openTestStore(t, backend, storeOptions{excluded: map[string]struct{}{"excluded": {}}})
+			if s.Name() != backend {
+				t.Errorf("Name() = %s, want %s", s.Name(), backend)
+			}
+
+			if err := s.Save(testMessage("1", "alice", "hello", 1000), "g1", ChannelTypeGroup); err != nil {
+				t.Fatal(err)
+			}
+			// 编辑后的消息覆盖原消息
+			if err := s.Save(testMessage("1", "alice", "edited", 1000), "g1", ChannelTypeGroup); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.Get("g1", ChannelTypeGroup, "1")
+			if err != nil || got.Content != "edited" || got.User.Id != "alice" || got.CreateAt != 1000 {
+				t.Fatalf("Get() = %+v, %v, want edited message", got, err)
+			}
+
+			// 相同 ID 在不同频道类型中互不影响
+			if _, err := s.Get("g1", ChannelTypePrivate, "1"); !errors.Is(err, ErrMessageNotFound) {
+				t.Errorf("Get() other channel type error = %v, want %v", err, ErrMessageNotFound)
+			}
+
+			if err := s.Delete("g1", ChannelTypeGroup, "1"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Get("g1", ChannelTypeGroup, "1"); !errors.Is(err, ErrMessageDeleted) {
+				t.Errorf("Get() deleted message error = %v, want %v", err, ErrMessageDeleted)
+			}
+			if err := s.Save(testMessage("1", "alice", "again", 1000), "g1", ChannelTypeGroup); !errors.Is(err, ErrMessageDeleted) {
+				t.Errorf("Save() deleted message error = %v, want %v", err, ErrMessageDeleted)
+			}
+
+			// 删除事件先于消息到达
+			if err := s.Delete("g1", ChannelTypeGroup, "2"); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Save(testMessage("2", "alice", "late", 2000), "g1", ChannelTypeGroup); !errors.Is(err, ErrMessageDeleted) {
+				t.Errorf("Save() after early delete error = %v, want %v", err, ErrMessageDeleted)
+			}
+
+			// 排除的频道不存储消息
+			if err := s.Save(testMessage("3", "alice", "hidden", 3000), "excluded", ChannelTypeGroup); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Get("excluded", ChannelTypeGroup, "3"); !errors.Is(err, ErrMessageNotFound) {
+				t.Errorf("Get() excluded channel error = %v, want %v", err, ErrMessageNotFound)
+			}
+		})
+	}
+}
+
+func TestStoreList(t *testing.T) {
+	for _, backend := range testBackends {
+		t.Run(backend, func(t *testing.T) {
+			s := openTestStore(t, backend, storeOptions{limit: 3})
+			saveTestMessages(t, s, "g1", 7)
+			if err := s.Delete("g1", ChannelTypeGroup, "4"); err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				name      string
+				next      string
+				direction QueryDirection
+				limit     int
+				want      []string
+				prev      string
+				after     string
+			}{
+				{"latest", "", QueryDirectionBefore, 10, []string{"5", "6", "7"}, "5", ""},
+				{"before", "5", QueryDirectionBefore, 2, []string{"2", "3"}, "2", "3"},
+				{"before oldest", "2", QueryDirectionBefore, 2, []string{"1"}, "", "1"},
+				{"after", "2", QueryDirectionAfter, 2, []string{"3", "5"}, "3", "5"},
+				{"after newest", "6", QueryDirectionAfter, 2, []string{"7"}, "7", ""},
+				{"around", "5", QueryDirectionAround, 3, []string{"3", "5", "6"}, "3", "6"},
+			}
+			for _, tt := range tests {
+				page, err := s.List("g1", ChannelTypeGroup, tt.next, tt.direction, tt.limit)
+				if err != nil {
+					t.Errorf("%s: List() error = %v", tt.name, err)
+					continue
+				}
+				if got := messageIds(page.Data); !slices.Equal(got, tt.want) {
+					t.Errorf("%s: List() = %v, want %v", tt.name, got, tt.want)
+				}
+				if page.Prev != tt.prev || page.Next != tt.after {
+					t.Errorf("%s: List() prev, next = %q, %q, want %q, %q", tt.name, page.Prev, page.Next, tt.prev, tt.after)
+				}
+			}
+
+			if _, err := s.List("g1", ChannelTypeGroup, "missing", QueryDirectionBefore, 3); !errors.Is(err, ErrMessageNotFound) {
+				t.Errorf("List() unknown cursor error = %v, want %v", err, ErrMessageNotFound)
+			}
+		})
+	}
+}
+
+func TestStoreSearch(t *testing.T) {
+	for _, backend := range testBackends {
+		t.Run(backend, func(t *testing.T) {
+			s := openTestStore(t, backend, storeOptions{limit: 2})
+			saveTestMessages(t, s, "g1", 5)
+			if err := s.Save(testMessage("6", "bob", "今天天气很好", 6000), "g2", ChannelTypeGroup); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Delete("g1", ChannelTypeGroup, "3"); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := s.Search(&SearchQuery{Keyword: "天气", Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := messageIds(result.Data); !slices.Equal(got, []string{"6"}) {
+				t.Errorf("Search() = %v, want [6]", got)
+			}
+
+			// 分页令牌在各存储后端间格式相同
+			var pages [][]string
+			query := SearchQuery{Keyword: "消息", ChannelId: "g1", ChannelType: ChannelTypeGroup}
+			for i := 0; i < 5; i++ {
+				result, err := s.Search(&query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pages = append(pages, messageIds(result.Data))
+				if result.Next == "" {
+					break
+				}
+				query.Next = result.Next
+			}
+			want := [][]string{{"5", "4"}, {"2", "1"}}
+			if fmt.Sprint(pages) != fmt.Sprint(want) {
+				t.Errorf("Search() pages = %v, want %v", pages, want)
+			}
+
+			query.Next = "not a cursor"
+			if _, err := s.Search(&query); !errors.Is(err, ErrInvalidSearchCursor) {
+				t.Errorf("Search() invalid cursor error = %v, want %v", err, ErrInvalidSearchCursor)
+			}
+		})
+	}
+}
+
+func TestStorePurge(t *testing.T) {
+	for _, backend := range testBackends {
+		t.Run(backend, func(t *testing.T) {
+			s := openTestStore(t, backend, storeOptions{})
+			saveTestMessages(t, s, "g1", 3)
+			saveTestMessages(t, s, "g2", 2)
+			if err := s.Save(testMessage("9", "bob", "bob", 9000), "g1", ChannelTypeGroup); err != nil {
+				t.Fatal(err)
+			}
+
+			if n, err := s.PurgeUser("bob", ""); err != nil || n != 1 {
+				t.Errorf("PurgeUser() = %d, %v, want 1", n, err)
+			}
+			if n, err := s.PurgeUser("alice", "g2"); err != nil || n != 2 {
+				t.Errorf("PurgeUser() in channel = %d, %v, want 2", n, err)
+			}
+			if n, err := s.PurgeChannel("g1"); err != nil || n != 3 {
+				t.Errorf("PurgeChannel() = %d, %v, want 3", n, err)
+			}
+			for _, channelId := range []string{"g1", "g2"} {
+				page, err := s.List(channelId, ChannelTypeGroup, "", QueryDirectionBefore, 10)
+				if err != nil || len(page.Data) != 0 {
+					t.Errorf("List(%s) after purge = %v, %v, want empty", channelId, messageIds(page.Data), err)
+				}
+			}
+		})
+	}
+}
+
+// collectRecords 获取存储中的全部导出记录
+func collectRecords(t *testing.T, s MessageStore) []*ExportRecord {
+	t.Helper()
+	var records []*ExportRecord
+	if err := s.Each(func(record *ExportRecord) error {
+		records = append(records, record)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestStoreTransfer(t *testing.T) {
+	// 在各存储后端之间导出与恢复消息
+	for _, from := range testBackends {
+		for _, to := range testBackends {
+			t.Run(from+" to "+to, func(t *testing.T) {
+				src := openTestStore(t, from, storeOptions{})
+				dst := openTestStore(t, to, storeOptions{})
+				saveTestMessages(t, src, "g1", 3)
+				saveTestMessages(t, src, "g2", 2)
+				if err := src.Delete("g1", ChannelTypeGroup, "2"); err != nil {
+					t.Fatal(err)
+				}
+
+				records := collectRecords(t, src)
+				if len(records) != 5 {
+					t.Fatalf("Each() = %d records, want 5", len(records))
+				}
+				for _, record := range records {
+					if err := dst.Restore(record); err != nil {
+						t.Fatalf("Restore() error = %v", err)
+					}
+				}
+
+				if _, err := dst.Get("g1", ChannelTypeGroup, "2"); !errors.Is(err, ErrMessageDeleted) {
+					t.Errorf("Get() restored deleted message error = %v, want %v", err, ErrMessageDeleted)
+				}
+				got, err := dst.Get("g2", ChannelTypeGroup, "2")
+				if err != nil || got.Content != "消息 2" || got.CreateAt != 2000 {
+					t.Errorf("Get() restored message = %+v, %v", got, err)
+				}
+				restored := collectRecords(t, dst)
+				if len(restored) != len(records) {
+					t.Errorf("Each() after restore = %d records, want %d", len(restored), len(records))
+				}
+			})
+		}
+	}
+}
+
+func TestNoopStore(t *testing.T) {
+	var s MessageStore = noopStore{}
+	errs := []error{
+		s.Save(testMessage("1", "alice", "hello", 1000), "g1", ChannelTypeGroup),
+		s.Delete("g1", ChannelTypeGroup, "1"),
+		s.Each(func(*ExportRecord) error { return nil }),
+		s.Restore(&ExportRecord{}),
+	}
+	_, err := s.Get("g1", ChannelTypeGroup, "1")
+	errs = append(errs, err)
+	_, err = s.List("g1", ChannelTypeGroup, "", QueryDirectionBefore, 10)
+	errs = append(errs, err)
+	_, err = s.Search(&SearchQuery{Limit: 10})
+	errs = append(errs, err)
+	_, err = s.Prune()
+	errs = append(errs, err)
+	_, err = s.PurgeChannel("g1")
+	errs = append(errs, err)
+	_, err = s.PurgeUser("alice", "")
+	errs = append(errs, err)
+	for i, err := range errs {
+		if !errors.Is(err, ErrMessageDBDisabled) {
+			t.Errorf("operation %d error = %v, want %v", i, err, ErrMessageDBDisabled)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+// gobMessage 以旧版本的 gob 格式编码消息
+func gobMessage(t *testing.T, m *message.Message) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(m); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMigrateLevelDB(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		prepare func(t *testing.T, db *leveldb.DB)
+	}{
+		{"version 1 legacy keys", "", func(t *testing.T, db *leveldb.DB) {
+			db.Put([]byte("group:g1:1"), gobMessage(t, testMessage("1", "alice", "今天天气很好", 1000)), nil)
+			db.Put([]byte("group:g1:2"), gobMessage(t, testMessage("2", "alice", "hello world", 2000)), nil)
+			db.Put([]byte("group:g1:broken"), []byte("not gob"), nil)
+		}},
+		{"version 2 gob records", "2", func(t *testing.T, db *leveldb.DB) {
+			for _, m := range []*message.Message{testMessage("1", "alice", "今天天气很好", 1000), testMessage("2", "alice", "hello world", 2000)} {
+				key := messageKey("g1", ChannelTypeGroup, m.CreateAt, m.Id)
+				db.Put(key, gobMessage(t, m), nil)
+				db.Put(idIndexKey("g1", ChannelTypeGroup, m.Id), key, nil)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "messages")
+			db, err := leveldb.OpenFile(path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.prepare(t, db)
+			if tt.version != "" {
+				db.Put(versionKey, []byte(tt.version), nil)
+			}
+			db.Close()
+
+			s, err := openLevelDBStore(path, storeOptions{})
+			if err != nil {
+				t.Fatalf("openLevelDBStore() error = %v", err)
+			}
+			defer s.Close()
+
+			if version, err := s.db.Get(versionKey, nil); err != nil || string(version) != strconv.Itoa(messageDBVersion) {
+				t.Errorf("version = %s, %v, want %d", version, err, messageDBVersion)
+			}
+			page, err := s.List("g1", ChannelTypeGroup, "", QueryDirectionBefore, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := messageIds(page.Data); !slices.Equal(got, []string{"1", "2"}) {
+				t.Errorf("List() after migration = %v, want [1 2]", got)
+			}
+			result, err := s.Search(&SearchQuery{Keyword: "天气", Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := messageIds(result.Data); !slices.Equal(got, []string{"1"}) {
+				t.Errorf("Search() after migration = %v, want [1]", got)
+			}
+		})
+	}
+
+	// 高于当前支持的版本时拒绝打开
+	path := filepath.Join(t.TempDir(), "messages")
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Put(versionKey, []byte(strconv.Itoa(messageDBVersion+1)), nil)
+	db.Close()
+	if s, err := openLevelDBStore(path, storeOptions{}); err == nil {
+		s.Close()
+		t.Error("openLevelDBStore() with newer version error = nil, want error")
+	}
+}
+
+func TestMigrateSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.db")
+	s, err := openSQLiteStore(path, storeOptions{})
+	if err != nil {
+		t.Skipf("SQLite is not available: %v", err)
+	}
+	if err := s.Save(testMessage("1", "alice", "hello", 1000), "g1", ChannelTypeGroup); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// 重新打开已有的数据库时保留数据
+	s, err = openSQLiteStore(path, storeOptions{})
+	if err != nil {
+		t.Fatalf("openSQLiteStore() error = %v", err)
+	}
+	if _, err := s.Get("g1", ChannelTypeGroup, "1"); err != nil {
+		t.Errorf("Get() after reopen error = %v", err)
+	}
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil || version != sqliteSchemaVersion {
+		t.Errorf("user_version = %d, %v, want %d", version, err, sqliteSchemaVersion)
+	}
+	s.Close()
+
+	// 高于当前支持的版本时拒绝打开
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", sqliteSchemaVersion+1)); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if s, err := openSQLiteStore(path, storeOptions{}); err == nil {
+		s.Close()
+		t.Error("openSQLiteStore() with newer version error = nil, want error")
+	}
+}
+
+// closeCheckStore 记录关闭后是否仍被清理的消息存储
+type closeCheckStore struct {
+	MessageStore
+	closed atomic.Bool
+	misuse atomic.Bool
+}
+
+func (s *closeCheckStore) Prune() (PruneResult, error) {
+	if s.closed.Load() {
+		s.misuse.Store(true)
+	}
+	return s.MessageStore.Prune()
+}
+
+func (s *closeCheckStore) Close() error {
+	s.closed.Store(true)
+	return s.MessageStore.Close()
+}
+
+func TestMessageDBOpenClose(t *testing.T) {
+	t.Cleanup(func() { _ = CloseMessageDB() })
+
+	for i := 0; i < 20; i++ {
+		s := &closeCheckStore{MessageStore: openTestStore(t, "leveldb", storeOptions{})}
+		useMessageStore(s, time.Millisecond)
+
+		// 在清理进行时并发读写
+		var wg sync.WaitGroup
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				m := testMessage(strconv.Itoa(j), "alice", "消息", int64(j)*1000)
+				_ = SaveMessage(m, "channel", ChannelTypeGroup)
+				_, _ = GetMessage("channel", ChannelTypeGroup, strconv.Itoa(j))
+				_, _ = Prune()
+			}(j)
+		}
+		time.Sleep(2 * time.Millisecond)
+		wg.Wait()
+
+		if err := CloseMessageDB(); err != nil {
+			t.Fatalf("CloseMessageDB() error = %v", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+		if s.misuse.Load() {
+			t.Fatal("pruner ran against a closed store")
+		}
+	}
+
+	if _, err := GetMessage("channel", ChannelTypeGroup, "1"); !errors.Is(err, ErrMessageDBDisabled) {
+		t.Fatalf("GetMessage() after close error = %v, want %v", err, ErrMessageDBDisabled)
+	}
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/satori-protocol-go/satori-model-go/pkg/message"
+)
+
+// ExportRecord 导出文件中的一条消息记录，每行一条
+type ExportRecord struct {
+	Version     int              `json:"v"`                    // 记录格式版本
+	ChannelType string           `json:"channel_type"`         // 频道类型
+	ChannelId   string           `json:"channel_id"`           // 频道 ID
+	Message     *message.Message `json:"message"`              // 消息
+	DeletedAt   int64            `json:"deleted_at,omitempty"` // 删除时间，毫秒时间戳，未删除时为 0
+}
+
+// exportFileName 将频道 ID 转换为可用作文件名的形式
+func exportFileName(channelId string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(channelId) + ".jsonl"
+}
+
+// ExportMessages 将全部消息导出到目录中，每个频道一个 JSON Lines 文件，
+// 路径为 目录/频道类型/频道 ID.jsonl ，返回导出的消息数
+func ExportMessages(dir string) (int, error) {
+	var (
+		count   int
+		channel string
+		file    *os.File
+		writer  *bufio.Writer
+	)
+	closeFile := func() error {
+		if file == nil {
+			return nil
+		}
+		err := writer.Flush()
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		file = nil
+		return err
+	}
+
+	err := getStore().Each(func(record *ExportRecord) error {
+		if current := record.ChannelType + ":" + record.ChannelId; current != channel {
+			if err := closeFile(); err != nil {
+				return err
+			}
+			channel = current
+			path := filepath.Join(dir, record.ChannelType, exportFileName(record.ChannelId))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			var err error
+			if file, err = os.Create(path); err != nil {
+				return err
+			}
+			writer = bufio.NewWriter(file)
+		}
+
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		writer.Write(line)
+		if err := writer.WriteByte('\n'); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		closeFile()
+		return count, err
+	}
+	return count, closeFile()
+}
+
+// ImportMessages 从 JSON Lines 文件或包含此类文件的目录中导入消息，
+// 已存在的同一消息会被覆盖，返回导入的消息数
+func ImportMessages(path string) (int, error) {
+	// 未启用消息数据库时直接返回，避免读取文件
+	if _, ok := getStore().(noopStore); ok {
+		return 0, ErrMessageDBDisabled
+	}
+
+	var count int
+	err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || (file != path && filepath.Ext(file) != ".jsonl") {
+			return nil
+		}
+		n, err := importFile(file)
+		count += n
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		return nil
+	})
+	return count, err
+}
+
+// importFile 导入单个 JSON Lines 文件
+func importFile(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var count, line int
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var record ExportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return count, fmt.Errorf("line %d: %w", line, err)
+		}
+		if record.Version != recordVersion {
+			return count, fmt.Errorf("line %d: unsupported message record version: %d", line, record.Version)
+		}
+		if record.Message == nil || record.Message.Id == "" || record.ChannelId == "" || record.ChannelType == "" {
+			return count, fmt.Errorf("line %d: incomplete message record", line)
+		}
+		if err := getStore().Restore(&record); err != nil {
+			return count, fmt.Errorf("line %d: %w", line, err)
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, err
+	}
+	return count, nil
+}
@@ -0,0 +1,271 @@
+package fileserver
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/WindowsSov8forUs/glyccat/log"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	expiryDatabasePath = "data/files/.expiry"
+	defaultSweepPeriod = time.Minute // 默认过期清理间隔为 1 分钟
+	expiryIndexVersion = 1           // 过期索引格式版本，变化时重建索引
+)
+
+// 过期索引的键前缀，键的格式为 前缀 + 8 字节大端序过期时间戳 + 标识符
+var (
+	expiryFilePrefix     = []byte("f/")
+	expiryFileInfoPrefix = []byte("i/")
+	expiryStateKey       = []byte("_state")
+)
+
+// expiryState 过期索引状态，用于判断是否需要重建索引
+type expiryState struct {
+	Version int `json:"version"` // 建立索引时的索引格式版本
+}
+
+// SweepStats 过期清理统计信息
+type SweepStats struct {
+	Files            int       `json:"files"`              // 带有有效期的文件数
+	FileInfos        int       `json:"file_infos"`         // 带有有效期的文件信息数
+	ExpiredFiles     uint64    `json:"expired_files"`      // 已清理的过期文件数
+	ExpiredFileInfos uint64    `json:"expired_file_infos"` // 已清理的过期文件信息数
+	Sweeps           uint64    `json:"sweeps"`             // 清理次数
+	Interval         float64   `json:"interval"`           // 清理间隔，单位秒
+	LastSweep        time.Time `json:"last_sweep"`         // 上次清理时间
+	LastDuration     float64   `json:"last_duration"`      // 上次清理耗时，单位秒
+}
+
+// ExpiryIndex 按过期时间排序的索引数据库
+type ExpiryIndex struct {
+	DB       *leveldb.DB
+	interval time.Duration
+	mu       sync.Mutex
+	stats    SweepStats
+}
+
+// StartExpiryIndex 启动过期索引数据库
+func StartExpiryIndex(interval time.Duration) (*ExpiryIndex, error) {
+	// 创建或打开过期索引数据库
+	db, err := leveldb.OpenFile(expiryDatabasePath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if interval <= 0 {
+		interval = defaultSweepPeriod
+	}
+
+	return &ExpiryIndex{
+		DB:       db,
+		interval: interval,
+	}, nil
+}
+
+// expiryKey 生成过期索引键
+func expiryKey(prefix []byte, expireAt uint64, ident string) []byte {
+	key := make([]byte, 0, len(prefix)+8+len(ident))
+	key = append(key, prefix...)
+	key = binary.BigEndian.AppendUint64(key, expireAt)
+	return append(key, ident...)
+}
+
+// Add 添加过期索引，有效期为 0 时不添加
+func (idx *ExpiryIndex) Add(prefix []byte, ident string, createAt, ttl uint64) error {
+	if ttl == 0 {
+		return nil
+	}
+	return idx.DB.Put(expiryKey(prefix, createAt+ttl, ident), nil, nil)
+}
+
+// Remove 删除过期索引
+func (idx *ExpiryIndex) Remove(prefix []byte, ident string, createAt, ttl uint64) error {
+	if ttl == 0 {
+		return nil
+	}
+	return idx.DB.Delete(expiryKey(prefix, createAt+ttl, ident), nil)
+}
+
+// expired 获取截至指定时间已过期的标识符
+func (idx *ExpiryIndex) expired(prefix []byte, now uint64) ([]string, [][]byte) {
+	limit := expiryKey(prefix, now+1, "")
+	iter := idx.DB.NewIterator(&util.Range{Start: prefix, Limit: limit}, nil)
+	defer iter.Release()
+
+	var idents []string
+	var keys [][]byte
+	for iter.Next() {
+		key := append([]byte(nil), iter.Key()...)
+		idents = append(idents, string(key[len(prefix)+8:]))
+		keys = append(keys, key)
+	}
+	if err := iter.Error(); err != nil {
+		log.Errorf("读取过期索引失败: %s", err)
+	}
+	return idents, keys
+}
+
+// count 统计指定前缀的索引数量
+func (idx *ExpiryIndex) count(prefix []byte) int {
+	iter := idx.DB.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+
+	count := 0
+	for iter.Next() {
+		count++
+	}
+	return count
+}
+
+// rebuild 根据已保存的文件元数据与文件信息重建过期索引
+//
+// 索引按各条记录自身保存的有效期建立，配置的文件有效期变化只影响之后保存的文件
+func (idx *ExpiryIndex) rebuild(metaDB *MetaDatabase, fileInfoDB *FileInfoDatabase) error {
+	data, err := idx.DB.Get(expiryStateKey, nil)
+	if err == nil {
+		var state expiryState
+		if json.Unmarshal(data, &state) == nil && state.Version == expiryIndexVersion {
+			return nil
+		}
+	}
+
+	log.Info("正在重建文件过期索引...")
+
+	// 清空已有索引
+	batch := new(leveldb.Batch)
+	iter := idx.DB.NewIterator(nil, nil)
+	for iter.Next() {
+		batch.Delete(append([]byte(nil), iter.Key()...))
+	}
+	iter.Release()
+	if err := idx.DB.Write(batch, nil); err != nil {
+		return err
+	}
+
+	metas, err := metaDB.GetFileMetas()
+	if err != nil {
+		return err
+	}
+	for ident, meta := range metas {
+		if err := idx.Add(expiryFilePrefix, ident, meta.CreateAt, meta.TTL); err != nil {
+			return err
+		}
+	}
+
+	infos, err := fileInfoDB.GetFileInfos()
+	if err != nil {
+		return err
+	}
+	for ident, info := range infos {
+		if err := idx.Add(expiryFileInfoPrefix, ident, info.CreateAt, info.TTL); err != nil {
+			return err
+		}
+	}
+
+	data, err = json.Marshal(expiryState{Version: expiryIndexVersion})
+	if err != nil {
+		return err
+	}
+	log.Infof("文件过期索引重建完成，共 %d 个文件， %d 条文件信息。", len(metas), len(infos))
+	return idx.DB.Put(expiryStateKey, data, nil)
+}
+
+// sweep 清理全部过期的文件与文件信息
+//
+// 扫描索引后逐条在 instance.mu 下重新检查当前记录的过期时间，
+// 期间被重新保存而刷新了有效期的文件或文件信息只删除旧的索引
+func (idx *ExpiryIndex) sweep() {
+	start := time.Now()
+	now := uint64(start.Unix())
+
+	var expiredFiles, expiredFileInfos uint64
+	idents, keys := idx.expired(expiryFilePrefix, now)
+	for i, ident := range idents {
+		if idx.sweepFile(ident, keys[i]) {
+			expiredFiles++
+		}
+	}
+
+	idents, keys = idx.expired(expiryFileInfoPrefix, now)
+	for i, ident := range idents {
+		if idx.sweepFileInfo(ident, keys[i]) {
+			expiredFileInfos++
+		}
+	}
+
+	if expiredFiles > 0 || expiredFileInfos > 0 {
+		log.Tracef("已清理 %d 个过期文件， %d 条过期文件信息。", expiredFiles, expiredFileInfos)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.stats.ExpiredFiles += expiredFiles
+	idx.stats.ExpiredFileInfos += expiredFileInfos
+	idx.stats.Sweeps++
+	idx.stats.LastSweep = start
+	idx.stats.LastDuration = time.Since(start).Seconds()
+}
+
+// sweepFile 删除过期的文件与其索引，返回文件是否被删除
+func (idx *ExpiryIndex) sweepFile(ident string, key []byte) bool {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	removed := false
+	if meta, err := instance.MetaDB.GetFileMeta(ident); err != nil || expired(meta.CreateAt, meta.TTL) {
+		if err := removeFile(ident); err != nil {
+			log.Errorf("清理文件失败: %s", err)
+		}
+		removed = true
+	}
+	if err := idx.DB.Delete(key, nil); err != nil {
+		log.Errorf("删除过期索引失败: %s", err)
+	}
+	return removed
+}
+
+// sweepFileInfo 删除过期的文件信息与其索引，返回文件信息是否被删除
+func (idx *ExpiryIndex) sweepFileInfo(ident string, key []byte) bool {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	removed := false
+	if info, err := instance.FileInfoDB.GetFileInfo(ident); err != nil || expired(info.CreateAt, info.TTL) {
+		if err := instance.FileInfoDB.DeleteFileInfo(ident); err != nil {
+			log.Errorf("清理文件信息失败: %s", err)
+		}
+		removed = true
+	}
+	if err := idx.DB.Delete(key, nil); err != nil {
+		log.Errorf("删除过期索引失败: %s", err)
+	}
+	return removed
+}
+
+// run 周期性清理过期数据
+func (idx *ExpiryIndex) run() {
+	idx.sweep()
+
+	ticker := time.NewTicker(idx.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		idx.sweep()
+	}
+}
+
+// Stats 获取过期清理统计信息
+func (idx *ExpiryIndex) Stats() SweepStats {
+	idx.mu.Lock()
+	stats := idx.stats
+	idx.mu.Unlock()
+
+	stats.Files = idx.count(expiryFilePrefix)
+	stats.FileInfos = idx.count(expiryFileInfoPrefix)
+	stats.Interval = idx.interval.Seconds()
+	return stats
+}
@@ -0,0 +1,189 @@
+package fileserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+// backdate 将文件的创建时间提前，并按新的创建时间重建其过期索引
+func backdate(t *testing.T, ident string, age time.Duration) {
+	t.Helper()
+	meta, err := instance.MetaDB.GetFileMeta(ident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := instance.Expiry.Remove(expiryFilePrefix, ident, meta.CreateAt, meta.TTL); err != nil {
+		t.Fatal(err)
+	}
+	meta.CreateAt -= uint64(age.Seconds())
+	if err := instance.MetaDB.SaveFileMeta(ident, meta); err != nil {
+		t.Fatal(err)
+	}
+	if err := instance.Expiry.Add(expiryFilePrefix, ident, meta.CreateAt, meta.TTL); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func saveTestFile(t *testing.T, data string) *FileMetadata {
+	t.Helper()
+	meta, err := SaveFile(bytes.NewReader([]byte(data)), "qq", "bot", "file.txt", "text/plain")
+	if err != nil {
+		t.Fatalf("SaveFile() error = %v", err)
+	}
+	return meta
+}
+
+func TestSweepRemovesExpired(t *testing.T) {
+	fs := startTestFileServer(t, 0, 0)
+
+	stale := saveTestFile(t, "stale")
+	fresh := saveTestFile(t, "fresh")
+	backdate(t, stale.ID, 2*time.Hour)
+
+	if _, err := SaveFileInfo("target", "src", "info", 60); err != nil {
+		t.Fatal(err)
+	}
+	info, err := SaveFileInfo("target", "old", "info", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Expiry.Remove(expiryFileInfoPrefix, info.ID, info.CreateAt, info.TTL); err != nil {
+		t.Fatal(err)
+	}
+	info.CreateAt -= 120
+	if err := fs.Expiry.Add(expiryFileInfoPrefix, info.ID, info.CreateAt, info.TTL); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.FileInfoDB.SaveFileInfo(info.ID, info); err != nil {
+		t.Fatal(err)
+	}
+
+	fs.Expiry.sweep()
+
+	if _, err := fs.MetaDB.GetFileMeta(stale.ID); err == nil || fs.Storage.Exists(stale.ID) {
+		t.Errorf("expired file %s was not removed", stale.ID)
+	}
+	if _, err := GetFile(fresh.ID); err != nil {
+		t.Errorf("GetFile(%s) error = %v", fresh.ID, err)
+	}
+	if _, err := fs.FileInfoDB.GetFileInfo(info.ID); err == nil {
+		t.Errorf("expired file info %s was not removed", info.ID)
+	}
+
+	stats := fs.Expiry.Stats()
+	if stats.ExpiredFiles != 1 || stats.ExpiredFileInfos != 1 || stats.Files != 1 || stats.FileInfos != 1 {
+		t.Errorf("Stats() = %+v, want 1 expired file, 1 expired file info, 1 file and 1 file info left", stats)
+	}
+	if usage := GetUsage(); usage.Files != 1 {
+		t.Errorf("usage files = %d, want 1", usage.Files)
+	}
+}
+
+func TestSweepKeepsRefreshedFile(t *testing.T) {
+	fs := startTestFileServer(t, 0, 0)
+
+	// 清理扫描到旧的过期索引后，文件被重新保存而刷新了有效期
+	meta := saveTestFile(t, "refreshed")
+	staleKey := expiryKey(expiryFilePrefix, meta.CreateAt-uint64(time.Hour.Seconds()), meta.ID)
+	if err := fs.Expiry.DB.Put(staleKey, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	fs.Expiry.sweep()
+
+	if _, err := GetFile(meta.ID); err != nil {
+		t.Fatalf("refreshed file was removed: %v", err)
+	}
+	if ok, _ := fs.Expiry.DB.Has(staleKey, nil); ok {
+		t.Error("stale expiry key was not removed")
+	}
+	if stats := fs.Expiry.Stats(); stats.ExpiredFiles != 0 || stats.Files != 1 {
+		t.Errorf("Stats() = %+v, want no expired files and 1 file left", stats)
+	}
+}
+
+func TestSweepConcurrentRefresh(t *testing.T) {
+	fs := startTestFileServer(t, 0, 0)
+
+	for i := 0; i < 20; i++ {
+		meta := saveTestFile(t, "racy")
+		backdate(t, meta.ID, 2*time.Hour)
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			fs.Expiry.sweep()
+		}()
+		go func() {
+			defer wg.Done()
+			saveTestFile(t, "racy")
+		}()
+		wg.Wait()
+
+		// 无论先后顺序，重新保存后的文件都必须完整可用
+		current, err := GetFile(meta.ID)
+		if err != nil {
+			t.Fatalf("round %d: refreshed file was removed: %v", i, err)
+		}
+		if expired(current.CreateAt, current.TTL) {
+			t.Fatalf("round %d: file was not refreshed", i)
+		}
+		if usage := GetUsage(); usage.Files != 1 || usage.TotalSize != 4 {
+			t.Fatalf("round %d: usage = %d bytes in %d files, want 4 bytes in 1 file", i, usage.TotalSize, usage.Files)
+		}
+	}
+}
+
+func TestRebuildKeepsStoredTTL(t *testing.T) {
+	fs := startTestFileServer(t, 0, 0)
+
+	old := saveTestFile(t, "old")
+
+	// 配置的文件有效期变化后只影响之后保存的文件
+	fs.TTL = 2 * time.Hour
+	updated := saveTestFile(t, "new")
+
+	// 旧版本的索引状态会触发重建
+	data, err := json.Marshal(map[string]uint64{"ttl": 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Expiry.DB.Put(expiryStateKey, data, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Expiry.rebuild(fs.MetaDB, fs.FileInfoDB); err != nil {
+		t.Fatalf("rebuild() error = %v", err)
+	}
+
+	tests := map[string]uint64{old.ID: 3600, updated.ID: 7200}
+	for ident, ttl := range tests {
+		meta, err := fs.MetaDB.GetFileMeta(ident)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.TTL != ttl {
+			t.Errorf("meta %s TTL = %d, want %d", ident, meta.TTL, ttl)
+		}
+		if ok, _ := fs.Expiry.DB.Has(expiryKey(expiryFilePrefix, meta.CreateAt+ttl, ident), nil); !ok {
+			t.Errorf("expiry key for %s was not rebuilt", ident)
+		}
+	}
+	if files := fs.Expiry.count(expiryFilePrefix); files != 2 {
+		t.Errorf("indexed files = %d, want 2", files)
+	}
+
+	// 索引版本一致时不再重建
+	if err := fs.Expiry.DB.Delete(expiryKey(expiryFilePrefix, old.CreateAt+old.TTL, old.ID), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Expiry.rebuild(fs.MetaDB, fs.FileInfoDB); err != nil {
+		t.Fatalf("rebuild() error = %v", err)
+	}
+	if files := fs.Expiry.count(expiryFilePrefix); files != 1 {
+		t.Errorf("indexed files after second rebuild = %d, want 1", files)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"sync"
-	"time"
 
 	"github.com/syndtr/goleveldb/leveldb"
 )
@@ -13,11 +12,10 @@ const fileInfoDatabasePath = "data/files/.fileinfo"
 
 // FileInfo 文件信息
 type FileInfo struct {
-	ID           string      `json:"id"`        // 文件唯一标识 ID
-	FileInfo     string      `json:"file_info"` // 开放平台返回的文件信息
-	CreateAt     uint64      `json:"create_at"` // 文件创建时间戳
-	TTL          uint64      `json:"ttl"`       // 文件有效时间
-	cleanerTimer *time.Timer `json:"-"`         // 清理定时器，用于定时删除过期文件
+	ID       string `json:"id"`        // 文件唯一标识 ID
+	FileInfo string `json:"file_info"` // 开放平台返回的文件信息
+	CreateAt uint64 `json:"create_at"` // 文件创建时间戳
+	TTL      uint64 `json:"ttl"`       // 文件有效时间
 }
 
 // MarshalBinary 序列化文件信息为二进制
@@ -4,16 +4,20 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
-	"path/filepath"
+	"path"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/WindowsSov8forUs/glyccat/config"
 	"github.com/WindowsSov8forUs/glyccat/log"
+	"github.com/syndtr/goleveldb/leveldb"
 )
 
 const (
@@ -22,6 +26,9 @@ const (
 	defaultTTL     = 7 * 24 * time.Hour // 默认文件有效期为 7 天
 )
 
+// ErrFileNotFound 文件不存在或已过期
+var ErrFileNotFound = errors.New("file not found")
+
 // FileServer 文件服务器
 type FileServer struct {
 	// version 协议版本
@@ -34,10 +41,29 @@ type FileServer struct {
 	Enable bool
 	// TTL 默认文件有效期
 	TTL time.Duration
+	// URLTTL 签名链接有效期
+	URLTTL time.Duration
+	// signKey 链接签名密钥
+	signKey []byte
 	// MetaDB 文件元数据数据库
 	MetaDB *MetaDatabase
 	// FileInfoDB 文件信息数据库
 	FileInfoDB *FileInfoDatabase
+	// Expiry 过期索引数据库
+	Expiry *ExpiryIndex
+	// Storage 文件存储后端
+	Storage Storage
+	// MaxFileSize 单个文件大小上限，为 0 时不限制
+	MaxFileSize int64
+	// MaxTotalSize 存储空间上限，为 0 时不限制
+	MaxTotalSize int64
+
+	// mu 保护文件元数据、文件信息与过期索引的修改，使过期清理与重新保存互斥
+	mu sync.Mutex
+
+	usageMu   sync.Mutex
+	usedSize  int64
+	fileCount int
 }
 
 var instance *FileServer
@@ -73,76 +99,80 @@ func StartFileServer(conf *config.Config) {
 		return
 	}
 
-	instance = &FileServer{
-		version:    fmt.Sprintf("v%d", conf.Satori.Version),
-		path:       conf.Satori.Path,
-		URL:        publicURL,
-		TTL:        time.Duration(conf.FileServer.TTL) * time.Second,
-		Enable:     conf.FileServer.Enable,
-		MetaDB:     metaDB,
-		FileInfoDB: fileInfoDB,
+	// 启动过期索引数据库
+	expiry, err := StartExpiryIndex(time.Duration(conf.FileServer.SweepInterval) * time.Second)
+	if err != nil {
+		log.Errorf("启动文件过期索引失败: %s", err)
+		instance = nil
+		return
 	}
-
-	// 清理过期文件
-	metaDBCleanup()
-	fileInfoDBCleanup()
-
-	// 确保文件服务器对公网开放
-	if instance.URL == "" {
-		log.Warn("文件服务器未配置公网地址，可能导致无法向 QQ 开放平台上传文件。")
-		instance.Enable = false
-		instance.URL = fmt.Sprintf("127.0.0.1:%d", conf.Satori.Server.Port)
+	if err := expiry.rebuild(metaDB, fileInfoDB); err != nil {
+		log.Errorf("重建文件过期索引失败: %s", err)
 	}
 
-	if instance.Enable {
-		log.Infof("文件服务器已启动，公网 IP : %s", instance.URL)
-	}
-}
-
-// metaDBCleanup 文件元数据数据库清理
-func metaDBCleanup() {
-	if instance == nil || !instance.Enable {
+	// 创建文件存储后端
+	storage, err := newStorage(conf)
+	if err != nil {
+		log.Errorf("创建文件存储失败: %s", err)
+		instance = nil
 		return
 	}
 
-	log.Trace("正在清理文件数据库...")
-
-	// 获取所有文件元数据
-	files, err := instance.MetaDB.GetFileMetas()
+	// 加载链接签名密钥
+	signKey, err := loadSignKey(conf.FileServer.SignKey)
 	if err != nil {
-		log.Tracef("获取文件元数据失败: %s", err)
+		log.Errorf("加载文件链接签名密钥失败: %s", err)
+		instance = nil
 		return
 	}
-
-	for _, meta := range files {
-		// 启动文件清理定时器
-		fileCleaner(meta)
+	urlTTL := time.Duration(conf.FileServer.URLTTL) * time.Second
+	if urlTTL <= 0 {
+		urlTTL = defaultURLTTL
 	}
 
-	log.Trace("文件数据库清理完成。")
-}
+	instance = &FileServer{
+		version:    fmt.Sprintf("v%d", conf.Satori.Version),
+		path:       conf.Satori.Path,
+		URL:        publicURL,
+		TTL:        time.Duration(conf.FileServer.TTL) * time.Second,
+		Enable:     conf.FileServer.Enable,
+		MetaDB:     metaDB,
+		FileInfoDB: fileInfoDB,
+		Expiry:     expiry,
+		URLTTL:     urlTTL,
+		signKey:    signKey,
+		Storage:    storage,
 
-// fileInfoDBCleanup 文件信息数据库清理
-func fileInfoDBCleanup() {
-	if instance == nil || !instance.Enable {
-		return
+		MaxFileSize:  int64(conf.FileServer.MaxFileSize) * 1024 * 1024,
+		MaxTotalSize: int64(conf.FileServer.MaxTotalSize) * 1024 * 1024,
 	}
 
-	log.Trace("正在清理文件信息数据库...")
-
-	// 获取所有文件信息
-	infos, err := instance.FileInfoDB.GetFileInfos()
-	if err != nil {
-		log.Tracef("获取文件信息失败: %s", err)
-		return
+	// 统计存储用量并清理残留的上传文件
+	if err := instance.loadUsage(); err != nil {
+		log.Errorf("统计文件存储用量失败: %s", err)
 	}
+	cleanupUploads()
 
-	for _, info := range infos {
-		// 启动文件信息清理定时器
-		fileInfoCleaner(info)
+	// 周期性清理过期文件
+	go instance.Expiry.run()
+
+	// 确保文件服务器对公网开放，可以生成直链的存储后端不需要公网地址
+	_, presignErr := storage.Presign("", &FileMetadata{}, time.Minute)
+	if instance.URL == "" {
+		if presignErr != nil {
+			log.Warn("文件服务器未配置公网地址，可能导致无法向 QQ 开放平台上传文件。")
+			instance.Enable = false
+		}
+		instance.URL = fmt.Sprintf("127.0.0.1:%d", conf.Satori.Server.Port)
 	}
 
-	log.Trace("文件信息数据库清理完成。")
+	if instance.Enable {
+		if presignErr == nil {
+			log.Infof("文件服务器已启动，使用 %s 存储直链。", storage.Name())
+		} else {
+			log.Infof("文件服务器已启动，公网 IP : %s ，使用 %s 存储。", instance.URL, storage.Name())
+		}
+	}
 }
 
 // CalculateFileIdent 计算文件标识符
@@ -180,31 +210,46 @@ func SaveFile(file io.Reader, platform, userId, name, fileType string) (*FileMet
 		return nil, fmt.Errorf("文件服务器未启用！")
 	}
 
-	// 确保文件目录存在
-	if err := os.MkdirAll(filePath, 0755); err != nil {
-		log.Errorf("创建文件目录失败: %s", err)
-		return nil, err
-	}
-
-	// 读取文件内容到内存
-	fileContent, err := io.ReadAll(file)
+	// 写入临时文件
+	u, err := receive(file, platform, userId, fileType)
 	if err != nil {
-		log.Errorf("读取文件内容失败: %s", err)
+		log.Errorf("接收文件失败: %s", err)
 		return nil, err
 	}
+	defer u.cleanup()
+	fileName := u.ident
 
-	// 生成文件名
-	fileName, err := CalculateFileIdent(platform, userId, strings.NewReader(string(fileContent)))
-	if err != nil {
-		log.Errorf("计算文件标识符失败: %s", err)
-		return nil, err
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	old, err := instance.MetaDB.GetFileMeta(fileName)
+	if err == nil {
+		// 相同文件重新保存时刷新有效期
+		if err := instance.Expiry.Remove(expiryFilePrefix, fileName, old.CreateAt, old.TTL); err != nil {
+			log.Errorf("删除文件过期索引失败: %s", err)
+		}
+		if !instance.Storage.Exists(fileName) {
+			instance.release(old.Size)
+			old = nil
+		}
+	} else {
+		old = nil
 	}
 
-	// 保存数据
-	path := filepath.Join(filePath, fileName)
-	if err := os.WriteFile(path, fileContent, 0644); err != nil {
-		log.Errorf("保存文件失败: %s", err)
-		return nil, err
+	// 保存数据，相同内容的文件不会重复保存
+	if old == nil {
+		if err := instance.reserve(u.size); err != nil {
+			return nil, err
+		}
+		if err := moveOrSave(instance.Storage, fileName, u); err != nil {
+			instance.release(u.size)
+			log.Errorf("保存文件失败: %s", err)
+			return nil, err
+		}
+	}
+	path := fileName
+	if local, ok := instance.Storage.(*localStorage); ok {
+		path = local.Path(fileName)
 	}
 
 	// 存储文件元数据
@@ -213,7 +258,8 @@ func SaveFile(file io.Reader, platform, userId, name, fileType string) (*FileMet
 		Name:        name,
 		URL:         fmt.Sprintf(internalFormat, platform, userId, fileName),
 		Path:        path,
-		ContentType: fileType,
+		ContentType: u.contentType,
+		Size:        u.size,
 		CreateAt:    uint64(time.Now().Unix()),
 		TTL:         uint64(instance.TTL.Seconds()),
 	}
@@ -221,8 +267,10 @@ func SaveFile(file io.Reader, platform, userId, name, fileType string) (*FileMet
 		log.Errorf("保存文件元数据失败: %s", err)
 	}
 
-	// 启动文件清理定时器
-	meta.cleanerTimer = fileCleaner(meta)
+	// 添加过期索引
+	if err := instance.Expiry.Add(expiryFilePrefix, fileName, meta.CreateAt, meta.TTL); err != nil {
+		log.Errorf("保存文件过期索引失败: %s", err)
+	}
 
 	return meta, nil
 }
@@ -240,6 +288,16 @@ func SaveFileInfo(targetId, src, fileInfo string, ttl uint64) (*FileInfo, error)
 		return nil, err
 	}
 
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	// 相同文件信息重新保存时刷新有效期
+	if old, err := instance.FileInfoDB.GetFileInfo(ident); err == nil {
+		if err := instance.Expiry.Remove(expiryFileInfoPrefix, ident, old.CreateAt, old.TTL); err != nil {
+			log.Errorf("删除文件信息过期索引失败: %s", err)
+		}
+	}
+
 	// 存储文件信息
 	info := &FileInfo{
 		ID:       ident,
@@ -252,8 +310,10 @@ func SaveFileInfo(targetId, src, fileInfo string, ttl uint64) (*FileInfo, error)
 		return nil, err
 	}
 
-	// 启动文件信息清理定时器
-	info.cleanerTimer = fileInfoCleaner(info)
+	// 添加过期索引
+	if err := instance.Expiry.Add(expiryFileInfoPrefix, ident, info.CreateAt, info.TTL); err != nil {
+		log.Errorf("保存文件信息过期索引失败: %s", err)
+	}
 
 	return info, nil
 }
@@ -266,16 +326,21 @@ func GetFile(ident string) (*FileMetadata, error) {
 
 	// 获取文件元数据
 	meta, err := instance.MetaDB.GetFileMeta(ident)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrFileNotFound, ident)
+	}
 	if err != nil {
 		log.Errorf("获取文件元数据失败: %s", err)
 		return nil, err
 	}
+	if expired(meta.CreateAt, meta.TTL) {
+		return nil, fmt.Errorf("%w: 文件已过期: %s", ErrFileNotFound, ident)
+	}
 
 	// 检查文件是否存在
-	filePath := filepath.Join(filePath, ident)
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		log.Errorf("文件不存在: %s", filePath)
-		return nil, fmt.Errorf("文件不存在: %s", filePath)
+	if !instance.Storage.Exists(ident) {
+		log.Errorf("文件不存在: %s", ident)
+		return nil, fmt.Errorf("%w: %s", ErrFileNotFound, ident)
 	}
 
 	return meta, nil
@@ -293,6 +358,9 @@ func GetFileInfo(ident string) (*FileInfo, error) {
 		log.Errorf("获取文件信息失败: %s", err)
 		return nil, err
 	}
+	if expired(info.CreateAt, info.TTL) {
+		return nil, fmt.Errorf("文件信息已过期: %s", ident)
+	}
 
 	return info, nil
 }
@@ -303,13 +371,31 @@ func DeleteFile(ident string) error {
 		return nil
 	}
 
-	// 删除文件元数据
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	// 删除过期索引
+	if meta, err := instance.MetaDB.GetFileMeta(ident); err == nil {
+		if err := instance.Expiry.Remove(expiryFilePrefix, ident, meta.CreateAt, meta.TTL); err != nil {
+			log.Errorf("删除文件过期索引失败: %s", err)
+		}
+	}
+
+	return removeFile(ident)
+}
+
+// removeFile 删除文件与文件元数据
+func removeFile(ident string) error {
+	// 删除文件元数据并释放存储空间
+	if meta, err := instance.MetaDB.GetFileMeta(ident); err == nil {
+		instance.release(meta.Size)
+	}
 	if err := instance.MetaDB.DeleteFileMeta(ident); err != nil {
 		log.Errorf("删除文件元数据失败: %s", err)
 	}
 
 	// 删除文件
-	return os.Remove(filepath.Join(filePath, ident))
+	return instance.Storage.Delete(ident)
 }
 
 // DeleteFileInfo 删除文件信息
@@ -318,6 +404,16 @@ func DeleteFileInfo(ident string) error {
 		return nil
 	}
 
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	// 删除过期索引
+	if info, err := instance.FileInfoDB.GetFileInfo(ident); err == nil {
+		if err := instance.Expiry.Remove(expiryFileInfoPrefix, ident, info.CreateAt, info.TTL); err != nil {
+			log.Errorf("删除文件信息过期索引失败: %s", err)
+		}
+	}
+
 	// 删除文件信息
 	if err := instance.FileInfoDB.DeleteFileInfo(ident); err != nil {
 		log.Errorf("删除文件信息失败: %s", err)
@@ -327,78 +423,80 @@ func DeleteFileInfo(ident string) error {
 	return nil
 }
 
-// fileCleaner 定期清理过期文件
-func fileCleaner(meta *FileMetadata) *time.Timer {
-	if meta.TTL == 0 {
-		return nil // 没有设置过期时间
-	}
+// expired 判断是否已超过有效期
+func expired(createAt, ttl uint64) bool {
+	return ttl != 0 && createAt+ttl <= uint64(time.Now().Unix())
+}
 
-	// 计算过期时间戳
-	expireAt := meta.CreateAt + meta.TTL
-	now := uint64(time.Now().Unix())
-	if expireAt <= now {
-		// 文件已过期，删除文件和元数据
-		fileCleanerFunc(meta)
+// GetSweepStats 获取过期清理统计信息
+func GetSweepStats() (SweepStats, error) {
+	if instance == nil {
+		return SweepStats{}, fmt.Errorf("文件服务器未启用！")
 	}
-
-	// 计算过期时长
-	duration := time.Duration(expireAt-now) * time.Second
-	// 启动定时器
-	return time.AfterFunc(duration, func() {
-		fileCleanerFunc(meta)
-	})
+	return instance.Expiry.Stats(), nil
 }
 
-// fileCleanerFunc 文件清理函数
-func fileCleanerFunc(meta *FileMetadata) {
-	if err := DeleteFile(meta.ID); err != nil {
-		log.Errorf("清理文件失败: %s", err)
+// InternalURLPrefix 获取内部链接前缀
+func InternalURLPrefix() string {
+	if instance == nil || !instance.Enable {
+		return ""
 	}
+	return fmt.Sprintf("http://%s%s/%s/proxy/", instance.URL, instance.path, instance.version)
 }
 
-// fileInfoCleaner 定期清理过期文件信息
-func fileInfoCleaner(meta *FileInfo) *time.Timer {
-	if meta.TTL == 0 {
-		return nil // 没有设置过期时间
+// InternalURL 获取内部链接格式
+//
+// 链接不带签名且不会过期，供 Satori 应用使用，访问时需要 Satori 鉴权令牌
+func InternalURL(meta *FileMetadata) string {
+	if instance == nil || !instance.Enable {
+		return ""
 	}
+	return InternalURLPrefix() + meta.URL
+}
 
-	// 计算过期时间戳
-	expireAt := meta.CreateAt + meta.TTL
-	now := uint64(time.Now().Unix())
-	if expireAt <= now {
-		// 文件信息已过期，删除文件信息
-		fileInfoCleanerFunc(meta)
+// PublicURL 获取交由 QQ 获取文件的公开链接，链接带有过期时间与签名
+//
+// 存储后端支持时直接返回存储后端的直链
+func PublicURL(meta *FileMetadata) string {
+	if instance == nil || !instance.Enable {
+		return ""
 	}
-
-	// 计算过期时长
-	duration := time.Duration(expireAt-now) * time.Second
-	// 启动定时器
-	return time.AfterFunc(duration, func() {
-		fileInfoCleanerFunc(meta)
-	})
+	if url, err := PresignedURL(meta); err == nil {
+		return url
+	}
+	return InternalURLPrefix() + meta.URL + "?" + signedQuery(meta)
 }
 
-// fileInfoCleanerFunc 文件信息清理函数
-func fileInfoCleanerFunc(info *FileInfo) {
-	if err := DeleteFileInfo(info.ID); err != nil {
-		log.Errorf("清理文件信息失败: %s", err)
+// SignURL 将文件服务器的内部链接转换为公开链接，其他链接原样返回
+func SignURL(rawURL string) string {
+	if instance == nil || !instance.Enable {
+		return rawURL
+	}
+	prefix := InternalURLPrefix()
+	if !strings.HasPrefix(rawURL, prefix) {
+		return rawURL
+	}
+	_, _, meta, err := ResolveURL(rawURL)
+	if err != nil {
+		return rawURL
 	}
+	return PublicURL(meta)
 }
 
-// InternalURLPrefix 获取内部链接前缀
-func InternalURLPrefix() string {
+// PresignedURL 获取存储后端的文件直链
+func PresignedURL(meta *FileMetadata) (string, error) {
 	if instance == nil || !instance.Enable {
-		return ""
+		return "", fmt.Errorf("文件服务器未启用！")
 	}
-	return fmt.Sprintf("http://%s%s/%s/proxy/", instance.URL, instance.path, instance.version)
+	return instance.Storage.Presign(meta.ID, meta, urlExpires(meta))
 }
 
-// InternalURL 获取内部链接格式
-func InternalURL(meta *FileMetadata) string {
+// OpenFile 打开文件
+func OpenFile(meta *FileMetadata) (io.ReadSeekCloser, error) {
 	if instance == nil || !instance.Enable {
-		return ""
+		return nil, fmt.Errorf("文件服务器未启用！")
 	}
-	return InternalURLPrefix() + meta.URL
+	return instance.Storage.Open(meta.ID)
 }
 
 // ParseInternalURL 解析内部链接
@@ -414,22 +512,76 @@ func ParseInternalURL(url string) (string, string, string, bool) {
 	return matches[1], matches[2], matches[3], true
 }
 
-// GetPath 获取文件本地路径
+// GetPath 获取文件本地路径，仅适用于本地存储
 func GetPath(path string) (string, error) {
+	meta, err := GetFileByPath(path)
+	if err != nil {
+		return "", err
+	}
+	local, ok := instance.Storage.(*localStorage)
+	if !ok {
+		return "", fmt.Errorf("文件不在本地存储中: %s", path)
+	}
+	return local.Path(meta.ID), nil
+}
+
+// GetFileByPath 根据内部链接路径获取文件元数据
+func GetFileByPath(path string) (*FileMetadata, error) {
 	if instance == nil || !instance.Enable {
-		return "", fmt.Errorf("文件服务器未启用！")
+		return nil, fmt.Errorf("文件服务器未启用！")
 	}
 
 	// 对于 _tmp 文件路径
 	if strings.HasPrefix(path, "_tmp/") {
 		// 提取文件 ident
 		ident := strings.TrimPrefix(path, "_tmp/")
-		if meta, err := GetFile(ident); err == nil {
-			return meta.Path, nil
-		} else {
-			return "", err
+		return GetFile(ident)
+	}
+
+	return nil, fmt.Errorf("无效的文件路径: %s", path)
+}
+
+// ListFiles 分页获取文件元数据
+func ListFiles(after string, limit int) ([]*FileMetadata, string, error) {
+	if instance == nil {
+		return nil, "", fmt.Errorf("文件服务器未启用！")
+	}
+	return instance.MetaDB.ListFileMetas(after, limit)
+}
+
+// ResolveURL 解析 upload.create 返回的链接，获取文件元数据
+//
+// 支持内部链接、带签名的文件服务器链接与存储后端直链
+func ResolveURL(rawURL string) (platform, userId string, meta *FileMetadata, err error) {
+	if instance == nil || !instance.Enable {
+		return "", "", nil, fmt.Errorf("文件服务器未启用！")
+	}
+
+	internalURL := rawURL
+	if prefix := InternalURLPrefix(); strings.HasPrefix(rawURL, prefix) {
+		internalURL = strings.TrimPrefix(rawURL, prefix)
+	} else if !strings.HasPrefix(rawURL, "internal:") {
+		// 存储后端直链以文件标识符作为对象名
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", "", nil, err
 		}
+		ident := path.Base(u.Path)
+		meta, err := GetFile(ident)
+		if err != nil {
+			return "", "", nil, err
+		}
+		internalURL = meta.URL
 	}
+	internalURL, _, _ = strings.Cut(internalURL, "?")
 
-	return "", fmt.Errorf("无效的文件路径: %s", path)
+	platform, userId, filePath, ok := ParseInternalURL(internalURL)
+	if !ok {
+		return "", "", nil, fmt.Errorf("无效的文件链接: %s", rawURL)
+	}
+	meta, err = GetFileByPath(filePath)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return platform, userId, meta, nil
 }
@@ -0,0 +1,78 @@
+package fileserver
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// startTestFileServer 在临时目录中启动使用本地存储的文件服务器
+func startTestFileServer(t *testing.T, maxFileSize, maxTotalSize int64) *FileServer {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	metaDB, err := StartMetaDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileInfoDB, err := StartFileInfoDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry, err := StartExpiryIndex(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage, err := newLocalStorage(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance = &FileServer{
+		version:      "v1",
+		URL:          "files.example.com",
+		Enable:       true,
+		TTL:          time.Hour,
+		URLTTL:       time.Hour,
+		signKey:      []byte("test-sign-key"),
+		MetaDB:       metaDB,
+		FileInfoDB:   fileInfoDB,
+		Expiry:       expiry,
+		Storage:      storage,
+		MaxFileSize:  maxFileSize,
+		MaxTotalSize: maxTotalSize,
+	}
+	t.Cleanup(func() {
+		metaDB.DB.Close()
+		fileInfoDB.DB.Close()
+		expiry.DB.Close()
+		instance = nil
+		_ = os.Chdir(wd)
+	})
+	return instance
+}
+
+func TestGetFileNotFound(t *testing.T) {
+	fs := startTestFileServer(t, 0, 0)
+
+	expiredFile := saveTestFile(t, "expired")
+	backdate(t, expiredFile.ID, 2*time.Hour)
+	removed := saveTestFile(t, "removed")
+	if err := fs.Storage.Delete(removed.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ident := range []string{"missing", expiredFile.ID, removed.ID} {
+		if _, err := GetFile(ident); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("GetFile(%s) error = %v, want %v", ident, err, ErrFileNotFound)
+		}
+	}
+}
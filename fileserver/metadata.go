@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"sync"
-	"time"
 
 	"github.com/syndtr/goleveldb/leveldb"
 )
@@ -13,14 +12,14 @@ const metaDatabasePath = "data/files/.metadata"
 
 // FileMetadata 文件元数据
 type FileMetadata struct {
-	ID           string      `json:"id"`           // 文件唯一标识 ID
-	Name         string      `json:"name"`         // 文件名
-	URL          string      `json:"url"`          // 文件内部链接
-	Path         string      `json:"path"`         // 文件存储相对路径
-	ContentType  string      `json:"content_type"` // 文件内容类型
-	CreateAt     uint64      `json:"create_at"`    // 文件创建时间戳
-	TTL          uint64      `json:"ttl"`          // 文件有效时间
-	cleanerTimer *time.Timer `json:"-"`            // 清理定时器，用于定时删除过期文件
+	ID          string `json:"id"`           // 文件唯一标识 ID
+	Name        string `json:"name"`         // 文件名
+	URL         string `json:"url"`          // 文件内部链接
+	Path        string `json:"path"`         // 文件存储相对路径
+	ContentType string `json:"content_type"` // 文件内容类型
+	Size        int64  `json:"size"`         // 文件大小
+	CreateAt    uint64 `json:"create_at"`    // 文件创建时间戳
+	TTL         uint64 `json:"ttl"`          // 文件有效时间
 }
 
 // MarshalBinary 序列化文件元数据为二进制
@@ -125,3 +124,39 @@ func (db *MetaDatabase) DeleteFileMeta(ident string) error {
 
 	return db.DB.Delete([]byte(ident), nil)
 }
+
+// ListFileMetas 按标识符顺序分页获取文件元数据
+//
+// 从 after 之后开始获取，返回的 next 为空时表示没有更多数据
+func (db *MetaDatabase) ListFileMetas(after string, limit int) ([]*FileMetadata, string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	iter := db.DB.NewIterator(nil, nil)
+	defer iter.Release()
+
+	var ok bool
+	if after == "" {
+		ok = iter.First()
+	} else if ok = iter.Seek([]byte(after)); ok && string(iter.Key()) == after {
+		ok = iter.Next()
+	}
+
+	metas := make([]*FileMetadata, 0, limit)
+	for ; ok; ok = iter.Next() {
+		if len(metas) >= limit {
+			return metas, metas[len(metas)-1].ID, nil
+		}
+		var meta FileMetadata
+		if err := meta.UnmarshalBinary(iter.Value()); err != nil {
+			return nil, "", err
+		}
+		metas = append(metas, &meta)
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, "", err
+	}
+
+	return metas, "", nil
+}
@@ -0,0 +1,104 @@
+package fileserver
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signKeyPath   = "data/files/.sign_key"
+	defaultURLTTL = time.Hour // 默认签名链接有效期为 1 小时
+)
+
+var (
+	ErrSignatureMissing = errors.New("signature is missing")
+	ErrSignatureInvalid = errors.New("signature is invalid")
+	ErrSignatureExpired = errors.New("signature has expired")
+)
+
+// loadSignKey 加载签名密钥，未配置时使用持久化的随机密钥
+func loadSignKey(configured string) ([]byte, error) {
+	if configured != "" {
+		return []byte(configured), nil
+	}
+
+	if data, err := os.ReadFile(signKeyPath); err == nil {
+		if key, err := hex.DecodeString(strings.TrimSpace(string(data))); err == nil && len(key) > 0 {
+			return key, nil
+		}
+	}
+
+	// 生成新的密钥并保存，以保证重启后已签发的链接仍然有效
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(signKeyPath), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(signKeyPath, []byte(hex.EncodeToString(key)), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// sign 计算内部链接与过期时间的签名
+func sign(key []byte, internalURL string, expires int64) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(internalURL))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// urlExpires 计算链接有效时长，不会超过文件本身的有效期
+func urlExpires(meta *FileMetadata) time.Duration {
+	expires := instance.URLTTL
+	if meta.TTL != 0 {
+		remaining := time.Until(time.Unix(int64(meta.CreateAt+meta.TTL), 0))
+		expires = max(min(expires, remaining), time.Second)
+	}
+	return expires
+}
+
+// signedQuery 生成内部链接的签名查询参数
+func signedQuery(meta *FileMetadata) string {
+	expires := time.Now().Add(urlExpires(meta)).Unix()
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", sign(instance.signKey, meta.URL, expires))
+	return query.Encode()
+}
+
+// VerifySignature 验证内部链接的签名查询参数
+func VerifySignature(internalURL, expires, signature string) error {
+	if instance == nil {
+		return ErrSignatureInvalid
+	}
+	if expires == "" || signature == "" {
+		return ErrSignatureMissing
+	}
+
+	expireAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	expected := sign(instance.signKey, internalURL, expireAt)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrSignatureInvalid
+	}
+	if time.Now().Unix() >= expireAt {
+		return ErrSignatureExpired
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package fileserver
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	startTestFileServer(t, 0, 0)
+
+	internalURL := "internal:qq/bot/_tmp/ident"
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Second).Unix()
+	valid := sign(instance.signKey, internalURL, future)
+
+	tests := []struct {
+		name      string
+		url       string
+		expires   string
+		signature string
+		err       error
+	}{
+		{"valid", internalURL, strconv.FormatInt(future, 10), valid, nil},
+		{"missing signature", internalURL, strconv.FormatInt(future, 10), "", ErrSignatureMissing},
+		{"missing expires", internalURL, "", valid, ErrSignatureMissing},
+		{"missing both", internalURL, "", "", ErrSignatureMissing},
+		{"tampered signature", internalURL, strconv.FormatInt(future, 10), strings.Repeat("0", len(valid)), ErrSignatureInvalid},
+		{"tampered url", "internal:qq/bot/_tmp/other", strconv.FormatInt(future, 10), valid, ErrSignatureInvalid},
+		{"tampered expires", internalURL, strconv.FormatInt(future+3600, 10), valid, ErrSignatureInvalid},
+		{"malformed expires", internalURL, "tomorrow", valid, ErrSignatureInvalid},
+		{"expired", internalURL, strconv.FormatInt(past, 10), sign(instance.signKey, internalURL, past), ErrSignatureExpired},
+		{"other key", internalURL, strconv.FormatInt(future, 10), sign([]byte("other-key"), internalURL, future), ErrSignatureInvalid},
+	}
+	for _, tt := range tests {
+		if err := VerifySignature(tt.url, tt.expires, tt.signature); !errors.Is(err, tt.err) {
+			t.Errorf("%s: VerifySignature() error = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestPublicURL(t *testing.T) {
+	startTestFileServer(t, 0, 0)
+	meta := saveTestFile(t, "public")
+	prefix := InternalURLPrefix()
+
+	// 返回给 Satori 应用的链接不带签名且保持不变
+	internal := InternalURL(meta)
+	if internal != prefix+meta.URL {
+		t.Fatalf("InternalURL() = %s, want %s", internal, prefix+meta.URL)
+	}
+
+	public := PublicURL(meta)
+	rawURL, rawQuery, ok := strings.Cut(public, "?")
+	if !ok || rawURL != internal {
+		t.Fatalf("PublicURL() = %s, want signed %s", public, internal)
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySignature(meta.URL, query.Get("expires"), query.Get("signature")); err != nil {
+		t.Fatalf("VerifySignature() of PublicURL() error = %v", err)
+	}
+
+	// 签名有效期不超过 url_ttl 与文件本身的有效期
+	expires, _ := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if limit := time.Now().Add(instance.URLTTL).Unix(); expires > limit {
+		t.Errorf("PublicURL() expires = %d, want at most %d", expires, limit)
+	}
+	meta.CreateAt = uint64(time.Now().Add(-instance.TTL + time.Minute).Unix())
+	_, rawQuery, _ = strings.Cut(PublicURL(meta), "?")
+	query, _ = url.ParseQuery(rawQuery)
+	expires, _ = strconv.ParseInt(query.Get("expires"), 10, 64)
+	if limit := int64(meta.CreateAt + meta.TTL); expires > limit {
+		t.Errorf("PublicURL() expires = %d, want at most file expiry %d", expires, limit)
+	}
+
+	tests := map[string]bool{
+		internal:                          true,
+		"https://example.com/a.png":       false,
+		prefix + "internal:qq/bot/_tmp/x": false,
+	}
+	for rawURL, signed := range tests {
+		got := SignURL(rawURL)
+		if signed != (got != rawURL && strings.HasPrefix(got, rawURL+"?")) {
+			t.Errorf("SignURL(%s) = %s, want signed %v", rawURL, got, signed)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package fileserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/WindowsSov8forUs/glyccat/config"
+	"github.com/WindowsSov8forUs/glyccat/pkg/s3"
+)
+
+// storageTimeout 单次远程存储操作超时时间
+const storageTimeout = 5 * time.Minute
+
+// ErrPresignUnsupported 存储后端不支持生成直链
+var ErrPresignUnsupported = errors.New("storage does not support presigned urls")
+
+// Storage 文件存储后端
+type Storage interface {
+	// Name 存储后端名称
+	Name() string
+	// Save 保存文件
+	Save(key string, reader io.Reader, size int64, contentType string) error
+	// Open 打开文件
+	Open(key string) (io.ReadSeekCloser, error)
+	// Exists 判断文件是否存在
+	Exists(key string) bool
+	// Delete 删除文件，文件不存在时不返回错误
+	Delete(key string) error
+	// Presign 生成可直接访问文件的链接，不支持时返回 ErrPresignUnsupported
+	Presign(key string, meta *FileMetadata, expires time.Duration) (string, error)
+}
+
+// newStorage 根据配置创建存储后端
+func newStorage(conf *config.Config) (Storage, error) {
+	storage := conf.FileServer.Storage
+	switch strings.ToLower(storage.Type) {
+	case "", "local":
+		return newLocalStorage(filePath)
+	case "s3":
+		client, err := s3.New(s3.Config{
+			Endpoint:       storage.S3.Endpoint,
+			PublicEndpoint: storage.S3.PublicEndpoint,
+			Region:         storage.S3.Region,
+			Bucket:         storage.S3.Bucket,
+			AccessKey:      storage.S3.AccessKey,
+			SecretKey:      storage.S3.SecretKey,
+			PathStyle:      storage.S3.PathStyle,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &s3Storage{client: client, presign: storage.S3.Presign}, nil
+	}
+	return nil, fmt.Errorf("未知的文件存储类型: %s", storage.Type)
+}
+
+// localStorage 本地磁盘存储
+type localStorage struct {
+	root string
+}
+
+// newLocalStorage 创建本地磁盘存储
+func newLocalStorage(root string) (*localStorage, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &localStorage{root: root}, nil
+}
+
+// Path 获取文件本地路径
+func (s *localStorage) Path(key string) string {
+	return filepath.Join(s.root, key)
+}
+
+func (s *localStorage) Name() string {
+	return "local"
+}
+
+func (s *localStorage) Save(key string, reader io.Reader, size int64, contentType string) error {
+	// 先写入临时文件再重命名，避免读取到写入一半的文件
+	tmp, err := os.CreateTemp(s.root, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, reader); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path(key))
+}
+
+func (s *localStorage) Open(key string) (io.ReadSeekCloser, error) {
+	return os.Open(s.Path(key))
+}
+
+func (s *localStorage) Exists(key string) bool {
+	info, err := os.Stat(s.Path(key))
+	return err == nil && info.Mode().IsRegular()
+}
+
+func (s *localStorage) Delete(key string) error {
+	err := os.Remove(s.Path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *localStorage) Presign(key string, meta *FileMetadata, expires time.Duration) (string, error) {
+	return "", ErrPresignUnsupported
+}
+
+// s3Storage S3 兼容对象存储
+type s3Storage struct {
+	client  *s3.Client
+	presign bool
+}
+
+func (s *s3Storage) Name() string {
+	return "s3"
+}
+
+func (s *s3Storage) Save(key string, reader io.Reader, size int64, contentType string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	return s.client.PutObject(ctx, key, reader, size, contentType)
+}
+
+func (s *s3Storage) Open(key string) (io.ReadSeekCloser, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	body, _, err := s.client.GetObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	// 读取至内存以支持 Range 请求
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	return nopSeekCloser{bytes.NewReader(data)}, nil
+}
+
+func (s *s3Storage) Exists(key string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := s.client.HeadObject(ctx, key)
+	return err == nil
+}
+
+func (s *s3Storage) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return s.client.DeleteObject(ctx, key)
+}
+
+func (s *s3Storage) Presign(key string, meta *FileMetadata, expires time.Duration) (string, error) {
+	if !s.presign {
+		return "", ErrPresignUnsupported
+	}
+
+	// 由对象存储返回正确的文件类型与文件名
+	query := url.Values{}
+	if meta.ContentType != "" {
+		query.Set("response-content-type", meta.ContentType)
+	}
+	if meta.Name != "" {
+		if disposition := mime.FormatMediaType("inline", map[string]string{"filename": meta.Name}); disposition != "" {
+			query.Set("response-content-disposition", disposition)
+		}
+	}
+	return s.client.PresignGetObject(key, expires, query)
+}
+
+// nopSeekCloser 为 io.ReadSeeker 添加空的 Close 方法
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
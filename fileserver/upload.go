@@ -0,0 +1,303 @@
+package fileserver
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/WindowsSov8forUs/glyccat/pkg/image"
+)
+
+const (
+	uploadTmpPath = "data/files/.tmp"
+	sniffLength   = 512
+)
+
+var (
+	ErrFileTooLarge        = errors.New("file exceeds the size limit")
+	ErrQuotaExceeded       = errors.New("file storage quota exceeded")
+	ErrContentTypeMismatch = errors.New("file content does not match its declared type")
+)
+
+// Usage 文件存储用量
+type Usage struct {
+	Files        int   `json:"files"`          // 文件数
+	TotalSize    int64 `json:"total_size"`     // 已使用空间，单位字节
+	MaxTotalSize int64 `json:"max_total_size"` // 存储空间上限，单位字节，为 0 时不限制
+	MaxFileSize  int64 `json:"max_file_size"`  // 单个文件大小上限，单位字节，为 0 时不限制
+}
+
+// upload 已写入临时文件的上传内容
+type upload struct {
+	path        string // 临时文件路径
+	ident       string // 文件标识符
+	size        int64  // 文件大小
+	contentType string // 嗅探得到的文件类型
+}
+
+// cleanup 删除临时文件
+func (u *upload) cleanup() {
+	os.Remove(u.path)
+}
+
+// receive 将上传内容写入临时文件，同时计算标识符、检查大小并嗅探文件类型
+func receive(reader io.Reader, platform, userId, declared string) (*upload, error) {
+	if err := os.MkdirAll(uploadTmpPath, 0755); err != nil {
+		return nil, err
+	}
+
+	// 嗅探文件类型
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(reader, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+	contentType, err := sniffContentType(head, declared)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(uploadTmpPath, "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	u := &upload{path: tmp.Name(), contentType: contentType}
+
+	// 使用与 CalculateFileIdent 相同的方式计算标识符
+	fromHash := sha256.Sum256([]byte(platform + ":" + userId))
+	h := hmac.New(sha256.New, fromHash[:])
+
+	limit, limitErr := instance.uploadLimit()
+	src := io.MultiReader(bytes.NewReader(head), reader)
+	if limit >= 0 {
+		src = io.LimitReader(src, limit+1)
+	}
+	u.size, err = io.Copy(io.MultiWriter(tmp, h), src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && limit >= 0 && u.size > limit {
+		err = limitErr
+	}
+	if err != nil {
+		u.cleanup()
+		return nil, err
+	}
+
+	u.ident = hex.EncodeToString(h.Sum(nil))
+	return u, nil
+}
+
+// uploadLimit 获取写入临时文件时允许的最大字节数与超出时的错误，为 -1 时不限制
+//
+// 存储空间配额在去重之后由 reserve 检查，这里只限制单个文件大小
+func (fs *FileServer) uploadLimit() (int64, error) {
+	switch {
+	case fs.MaxFileSize > 0:
+		return fs.MaxFileSize, ErrFileTooLarge
+	case fs.MaxTotalSize > 0:
+		return fs.MaxTotalSize, ErrQuotaExceeded
+	}
+	return -1, nil
+}
+
+// reserve 为文件预留存储空间
+func (fs *FileServer) reserve(size int64) error {
+	fs.usageMu.Lock()
+	defer fs.usageMu.Unlock()
+
+	if fs.MaxTotalSize > 0 && fs.usedSize+size > fs.MaxTotalSize {
+		return ErrQuotaExceeded
+	}
+	fs.usedSize += size
+	fs.fileCount++
+	return nil
+}
+
+// release 释放文件占用的存储空间
+func (fs *FileServer) release(size int64) {
+	fs.usageMu.Lock()
+	defer fs.usageMu.Unlock()
+
+	fs.usedSize = max(fs.usedSize-size, 0)
+	fs.fileCount = max(fs.fileCount-1, 0)
+}
+
+// loadUsage 统计已有文件的存储用量
+func (fs *FileServer) loadUsage() error {
+	metas, err := fs.MetaDB.GetFileMetas()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for ident, meta := range metas {
+		// 旧版本的元数据没有记录文件大小
+		if meta.Size == 0 {
+			if local, ok := fs.Storage.(*localStorage); ok {
+				if info, err := os.Stat(local.Path(ident)); err == nil {
+					meta.Size = info.Size()
+					_ = fs.MetaDB.SaveFileMeta(ident, meta)
+				}
+			}
+		}
+		total += meta.Size
+	}
+
+	fs.usageMu.Lock()
+	defer fs.usageMu.Unlock()
+	fs.usedSize = total
+	fs.fileCount = len(metas)
+	return nil
+}
+
+// GetUsage 获取文件存储用量
+func GetUsage() Usage {
+	if instance == nil {
+		return Usage{}
+	}
+
+	instance.usageMu.Lock()
+	defer instance.usageMu.Unlock()
+	return Usage{
+		Files:        instance.fileCount,
+		TotalSize:    instance.usedSize,
+		MaxTotalSize: instance.MaxTotalSize,
+		MaxFileSize:  instance.MaxFileSize,
+	}
+}
+
+// inlineContentTypes 允许在浏览器中直接展示的文件类型，其余类型一律作为附件下载
+//
+// 只包含不会执行脚本的图片、音频与视频格式，SVG 等可以内嵌脚本的格式不在其中
+var inlineContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"image/bmp":       true,
+	"image/heic":      true,
+	"image/avif":      true,
+	"audio/silk":      true,
+	"audio/amr":       true,
+	"audio/mpeg":      true,
+	"audio/wav":       true,
+	"audio/wave":      true,
+	"audio/x-wav":     true,
+	"audio/ogg":       true,
+	"audio/aac":       true,
+	"audio/mp4":       true,
+	"audio/flac":      true,
+	"audio/webm":      true,
+	"video/mp4":       true,
+	"video/webm":      true,
+	"video/ogg":       true,
+	"video/quicktime": true,
+	"application/ogg": true,
+}
+
+// IsInlineContentType 判断文件类型是否允许在浏览器中直接展示
+func IsInlineContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && inlineContentTypes[mediaType]
+}
+
+// sniffContentType 根据文件内容确定文件类型
+//
+// 内容可以识别时使用嗅探结果，声明为媒体类型但内容为其他已知格式时拒绝；
+// 内容无法识别时只信任可以直接展示的媒体类型，避免以声明的 HTML 等类型保存任意内容
+func sniffContentType(head []byte, declared string) (string, error) {
+	sniffed := detectContentType(head)
+	declaredType, _, err := mime.ParseMediaType(declared)
+	if err != nil {
+		declaredType = ""
+	}
+	if declaredType == "" {
+		return sniffed, nil
+	}
+
+	switch {
+	case strings.HasPrefix(sniffed, "application/octet-stream"):
+		// 无法识别的二进制内容只信任声明的媒体类型
+		if inlineContentTypes[declaredType] {
+			return declaredType, nil
+		}
+		return sniffed, nil
+	case strings.HasPrefix(sniffed, "text/plain"):
+		if isMediaType(declaredType) {
+			return "", ErrContentTypeMismatch
+		}
+		return sniffed, nil
+	case isAVType(declaredType) && isAVType(sniffed):
+		// 音视频容器难以区分，例如 M4A 会被识别为 video/mp4
+		if inlineContentTypes[declaredType] {
+			return declaredType, nil
+		}
+		return sniffed, nil
+	case isMediaType(declaredType) && !isMediaType(sniffed):
+		return "", ErrContentTypeMismatch
+	}
+	return sniffed, nil
+}
+
+// detectContentType 嗅探文件类型，补充标准库无法识别的格式
+func detectContentType(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("#!SILK_V3")), bytes.HasPrefix(head, []byte("\x02#!SILK_V3")):
+		return "audio/silk"
+	case bytes.HasPrefix(head, []byte("#!AMR")):
+		return "audio/amr"
+	}
+	if mimeType, ok := image.DetectHEIF(head); ok {
+		return mimeType
+	}
+	return http.DetectContentType(head)
+}
+
+// isMediaType 判断是否为图片、音频或视频类型
+func isMediaType(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/") || isAVType(contentType)
+}
+
+// isAVType 判断是否为音频或视频类型
+func isAVType(contentType string) bool {
+	return strings.HasPrefix(contentType, "audio/") || strings.HasPrefix(contentType, "video/") ||
+		strings.HasPrefix(contentType, "application/ogg")
+}
+
+// moveOrSave 将临时文件保存至存储后端
+func moveOrSave(storage Storage, key string, u *upload) error {
+	if local, ok := storage.(*localStorage); ok {
+		if err := os.Chmod(u.path, 0644); err != nil {
+			return err
+		}
+		return os.Rename(u.path, local.Path(key))
+	}
+
+	file, err := os.Open(u.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return storage.Save(key, file, u.size, u.contentType)
+}
+
+// cleanupUploads 清理上次运行残留的临时文件
+func cleanupUploads() {
+	entries, err := os.ReadDir(uploadTmpPath)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		os.Remove(filepath.Join(uploadTmpPath, entry.Name()))
+	}
+}
@@ -0,0 +1,102 @@
+package fileserver
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSniffContentType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	binary := []byte{0x00, 0x01, 0x02, 0x03, 0xfe, 0xff}
+	tests := []struct {
+		name     string
+		head     []byte
+		declared string
+		want     string
+		err      error
+	}{
+		{"sniffed image", png, "image/png", "image/png", nil},
+		{"sniffed without declared type", png, "", "image/png", nil},
+		{"silk declared as octet-stream", []byte("\x02#!SILK_V3"), "application/octet-stream", "audio/silk", nil},
+		{"unknown binary declared as media", binary, "audio/amr", "audio/amr", nil},
+		{"unknown binary declared as html", binary, "text/html", "application/octet-stream", nil},
+		{"unknown binary declared as svg", binary, "image/svg+xml", "application/octet-stream", nil},
+		{"text declared as html", []byte("just some text"), "text/html", "text/plain; charset=utf-8", nil},
+		{"text declared as image", []byte("just some text"), "image/png", "", ErrContentTypeMismatch},
+		{"html declared as image", []byte("<html><script>alert(1)</script>"), "image/gif", "", ErrContentTypeMismatch},
+		{"image declared as video uses sniffed type", png, "video/mp4", "image/png", nil},
+		{"html stays html", []byte("<html><body>"), "text/plain", "text/html; charset=utf-8", nil},
+	}
+	for _, tt := range tests {
+		got, err := sniffContentType(tt.head, tt.declared)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: sniffContentType() error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: sniffContentType() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsInlineContentType(t *testing.T) {
+	tests := map[string]bool{
+		"image/png":                 true,
+		"video/mp4":                 true,
+		"audio/silk":                true,
+		"image/svg+xml":             false,
+		"text/html; charset=utf-8":  false,
+		"text/plain; charset=utf-8": false,
+		"application/octet-stream":  false,
+		"application/pdf":           false,
+		"":                          false,
+	}
+	for contentType, want := range tests {
+		if got := IsInlineContentType(contentType); got != want {
+			t.Errorf("IsInlineContentType(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
+
+func TestSaveFileQuota(t *testing.T) {
+	startTestFileServer(t, 10, 25)
+
+	tests := []struct {
+		name  string
+		data  string
+		err   error
+		used  int64
+		files int
+	}{
+		{"over file size limit", "0123456789a", ErrFileTooLarge, 0, 0},
+		{"first file", "0123456789", nil, 10, 1},
+		{"duplicate is not counted", "0123456789", nil, 10, 1},
+		{"second file", "abcdefghij", nil, 20, 2},
+		{"over quota", "klmnopqrst", ErrQuotaExceeded, 20, 2},
+		{"fits remaining quota", "uvwxy", nil, 25, 3},
+	}
+	for _, tt := range tests {
+		_, err := SaveFile(bytes.NewReader([]byte(tt.data)), "qq", "bot", "file.txt", "text/plain")
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%s: SaveFile() error = %v, want %v", tt.name, err, tt.err)
+		}
+		usage := GetUsage()
+		if usage.TotalSize != tt.used || usage.Files != tt.files {
+			t.Fatalf("%s: usage = %d bytes in %d files, want %d bytes in %d files",
+				tt.name, usage.TotalSize, usage.Files, tt.used, tt.files)
+		}
+	}
+
+	// 删除文件后释放空间
+	meta, err := SaveFile(bytes.NewReader([]byte("abcdefghij")), "qq", "bot", "file.txt", "text/plain")
+	if err != nil {
+		t.Fatalf("SaveFile() error = %v", err)
+	}
+	if err := DeleteFile(meta.ID); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+	if usage := GetUsage(); usage.TotalSize != 15 || usage.Files != 2 {
+		t.Fatalf("usage after delete = %d bytes in %d files, want 15 bytes in 2 files", usage.TotalSize, usage.Files)
+	}
+}
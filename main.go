@@ -6,14 +6,19 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/WindowsSov8forUs/glyccat/config"
 	"github.com/WindowsSov8forUs/glyccat/database"
 	"github.com/WindowsSov8forUs/glyccat/fileserver"
 	"github.com/WindowsSov8forUs/glyccat/log"
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
+	"github.com/WindowsSov8forUs/glyccat/pkg/silk"
 	"github.com/WindowsSov8forUs/glyccat/processor"
+	"github.com/WindowsSov8forUs/glyccat/proxy"
 	"github.com/WindowsSov8forUs/glyccat/server"
 	"github.com/WindowsSov8forUs/glyccat/sys"
+	"github.com/WindowsSov8forUs/glyccat/transcoder"
 	"github.com/WindowsSov8forUs/glyccat/version"
 
 	"github.com/gin-gonic/gin"
@@ -30,6 +35,11 @@ func main() {
 	// 解析命令行参数到定义的标志
 	flag.Parse()
 
+	// 执行子命令
+	if runCommand(flag.Args()) {
+		return
+	}
+
 	// 检查是否使用了 -faststart 参数
 	if !*fastStart {
 		sys.InitBase()
@@ -73,10 +83,16 @@ func main() {
 	// 开启本地文件服务器
 	fileserver.StartFileServer(conf)
 
+	// 启动代理路由
+	proxy.StartProxy(conf)
+
+	// 启动转码服务
+	transcoder.StartTranscoder(conf)
+
 	// 启动消息数据库
 	if conf.Database.MessageDatabase.Enable {
 		log.Info("正在启动消息数据库...")
-		err := database.StartMessageDB(conf.Database.MessageDatabase.Limit)
+		err := database.StartMessageDB(conf)
 		if err != nil {
 			log.Errorf("启动消息数据库时出错，将无法使用消息缓存: %v", err)
 		}
@@ -84,6 +100,14 @@ func main() {
 		log.Warn("消息数据库未启动，将无法使用消息缓存。")
 	}
 
+	// 启动用户名册数据库
+	if err := database.StartRosterDB(); err != nil {
+		log.Errorf("启动用户名册数据库时出错，将无法获取 QQ 群成员: %v", err)
+	}
+
+	// 启动实体缓存
+	entitycache.Setup(conf.Database.EntityCache.Enable, time.Duration(conf.Database.EntityCache.MaxAge)*time.Second)
+
 	// 初始化消息处理器
 	p, ctx, err := processor.NewProcessor(conf)
 	if err != nil {
@@ -108,4 +132,6 @@ func main() {
 	<-sigCh
 
 	server.Close()
+	database.CloseRosterDB()
+	silk.Cleanup()
 }
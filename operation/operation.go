@@ -120,5 +120,6 @@ type ReadyBody struct {
 
 // META 信令的信令数据
 type MetaBody struct {
-	ProxyUrls []string `json:"proxy_urls"` // 代理路由 列表
+	Logins    []*login.Login `json:"logins"`     // 登录信息
+	ProxyUrls []string       `json:"proxy_urls"` // 代理路由 列表
 }
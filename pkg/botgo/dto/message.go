@@ -60,6 +60,14 @@ type GroupAddBotEvent struct {
 	Timestamp      int64  `json:"timestamp"`
 }
 
+// FriendEvent 表示用户添加或删除机器人好友事件的数据结构
+type FriendEvent struct {
+	ID        string `json:"id"`
+	EventID   string `json:"event_id"`
+	OpenID    string `json:"openid"`
+	Timestamp int64  `json:"timestamp"`
+}
+
 type GroupMsgRejectEvent struct {
 	EventID        string      `json:"event_id"`
 	GroupOpenID    string      `json:"group_openid"`
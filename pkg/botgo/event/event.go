@@ -76,6 +76,8 @@ var eventParseFuncMap = map[dto.OPCode]map[dto.EventType]eventParseFunc{
 		dto.EventInteractionCreate:    interactionHandler,
 		dto.EventGroupAtMessageCreate: groupAtMessageHandler,
 		dto.EventC2CMessageCreate:     c2cMessageHandler,
+		dto.EventFriendAdd:            friendAddHandler,
+		dto.EventFriendDel:            friendDelHandler,
 		dto.EventGroupAddRobot:        groupaddbothandler,
 		dto.EventGroupDelRobot:        groupdelbothandler,
 		dto.EventGroupMsgReject:       groupMsgRejecthandler,
@@ -125,6 +127,15 @@ func ParseData(message []byte, target interface{}) error {
 		v.EventID = eventid
 		return nil
 
+	case *dto.FriendEvent:
+		// 特殊处理dto.FriendEvent
+		if err := json.Unmarshal([]byte(data.String()), v); err != nil {
+			return err
+		}
+		// 设置ID字段
+		v.EventID = eventid
+		return nil
+
 	case *dto.InteractionEventData:
 		// 特殊处理dto.InteractionEventData
 		if err := json.Unmarshal([]byte(data.String()), v); err != nil {
@@ -282,6 +293,28 @@ func groupdelbothandler(payload *dto.Payload, message []byte) error {
 	return nil
 }
 
+func friendAddHandler(payload *dto.Payload, message []byte) error {
+	data := &dto.FriendEvent{}
+	if err := ParseData(message, data); err != nil {
+		return err
+	}
+	if DefaultHandlers.FriendAdd != nil {
+		return DefaultHandlers.FriendAdd(payload, data)
+	}
+	return nil
+}
+
+func friendDelHandler(payload *dto.Payload, message []byte) error {
+	data := &dto.FriendEvent{}
+	if err := ParseData(message, data); err != nil {
+		return err
+	}
+	if DefaultHandlers.FriendDel != nil {
+		return DefaultHandlers.FriendDel(payload, data)
+	}
+	return nil
+}
+
 func publicMessageDeleteHandler(payload *dto.Payload, message []byte) error {
 	data := &dto.PublicMessageDeleteData{}
 	if err := ParseData(message, data); err != nil {
@@ -12,6 +12,7 @@ var DefaultHandlers struct {
 
 	Hello     HelloHandler
 	Reconnect ReconnectHandler
+	Resumed   ResumedHandler
 
 	Guild       GuildEventHandler
 	GuildMember GuildMemberEventHandler
@@ -37,6 +38,8 @@ var DefaultHandlers struct {
 
 	GroupATMessage  GroupATMessageEventHandler
 	C2CMessage      C2CMessageEventHandler
+	FriendAdd       FriendAddEventHandler
+	FriendDel       FriendDelEventHandler
 	GroupAddbot     GroupAddRobotEventHandler
 	GroupDelbot     GroupDelRobotEventHandler
 	GroupMsgReject  GroupMsgRejectHandler
@@ -59,6 +62,9 @@ type HelloHandler func(event *dto.Payload)
 // ReconnectHandler 当 ws 重新连接的时候会回调
 type ReconnectHandler func(event *dto.Payload)
 
+// ResumedHandler 当 ws 通过 resume 恢复连接的时候会回调
+type ResumedHandler func(event *dto.Payload)
+
 // GuildEventHandler 频道事件handler
 type GuildEventHandler func(event *dto.Payload, data *dto.GuildData) error
 
@@ -118,6 +124,12 @@ type GroupATMessageEventHandler func(event *dto.Payload, data *dto.GroupATMessag
 // C2CMessageEventHandler 机器人消息事件 handler
 type C2CMessageEventHandler func(event *dto.Payload, data *dto.C2CMessageData) error
 
+// FriendAddEventHandler 用户添加机器人好友事件 handler
+type FriendAddEventHandler func(event *dto.Payload, data *dto.FriendEvent) error
+
+// FriendDelEventHandler 用户删除机器人好友事件 handler
+type FriendDelEventHandler func(event *dto.Payload, data *dto.FriendEvent) error
+
 // GroupAddRobot 机器人新增事件 handler
 type GroupAddRobotEventHandler func(event *dto.Payload, data *dto.GroupAddBotEvent) error
 
@@ -147,6 +159,8 @@ func RegisterHandlers(handlers ...interface{}) dto.Intent {
 			DefaultHandlers.Hello = handle
 		case ReconnectHandler:
 			DefaultHandlers.Reconnect = handle
+		case ResumedHandler:
+			DefaultHandlers.Resumed = handle
 		case AudioEventHandler:
 			DefaultHandlers.Audio = handle
 			i = i | dto.EventToIntent(
@@ -162,6 +176,12 @@ func RegisterHandlers(handlers ...interface{}) dto.Intent {
 		case GroupDelRobotEventHandler:
 			DefaultHandlers.GroupDelbot = handle
 			i = i | dto.EventToIntent(dto.EventGroupDelRobot)
+		case FriendAddEventHandler:
+			DefaultHandlers.FriendAdd = handle
+			i = i | dto.EventToIntent(dto.EventFriendAdd)
+		case FriendDelEventHandler:
+			DefaultHandlers.FriendDel = handle
+			i = i | dto.EventToIntent(dto.EventFriendDel)
 		case GroupMsgRejectHandler:
 			DefaultHandlers.GroupMsgReject = handle
 			i = i | dto.EventToIntent(dto.EventGroupMsgReject)
@@ -317,6 +317,13 @@ func (c *Client) listenMessageAndHandle() {
 			c.readyHandler(payload)
 			continue
 		}
+		// resume 成功后服务端会下发 RESUMED 事件
+		if payload.Type == "RESUMED" {
+			if event.DefaultHandlers.Resumed != nil {
+				event.DefaultHandlers.Resumed(payload)
+			}
+			continue
+		}
 
 		// 性能不够 报错也没用 就扬了
 		go event.ParseAndHandle(payload)
@@ -343,6 +350,9 @@ func (c *Client) isHandleBuildIn(payload *dto.Payload) bool {
 		c.startHeartBeatTicker(payload.RawMessage)
 	case dto.WSHeartbeatAck: // 心跳 ack 不需要业务处理
 	case dto.WSReconnect: // 达到连接时长，需要重新连接，此时可以通过 resume 续传原连接上的事件
+		if event.DefaultHandlers.Reconnect != nil {
+			event.DefaultHandlers.Reconnect(payload)
+		}
 		c.closeChan <- errs.ErrNeedReConnect
 	case dto.WSInvalidSession: // 无效的 sessionLog，需要重新鉴权
 		c.closeChan <- errs.ErrInvalidSession
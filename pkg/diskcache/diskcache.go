@@ -0,0 +1,333 @@
+package diskcache
+
+import (
+	"container/list"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	metaSuffix = ".meta" // 缓存项元数据文件后缀
+	tempPrefix = "tmp-"  // 写入中的临时文件前缀
+)
+
+// Stats 缓存统计信息
+type Stats struct {
+	Entries     int   `json:"entries"`     // 缓存项数量
+	Size        int64 `json:"size"`        // 缓存总大小
+	MaxSize     int64 `json:"max_size"`    // 缓存大小上限
+	Hits        int64 `json:"hits"`        // 命中次数
+	Misses      int64 `json:"misses"`      // 未命中次数
+	Writes      int64 `json:"writes"`      // 写入次数
+	Evictions   int64 `json:"evictions"`   // 因容量淘汰的次数
+	Expirations int64 `json:"expirations"` // 因过期淘汰的次数
+}
+
+// entry 缓存项，除 key 外保存在元数据文件中
+type entry struct {
+	key         string
+	ContentType string `json:"content_type,omitempty"` // 内容类型
+	Size        int64  `json:"size"`                   // 文件大小
+	CreateAt    int64  `json:"create_at,omitempty"`    // 写入时间，毫秒时间戳
+}
+
+// createTime 获取缓存项的写入时间
+func (e *entry) createTime() time.Time {
+	return time.UnixMilli(e.CreateAt)
+}
+
+// Cache 磁盘 LRU 缓存
+//
+// 数据文件以缓存键命名，元数据文件记录内容类型、大小与写入时间；
+// 数据文件的修改时间为最近访问时间，用于重新打开时恢复访问顺序
+type Cache struct {
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+	size    int64
+	entries map[string]*list.Element
+	lru     *list.List
+	stats   Stats
+	mu      sync.Mutex
+}
+
+// New 创建磁盘缓存，并从缓存目录中恢复已有的缓存项
+//
+// maxSize 为缓存大小上限，maxAge 为缓存项有效期，为 0 时不会过期
+func New(dir string, maxSize int64, maxAge time.Duration) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	cache := &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		maxAge:  maxAge,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type restored struct {
+		entry      *entry
+		accessedAt time.Time
+	}
+	var items []restored
+	for _, file := range files {
+		name := file.Name()
+		if strings.HasPrefix(name, tempPrefix) {
+			// 上次运行遗留的临时文件
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		if strings.HasSuffix(name, metaSuffix) {
+			// 数据文件已不存在的元数据文件
+			if _, err := os.Stat(cache.dataPath(strings.TrimSuffix(name, metaSuffix))); os.IsNotExist(err) {
+				os.Remove(filepath.Join(dir, name))
+			}
+			continue
+		}
+		if file.IsDir() {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		e, err := cache.readMeta(name)
+		if os.IsNotExist(err) {
+			// 没有元数据文件的缓存项以修改时间作为写入时间
+			e = &entry{key: name, Size: info.Size(), CreateAt: info.ModTime().UnixMilli()}
+			_ = cache.writeMeta(e)
+		} else if err != nil || e.Size != info.Size() {
+			cache.remove(name)
+			continue
+		}
+		items = append(items, restored{e, info.ModTime()})
+	}
+
+	// 最近访问的排在最前
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].accessedAt.After(items[j].accessedAt)
+	})
+	for _, item := range items {
+		cache.entries[item.entry.key] = cache.lru.PushBack(item.entry)
+		cache.size += item.entry.Size
+	}
+	cache.evict()
+
+	return cache, nil
+}
+
+// Open 打开缓存项，返回文件与内容类型
+func (c *Cache) Open(key string) (*os.File, string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, "", false
+	}
+	e := element.Value.(*entry)
+
+	if c.expired(e) {
+		c.removeElement(element)
+		c.stats.Expirations++
+		c.stats.Misses++
+		return nil, "", false
+	}
+
+	file, err := os.Open(c.dataPath(key))
+	if err != nil {
+		c.removeElement(element)
+		c.stats.Misses++
+		return nil, "", false
+	}
+
+	// 更新访问顺序
+	c.lru.MoveToFront(element)
+	now := time.Now()
+	_ = os.Chtimes(c.dataPath(key), now, now)
+	c.stats.Hits++
+
+	return file, e.ContentType, true
+}
+
+// Get 读取缓存项的全部内容
+func (c *Cache) Get(key string) ([]byte, bool) {
+	file, _, ok := c.Open(key)
+	if !ok {
+		return nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// TempFile 在缓存目录中创建用于写入的临时文件
+func (c *Cache) TempFile() (*os.File, error) {
+	return os.CreateTemp(c.dir, tempPrefix+"*")
+}
+
+// Fits 判断给定大小的资源能否放入缓存
+func (c *Cache) Fits(size int64) bool {
+	return size <= c.maxSize
+}
+
+// Commit 将写入完成的临时文件放入缓存
+func (c *Cache) Commit(key, tempPath, contentType string, size int64) error {
+	if !c.Fits(size) {
+		os.Remove(tempPath)
+		return nil
+	}
+
+	e := &entry{
+		key:         key,
+		ContentType: contentType,
+		Size:        size,
+		CreateAt:    time.Now().UnixMilli(),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.Rename(tempPath, c.dataPath(key)); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	if err := c.writeMeta(e); err != nil {
+		os.Remove(c.dataPath(key))
+		return err
+	}
+
+	if element, ok := c.entries[key]; ok {
+		c.size -= element.Value.(*entry).Size
+		c.lru.Remove(element)
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	c.size += size
+	c.stats.Writes++
+	c.evict()
+
+	return nil
+}
+
+// Put 写入缓存项
+func (c *Cache) Put(key string, data []byte, contentType string) error {
+	size := int64(len(data))
+	if !c.Fits(size) {
+		return nil
+	}
+
+	// 先写入临时文件再重命名，避免读取到写入一半的文件
+	file, err := c.TempFile()
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return c.Commit(key, file.Name(), contentType, size)
+}
+
+// Stats 获取缓存统计信息
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	stats.Size = c.size
+	stats.MaxSize = c.maxSize
+	return stats
+}
+
+// evict 淘汰过期的缓存项，再淘汰最久未访问的缓存项直至缓存大小不超过上限
+func (c *Cache) evict() {
+	if c.maxAge > 0 {
+		for element := c.lru.Front(); element != nil; {
+			next := element.Next()
+			if c.expired(element.Value.(*entry)) {
+				c.removeElement(element)
+				c.stats.Expirations++
+			}
+			element = next
+		}
+	}
+
+	for c.size > c.maxSize {
+		element := c.lru.Back()
+		if element == nil {
+			return
+		}
+		c.removeElement(element)
+		c.stats.Evictions++
+	}
+}
+
+// expired 判断缓存项是否过期
+func (c *Cache) expired(e *entry) bool {
+	return c.maxAge > 0 && time.Since(e.createTime()) > c.maxAge
+}
+
+// removeElement 移除缓存项及其文件
+func (c *Cache) removeElement(element *list.Element) {
+	e := element.Value.(*entry)
+	c.lru.Remove(element)
+	delete(c.entries, e.key)
+	c.size -= e.Size
+	c.remove(e.key)
+}
+
+// dataPath 获取缓存数据文件路径
+func (c *Cache) dataPath(key string) string {
+	return filepath.Join(c.dir, key)
+}
+
+// readMeta 读取缓存项元数据
+func (c *Cache) readMeta(key string) (*entry, error) {
+	data, err := os.ReadFile(c.dataPath(key) + metaSuffix)
+	if err != nil {
+		return nil, err
+	}
+	e := &entry{key: key}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// writeMeta 写入缓存项元数据
+func (c *Cache) writeMeta(e *entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.dataPath(e.key)+metaSuffix, data, 0644)
+}
+
+// remove 删除缓存项文件
+func (c *Cache) remove(key string) {
+	os.Remove(c.dataPath(key))
+	os.Remove(c.dataPath(key) + metaSuffix)
+}
@@ -0,0 +1,211 @@
+package diskcache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// put 将数据写入缓存
+func put(t *testing.T, cache *Cache, key, data string) {
+	t.Helper()
+	temp, err := cache.TempFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := temp.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+	temp.Close()
+	if err := cache.Commit(key, temp.Name(), "image/png", int64(len(data))); err != nil {
+		t.Fatalf("Commit(%s) error = %v", key, err)
+	}
+}
+
+// cached 判断缓存项是否存在
+func cached(cache *Cache, key string) bool {
+	file, _, ok := cache.Open(key)
+	if ok {
+		file.Close()
+	}
+	return ok
+}
+
+func TestCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := New(dir, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put(t, cache, "a", "aaaa")
+	put(t, cache, "b", "bbbb")
+	// 访问 a 之后 b 成为最久未访问的缓存项
+	if !cached(cache, "a") {
+		t.Fatal("Open(a) missed")
+	}
+	put(t, cache, "c", "cccc")
+
+	tests := map[string]bool{"a": true, "b": false, "c": true}
+	for key, want := range tests {
+		if got := cached(cache, key); got != want {
+			t.Errorf("Open(%s) = %v, want %v", key, got, want)
+		}
+	}
+	for _, path := range []string{cache.dataPath("b"), cache.dataPath("b") + metaSuffix} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("evicted file %s still exists: %v", path, err)
+		}
+	}
+
+	// 超出上限的资源不会被缓存
+	put(t, cache, "d", "dddddddddddd")
+	if cached(cache, "d") {
+		t.Error("Open(d) hit for entry larger than the cache")
+	}
+
+	stats := cache.Stats()
+	if stats.Entries != 2 || stats.Size != 8 || stats.Evictions != 1 || stats.Writes != 3 {
+		t.Errorf("Stats() = %+v, want 2 entries, 8 bytes, 1 eviction and 3 writes", stats)
+	}
+
+	// 重新打开缓存时恢复已有的缓存项与访问顺序
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(cache.dataPath("c"), past, past); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := New(dir, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.size != 8 {
+		t.Errorf("restored size = %d, want 8", reopened.size)
+	}
+	file, contentType, ok := reopened.Open("a")
+	if !ok || contentType != "image/png" {
+		t.Fatalf("Open(a) after reopen = %q, %v, want image/png", contentType, ok)
+	}
+	file.Close()
+	put(t, reopened, "e", "ee")
+	put(t, reopened, "f", "ff")
+	put(t, reopened, "g", "gg")
+	if cached(reopened, "c") || !cached(reopened, "a") {
+		t.Error("least recently used entry c was not evicted first after reopen")
+	}
+}
+
+func TestCacheGetPut(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := New(dir, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.Put("a", []byte("hello"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := cache.Get("a"); !ok || string(data) != "hello" {
+		t.Errorf("Get(a) = %q, %v, want hello", data, ok)
+	}
+	if _, ok := cache.Get("missing"); ok {
+		t.Error("Get(missing) hit")
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Stats() = %+v, want 1 hit and 1 miss", stats)
+	}
+
+	// 文件被外部删除时视为未命中
+	os.Remove(cache.dataPath("a"))
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Get(a) hit after the file was removed")
+	}
+	if cache.size != 0 {
+		t.Errorf("size = %d, want 0", cache.size)
+	}
+}
+
+func TestCacheRestore(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"legacy":       "legacy",                                // 没有元数据文件的缓存项
+		"broken":       "broken",                                // 大小与元数据不符的缓存项
+		"broken.meta":  `{"size":1}`,                            // 与数据文件大小不符的元数据
+		"orphan.meta":  `{"size":3}`,                            // 数据文件已不存在的元数据
+		"tmp-123":      "partial",                               // 写入中的临时文件
+		"proxied":      "data",                                  // 带有内容类型的缓存项
+		"proxied.meta": `{"content_type":"image/gif","size":4}`, // 旧版本代理缓存的元数据
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache, err := New(dir, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := cache.Get("legacy"); !ok || string(data) != "legacy" {
+		t.Errorf("Get(legacy) = %q, %v", data, ok)
+	}
+	file, contentType, ok := cache.Open("proxied")
+	if !ok || contentType != "image/gif" {
+		t.Errorf("Open(proxied) = %q, %v, want image/gif", contentType, ok)
+	} else {
+		file.Close()
+	}
+	for _, name := range []string{"broken", "broken.meta", "orphan.meta", "tmp-123"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "legacy.meta")); err != nil {
+		t.Errorf("metadata for legacy entry was not written: %v", err)
+	}
+	if cache.size != 10 {
+		t.Errorf("restored size = %d, want 10", cache.size)
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := New(dir, 100, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put(t, cache, "old", "old")
+	put(t, cache, "new", "new")
+
+	// 将 old 的写入时间改为两小时前
+	e := cache.entries["old"].Value.(*entry)
+	e.CreateAt = time.Now().Add(-2 * time.Hour).UnixMilli()
+	if err := cache.writeMeta(e); err != nil {
+		t.Fatal(err)
+	}
+
+	if cached(cache, "old") {
+		t.Error("Open(old) hit for expired entry")
+	}
+	if !cached(cache, "new") {
+		t.Error("Open(new) missed")
+	}
+	if stats := cache.Stats(); stats.Expirations != 1 || stats.Entries != 1 {
+		t.Errorf("Stats() = %+v, want 1 expiration and 1 entry", stats)
+	}
+
+	// 访问不会延长有效期，重新打开时按元数据中的写入时间判断
+	e = cache.entries["new"].Value.(*entry)
+	e.CreateAt = time.Now().Add(-2 * time.Hour).UnixMilli()
+	if err := cache.writeMeta(e); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := New(dir, 100, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := reopened.Stats(); stats.Entries != 0 || stats.Expirations != 1 {
+		t.Errorf("Stats() after reopen = %+v, want no entries and 1 expiration", stats)
+	}
+}
@@ -0,0 +1,549 @@
+package entitycache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/satori-protocol-go/satori-model-go/pkg/channel"
+	"github.com/satori-protocol-go/satori-model-go/pkg/guild"
+	"github.com/satori-protocol-go/satori-model-go/pkg/guildmember"
+	"github.com/satori-protocol-go/satori-model-go/pkg/guildrole"
+	"github.com/satori-protocol-go/satori-model-go/pkg/user"
+)
+
+// Stats 缓存统计信息
+type Stats struct {
+	Guilds        int   `json:"guilds"`        // 缓存的群组数量
+	Channels      int   `json:"channels"`      // 缓存的频道数量
+	Members       int   `json:"members"`       // 缓存的群组成员数量
+	Roles         int   `json:"roles"`         // 缓存了角色列表的群组数量
+	MaxAge        int64 `json:"max_age"`       // 缓存项最长有效时间，单位秒，0 表示不过期
+	Hits          int64 `json:"hits"`          // 命中次数
+	Misses        int64 `json:"misses"`        // 未命中次数
+	Invalidations int64 `json:"invalidations"` // 因更新或删除事件失效的次数
+	Expirations   int64 `json:"expirations"`   // 因过期淘汰的次数
+}
+
+// item 缓存项
+type item[T any] struct {
+	value    T
+	updateAt time.Time
+}
+
+// channelItem 频道缓存项，记录频道所属的群组
+type channelItem struct {
+	guildId string
+	channel channel.Channel
+}
+
+// Cache 群组、频道、成员与角色的内存缓存
+//
+// 缓存项在超过 maxAge 后视为过期，更新与删除事件会使对应的缓存项立即失效
+type Cache struct {
+	maxAge        time.Duration
+	guilds        map[string]item[guild.Guild]
+	channels      map[string]item[channelItem]
+	guildChannels map[string]item[[]string] // 群组的完整频道列表
+	members       map[string]item[guildmember.GuildMember]
+	roles         map[string]item[[]guildrole.GuildRole] // 群组的完整角色列表
+	stats         Stats
+	mu            sync.Mutex
+}
+
+var instance *Cache
+
+// Setup 设置全局实体缓存，enable 为 false 时关闭缓存，maxAge 为 0 时缓存项不会过期
+func Setup(enable bool, maxAge time.Duration) {
+	if !enable {
+		instance = nil
+		return
+	}
+	cache := NewCache(maxAge)
+	instance = cache
+	if maxAge > 0 {
+		go cache.runSweeper()
+	}
+}
+
+// NewCache 创建实体缓存
+func NewCache(maxAge time.Duration) *Cache {
+	return &Cache{
+		maxAge:        maxAge,
+		guilds:        make(map[string]item[guild.Guild]),
+		channels:      make(map[string]item[channelItem]),
+		guildChannels: make(map[string]item[[]string]),
+		members:       make(map[string]item[guildmember.GuildMember]),
+		roles:         make(map[string]item[[]guildrole.GuildRole]),
+	}
+}
+
+// memberKey 生成群组成员缓存键
+func memberKey(guildId, userId string) string {
+	return guildId + "\x00" + userId
+}
+
+// copyMember 复制群组成员，避免调用方修改缓存中的用户对象
+func copyMember(member guildmember.GuildMember) *guildmember.GuildMember {
+	if member.User != nil {
+		u := *member.User
+		member.User = &u
+	}
+	return &member
+}
+
+// fresh 判断缓存项是否仍然有效
+func (c *Cache) fresh(updateAt time.Time) bool {
+	return c.maxAge <= 0 || time.Since(updateAt) < c.maxAge
+}
+
+// hit 记录一次查询结果
+func (c *Cache) hit(ok bool) bool {
+	if ok {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+	return ok
+}
+
+// lookup 在缓存表中查找未过期的缓存项，过期的缓存项会被删除
+func lookup[T any](c *Cache, m map[string]item[T], key string) (T, bool) {
+	entry, ok := m[key]
+	if ok && !c.fresh(entry.updateAt) {
+		delete(m, key)
+		c.stats.Expirations++
+		ok = false
+	}
+	return entry.value, c.hit(ok)
+}
+
+// GetGuild 获取群组
+func (c *Cache) GetGuild(guildId string) (*guild.Guild, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := lookup(c, c.guilds, guildId)
+	if !ok {
+		return nil, false
+	}
+	return &value, true
+}
+
+// SetGuild 写入群组
+func (c *Cache) SetGuild(g *guild.Guild) {
+	if g == nil || g.Id == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.guilds[g.Id] = item[guild.Guild]{*g, time.Now()}
+}
+
+// DeleteGuild 删除群组及其全部频道、成员与角色
+func (c *Cache) DeleteGuild(guildId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Invalidations++
+	delete(c.guilds, guildId)
+	delete(c.guildChannels, guildId)
+	delete(c.roles, guildId)
+	for id, entry := range c.channels {
+		if entry.value.guildId == guildId {
+			delete(c.channels, id)
+		}
+	}
+	prefix := memberKey(guildId, "")
+	for key := range c.members {
+		if len(key) > len(prefix) && key[:len(prefix)] == prefix {
+			delete(c.members, key)
+		}
+	}
+}
+
+// GetChannel 获取频道
+func (c *Cache) GetChannel(channelId string) (*channel.Channel, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := lookup(c, c.channels, channelId)
+	if !ok {
+		return nil, false
+	}
+	return &value.channel, true
+}
+
+// SetChannel 写入频道，guildId 为空时保留已缓存的所属群组
+func (c *Cache) SetChannel(guildId string, ch *channel.Channel) {
+	if ch == nil || ch.Id == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setChannel(guildId, ch, time.Now())
+}
+
+func (c *Cache) setChannel(guildId string, ch *channel.Channel, now time.Time) {
+	if guildId == "" {
+		guildId = c.channels[ch.Id].value.guildId
+	}
+	c.channels[ch.Id] = item[channelItem]{channelItem{guildId, *ch}, now}
+}
+
+// DeleteChannel 删除频道，同时使所属群组的频道列表失效
+func (c *Cache) DeleteChannel(channelId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Invalidations++
+	if entry, ok := c.channels[channelId]; ok {
+		delete(c.guildChannels, entry.value.guildId)
+	}
+	delete(c.channels, channelId)
+}
+
+// InvalidateChannels 使群组的频道列表失效
+func (c *Cache) InvalidateChannels(guildId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Invalidations++
+	delete(c.guildChannels, guildId)
+}
+
+// GetChannels 获取群组的完整频道列表，列表中任一频道失效时视为未命中
+func (c *Cache) GetChannels(guildId string) ([]*channel.Channel, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.guildChannels[guildId]
+	valid := ok && c.fresh(entry.updateAt)
+	var channels []*channel.Channel
+	if valid {
+		channels = make([]*channel.Channel, 0, len(entry.value))
+		for _, id := range entry.value {
+			channelEntry, ok := c.channels[id]
+			if !ok || !c.fresh(channelEntry.updateAt) {
+				valid = false
+				break
+			}
+			ch := channelEntry.value.channel
+			channels = append(channels, &ch)
+		}
+	}
+	if !valid {
+		if ok {
+			delete(c.guildChannels, guildId)
+			c.stats.Expirations++
+		}
+		return nil, c.hit(false)
+	}
+	return channels, c.hit(true)
+}
+
+// SetChannels 写入群组的完整频道列表
+func (c *Cache) SetChannels(guildId string, channels []*channel.Channel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	ids := make([]string, 0, len(channels))
+	for _, ch := range channels {
+		if ch == nil || ch.Id == "" {
+			continue
+		}
+		c.setChannel(guildId, ch, now)
+		ids = append(ids, ch.Id)
+	}
+	c.guildChannels[guildId] = item[[]string]{ids, now}
+}
+
+// GetMember 获取群组成员
+func (c *Cache) GetMember(guildId, userId string) (*guildmember.GuildMember, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := lookup(c, c.members, memberKey(guildId, userId))
+	if !ok {
+		return nil, false
+	}
+	return copyMember(value), true
+}
+
+// SetMember 写入群组成员，成员的 User 不能为空
+//
+// 新的成员信息中为空的字段会保留已缓存的值，以便合并来自不同事件的部分信息
+func (c *Cache) SetMember(guildId string, member *guildmember.GuildMember) {
+	if member == nil || member.User == nil || member.User.Id == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := memberKey(guildId, member.User.Id)
+	merged := *copyMember(*member)
+	if old, ok := c.members[key]; ok && c.fresh(old.updateAt) {
+		if merged.Nick == "" {
+			merged.Nick = old.value.Nick
+		}
+		if merged.Avatar == "" {
+			merged.Avatar = old.value.Avatar
+		}
+		if merged.JoinedAt == 0 {
+			merged.JoinedAt = old.value.JoinedAt
+		}
+		if old.value.User != nil {
+			if merged.User.Name == "" {
+				merged.User.Name = old.value.User.Name
+			}
+			if merged.User.Avatar == "" {
+				merged.User.Avatar = old.value.User.Avatar
+			}
+		}
+	}
+	c.members[key] = item[guildmember.GuildMember]{merged, time.Now()}
+}
+
+// GetUser 从任一群组的成员中获取用户，存在多个时取最近更新的一个
+func (c *Cache) GetUser(userId string) (*user.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var found item[guildmember.GuildMember]
+	for _, entry := range c.members {
+		if entry.value.User == nil || entry.value.User.Id != userId || !c.fresh(entry.updateAt) {
+			continue
+		}
+		if found.value.User == nil || entry.updateAt.After(found.updateAt) {
+			found = entry
+		}
+	}
+	if found.value.User == nil {
+		return nil, c.hit(false)
+	}
+	u := *found.value.User
+	if u.Avatar == "" {
+		u.Avatar = found.value.Avatar
+	}
+	return &u, c.hit(true)
+}
+
+// DeleteMember 删除群组成员
+func (c *Cache) DeleteMember(guildId, userId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Invalidations++
+	delete(c.members, memberKey(guildId, userId))
+}
+
+// GetRoles 获取群组的完整角色列表
+func (c *Cache) GetRoles(guildId string) ([]*guildrole.GuildRole, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := lookup(c, c.roles, guildId)
+	if !ok {
+		return nil, false
+	}
+	roles := make([]*guildrole.GuildRole, len(value))
+	for i := range value {
+		role := value[i]
+		roles[i] = &role
+	}
+	return roles, true
+}
+
+// SetRoles 写入群组的完整角色列表
+func (c *Cache) SetRoles(guildId string, roles []*guildrole.GuildRole) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value := make([]guildrole.GuildRole, 0, len(roles))
+	for _, role := range roles {
+		if role != nil {
+			value = append(value, *role)
+		}
+	}
+	c.roles[guildId] = item[[]guildrole.GuildRole]{value, time.Now()}
+}
+
+// InvalidateRoles 使群组的角色列表失效
+func (c *Cache) InvalidateRoles(guildId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Invalidations++
+	delete(c.roles, guildId)
+}
+
+// Clear 清空全部缓存项
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.guilds)
+	clear(c.channels)
+	clear(c.guildChannels)
+	clear(c.members)
+	clear(c.roles)
+}
+
+// Stats 获取缓存统计信息
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Guilds = len(c.guilds)
+	stats.Channels = len(c.channels)
+	stats.Members = len(c.members)
+	stats.Roles = len(c.roles)
+	stats.MaxAge = int64(c.maxAge / time.Second)
+	return stats
+}
+
+// sweep 删除全部过期的缓存项
+func (c *Cache) sweep() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Expirations += int64(sweepMap(c, c.guilds) + sweepMap(c, c.channels) +
+		sweepMap(c, c.guildChannels) + sweepMap(c, c.members) + sweepMap(c, c.roles))
+}
+
+func sweepMap[T any](c *Cache, m map[string]item[T]) int {
+	var count int
+	for key, entry := range m {
+		if !c.fresh(entry.updateAt) {
+			delete(m, key)
+			count++
+		}
+	}
+	return count
+}
+
+// runSweeper 周期性删除过期的缓存项，缓存被替换后停止
+func (c *Cache) runSweeper() {
+	for instance == c {
+		time.Sleep(c.maxAge)
+		c.sweep()
+	}
+}
+
+// GetGuild 从全局缓存中获取群组
+func GetGuild(guildId string) (*guild.Guild, bool) {
+	if instance == nil {
+		return nil, false
+	}
+	return instance.GetGuild(guildId)
+}
+
+// SetGuild 将群组写入全局缓存
+func SetGuild(g *guild.Guild) {
+	if instance != nil {
+		instance.SetGuild(g)
+	}
+}
+
+// DeleteGuild 从全局缓存中删除群组及其全部频道、成员与角色
+func DeleteGuild(guildId string) {
+	if instance != nil {
+		instance.DeleteGuild(guildId)
+	}
+}
+
+// GetChannel 从全局缓存中获取频道
+func GetChannel(channelId string) (*channel.Channel, bool) {
+	if instance == nil {
+		return nil, false
+	}
+	return instance.GetChannel(channelId)
+}
+
+// SetChannel 将频道写入全局缓存
+func SetChannel(guildId string, ch *channel.Channel) {
+	if instance != nil {
+		instance.SetChannel(guildId, ch)
+	}
+}
+
+// DeleteChannel 从全局缓存中删除频道
+func DeleteChannel(channelId string) {
+	if instance != nil {
+		instance.DeleteChannel(channelId)
+	}
+}
+
+// InvalidateChannels 使全局缓存中群组的频道列表失效
+func InvalidateChannels(guildId string) {
+	if instance != nil {
+		instance.InvalidateChannels(guildId)
+	}
+}
+
+// GetChannels 从全局缓存中获取群组的完整频道列表
+func GetChannels(guildId string) ([]*channel.Channel, bool) {
+	if instance == nil {
+		return nil, false
+	}
+	return instance.GetChannels(guildId)
+}
+
+// SetChannels 将群组的完整频道列表写入全局缓存
+func SetChannels(guildId string, channels []*channel.Channel) {
+	if instance != nil {
+		instance.SetChannels(guildId, channels)
+	}
+}
+
+// GetMember 从全局缓存中获取群组成员
+func GetMember(guildId, userId string) (*guildmember.GuildMember, bool) {
+	if instance == nil {
+		return nil, false
+	}
+	return instance.GetMember(guildId, userId)
+}
+
+// SetMember 将群组成员写入全局缓存
+func SetMember(guildId string, member *guildmember.GuildMember) {
+	if instance != nil {
+		instance.SetMember(guildId, member)
+	}
+}
+
+// GetUser 从全局缓存的群组成员中获取用户
+func GetUser(userId string) (*user.User, bool) {
+	if instance == nil {
+		return nil, false
+	}
+	return instance.GetUser(userId)
+}
+
+// DeleteMember 从全局缓存中删除群组成员
+func DeleteMember(guildId, userId string) {
+	if instance != nil {
+		instance.DeleteMember(guildId, userId)
+	}
+}
+
+// GetRoles 从全局缓存中获取群组的完整角色列表
+func GetRoles(guildId string) ([]*guildrole.GuildRole, bool) {
+	if instance == nil {
+		return nil, false
+	}
+	return instance.GetRoles(guildId)
+}
+
+// SetRoles 将群组的完整角色列表写入全局缓存
+func SetRoles(guildId string, roles []*guildrole.GuildRole) {
+	if instance != nil {
+		instance.SetRoles(guildId, roles)
+	}
+}
+
+// InvalidateRoles 使全局缓存中群组的角色列表失效
+func InvalidateRoles(guildId string) {
+	if instance != nil {
+		instance.InvalidateRoles(guildId)
+	}
+}
+
+// Clear 清空全局缓存
+func Clear() {
+	if instance != nil {
+		instance.Clear()
+	}
+}
+
+// GetStats 获取全局缓存统计信息
+func GetStats() Stats {
+	if instance == nil {
+		return Stats{}
+	}
+	return instance.Stats()
+}
@@ -0,0 +1,187 @@
+package entitycache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/satori-protocol-go/satori-model-go/pkg/channel"
+	"github.com/satori-protocol-go/satori-model-go/pkg/guild"
+	"github.com/satori-protocol-go/satori-model-go/pkg/guildmember"
+	"github.com/satori-protocol-go/satori-model-go/pkg/guildrole"
+	"github.com/satori-protocol-go/satori-model-go/pkg/user"
+)
+
+// newTestCache 创建写入了两个群组数据的缓存
+func newTestCache() *Cache {
+	c := NewCache(0)
+	for _, guildId := range []string{"g1", "g2"} {
+		c.SetGuild(&guild.Guild{Id: guildId, Name: guildId})
+		c.SetChannels(guildId, []*channel.Channel{
+			{Id: guildId + "-c1", Name: "c1"},
+			{Id: guildId + "-c2", Name: "c2"},
+		})
+		c.SetMember(guildId, &guildmember.GuildMember{User: &user.User{Id: "u1"}, Nick: guildId + "-nick"})
+		c.SetRoles(guildId, []*guildrole.GuildRole{{Id: "r1", Name: "role"}})
+	}
+	return c
+}
+
+// present 缓存项是否存在
+type present struct {
+	guild, channels, channel, member, roles bool
+}
+
+// check 检查群组 g1 的缓存项，并确认群组 g2 的缓存项未受影响
+func check(t *testing.T, c *Cache, want present) {
+	t.Helper()
+	for _, guildId := range []string{"g1", "g2"} {
+		expect := want
+		if guildId == "g2" {
+			expect = present{true, true, true, true, true}
+		}
+		_, ok := c.GetGuild(guildId)
+		if ok != expect.guild {
+			t.Errorf("GetGuild(%s) ok = %v, want %v", guildId, ok, expect.guild)
+		}
+		_, ok = c.GetChannels(guildId)
+		if ok != expect.channels {
+			t.Errorf("GetChannels(%s) ok = %v, want %v", guildId, ok, expect.channels)
+		}
+		_, ok = c.GetChannel(guildId + "-c2")
+		if ok != expect.channel {
+			t.Errorf("GetChannel(%s-c2) ok = %v, want %v", guildId, ok, expect.channel)
+		}
+		_, ok = c.GetMember(guildId, "u1")
+		if ok != expect.member {
+			t.Errorf("GetMember(%s, u1) ok = %v, want %v", guildId, ok, expect.member)
+		}
+		_, ok = c.GetRoles(guildId)
+		if ok != expect.roles {
+			t.Errorf("GetRoles(%s) ok = %v, want %v", guildId, ok, expect.roles)
+		}
+	}
+}
+
+func TestInvalidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		invalidate func(c *Cache)
+		want       present
+	}{
+		{
+			name:       "delete guild",
+			invalidate: func(c *Cache) { c.DeleteGuild("g1") },
+			want:       present{},
+		},
+		{
+			name:       "delete channel",
+			invalidate: func(c *Cache) { c.DeleteChannel("g1-c1") },
+			want:       present{guild: true, channel: true, member: true, roles: true},
+		},
+		{
+			name:       "delete unknown channel",
+			invalidate: func(c *Cache) { c.DeleteChannel("unknown") },
+			want:       present{true, true, true, true, true},
+		},
+		{
+			name:       "invalidate channels",
+			invalidate: func(c *Cache) { c.InvalidateChannels("g1") },
+			want:       present{guild: true, channel: true, member: true, roles: true},
+		},
+		{
+			name:       "delete member",
+			invalidate: func(c *Cache) { c.DeleteMember("g1", "u1") },
+			want:       present{guild: true, channels: true, channel: true, roles: true},
+		},
+		{
+			name:       "invalidate roles",
+			invalidate: func(c *Cache) { c.InvalidateRoles("g1") },
+			want:       present{guild: true, channels: true, channel: true, member: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCache()
+			tt.invalidate(c)
+			check(t, c, tt.want)
+			if stats := c.Stats(); stats.Invalidations != 1 {
+				t.Errorf("Stats().Invalidations = %d, want 1", stats.Invalidations)
+			}
+		})
+	}
+}
+
+func TestChannelsMissWhenChannelDeleted(t *testing.T) {
+	c := newTestCache()
+	// 频道列表保存的是频道 ID，单独写入的频道更新会体现在列表中
+	c.SetChannel("", &channel.Channel{Id: "g1-c1", Name: "renamed"})
+	channels, ok := c.GetChannels("g1")
+	if !ok || len(channels) != 2 || channels[0].Name != "renamed" {
+		t.Fatalf("GetChannels(g1) = %v, %v, want renamed first channel", channels, ok)
+	}
+	// 频道更新时保留已缓存的所属群组，删除时仍能使所属群组的频道列表失效
+	c.DeleteChannel("g1-c1")
+	if _, ok := c.GetChannels("g1"); ok {
+		t.Error("GetChannels(g1) hit after a listed channel was deleted")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	c := newTestCache()
+	c.maxAge = time.Minute
+	past := time.Now().Add(-time.Hour)
+	old := c.guilds["g1"]
+	old.updateAt = past
+	c.guilds["g1"] = old
+	member := c.members[memberKey("g1", "u1")]
+	member.updateAt = past
+	c.members[memberKey("g1", "u1")] = member
+
+	if _, ok := c.GetGuild("g1"); ok {
+		t.Error("GetGuild(g1) hit for expired entry")
+	}
+	if _, ok := c.GetGuild("g2"); !ok {
+		t.Error("GetGuild(g2) missed")
+	}
+	// 过期的成员不会参与合并
+	c.SetMember("g1", &guildmember.GuildMember{User: &user.User{Id: "u1"}})
+	if got, ok := c.GetMember("g1", "u1"); !ok || got.Nick != "" {
+		t.Errorf("GetMember(g1, u1) = %+v, %v, want member without the expired nick", got, ok)
+	}
+
+	c.sweep()
+	if stats := c.Stats(); stats.Guilds != 1 || stats.Expirations != 1 {
+		t.Errorf("Stats() = %+v, want 1 guild and 1 expiration", stats)
+	}
+}
+
+func TestSetMemberMerge(t *testing.T) {
+	c := NewCache(0)
+	c.SetMember("g", &guildmember.GuildMember{User: &user.User{Id: "u", Name: "name"}, Nick: "nick", JoinedAt: 10})
+	c.SetMember("g", &guildmember.GuildMember{User: &user.User{Id: "u", Avatar: "avatar"}})
+
+	got, ok := c.GetMember("g", "u")
+	if !ok {
+		t.Fatal("GetMember(g, u) missed")
+	}
+	if got.Nick != "nick" || got.JoinedAt != 10 || got.User.Name != "name" || got.User.Avatar != "avatar" {
+		t.Errorf("GetMember(g, u) = %+v, user %+v, want merged member", got, got.User)
+	}
+	// 修改返回值不会影响缓存
+	got.User.Name = "changed"
+	if u, ok := c.GetUser("u"); !ok || u.Name != "name" {
+		t.Errorf("GetUser(u) = %+v, %v, want name", u, ok)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	Setup(false, 0)
+	SetGuild(&guild.Guild{Id: "g"})
+	if _, ok := GetGuild("g"); ok {
+		t.Error("GetGuild(g) hit while the cache is disabled")
+	}
+	DeleteGuild("g")
+	if stats := GetStats(); stats != (Stats{}) {
+		t.Errorf("GetStats() = %+v, want zero", stats)
+	}
+}
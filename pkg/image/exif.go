@@ -0,0 +1,78 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// jpegOrientation 读取 JPEG 文件 EXIF 中的方向信息，不存在时返回 1
+func jpegOrientation(data []byte) int {
+	if !bytes.HasPrefix(data, []byte(HeaderJPG)) {
+		return 1
+	}
+
+	// 遍历 JPEG 标记段寻找 APP1 EXIF 段
+	offset := 2
+	for offset+4 <= len(data) {
+		if data[offset] != 0xFF {
+			return 1
+		}
+		marker := data[offset+1]
+		if marker == 0xD8 || (marker >= 0xD0 && marker <= 0xD7) {
+			offset += 2
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			// 已进入图像数据
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[offset+2 : offset+4]))
+		start := offset + 4
+		end := offset + 2 + length
+		if length < 2 || end > len(data) {
+			return 1
+		}
+		if marker == 0xE1 && bytes.HasPrefix(data[start:end], []byte("Exif\x00\x00")) {
+			return exifOrientation(data[start+6 : end])
+		}
+		offset = end
+	}
+	return 1
+}
+
+// exifOrientation 从 TIFF 结构中读取方向信息
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8 : entry+10]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
@@ -0,0 +1,147 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+
+	"github.com/disintegration/imaging"
+)
+
+// gifPalette 动图编码使用的调色板，最后一位为透明色
+var gifPalette = func() color.Palette {
+	p := make(color.Palette, 0, len(palette.WebSafe)+1)
+	p = append(p, palette.WebSafe...)
+	p = append(p, color.NRGBA{})
+	return p
+}()
+
+// decodeGIF 解码 GIF 动图，并将每一帧合成为完整画布
+func decodeGIF(data []byte) (*animation, error) {
+	config, err := gif.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPixels(config.Width, config.Height, 1); err != nil {
+		return nil, err
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := g.Config.Width, g.Config.Height
+	if width == 0 || height == 0 {
+		bounds := g.Image[0].Bounds()
+		width, height = bounds.Dx(), bounds.Dy()
+	}
+	// 每一帧都会合成为完整画布
+	if err := checkPixels(width, height, len(g.Image)); err != nil {
+		return nil, err
+	}
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	anim := &animation{loopCount: g.LoopCount}
+	for i, frame := range g.Image {
+		var previous *image.NRGBA
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewNRGBA(canvas.Bounds())
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		snapshot := image.NewNRGBA(canvas.Bounds())
+		copy(snapshot.Pix, canvas.Pix)
+		anim.frames = append(anim.frames, snapshot)
+		delay := 0
+		if i < len(g.Delay) {
+			delay = g.Delay[i]
+		}
+		anim.delays = append(anim.delays, delay)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			copy(canvas.Pix, previous.Pix)
+		}
+	}
+
+	return anim, nil
+}
+
+// encodeAnimation 将动图编码为 GIF ，必要时缩放至边长与大小上限以内
+func encodeAnimation(ctx context.Context, anim *animation, opts Options) ([]byte, error) {
+	limit := opts.MaxDimension
+	for {
+		data, size, err := encodeFrames(ctx, anim, limit)
+		if err != nil {
+			return nil, err
+		}
+		if opts.MaxSize <= 0 || int64(len(data)) <= opts.MaxSize {
+			return data, nil
+		}
+
+		// 超出大小上限时逐步缩小尺寸
+		limit = size * 3 / 4
+		if limit <= 16 {
+			return nil, fmt.Errorf("failed to compress animation under %d bytes", opts.MaxSize)
+		}
+	}
+}
+
+// encodeFrames 将动图的每一帧缩放至指定边长以内后编码为 GIF ，同时返回编码后的最大边长
+func encodeFrames(ctx context.Context, anim *animation, maxDimension int) ([]byte, int, error) {
+	g := &gif.GIF{LoopCount: anim.loopCount}
+	size := 0
+	for i, frame := range anim.frames {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		var img image.Image = frame
+		if exceeds(frame.Bounds(), maxDimension) {
+			img = imaging.Fit(frame, maxDimension, maxDimension, imaging.Lanczos)
+		}
+
+		bounds := img.Bounds()
+		size = max(size, bounds.Dx(), bounds.Dy())
+		paletted := image.NewPaletted(bounds, gifPalette)
+		draw.FloydSteinberg.Draw(paletted, bounds, img, bounds.Min)
+
+		// 将透明像素映射到调色板的透明色
+		transparent := uint8(len(gifPalette) - 1)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				if _, _, _, a := img.At(x, y).RGBA(); a < 0x8000 {
+					paletted.SetColorIndex(x, y, transparent)
+				}
+			}
+		}
+
+		g.Image = append(g.Image, paletted)
+		g.Delay = append(g.Delay, anim.delays[i])
+		// 每一帧都是完整画布，绘制前需要清空上一帧
+		g.Disposal = append(g.Disposal, gif.DisposalBackground)
+	}
+
+	buffer := new(bytes.Buffer)
+	if err := gif.EncodeAll(buffer, g); err != nil {
+		return nil, 0, err
+	}
+	return buffer.Bytes(), size, nil
+}
+
+// exceeds 判断尺寸是否超出上限
+func exceeds(bounds image.Rectangle, maxDimension int) bool {
+	return maxDimension > 0 && (bounds.Dx() > maxDimension || bounds.Dy() > maxDimension)
+}
@@ -2,8 +2,10 @@ package image
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"image"
 	"image/jpeg"
@@ -11,10 +13,14 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"path"
+	"os/exec"
+	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/WindowsSov8forUs/glyccat/pkg/mediacache"
 	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp"
 )
 
 const (
@@ -28,6 +34,52 @@ const cachePath = "data/cache"
 
 const limit = 4 * 1024
 
+// minQuality 压缩 JPEG 时允许的最低质量
+const minQuality = 40
+
+// 解码前检查的像素数上限，防止极小的文件解码出巨大的图像耗尽内存
+const (
+	maxPixels          = 40 * 1000 * 1000  // 单张图像的像素数上限
+	maxAnimationPixels = 100 * 1000 * 1000 // 动图所有帧的像素数之和上限
+)
+
+// ErrTooManyPixels 图像像素数超出解码上限
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+// Options 图像规范化参数
+type Options struct {
+	MaxSize      int64 // 图像大小上限，单位字节
+	MaxDimension int   // 图像边长上限，单位像素
+	Quality      int   // JPEG 编码质量
+}
+
+var (
+	options = Options{
+		MaxSize:      10 * 1024 * 1024,
+		MaxDimension: 4096,
+		Quality:      85,
+	}
+	optionsMutex sync.RWMutex
+)
+
+// SetOptions 设置图像规范化参数
+func SetOptions(opts Options) {
+	if opts.Quality <= 0 || opts.Quality > 100 {
+		opts.Quality = 85
+	}
+
+	optionsMutex.Lock()
+	defer optionsMutex.Unlock()
+	options = opts
+}
+
+// getOptions 获取图像规范化参数
+func getOptions() Options {
+	optionsMutex.RLock()
+	defer optionsMutex.RUnlock()
+	return options
+}
+
 // IsGIForPNGorJPG 判断是否为 GIF/PNG/JPG
 func IsGIForPNGorJPG(file []byte) bool {
 	if len(file) < 8 {
@@ -45,6 +97,42 @@ func IsGIForPNGorJPG(file []byte) bool {
 	return false
 }
 
+// DetectHEIF 判断是否为 HEIC/AVIF 图像，返回对应的 MIME 类型
+func DetectHEIF(file []byte) (string, bool) {
+	if len(file) < 12 || string(file[4:8]) != "ftyp" {
+		return "", false
+	}
+	switch string(file[8:12]) {
+	case "avif", "avis":
+		return "image/avif", true
+	case "heic", "heix", "heim", "heis", "hevc", "hevx", "mif1", "msf1":
+		return "image/heic", true
+	}
+	return "", false
+}
+
+// NeedsEncode 判断图像是否需要重新编码才能发送
+func NeedsEncode(file []byte) bool {
+	if !IsGIForPNGorJPG(file) {
+		return true
+	}
+
+	opts := getOptions()
+	if opts.MaxSize > 0 && int64(len(file)) > opts.MaxSize {
+		return true
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(file))
+	if err != nil {
+		return false
+	}
+	if opts.MaxDimension > 0 && (config.Width > opts.MaxDimension || config.Height > opts.MaxDimension) {
+		return true
+	}
+
+	// 带有旋转信息的 JPEG 在部分客户端上会显示错误
+	return jpegOrientation(file) != 1
+}
+
 // CheckImage 判断给定图像流是否为合法图像
 func CheckImage(readSeeker io.ReadSeeker) (string, bool) {
 	t := scanType(readSeeker)
@@ -60,67 +148,186 @@ func scanType(readerSeeker io.ReadSeeker) string {
 	defer readerSeeker.Seek(0, io.SeekStart)
 	in := make([]byte, limit)
 	_, _ = readerSeeker.Read(in)
+	if t, ok := DetectHEIF(in); ok {
+		return t
+	}
 	return http.DetectContentType(in)
 }
 
 // EncoderImage 重编码图像
 func EncoderImage(data []byte) ([]byte, error) {
+	return EncoderImageContext(context.Background(), data)
+}
+
+// EncoderImageContext 重编码图像，ctx 取消时在各处理阶段之间返回，外部进程会随之终止
+func EncoderImageContext(ctx context.Context, data []byte) ([]byte, error) {
 	hash := md5.New()
 	_, err := hash.Write(data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compute md5: %v", err)
 	}
 	name := hex.EncodeToString(hash.Sum(nil))
-	return encode(data, name)
-}
 
-// encode 编码为 MP4
-func encode(data []byte, name string) (imageData []byte, err error) {
-	// 0. 创建缓存目录
-	err = createDirectoryIfNotExist(cachePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create image cache directory: %v", err)
+	// 相同内容与参数的转码结果可以直接复用
+	opts := getOptions()
+	key := mediacache.Key(data, "image",
+		strconv.FormatInt(opts.MaxSize, 10),
+		strconv.Itoa(opts.MaxDimension),
+		strconv.Itoa(opts.Quality),
+	)
+	if cached, ok := mediacache.Get(key); ok {
+		return cached, nil
 	}
 
-	// 1. 创建临时文件
-	rawPath := path.Join(cachePath, name)
-	err = os.WriteFile(rawPath, data, os.ModePerm)
+	imageData, err := encode(ctx, data, name, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temporary file: %v", err)
+		return nil, err
 	}
-	defer os.Remove(rawPath)
+	mediacache.Put(key, imageData)
+	return imageData, nil
+}
 
-	// 2. 检查图像格式
-	img, err := imaging.Open(rawPath)
+// encode 将图像规范化为 QQ 可接受的格式与大小
+func encode(ctx context.Context, data []byte, name string, opts Options) ([]byte, error) {
+	// 1. 动图转换为 GIF
+	if IsAnimatedWebP(data) {
+		anim, err := decodeAnimatedWebP(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode animated webp: %w", err)
+		}
+		return encodeAnimation(ctx, anim, opts)
+	}
+	if bytes.HasPrefix(data, []byte(HeaderGIF)) || bytes.HasPrefix(data, []byte(HeaderGIF2)) {
+		anim, err := decodeGIF(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode gif: %w", err)
+		}
+		if len(anim.frames) > 1 {
+			return encodeAnimation(ctx, anim, opts)
+		}
+	}
+
+	// 2. HEIC/AVIF 无法在进程内解码，交由 ffmpeg 转换
+	if _, ok := DetectHEIF(data); ok {
+		converted, err := convertWithFFmpeg(ctx, data, name)
+		if err != nil {
+			return nil, err
+		}
+		data = converted
+	}
+
+	// 3. 解码并根据 EXIF 信息旋转
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
 	if err != nil {
-		return nil, fmt.Errorf("failed to open temporary file: %v", err)
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	if err := checkPixels(config.Width, config.Height, 1); err != nil {
+		return nil, err
 	}
-	reader := bytes.NewReader(data)
-	_, format, err := image.DecodeConfig(reader)
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode temporary file: %v", err)
+		return nil, fmt.Errorf("failed to decode image: %v", err)
 	}
 
-	// 3. 根据不同图像格式处理
-	buffer := new(bytes.Buffer)
-	switch format {
-	case "bmp":
-		// 转换为 JPG
-		err = jpeg.Encode(buffer, img, nil)
+	// 4. 缩放至边长上限以内
+	if exceeds(img.Bounds(), opts.MaxDimension) {
+		img = imaging.Fit(img, opts.MaxDimension, opts.MaxDimension, imaging.Lanczos)
+	}
+
+	// 5. 有损格式与不透明图像编码为 JPEG ，其余编码为 PNG
+	opaque := isOpaque(img)
+	useJPEG := opaque && (format == "jpeg" || format == "bmp" || (format == "webp" && isLossyWebP(data)))
+	quality := opts.Quality
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		imageData, err := encodeStatic(img, useJPEG, quality)
 		if err != nil {
+			return nil, err
+		}
+		if opts.MaxSize <= 0 || int64(len(imageData)) <= opts.MaxSize {
+			return imageData, nil
+		}
+
+		// 超出大小上限时依次尝试：改用 JPEG 、降低质量、缩小尺寸
+		switch {
+		case !useJPEG && opaque:
+			useJPEG = true
+		case useJPEG && quality > minQuality:
+			quality -= 10
+			if quality < minQuality {
+				quality = minQuality
+			}
+		default:
+			bounds := img.Bounds()
+			if bounds.Dx() <= 16 || bounds.Dy() <= 16 {
+				return nil, fmt.Errorf("failed to compress image under %d bytes", opts.MaxSize)
+			}
+			img = imaging.Resize(img, bounds.Dx()*3/4, 0, imaging.Lanczos)
+		}
+	}
+}
+
+// checkPixels 检查指定尺寸与帧数的图像解码后是否超出像素数上限
+func checkPixels(width, height, frames int) error {
+	pixels := int64(width) * int64(height)
+	if pixels > maxPixels || pixels*int64(frames) > maxAnimationPixels {
+		return fmt.Errorf("%w: %dx%d, %d frames", ErrTooManyPixels, width, height, frames)
+	}
+	return nil
+}
+
+// encodeStatic 编码静态图像
+func encodeStatic(img image.Image, useJPEG bool, quality int) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	if useJPEG {
+		if err := jpeg.Encode(buffer, img, &jpeg.Options{Quality: quality}); err != nil {
 			return nil, fmt.Errorf("failed to convert image to jpg: %v", err)
 		}
-		imageData = buffer.Bytes()
-	default:
-		// 转换为 PNG
-		err = png.Encode(buffer, img)
-		if err != nil {
+	} else {
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := encoder.Encode(buffer, img); err != nil {
 			return nil, fmt.Errorf("failed to convert image to png: %v", err)
 		}
-		imageData = buffer.Bytes()
 	}
+	return buffer.Bytes(), nil
+}
 
-	return imageData, nil
+// convertWithFFmpeg 通过 ffmpeg 将图像转换为 PNG
+func convertWithFFmpeg(ctx context.Context, data []byte, name string) ([]byte, error) {
+	err := createDirectoryIfNotExist(cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image cache directory: %v", err)
+	}
+
+	// 相同内容的图像可能被并发转换，临时文件名需要唯一
+	rawFile, err := os.CreateTemp(cachePath, name+"_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	rawPath := rawFile.Name()
+	defer os.Remove(rawPath)
+	_, err = rawFile.Write(data)
+	rawFile.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %v", err)
+	}
+
+	pngPath := rawPath + ".png"
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-i", rawPath, "-frames:v", "1", "-y", pngPath)
+	if errors.Is(cmd.Err, exec.ErrDot) {
+		cmd.Err = nil
+	}
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to convert image with ffmpeg: %w", err)
+	}
+	defer os.Remove(pngPath)
+
+	return os.ReadFile(pngPath)
 }
 
 // createDirectoryIfNotExist 检查目录是否存在，不存在则创建
@@ -0,0 +1,232 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+
+	"golang.org/x/image/webp"
+)
+
+var errInvalidWebP = errors.New("invalid webp")
+
+const (
+	webpAnimationBit = 1 << 1 // VP8X 动图标志
+	webpAlphaBit     = 1 << 4 // VP8X 透明通道标志
+)
+
+// webpChunk WebP 文件中的 RIFF 块
+type webpChunk struct {
+	id   string
+	data []byte
+}
+
+// readWebPChunks 读取 WebP 文件中的所有 RIFF 块
+func readWebPChunks(data []byte) ([]webpChunk, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errInvalidWebP
+	}
+	return readChunks(data[12:])
+}
+
+// readChunks 读取连续的 RIFF 块
+func readChunks(data []byte) ([]webpChunk, error) {
+	var chunks []webpChunk
+	for offset := 0; offset+8 <= len(data); {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		start := offset + 8
+		if size < 0 || start+size > len(data) {
+			return nil, errInvalidWebP
+		}
+		chunks = append(chunks, webpChunk{id: id, data: data[start : start+size]})
+		// 块按偶数字节对齐
+		offset = start + size + size%2
+	}
+	return chunks, nil
+}
+
+// IsAnimatedWebP 判断是否为 WebP 动图
+func IsAnimatedWebP(data []byte) bool {
+	chunks, err := readWebPChunks(data)
+	if err != nil || len(chunks) == 0 || chunks[0].id != "VP8X" || len(chunks[0].data) < 1 {
+		return false
+	}
+	return chunks[0].data[0]&webpAnimationBit != 0
+}
+
+// isLossyWebP 判断是否为有损压缩的 WebP 图像
+func isLossyWebP(data []byte) bool {
+	chunks, err := readWebPChunks(data)
+	if err != nil {
+		return false
+	}
+	for _, chunk := range chunks {
+		if chunk.id == "VP8 " {
+			return true
+		}
+	}
+	return false
+}
+
+// animation 解码后的动图，每一帧都是完整画布
+type animation struct {
+	frames    []*image.NRGBA
+	delays    []int // 每帧时长，单位 10 毫秒
+	loopCount int
+}
+
+// decodeAnimatedWebP 解码 WebP 动图
+func decodeAnimatedWebP(data []byte) (*animation, error) {
+	chunks, err := readWebPChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 || chunks[0].id != "VP8X" || len(chunks[0].data) < 10 {
+		return nil, errInvalidWebP
+	}
+
+	header := chunks[0].data
+	canvasWidth := int(readUint24(header[4:7])) + 1
+	canvasHeight := int(readUint24(header[7:10])) + 1
+	frames := 0
+	for _, chunk := range chunks[1:] {
+		if chunk.id == "ANMF" {
+			frames++
+		}
+	}
+	// 每一帧都会合成为完整画布
+	if err := checkPixels(canvasWidth, canvasHeight, max(frames, 1)); err != nil {
+		return nil, err
+	}
+	canvas := image.NewNRGBA(image.Rect(0, 0, canvasWidth, canvasHeight))
+
+	anim := &animation{}
+	for _, chunk := range chunks[1:] {
+		switch chunk.id {
+		case "ANIM":
+			if len(chunk.data) >= 6 {
+				anim.loopCount = int(binary.LittleEndian.Uint16(chunk.data[4:6]))
+			}
+		case "ANMF":
+			if len(chunk.data) < 16 {
+				return nil, errInvalidWebP
+			}
+			x := int(readUint24(chunk.data[0:3])) * 2
+			y := int(readUint24(chunk.data[3:6])) * 2
+			width := int(readUint24(chunk.data[6:9])) + 1
+			height := int(readUint24(chunk.data[9:12])) + 1
+			duration := int(readUint24(chunk.data[12:15]))
+			flags := chunk.data[15]
+			if err := checkPixels(width, height, 1); err != nil {
+				return nil, err
+			}
+
+			frame, err := decodeWebPFrame(chunk.data[16:], width, height)
+			if err != nil {
+				return nil, err
+			}
+
+			rect := image.Rect(x, y, x+width, y+height)
+			if flags&0x02 != 0 {
+				// 不进行混合，直接覆盖
+				draw.Draw(canvas, rect, frame, image.Point{}, draw.Src)
+			} else {
+				draw.Draw(canvas, rect, frame, image.Point{}, draw.Over)
+			}
+
+			snapshot := image.NewNRGBA(canvas.Bounds())
+			copy(snapshot.Pix, canvas.Pix)
+			anim.frames = append(anim.frames, snapshot)
+			anim.delays = append(anim.delays, (duration+5)/10)
+
+			if flags&0x01 != 0 {
+				// 显示后将区域清理为背景
+				draw.Draw(canvas, rect, image.Transparent, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	if len(anim.frames) == 0 {
+		return nil, errInvalidWebP
+	}
+	return anim, nil
+}
+
+// decodeWebPFrame 将动图帧数据封装为独立的 WebP 文件后解码
+func decodeWebPFrame(frameData []byte, width, height int) (image.Image, error) {
+	chunks, err := readChunks(frameData)
+	if err != nil {
+		return nil, err
+	}
+
+	body := new(bytes.Buffer)
+	hasAlpha := false
+	for _, chunk := range chunks {
+		if chunk.id == "ALPH" {
+			hasAlpha = true
+		}
+	}
+	if hasAlpha {
+		// 带有 ALPH 块的 VP8 帧需要 VP8X 头
+		vp8x := make([]byte, 10)
+		vp8x[0] = webpAlphaBit
+		putUint24(vp8x[4:7], uint32(width-1))
+		putUint24(vp8x[7:10], uint32(height-1))
+		writeChunk(body, "VP8X", vp8x)
+	}
+	for _, chunk := range chunks {
+		switch chunk.id {
+		case "ALPH", "VP8 ", "VP8L":
+			writeChunk(body, chunk.id, chunk.data)
+		}
+	}
+
+	file := new(bytes.Buffer)
+	file.WriteString("RIFF")
+	_ = binary.Write(file, binary.LittleEndian, uint32(4+body.Len()))
+	file.WriteString("WEBP")
+	file.Write(body.Bytes())
+
+	return webp.Decode(file)
+}
+
+// writeChunk 写入 RIFF 块
+func writeChunk(buf *bytes.Buffer, id string, data []byte) {
+	buf.WriteString(id)
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	if len(data)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+// readUint24 读取小端序 24 位整数
+func readUint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+// putUint24 写入小端序 24 位整数
+func putUint24(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+}
+
+// isOpaque 判断图像是否完全不透明
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+	return true
+}
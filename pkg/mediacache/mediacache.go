@@ -0,0 +1,68 @@
+package mediacache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/WindowsSov8forUs/glyccat/pkg/diskcache"
+)
+
+// Stats 缓存统计信息
+type Stats = diskcache.Stats
+
+// instance 全局转码缓存，以内容寻址保存转码结果
+var instance *diskcache.Cache
+
+// Setup 设置全局转码缓存，maxSize 为 0 时关闭缓存
+func Setup(dir string, maxSize int64, maxAge time.Duration) error {
+	if maxSize <= 0 {
+		instance = nil
+		return nil
+	}
+
+	cache, err := diskcache.New(dir, maxSize, maxAge)
+	if err != nil {
+		instance = nil
+		return err
+	}
+	instance = cache
+	return nil
+}
+
+// Key 根据输入内容、目标格式与转码参数计算缓存键
+func Key(data []byte, target string, options ...string) string {
+	h := sha256.New()
+	h.Write(data)
+	h.Write([]byte{0})
+	h.Write([]byte(target))
+	for _, option := range options {
+		h.Write([]byte{0})
+		h.Write([]byte(option))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get 从全局缓存中获取转码结果
+func Get(key string) ([]byte, bool) {
+	if instance == nil {
+		return nil, false
+	}
+	return instance.Get(key)
+}
+
+// Put 将转码结果放入全局缓存
+func Put(key string, data []byte) {
+	if instance == nil {
+		return
+	}
+	_ = instance.Put(key, data, "")
+}
+
+// GetStats 获取全局缓存统计信息
+func GetStats() Stats {
+	if instance == nil {
+		return Stats{}
+	}
+	return instance.Stats()
+}
@@ -0,0 +1,68 @@
+package mediacache
+
+import (
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	data := []byte("audio data")
+	key := Key(data, "silk", "24000")
+	if got := Key(data, "silk", "24000"); got != key {
+		t.Errorf("Key() = %s, want stable %s", got, key)
+	}
+	if len(key) != 64 {
+		t.Errorf("Key() length = %d, want 64", len(key))
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		target  string
+		options []string
+	}{
+		{"different data", []byte("other data"), "silk", []string{"24000"}},
+		{"different target", data, "mp3", []string{"24000"}},
+		{"different option", data, "silk", []string{"16000"}},
+		{"no option", data, "silk", nil},
+		{"extra option", data, "silk", []string{"24000", "in-process"}},
+		{"target merged into data", []byte("audio datasilk"), "", []string{"24000"}},
+		{"option merged into target", data, "silk24000", nil},
+	}
+	for _, tt := range tests {
+		if got := Key(tt.data, tt.target, tt.options...); got == key {
+			t.Errorf("%s: Key() = %s, same as the original key", tt.name, got)
+		}
+	}
+}
+
+func TestSetup(t *testing.T) {
+	t.Cleanup(func() { instance = nil })
+
+	if err := Setup(t.TempDir(), 1024, 0); err != nil {
+		t.Fatal(err)
+	}
+	Put("a", []byte("data"))
+	if data, ok := Get("a"); !ok || string(data) != "data" {
+		t.Fatalf("Get(a) = %q, %v, want data", data, ok)
+	}
+	if stats := GetStats(); stats.Entries != 1 {
+		t.Errorf("GetStats() = %+v, want 1 entry", stats)
+	}
+
+	// 大小上限不大于 0 时关闭缓存
+	for _, maxSize := range []int64{0, -1} {
+		if err := Setup(t.TempDir(), maxSize, 0); err != nil {
+			t.Fatal(err)
+		}
+		Put("b", []byte("data"))
+		if _, ok := Get("a"); ok {
+			t.Errorf("Setup(%d): Get(a) hit a disabled cache", maxSize)
+		}
+		if _, ok := Get("b"); ok {
+			t.Errorf("Setup(%d): Get(b) hit a disabled cache", maxSize)
+		}
+		if stats := GetStats(); stats != (Stats{}) {
+			t.Errorf("Setup(%d): GetStats() = %+v, want empty", maxSize, stats)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// ErrFastStartUnsupported 无法在不借助 ffmpeg 的情况下前置 moov
+var ErrFastStartUnsupported = errors.New("faststart remux is not supported for this file")
+
+// containerBoxes 需要递归查找块偏移表的容器盒子
+var containerBoxes = map[string]bool{
+	"moov": true,
+	"trak": true,
+	"mdia": true,
+	"minf": true,
+	"stbl": true,
+}
+
+// FastStart 将 moov 盒子移动至 mdat 之前，使视频可以边下边播
+//
+// 已经满足条件的文件将原样返回
+func FastStart(file []byte) ([]byte, error) {
+	if !IsMP4(file) {
+		return nil, ErrNotMP4
+	}
+	boxes, err := readBoxes(file)
+	if err != nil {
+		return nil, err
+	}
+
+	moovIndex, mdatIndex := -1, -1
+	for i, b := range boxes {
+		switch b.typ {
+		case "moov":
+			if moovIndex >= 0 {
+				return nil, ErrFastStartUnsupported
+			}
+			moovIndex = i
+		case "mdat":
+			if mdatIndex < 0 {
+				mdatIndex = i
+			}
+		case "moof":
+			// 分片 MP4 的偏移量不在 moov 中
+			return nil, ErrFastStartUnsupported
+		}
+	}
+	if moovIndex < 0 {
+		return nil, ErrMissingMoov
+	}
+	if mdatIndex < 0 || moovIndex < mdatIndex {
+		return file, nil
+	}
+
+	// moov 插入至第一个 mdat 之前，二者之间的数据整体后移 moov 的长度
+	moovBox := boxes[moovIndex]
+	insertAt := boxes[mdatIndex].offset
+	shift := moovBox.size
+
+	moov := make([]byte, moovBox.size)
+	copy(moov, file[moovBox.offset:moovBox.offset+moovBox.size])
+	err = shiftChunkOffsets(moov[moovBox.header:], func(offset uint64) uint64 {
+		if offset >= uint64(insertAt) && offset < uint64(moovBox.offset) {
+			return offset + uint64(shift)
+		}
+		return offset
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, 0, len(file))
+	result = append(result, file[:insertAt]...)
+	result = append(result, moov...)
+	result = append(result, file[insertAt:moovBox.offset]...)
+	result = append(result, file[moovBox.offset+moovBox.size:]...)
+	return result, nil
+}
+
+// shiftChunkOffsets 修正 stco/co64 盒子中的块偏移量
+func shiftChunkOffsets(data []byte, adjust func(uint64) uint64) error {
+	boxes, err := readBoxes(data)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range boxes {
+		payload := b.payload(data)
+		switch {
+		case containerBoxes[b.typ]:
+			if err := shiftChunkOffsets(payload, adjust); err != nil {
+				return err
+			}
+		case b.typ == "stco":
+			entries, ok := tableEntries(payload, 4)
+			if !ok {
+				return ErrInvalidBox
+			}
+			for i := 0; i < entries; i++ {
+				pos := 8 + i*4
+				offset := adjust(uint64(binary.BigEndian.Uint32(payload[pos:])))
+				if offset > math.MaxUint32 {
+					// 需要将 stco 升级为 co64 ，交由 ffmpeg 处理
+					return ErrFastStartUnsupported
+				}
+				binary.BigEndian.PutUint32(payload[pos:], uint32(offset))
+			}
+		case b.typ == "co64":
+			entries, ok := tableEntries(payload, 8)
+			if !ok {
+				return ErrInvalidBox
+			}
+			for i := 0; i < entries; i++ {
+				pos := 8 + i*8
+				binary.BigEndian.PutUint64(payload[pos:], adjust(binary.BigEndian.Uint64(payload[pos:])))
+			}
+		}
+	}
+	return nil
+}
+
+// tableEntries 获取偏移表的条目数，并检查长度是否足够
+func tableEntries(payload []byte, width int) (int, bool) {
+	if len(payload) < 8 {
+		return 0, false
+	}
+	entries := int(binary.BigEndian.Uint32(payload[4:]))
+	if entries < 0 || (len(payload)-8)/width < entries {
+		return 0, false
+	}
+	return entries, true
+}
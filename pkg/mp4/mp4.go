@@ -2,6 +2,7 @@ package mp4
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
@@ -9,27 +10,83 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
-	"path"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/WindowsSov8forUs/glyccat/pkg/mediacache"
 )
 
 const cachePath = "data/cache"
 
 const limit = 4 * 1024
 
-var HeadersMP4 []string = []string{
-	"ftypisom",
-	"ftypmp42",
+// audioBitrate 重新编码时的音频码率，单位 bit/s
+const audioBitrate = 128 * 1000
+
+// minVideoBitrate 按大小限制计算码率时允许的最低视频码率，单位 bit/s
+const minVideoBitrate = 200 * 1000
+
+// Options 视频规范化参数
+type Options struct {
+	MaxSize    int64 // 视频大小上限，单位字节
+	MaxBitrate int64 // 视频码率上限，单位 bit/s
+}
+
+var (
+	options = Options{
+		MaxSize:    100 * 1024 * 1024,
+		MaxBitrate: 5000 * 1000,
+	}
+	optionsMutex sync.RWMutex
+)
+
+// SetOptions 设置视频规范化参数
+func SetOptions(opts Options) {
+	optionsMutex.Lock()
+	defer optionsMutex.Unlock()
+	options = opts
+}
+
+// getOptions 获取视频规范化参数
+func getOptions() Options {
+	optionsMutex.RLock()
+	defer optionsMutex.RUnlock()
+	return options
 }
 
 // IsMP4 判断是否为 MP4 文件
 func IsMP4(file []byte) bool {
-	for _, header := range HeadersMP4 {
-		if bytes.HasPrefix(file, []byte(header)) {
-			return true
-		}
+	if len(file) < 12 || !bytes.Equal(file[4:8], []byte("ftyp")) {
+		return false
+	}
+	// HEIC/AVIF 与 QuickTime 同样以 ftyp 盒子开头
+	brand := string(file[8:12])
+	return !imageBrands[brand] && brand != "qt  "
+}
+
+// NeedsEncode 判断视频是否需要重新编码才能发送
+func NeedsEncode(file []byte) bool {
+	info, err := Probe(file)
+	if err != nil || !info.Supported() {
+		return true
+	}
+
+	opts := getOptions()
+	if opts.MaxSize > 0 && int64(len(file)) > opts.MaxSize {
+		return true
+	}
+	return opts.MaxBitrate > 0 && info.Bitrate > opts.MaxBitrate
+}
+
+// NeedsProcess 判断视频是否需要重新编码或重新封装才能发送
+func NeedsProcess(file []byte) bool {
+	if NeedsEncode(file) {
+		return true
 	}
-	return false
+	info, err := Probe(file)
+	return err != nil || !info.FastStart
 }
 
 // CheckVideo 判断给定视频流是否为合法视频
@@ -52,46 +109,186 @@ func scanType(readerSeeker io.ReadSeeker) string {
 
 // EncoderMP4 编码为 MP4
 func EncoderMP4(data []byte) ([]byte, error) {
-	hash := md5.New()
-	_, err := hash.Write(data)
+	return EncoderMP4Context(context.Background(), data)
+}
+
+// EncoderMP4Context 编码为 MP4 ，外部进程会随 ctx 取消而终止
+//
+// 编码受支持且未超出限制的视频只会被重新封装为 moov 前置的文件
+func EncoderMP4Context(ctx context.Context, data []byte) ([]byte, error) {
+	if !NeedsEncode(data) {
+		return remux(ctx, data)
+	}
+
+	opts := getOptions()
+	bitrate := strconv.FormatInt(opts.MaxBitrate, 10)
+	size := strconv.FormatInt(opts.MaxSize, 10)
+
+	// 相同内容与参数的转码结果可以直接复用
+	key := mediacache.Key(data, "mp4", "libx264", "aac", bitrate, size)
+	if cached, ok := mediacache.Get(key); ok {
+		return cached, nil
+	}
+
+	mp4Data, err := encode(ctx, data, hashName(data), opts)
+	if err != nil {
+		return nil, err
+	}
+	mediacache.Put(key, mp4Data)
+	return mp4Data, nil
+}
+
+// remux 将 moov 前置，无法直接处理时使用 ffmpeg 重新封装
+func remux(ctx context.Context, data []byte) ([]byte, error) {
+	result, err := FastStart(data)
+	if err == nil {
+		return result, nil
+	}
+
+	key := mediacache.Key(data, "mp4", "faststart")
+	if cached, ok := mediacache.Get(key); ok {
+		return cached, nil
+	}
+
+	result, err = runFFmpeg(ctx, data, hashName(data)+"_faststart", ".mp4", "-c", "copy", "-movflags", "+faststart", "-f", "mp4")
 	if err != nil {
-		return nil, fmt.Errorf("failed to compute md5: %v", err)
+		return nil, fmt.Errorf("failed to remux mp4: %w", err)
 	}
-	name := hex.EncodeToString(hash.Sum(nil))
-	return encode(data, name)
+	mediacache.Put(key, result)
+	return result, nil
 }
 
 // encode 编码为 MP4
-func encode(data []byte, name string) (mp4Video []byte, err error) {
-	// 0. 创建缓存目录
-	err = createDirectoryIfNotExist(cachePath)
+func encode(ctx context.Context, data []byte, name string, opts Options) ([]byte, error) {
+	args := []string{
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "23",
+		// 部分播放器不支持奇数边长与 yuv420p 以外的像素格式
+		"-vf", "scale=trunc(iw/2)*2:trunc(ih/2)*2", "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-b:a", strconv.Itoa(audioBitrate),
+		"-movflags", "+faststart", "-f", "mp4",
+	}
+	if maxrate := videoBitrate(ctx, data, name, opts); maxrate > 0 {
+		args = append(args,
+			"-maxrate", strconv.FormatInt(maxrate, 10),
+			"-bufsize", strconv.FormatInt(maxrate*2, 10),
+		)
+	}
+
+	mp4Video, err := runFFmpeg(ctx, data, name, ".mp4", args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create video cache directory: %v", err)
+		return nil, fmt.Errorf("failed to convert to mp4: %w", err)
 	}
+	return mp4Video, nil
+}
+
+// videoBitrate 根据码率与大小限制计算视频码率上限，为 0 时不限制
+func videoBitrate(ctx context.Context, data []byte, name string, opts Options) int64 {
+	var bitrate int64
+	if opts.MaxBitrate > 0 {
+		bitrate = max(opts.MaxBitrate-audioBitrate, minVideoBitrate)
+	}
+	if opts.MaxSize <= 0 {
+		return bitrate
+	}
+
+	// 按时长分配大小，留出 5% 的封装开销
+	duration := Duration(ctx, data, name)
+	if duration <= 0 {
+		return bitrate
+	}
+	budget := int64(float64(opts.MaxSize*8)*0.95/duration.Seconds()) - audioBitrate
+	budget = max(budget, minVideoBitrate)
+	if bitrate == 0 || budget < bitrate {
+		return budget
+	}
+	return bitrate
+}
+
+// Duration 获取视频时长，MP4 文件直接解析，其他格式使用 ffprobe
+func Duration(ctx context.Context, data []byte, name string) time.Duration {
+	if info, err := Probe(data); err == nil && info.Duration > 0 {
+		return info.Duration
+	}
+
+	rawPath, err := writeTemp(data, name)
+	if err != nil {
+		return 0
+	}
+	defer os.Remove(rawPath)
+
+	output, err := exec.CommandContext(ctx, "ffprobe", "-v", "error",
+		"-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", rawPath).Output()
+	if err != nil {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// Thumbnail 截取视频首帧作为 JPEG 封面
+func Thumbnail(ctx context.Context, data []byte) ([]byte, error) {
+	key := mediacache.Key(data, "thumbnail", "jpeg")
+	if cached, ok := mediacache.Get(key); ok {
+		return cached, nil
+	}
+
+	thumbnail, err := runFFmpeg(ctx, data, hashName(data)+"_thumbnail", ".jpg", "-frames:v", "1", "-q:v", "3", "-f", "image2")
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate thumbnail: %w", err)
+	}
+	mediacache.Put(key, thumbnail)
+	return thumbnail, nil
+}
 
-	// 1. 创建临时文件
-	rawPath := path.Join(cachePath, name)
-	err = os.WriteFile(rawPath, data, os.ModePerm)
+// runFFmpeg 使用 ffmpeg 处理数据并读取输出文件
+func runFFmpeg(ctx context.Context, data []byte, name, ext string, args ...string) ([]byte, error) {
+	rawPath, err := writeTemp(data, name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temporary file: %v", err)
+		return nil, err
 	}
 	defer os.Remove(rawPath)
 
-	// 2. 转换 MP4
-	mp4Path := path.Join(cachePath, name+".mp4")
-	cmd := exec.Command("ffmpeg", "-i", rawPath, "-vcodec", "libx264", "-acodec", "aac", mp4Path)
+	outPath := rawPath + ext
+	args = append(append([]string{"-i", rawPath}, args...), "-y", outPath)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
 	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	defer os.Remove(outPath)
 
-		return nil, fmt.Errorf("failed to convert to mp4: %v", err)
+	output, err := os.ReadFile(outPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output file: %v", err)
 	}
-	mp4Video, err = os.ReadFile(mp4Path)
+	return output, nil
+}
+
+// writeTemp 将数据写入临时文件，name 作为文件名前缀，同一数据的并发任务不会共用文件
+func writeTemp(data []byte, name string) (string, error) {
+	err := createDirectoryIfNotExist(cachePath)
 	if err != nil {
+		return "", fmt.Errorf("failed to create video cache directory: %v", err)
+	}
 
-		return nil, fmt.Errorf("failed to read mp4 file: %v", err)
+	file, err := os.CreateTemp(cachePath, name+"_*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to create temporary file: %v", err)
 	}
-	defer os.Remove(mp4Path)
+	return file.Name(), nil
+}
 
-	return mp4Video, nil
+// hashName 使用数据的 MD5 作为临时文件名前缀
+func hashName(data []byte) string {
+	hash := md5.Sum(data)
+	return hex.EncodeToString(hash[:])
 }
 
 // createDirectoryIfNotExist 检查目录是否存在，不存在则创建
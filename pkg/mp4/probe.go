@@ -0,0 +1,242 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNotMP4      = errors.New("not an mp4 file")
+	ErrInvalidBox  = errors.New("invalid mp4 box")
+	ErrMissingMoov = errors.New("mp4 file has no moov box")
+)
+
+// imageBrands 同样使用 ftyp 盒子的图像格式
+var imageBrands = map[string]bool{
+	"avif": true, "avis": true,
+	"heic": true, "heix": true, "heim": true, "heis": true,
+	"hevc": true, "hevx": true, "mif1": true, "msf1": true,
+}
+
+// supportedVideoCodecs QQ 支持的视频编码
+var supportedVideoCodecs = map[string]bool{
+	"avc1": true,
+	"avc3": true,
+}
+
+// supportedAudioCodecs QQ 支持的音频编码
+var supportedAudioCodecs = map[string]bool{
+	"mp4a": true,
+}
+
+// Info 视频信息
+type Info struct {
+	Brand      string        // ftyp 主品牌
+	VideoCodec string        // 视频编码，如 avc1 、hvc1 、vp09
+	AudioCodec string        // 音频编码，如 mp4a 、Opus ，无音轨时为空
+	Width      uint32        // 视频宽度
+	Height     uint32        // 视频高度
+	Duration   time.Duration // 视频时长
+	Bitrate    int64         // 平均码率，单位 bit/s
+	FastStart  bool          // moov 是否位于 mdat 之前
+	Fragmented bool          // 是否为分片 MP4
+}
+
+// Supported 判断视频编码是否受 QQ 支持
+func (i *Info) Supported() bool {
+	if !supportedVideoCodecs[i.VideoCodec] {
+		return false
+	}
+	return i.AudioCodec == "" || supportedAudioCodecs[i.AudioCodec]
+}
+
+// box MP4 盒子
+type box struct {
+	typ    string
+	offset int64 // 盒子在所属数据中的起始位置
+	header int64 // 盒子头部长度
+	size   int64 // 盒子总长度
+}
+
+// payload 获取盒子内容
+func (b box) payload(data []byte) []byte {
+	return data[b.offset+b.header : b.offset+b.size]
+}
+
+// readBoxes 读取数据中的全部同级盒子
+func readBoxes(data []byte) ([]box, error) {
+	var boxes []box
+	length := int64(len(data))
+	for offset := int64(0); offset < length; {
+		if length-offset < 8 {
+			return nil, ErrInvalidBox
+		}
+		b := box{
+			typ:    string(data[offset+4 : offset+8]),
+			offset: offset,
+			header: 8,
+			size:   int64(binary.BigEndian.Uint32(data[offset:])),
+		}
+		switch b.size {
+		case 0:
+			// 盒子延伸至数据末尾
+			b.size = length - offset
+		case 1:
+			if length-offset < 16 {
+				return nil, ErrInvalidBox
+			}
+			b.header = 16
+			b.size = int64(binary.BigEndian.Uint64(data[offset+8:]))
+		}
+		if b.size < b.header || b.size > length-offset {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidBox, b.typ)
+		}
+		boxes = append(boxes, b)
+		offset += b.size
+	}
+	return boxes, nil
+}
+
+// findBox 查找指定类型的第一个盒子
+func findBox(boxes []box, typ string) (box, bool) {
+	for _, b := range boxes {
+		if b.typ == typ {
+			return b, true
+		}
+	}
+	return box{}, false
+}
+
+// findPath 按路径查找嵌套盒子的内容
+func findPath(data []byte, path ...string) ([]byte, bool) {
+	for _, typ := range path {
+		boxes, err := readBoxes(data)
+		if err != nil {
+			return nil, false
+		}
+		b, ok := findBox(boxes, typ)
+		if !ok {
+			return nil, false
+		}
+		data = b.payload(data)
+	}
+	return data, true
+}
+
+// Probe 解析 MP4 文件的容器与编码信息
+func Probe(file []byte) (*Info, error) {
+	if !IsMP4(file) {
+		return nil, ErrNotMP4
+	}
+	boxes, err := readBoxes(file)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{}
+	moovIndex, mdatIndex := -1, -1
+	for i, b := range boxes {
+		switch b.typ {
+		case "ftyp":
+			if payload := b.payload(file); len(payload) >= 4 {
+				info.Brand = string(payload[:4])
+			}
+		case "moov":
+			if moovIndex < 0 {
+				moovIndex = i
+			}
+		case "mdat":
+			if mdatIndex < 0 {
+				mdatIndex = i
+			}
+		case "moof":
+			info.Fragmented = true
+		}
+	}
+	if moovIndex < 0 {
+		return nil, ErrMissingMoov
+	}
+	info.FastStart = mdatIndex < 0 || moovIndex < mdatIndex
+
+	moov := boxes[moovIndex].payload(file)
+	if err := parseMoov(moov, info); err != nil {
+		return nil, err
+	}
+	if info.Duration > 0 {
+		info.Bitrate = int64(float64(len(file)*8) / info.Duration.Seconds())
+	}
+	return info, nil
+}
+
+// parseMoov 解析 moov 盒子
+func parseMoov(moov []byte, info *Info) error {
+	boxes, err := readBoxes(moov)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range boxes {
+		switch b.typ {
+		case "mvhd":
+			info.Duration = parseMvhd(b.payload(moov))
+		case "trak":
+			parseTrak(b.payload(moov), info)
+		}
+	}
+	return nil
+}
+
+// parseMvhd 解析 mvhd 盒子中的时长
+func parseMvhd(mvhd []byte) time.Duration {
+	var timescale, duration uint64
+	if len(mvhd) >= 1 && mvhd[0] == 1 {
+		if len(mvhd) < 32 {
+			return 0
+		}
+		timescale = uint64(binary.BigEndian.Uint32(mvhd[20:]))
+		duration = binary.BigEndian.Uint64(mvhd[24:])
+	} else {
+		if len(mvhd) < 20 {
+			return 0
+		}
+		timescale = uint64(binary.BigEndian.Uint32(mvhd[12:]))
+		duration = uint64(binary.BigEndian.Uint32(mvhd[16:]))
+	}
+	if timescale == 0 {
+		return 0
+	}
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+}
+
+// parseTrak 解析轨道的类型与编码
+func parseTrak(trak []byte, info *Info) {
+	hdlr, ok := findPath(trak, "mdia", "hdlr")
+	if !ok || len(hdlr) < 12 {
+		return
+	}
+	stsd, ok := findPath(trak, "mdia", "minf", "stbl", "stsd")
+	if !ok || len(stsd) < 16 {
+		return
+	}
+	// 跳过 version/flags 与 entry_count ，第一个采样描述的类型即为编码
+	codec := string(stsd[12:16])
+
+	switch string(hdlr[8:12]) {
+	case "vide":
+		if info.VideoCodec != "" {
+			return
+		}
+		info.VideoCodec = codec
+		if tkhd, ok := findPath(trak, "tkhd"); ok && len(tkhd) >= 8 {
+			// tkhd 末尾为 16.16 定点数表示的宽高
+			info.Width = binary.BigEndian.Uint32(tkhd[len(tkhd)-8:]) >> 16
+			info.Height = binary.BigEndian.Uint32(tkhd[len(tkhd)-4:]) >> 16
+		}
+	case "soun":
+		if info.AudioCodec == "" {
+			info.AudioCodec = codec
+		}
+	}
+}
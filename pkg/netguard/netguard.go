@@ -0,0 +1,85 @@
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// ErrAddressForbidden 目标地址位于禁止访问的网段
+var ErrAddressForbidden = errors.New("remote address forbidden")
+
+// deniedNetworks 禁止访问的网段
+var deniedNetworks = func() []*net.IPNet {
+	cidrs := []string{
+		"0.0.0.0/8",      // 本网络
+		"10.0.0.0/8",     // 私有网络
+		"100.64.0.0/10",  // 运营商级 NAT
+		"127.0.0.0/8",    // 回环地址
+		"169.254.0.0/16", // 链路本地地址
+		"172.16.0.0/12",  // 私有网络
+		"192.0.0.0/24",   // IETF 协议分配
+		"192.168.0.0/16", // 私有网络
+		"198.18.0.0/15",  // 基准测试
+		"224.0.0.0/4",    // 多播
+		"240.0.0.0/4",    // 保留地址
+		"::/128",         // 未指定地址
+		"::1/128",        // 回环地址
+		"64:ff9b::/96",   // NAT64
+		"fc00::/7",       // 唯一本地地址
+		"fe80::/10",      // 链路本地地址
+		"ff00::/8",       // 多播
+	}
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// IsDeniedIP 判断 IP 是否位于禁止访问的网段
+//
+// 网段列表中不能包含 ::ffff:0:0/96 ，net 包会将其视为 0.0.0.0/0 而匹配所有 IPv4 地址
+func IsDeniedIP(ip net.IP) bool {
+	// IPv4 映射地址按照其 IPv4 地址判断
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, network := range deniedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckAddress 检查连接的目标地址是否允许访问
+func CheckAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || IsDeniedIP(ip) {
+		return fmt.Errorf("%w: %s", ErrAddressForbidden, host)
+	}
+	return nil
+}
+
+// NewDialer 创建在建立连接时以 checkAddress 检查实际连接地址的拨号器
+//
+// 在连接时检查而不是在请求前解析域名，可以防止重定向或 DNS 重绑定绕过检查
+func NewDialer(timeout time.Duration, checkAddress func(address string) error) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return checkAddress(address)
+		},
+	}
+}
@@ -0,0 +1,55 @@
+package netguard
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestIsDeniedIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		denied bool
+	}{
+		{"127.0.0.1", true},
+		{"127.1.2.3", true},
+		{"::1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"192.168.1.1", true},
+		{"fd00::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"0.0.0.0", true},
+		{"100.64.0.1", true},
+		{"172.32.0.1", false},
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+	}
+	for _, tt := range tests {
+		if got := IsDeniedIP(net.ParseIP(tt.ip)); got != tt.denied {
+			t.Errorf("IsDeniedIP(%s) = %v, want %v", tt.ip, got, tt.denied)
+		}
+	}
+}
+
+func TestCheckAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		denied  bool
+	}{
+		{"127.0.0.1:80", true},
+		{"[::1]:443", true},
+		{"localhost:80", true}, // 拨号时地址已经解析为 IP ，域名视为不允许
+		{"8.8.8.8:53", false},
+	}
+	for _, tt := range tests {
+		err := CheckAddress(tt.address)
+		if got := errors.Is(err, ErrAddressForbidden); got != tt.denied {
+			t.Errorf("CheckAddress(%s) error = %v, want denied %v", tt.address, err, tt.denied)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package s3
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm       = "AWS4-HMAC-SHA256"
+	service         = "s3"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	emptyPayload    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	amzDateFormat   = "20060102T150405Z"
+	shortDateFormat = "20060102"
+	maxPresignTime  = 7 * 24 * time.Hour // 预签名链接有效期上限为 7 天
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("s3: object not found")
+
+// Config S3 兼容存储配置
+type Config struct {
+	Endpoint       string // 服务地址，如 https://s3.amazonaws.com
+	PublicEndpoint string // 生成预签名链接时使用的公网地址，为空时使用 Endpoint
+	Region         string // 区域
+	Bucket         string // 存储桶
+	AccessKey      string // 访问密钥 ID
+	SecretKey      string // 访问密钥
+	PathStyle      bool   // 是否使用路径形式访问存储桶
+}
+
+// Client S3 兼容存储客户端
+type Client struct {
+	conf     Config
+	endpoint *url.URL
+	public   *url.URL
+	http     *http.Client
+	now      func() time.Time
+}
+
+// Error S3 服务返回的错误
+type Error struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: unexpected status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3: %s: %s (status %d)", e.Code, e.Message, e.StatusCode)
+}
+
+// New 创建 S3 兼容存储客户端
+func New(conf Config) (*Client, error) {
+	if conf.Bucket == "" {
+		return nil, errors.New("s3: bucket is required")
+	}
+	if conf.Region == "" {
+		conf.Region = "us-east-1"
+	}
+
+	endpoint, err := url.Parse(strings.TrimSuffix(conf.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", conf.Endpoint)
+	}
+	public := endpoint
+	if conf.PublicEndpoint != "" {
+		public, err = url.Parse(strings.TrimSuffix(conf.PublicEndpoint, "/"))
+		if err != nil || public.Host == "" {
+			return nil, fmt.Errorf("s3: invalid public endpoint %q", conf.PublicEndpoint)
+		}
+	}
+
+	return &Client{
+		conf:     conf,
+		endpoint: endpoint,
+		public:   public,
+		http:     &http.Client{Timeout: 5 * time.Minute},
+		now:      time.Now,
+	}, nil
+}
+
+// objectURL 获取对象链接
+func (c *Client) objectURL(base *url.URL, key string) *url.URL {
+	u := *base
+	key = strings.TrimPrefix(key, "/")
+	if c.conf.PathStyle {
+		u.Path = strings.TrimSuffix(base.Path, "/") + "/" + c.conf.Bucket + "/" + key
+	} else {
+		u.Host = c.conf.Bucket + "." + base.Host
+		u.Path = strings.TrimSuffix(base.Path, "/") + "/" + key
+	}
+	return &u
+}
+
+// PutObject 上传对象
+func (c *Client) PutObject(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.objectURL(c.endpoint, key).String(), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.do(req, unsignedPayload)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetObject 下载对象
+func (c *Client) GetObject(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.objectURL(c.endpoint, key).String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := c.do(req, emptyPayload)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// HeadObject 获取对象大小
+func (c *Client) HeadObject(ctx context.Context, key string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.objectURL(c.endpoint, key).String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.do(req, emptyPayload)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.ContentLength, nil
+}
+
+// DeleteObject 删除对象，对象不存在时不会返回错误
+func (c *Client) DeleteObject(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.objectURL(c.endpoint, key).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req, emptyPayload)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// PresignGetObject 生成对象的预签名下载链接
+//
+// query 中可以附带 response-content-type 等覆盖响应头的参数
+func (c *Client) PresignGetObject(key string, expires time.Duration, query url.Values) (string, error) {
+	if expires <= 0 || expires > maxPresignTime {
+		expires = maxPresignTime
+	}
+
+	u := c.objectURL(c.public, key)
+	now := c.now().UTC()
+	values := url.Values{}
+	for k, v := range query {
+		values[k] = v
+	}
+	values.Set("X-Amz-Algorithm", algorithm)
+	values.Set("X-Amz-Credential", c.conf.AccessKey+"/"+c.scope(now))
+	values.Set("X-Amz-Date", now.Format(amzDateFormat))
+	values.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	values.Set("X-Amz-SignedHeaders", "host")
+
+	canonical := strings.Join([]string{
+		http.MethodGet,
+		encodePath(u.Path),
+		canonicalQuery(values),
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+	signature := c.signature(now, canonical)
+
+	u.RawQuery = canonicalQuery(values) + "&X-Amz-Signature=" + signature
+	return u.String(), nil
+}
+
+// do 签名并发送请求
+func (c *Client) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	c.sign(req, payloadHash)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	if data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024)); err == nil {
+		_ = xml.Unmarshal(data, apiErr)
+	}
+	return nil, apiErr
+}
+
+// sign 使用 AWS Signature Version 4 为请求添加鉴权头
+func (c *Client) sign(req *http.Request, payloadHash string) {
+	now := c.now().UTC()
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           now.Format(amzDateFormat),
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		headers["content-type"] = contentType
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		encodePath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, c.conf.AccessKey, c.scope(now), signedHeaders, c.signature(now, canonical),
+	))
+}
+
+// scope 获取凭证范围
+func (c *Client) scope(t time.Time) string {
+	return t.Format(shortDateFormat) + "/" + c.conf.Region + "/" + service + "/aws4_request"
+}
+
+// signature 计算规范请求的签名
+func (c *Client) signature(t time.Time, canonical string) string {
+	hash := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{
+		algorithm,
+		t.Format(amzDateFormat),
+		c.scope(t),
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.conf.SecretKey), t.Format(shortDateFormat))
+	key = hmacSHA256(key, c.conf.Region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery 生成规范查询字符串
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), values[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// encodePath 按 S3 规则编码路径，保留路径分隔符
+func encodePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return uriEncode(path, false)
+}
+
+// uriEncode 按 RFC 3986 编码，仅保留非保留字符
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,583 @@
+package silk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+const (
+	nbSubframes           = 4  // 每帧的子帧数
+	frameLengthMs         = 20 // 帧长，单位毫秒
+	shellCodecFrameLength = 16 // 脉冲编码的块长
+	maxPulses             = 18 // 每块可直接编码的最大脉冲数
+	nRateLevels           = 10 // 码率等级数
+	ltpOrder              = 5  // 长时预测阶数
+	pitchEstMinLagMs      = 2  // 最小基音周期，单位毫秒
+	maxFramesPerPacket    = 5  // 每个数据包的最大帧数
+	maxFrameLength        = frameLengthMs * 24
+	maxSubframeLength     = maxFrameLength / nbSubframes
+
+	sigTypeVoiced   = 0 // 浊音帧
+	sigTypeUnvoiced = 1 // 清音帧
+
+	frameTerminationLast = 0 // 数据包的最后一帧
+	frameTerminationMore = 1 // 数据包中还有更多帧
+
+	nLevelsQGain        = 64 // 增益量化等级数
+	minDeltaGainQuant   = -4 // 增益差分量化的最小值
+	maxDeltaGainQuant   = 40 // 增益差分量化的最大值
+	gainOffset          = (6*128)/6 + 16*128
+	gainScaleQ16        = (65536 * (nLevelsQGain - 1)) / (((86 - 6) * 128) / 6)
+	gainInverseScaleQ16 = (65536 * (((86 - 6) * 128) / 6)) / (nLevelsQGain - 1)
+)
+
+// ErrUnsupportedSilk 进程内解码器无法处理的 SILK 数据
+//
+// 包括丢失的数据包与损坏的数据，此时需要交由 SILK SDK 进行丢包补偿
+var ErrUnsupportedSilk = errors.New("unsupported silk stream")
+
+// decoderControl 一帧的解码参数
+type decoderControl struct {
+	pitchL          [nbSubframes]int
+	gainsQ16        [nbSubframes]int32
+	seed            int32
+	predCoefQ12     [2][maxLPCOrder]int16
+	ltpCoefQ14      [ltpOrder * nbSubframes]int16
+	ltpScaleQ14     int32
+	perIndex        int
+	rateLevelIndex  int
+	quantOffsetType int
+	sigType         int
+	nlsfInterpQ2    int
+}
+
+// decoderState 解码器状态
+type decoderState struct {
+	fsKHz          int
+	frameLength    int
+	subfrLength    int
+	lpcOrder       int
+	nlsfCB         [2]*nlsfCodebook
+	hpB            [3]int32
+	hpA            [2]int32
+	hpState        [2]int32
+	prevNLSFQ15    [maxLPCOrder]int32
+	sLPCQ14        [maxSubframeLength + maxLPCOrder]int32
+	sLTPQ16        [2 * maxFrameLength]int32
+	outBuf         [2 * maxFrameLength]int16
+	excQ10         [maxFrameLength]int32
+	resQ10         [maxFrameLength]int32
+	prevInvGainQ16 int32
+	lastGainIndex  int32
+	typeOffsetPrev int
+	firstFrame     bool
+	framesDecoded  int
+
+	vadFlag          int
+	frameTermination int
+}
+
+// newDecoderState 创建解码器状态
+func newDecoderState() *decoderState {
+	d := &decoderState{prevInvGainQ16: 65536}
+	d.setFs(24)
+	return d
+}
+
+// setFs 设置内部采样率
+func (d *decoderState) setFs(fsKHz int) {
+	if d.fsKHz == fsKHz {
+		return
+	}
+	d.fsKHz = fsKHz
+	d.frameLength = frameLengthMs * fsKHz
+	d.subfrLength = frameLengthMs / nbSubframes * fsKHz
+	if fsKHz == 8 {
+		d.lpcOrder = 10
+		d.nlsfCB = [2]*nlsfCodebook{nlsfCB0_10, nlsfCB1_10}
+	} else {
+		d.lpcOrder = 16
+		d.nlsfCB = [2]*nlsfCodebook{nlsfCB0_16, nlsfCB1_16}
+	}
+	d.sLPCQ14 = [len(d.sLPCQ14)]int32{}
+	for i := 0; i < maxFrameLength; i++ {
+		d.outBuf[i] = 0
+	}
+	d.prevNLSFQ15 = [maxLPCOrder]int32{}
+	d.lastGainIndex = 1
+	d.firstFrame = true
+	hp := decoderHP[fsKHz]
+	d.hpB, d.hpA = hp.b, hp.a
+}
+
+// decodePacket 解码一个数据包，返回以内部采样率输出的采样
+func (d *decoderState) decodePacket(payload []byte) ([]int16, error) {
+	if len(payload) == 0 || len(payload) > maxArithmBytes {
+		return nil, ErrUnsupportedSilk
+	}
+	rc := newRangeDecoder(payload)
+	d.framesDecoded = 0
+	var out []int16
+	for {
+		fsOld := d.fsKHz
+		var ctrl decoderControl
+		var q [maxFrameLength]int
+		nBytesLeft := d.decodeParameters(rc, &ctrl, q[:])
+		if rc.err != nil {
+			d.setFs(fsOld)
+			return nil, ErrUnsupportedSilk
+		}
+		d.framesDecoded++
+
+		frame := make([]int16, d.frameLength)
+		d.decodeCore(&ctrl, frame, q[:])
+		copy(d.outBuf[:], frame)
+		d.firstFrame = false
+		biquad(frame, d.hpB, d.hpA, &d.hpState)
+		out = append(out, frame...)
+
+		if nBytesLeft <= 0 || d.frameTermination != frameTerminationMore || d.framesDecoded >= maxFramesPerPacket {
+			return out, nil
+		}
+	}
+}
+
+// decodeParameters 解码一帧的参数与脉冲，返回数据包中剩余的字节数
+func (d *decoderState) decodeParameters(rc *rangeDecoder, ctrl *decoderControl, q []int) int {
+	// 采样率只在数据包的第一帧中编码
+	if d.framesDecoded == 0 {
+		ix := rc.decode(samplingRatesCDF[:], samplingRatesOffset)
+		if rc.err != nil {
+			return 0
+		}
+		d.setFs(samplingRatesTable[ix])
+	}
+
+	// 帧类型与量化偏移类型
+	var ix int
+	if d.framesDecoded == 0 {
+		ix = rc.decode(typeOffsetCDF[:], typeOffsetCDFOffset)
+	} else {
+		ix = rc.decode(typeOffsetJointCDF[d.typeOffsetPrev][:], typeOffsetCDFOffset)
+	}
+	ctrl.sigType = ix >> 1
+	ctrl.quantOffsetType = ix & 1
+	d.typeOffsetPrev = ix
+
+	// 增益
+	var gainIndices [nbSubframes]int
+	if d.framesDecoded == 0 {
+		gainIndices[0] = rc.decode(gainCDF[ctrl.sigType][:], gainCDFOffset)
+	} else {
+		gainIndices[0] = rc.decode(deltaGainCDF[:], deltaGainCDFOffset)
+	}
+	for i := 1; i < nbSubframes; i++ {
+		gainIndices[i] = rc.decode(deltaGainCDF[:], deltaGainCDFOffset)
+	}
+	gainsDequant(ctrl.gainsQ16[:], gainIndices[:], &d.lastGainIndex, d.framesDecoded != 0)
+
+	// NLSF
+	cb := d.nlsfCB[ctrl.sigType]
+	nlsfIndices := make([]int, len(cb.stages))
+	for s := range nlsfIndices {
+		nlsfIndices[s] = rc.decode(cb.stageCDF(s), cb.middleIx[s])
+	}
+	if rc.err != nil {
+		return 0
+	}
+	nlsfQ15 := make([]int32, d.lpcOrder)
+	cb.msvqDecode(nlsfQ15, nlsfIndices)
+
+	ctrl.nlsfInterpQ2 = rc.decode(nlsfInterpolationFactorCDF[:], nlsfInterpolationFactorOffset)
+	if d.firstFrame {
+		ctrl.nlsfInterpQ2 = 4
+	}
+	nlsf2AStable(ctrl.predCoefQ12[1][:d.lpcOrder], nlsfQ15)
+	if ctrl.nlsfInterpQ2 < 4 {
+		nlsf0Q15 := make([]int32, d.lpcOrder)
+		for i := range nlsf0Q15 {
+			nlsf0Q15[i] = d.prevNLSFQ15[i] + (int32(ctrl.nlsfInterpQ2)*(nlsfQ15[i]-d.prevNLSFQ15[i]))>>2
+		}
+		nlsf2AStable(ctrl.predCoefQ12[0][:d.lpcOrder], nlsf0Q15)
+	} else {
+		ctrl.predCoefQ12[0] = ctrl.predCoefQ12[1]
+	}
+	copy(d.prevNLSFQ15[:], nlsfQ15)
+
+	if ctrl.sigType == sigTypeVoiced {
+		// 基音周期
+		var lagIndex, contourIndex int
+		switch d.fsKHz {
+		case 8:
+			lagIndex = rc.decode(pitchLagNBCDF[:], pitchLagNBCDFOffset)
+			contourIndex = rc.decode(pitchContourNBCDF[:], pitchContourNBCDFOffset)
+		case 12:
+			lagIndex = rc.decode(pitchLagMBCDF[:], pitchLagMBCDFOffset)
+			contourIndex = rc.decode(pitchContourCDF[:], pitchContourCDFOffset)
+		case 16:
+			lagIndex = rc.decode(pitchLagWBCDF[:], pitchLagWBCDFOffset)
+			contourIndex = rc.decode(pitchContourCDF[:], pitchContourCDFOffset)
+		default:
+			lagIndex = rc.decode(pitchLagSWBCDF[:], pitchLagSWBCDFOffset)
+			contourIndex = rc.decode(pitchContourCDF[:], pitchContourCDFOffset)
+		}
+		decodePitch(lagIndex, contourIndex, ctrl.pitchL[:], d.fsKHz)
+
+		// 长时预测系数
+		ctrl.perIndex = rc.decode(ltpPerIndexCDF[:], ltpPerIndexCDFOffset)
+		if rc.err != nil {
+			return 0
+		}
+		vq := ltpVQQ14[ctrl.perIndex]
+		for k := 0; k < nbSubframes; k++ {
+			ix := rc.decode(ltpGainCDFs[ctrl.perIndex], ltpGainCDFOffsets[ctrl.perIndex])
+			copy(ctrl.ltpCoefQ14[k*ltpOrder:(k+1)*ltpOrder], vq[ix*ltpOrder:])
+		}
+		ix = rc.decode(ltpScaleCDF[:], ltpScaleOffset)
+		ctrl.ltpScaleQ14 = int32(ltpScalesTableQ14[ix])
+	}
+
+	ctrl.seed = int32(rc.decode(seedCDF[:], seedOffset))
+	decodePulses(rc, ctrl, q[:d.frameLength])
+	d.vadFlag = rc.decode(vadFlagCDF[:], vadFlagOffset)
+	d.frameTermination = rc.decode(frameTerminationCDF[:], frameTerminationOffset)
+
+	_, nBytesUsed := rc.length()
+	nBytesLeft := len(rc.buffer) - nBytesUsed
+	if nBytesLeft < 0 {
+		rc.err = errRangeCoderCorrupt
+	} else if nBytesLeft == 0 {
+		rc.check()
+	}
+	return nBytesLeft
+}
+
+// gainsDequant 增益反量化
+func gainsDequant(gainsQ16 []int32, indices []int, prevIndex *int32, conditional bool) {
+	for k := range gainsQ16 {
+		if k == 0 && !conditional {
+			*prevIndex = int32(indices[k])
+		} else {
+			*prevIndex += int32(indices[k]) + minDeltaGainQuant
+		}
+		gainsQ16[k] = gainFromIndex(*prevIndex)
+	}
+}
+
+// gainFromIndex 由量化索引计算增益，Q16
+func gainFromIndex(index int32) int32 {
+	logQ7 := smulwb(gainInverseScaleQ16, index) + gainOffset
+	if logQ7 > 3967 {
+		logQ7 = 3967
+	}
+	return log2lin(logQ7)
+}
+
+// decodePitch 由索引还原各子帧的基音周期
+func decodePitch(lagIndex, contourIndex int, pitchLags []int, fsKHz int) {
+	lag := pitchEstMinLagMs*fsKHz + lagIndex
+	for i := range pitchLags {
+		if fsKHz == 8 {
+			pitchLags[i] = lag + int(cbLagsStage2[i][contourIndex])
+		} else {
+			pitchLags[i] = lag + int(cbLagsStage3[i][contourIndex])
+		}
+	}
+}
+
+// decodePulses 解码激励脉冲
+func decodePulses(rc *rangeDecoder, ctrl *decoderControl, q []int) {
+	ctrl.rateLevelIndex = rc.decode(rateLevelsCDF[ctrl.sigType][:], rateLevelsCDFOffset)
+	if rc.err != nil {
+		return
+	}
+
+	iter := len(q) / shellCodecFrameLength
+	sumPulses := make([]int, iter)
+	nLShifts := make([]int, iter)
+	cdf := pulsesPerBlockCDF[ctrl.rateLevelIndex][:]
+	for i := 0; i < iter; i++ {
+		sumPulses[i] = rc.decode(cdf, pulsesPerBlockCDFOffset)
+		for sumPulses[i] == maxPulses+1 && rc.err == nil {
+			nLShifts[i]++
+			sumPulses[i] = rc.decode(pulsesPerBlockCDF[nRateLevels-1][:], pulsesPerBlockCDFOffset)
+		}
+	}
+	if rc.err != nil {
+		return
+	}
+
+	for i := 0; i < iter; i++ {
+		block := q[i*shellCodecFrameLength : (i+1)*shellCodecFrameLength]
+		if sumPulses[i] > 0 {
+			shellDecoder(block, rc, sumPulses[i])
+		} else {
+			for k := range block {
+				block[k] = 0
+			}
+		}
+	}
+
+	for i := 0; i < iter; i++ {
+		if nLShifts[i] == 0 {
+			continue
+		}
+		block := q[i*shellCodecFrameLength : (i+1)*shellCodecFrameLength]
+		for k := range block {
+			absQ := block[k]
+			for j := 0; j < nLShifts[i]; j++ {
+				absQ = absQ<<1 + rc.decode(lsbCDF[:], 1)
+			}
+			block[k] = absQ
+		}
+	}
+
+	signs := signCDFFor(ctrl.sigType, ctrl.quantOffsetType, ctrl.rateLevelIndex)
+	for i := range q {
+		if q[i] > 0 && rc.decode(signs[:], 1) == 0 {
+			q[i] = -q[i]
+		}
+	}
+}
+
+// signCDFFor 获取脉冲符号的累积分布
+func signCDFFor(sigType, quantOffsetType, rateLevelIndex int) [3]uint16 {
+	i := (nRateLevels-1)*(sigType<<1+quantOffsetType) + rateLevelIndex
+	return [3]uint16{0, signCDF[i], 65535}
+}
+
+// shellCodeTables 各层的脉冲分割码表
+var shellCodeTables = [...][]uint16{shellCodeTable0[:], shellCodeTable1[:], shellCodeTable2[:], shellCodeTable3[:]}
+
+// decodeSplit 解码脉冲在两个子块间的分割
+func decodeSplit(rc *rangeDecoder, p int, table []uint16) (int, int) {
+	if p <= 0 {
+		return 0, 0
+	}
+	child := rc.decode(table[shellCodeTableOffsets[p]:], p>>1)
+	return child, p - child
+}
+
+// shellDecoder 解码一个块中的脉冲分布
+func shellDecoder(pulses0 []int, rc *rangeDecoder, pulses4 int) {
+	var pulses3 [2]int
+	var pulses2 [4]int
+	var pulses1 [8]int
+	pulses3[0], pulses3[1] = decodeSplit(rc, pulses4, shellCodeTables[3])
+	for i3 := 0; i3 < 2; i3++ {
+		pulses2[2*i3], pulses2[2*i3+1] = decodeSplit(rc, pulses3[i3], shellCodeTables[2])
+		for i2 := 2 * i3; i2 < 2*i3+2; i2++ {
+			pulses1[2*i2], pulses1[2*i2+1] = decodeSplit(rc, pulses2[i2], shellCodeTables[1])
+			for i1 := 2 * i2; i1 < 2*i2+2; i1++ {
+				pulses0[2*i1], pulses0[2*i1+1] = decodeSplit(rc, pulses1[i1], shellCodeTables[0])
+			}
+		}
+	}
+}
+
+// decodeCore 由参数与脉冲合成一帧信号
+func (d *decoderState) decodeCore(ctrl *decoderControl, xq []int16, q []int) {
+	offsetQ10 := quantizationOffsetsQ10[ctrl.sigType][ctrl.quantOffsetType]
+	nlsfInterpolation := ctrl.nlsfInterpQ2 < 4
+
+	// 激励信号
+	randSeed := ctrl.seed
+	for i := 0; i < d.frameLength; i++ {
+		randSeed = rand(randSeed)
+		dither := randSeed >> 31
+		d.excQ10[i] = int32(q[i])<<10 + offsetQ10
+		d.excQ10[i] = (d.excQ10[i] ^ dither) - dither
+		randSeed += int32(q[i])
+	}
+
+	var sLTP [maxFrameLength]int16
+	sLTPBufIndex := d.frameLength
+	lag := 0
+	for k := 0; k < nbSubframes; k++ {
+		offset := k * d.subfrLength
+		exc := d.excQ10[offset : offset+d.subfrLength]
+		res := d.resQ10[offset : offset+d.subfrLength]
+		pxq := d.outBuf[d.frameLength+offset : d.frameLength+offset+d.subfrLength]
+		aQ12 := ctrl.predCoefQ12[k>>1][:d.lpcOrder]
+		bQ14 := ctrl.ltpCoefQ14[k*ltpOrder : (k+1)*ltpOrder]
+		gainQ16 := ctrl.gainsQ16[k]
+
+		invGainQ16 := inverse32VarQ(max32(gainQ16, 1), 32)
+		if invGainQ16 > math.MaxInt16 {
+			invGainQ16 = math.MaxInt16
+		}
+		gainAdjQ16 := int32(1 << 16)
+		if invGainQ16 != d.prevInvGainQ16 {
+			gainAdjQ16 = div32VarQ(invGainQ16, d.prevInvGainQ16, 16)
+		}
+
+		if ctrl.sigType == sigTypeVoiced {
+			lag = ctrl.pitchL[k]
+			interpolationMask := 3
+			if nlsfInterpolation {
+				interpolationMask = 1
+			}
+			if k&interpolationMask == 0 {
+				// 以新的 LPC 系数重新计算长时预测状态
+				startIndex := d.frameLength - lag - d.lpcOrder - ltpOrder/2
+				if startIndex < 0 {
+					startIndex = 0
+				} else if startIndex > d.frameLength-d.lpcOrder {
+					startIndex = d.frameLength - d.lpcOrder
+				}
+				maPrediction(d.outBuf[startIndex+k*(d.frameLength>>2):], aQ12, sLTP[startIndex:d.frameLength])
+
+				invGainQ32 := invGainQ16 << 16
+				if k == 0 {
+					invGainQ32 = smulwb(invGainQ32, ctrl.ltpScaleQ14) << 2
+				}
+				for i := 0; i < lag+ltpOrder/2; i++ {
+					d.sLTPQ16[sLTPBufIndex-i-1] = smulwb(invGainQ32, int32(sLTP[d.frameLength-i-1]))
+				}
+			} else if gainAdjQ16 != 1<<16 {
+				for i := 0; i < lag+ltpOrder/2; i++ {
+					d.sLTPQ16[sLTPBufIndex-i-1] = smulww(gainAdjQ16, d.sLTPQ16[sLTPBufIndex-i-1])
+				}
+			}
+		}
+
+		for i := 0; i < maxLPCOrder; i++ {
+			d.sLPCQ14[i] = smulww(gainAdjQ16, d.sLPCQ14[i])
+		}
+		d.prevInvGainQ16 = invGainQ16
+
+		// 长时预测
+		if ctrl.sigType == sigTypeVoiced {
+			predLag := sLTPBufIndex - lag + ltpOrder/2
+			for i := range res {
+				ltpPredQ14 := smulwb(d.sLTPQ16[predLag], int32(bQ14[0]))
+				ltpPredQ14 = smlawb(ltpPredQ14, d.sLTPQ16[predLag-1], int32(bQ14[1]))
+				ltpPredQ14 = smlawb(ltpPredQ14, d.sLTPQ16[predLag-2], int32(bQ14[2]))
+				ltpPredQ14 = smlawb(ltpPredQ14, d.sLTPQ16[predLag-3], int32(bQ14[3]))
+				ltpPredQ14 = smlawb(ltpPredQ14, d.sLTPQ16[predLag-4], int32(bQ14[4]))
+				predLag++
+
+				res[i] = exc[i] + rshiftRound(ltpPredQ14, 4)
+				d.sLTPQ16[sLTPBufIndex] = res[i] << 6
+				sLTPBufIndex++
+			}
+		} else {
+			copy(res, exc)
+		}
+
+		// 短时预测
+		for i := range res {
+			predQ10 := lpcPrediction(d.sLPCQ14[:maxLPCOrder+i], aQ12)
+			vecQ10 := res[i] + predQ10
+			d.sLPCQ14[maxLPCOrder+i] = vecQ10 << 4
+			pxq[i] = int16(sat16(rshiftRound(smulww(vecQ10, gainQ16), 10)))
+		}
+		copy(d.sLPCQ14[:maxLPCOrder], d.sLPCQ14[d.subfrLength:d.subfrLength+maxLPCOrder])
+	}
+	copy(xq, d.outBuf[d.frameLength:2*d.frameLength])
+}
+
+// lpcPrediction 计算短时预测值，Q10，history 以最新的采样结尾
+func lpcPrediction(history []int32, aQ12 []int16) int32 {
+	n := len(history) - 1
+	predQ10 := smulwb(history[n], int32(aQ12[0]))
+	for j := 1; j < len(aQ12); j++ {
+		predQ10 = smlawb(predQ10, history[n-j], int32(aQ12[j]))
+	}
+	return predQ10
+}
+
+// maPrediction 以 LPC 系数对信号进行白化
+func maPrediction(in []int16, aQ12 []int16, out []int16) {
+	order := len(aQ12)
+	var state [maxLPCOrder]int32
+	for k := range out {
+		in16 := int32(in[k])
+		out32 := in16<<12 - state[0]
+		out32 = rshiftRound(out32, 12)
+		for d := 0; d < order-1; d++ {
+			state[d] = smlabb(state[d+1], in16, int32(aQ12[d]))
+		}
+		state[order-1] = smulbb(in16, int32(aQ12[order-1]))
+		out[k] = int16(sat16(out32))
+	}
+}
+
+// biquad 二阶 IIR 滤波，系数为 Q13
+func biquad(samples []int16, b [3]int32, a [2]int32, state *[2]int32) {
+	s0, s1 := state[0], state[1]
+	a0Neg, a1Neg := -a[0], -a[1]
+	for k, in := range samples {
+		in16 := int32(in)
+		out32 := smlabb(s0, in16, b[0])
+		s0 = smlabb(s1, in16, b[1])
+		s0 += smulwb(out32, a0Neg) << 3
+		s1 = smulwb(out32, a1Neg) << 3
+		s1 = smlabb(s1, in16, b[2])
+		samples[k] = int16(sat16(rshiftRound(out32, 13) + 1))
+	}
+	state[0], state[1] = s0, s1
+}
+
+// max32 两者中的较大值
+func max32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// splitSilkPackets 将 SILK 文件拆分为数据包
+func splitSilkPackets(data []byte) ([][]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte(HeaderSilk)):
+		data = data[len(HeaderSilk):]
+	case bytes.HasPrefix(data, []byte(HeaderSilk[1:])):
+		data = data[len(HeaderSilk)-1:]
+	default:
+		return nil, errors.New("not a silk file")
+	}
+
+	var packets [][]byte
+	for len(data) >= 2 {
+		n := int(int16(binary.LittleEndian.Uint16(data)))
+		data = data[2:]
+		if n < 0 || n > len(data) {
+			// 文件结束标记或被截断的数据包
+			break
+		}
+		packets = append(packets, data[:n])
+		data = data[n:]
+	}
+	return packets, nil
+}
+
+// decodeSilk 在进程内将 SILK 文件解码为 PCM
+//
+// 输出为各数据包内部采样率下的采样，内部采样率变化时返回 ErrUnsupportedSilk
+func decodeSilk(data []byte) (*PCM, error) {
+	packets, err := splitSilkPackets(data)
+	if err != nil {
+		return nil, err
+	}
+	d := newDecoderState()
+	var samples []int16
+	sampleRate := 0
+	for _, packet := range packets {
+		frame, err := d.decodePacket(packet)
+		if err != nil {
+			return nil, err
+		}
+		if sampleRate != 0 && sampleRate != d.fsKHz*1000 {
+			return nil, ErrUnsupportedSilk
+		}
+		sampleRate = d.fsKHz * 1000
+		samples = append(samples, frame...)
+	}
+	if sampleRate == 0 {
+		sampleRate = decodeSampleRate
+	}
+	return &PCM{SampleRate: sampleRate, Samples: samples}, nil
+}
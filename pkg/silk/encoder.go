@@ -0,0 +1,498 @@
+package silk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	encoderLPCChirp       = 0.99 // LPC 分析的带宽扩展系数
+	encoderNLSFSurvivors  = 8    // NLSF 多级矢量量化每级保留的路径数
+	encoderNLSFRateWeight = 2000 // NLSF 量化中码率相对于误差的权重
+	encoderPulsesPerGain  = 1.0  // 残差均方根与量化步长之比，决定码率与音质
+	encoderMaxPulse       = 127  // 单个脉冲的最大幅度
+	encoderMinGainQ16     = 1 << 16
+)
+
+// encoderState 编码器状态
+type encoderState struct {
+	fsKHz         int
+	frameLength   int
+	subfrLength   int
+	lpcOrder      int
+	frameCount    int
+	prevGainIndex int32
+	pulsesPerGain float64
+	history       []float64     // 上一帧的后半部分输入，用于 LPC 分析
+	window        []float64     // LPC 分析使用的正弦窗
+	dec           *decoderState // 与解码端一致的合成状态
+}
+
+// newEncoderState 创建以指定内部采样率编码的编码器
+func newEncoderState(fsKHz int) (*encoderState, error) {
+	if _, ok := decoderHP[fsKHz]; !ok {
+		return nil, fmt.Errorf("unsupported silk sample rate: %d kHz", fsKHz)
+	}
+	dec := newDecoderState()
+	dec.setFs(fsKHz)
+	window := make([]float64, dec.frameLength*3/2)
+	for i := range window {
+		window[i] = math.Sin(math.Pi * (float64(i) + 0.5) / float64(len(window)))
+	}
+	return &encoderState{
+		fsKHz:         fsKHz,
+		frameLength:   dec.frameLength,
+		subfrLength:   dec.subfrLength,
+		lpcOrder:      dec.lpcOrder,
+		pulsesPerGain: encoderPulsesPerGain,
+		history:       make([]float64, dec.frameLength/2),
+		window:        window,
+		dec:           dec,
+	}, nil
+}
+
+// encodeFrame 编码一帧，每帧单独作为一个数据包
+func (e *encoderState) encodeFrame(x []int16) ([]byte, error) {
+	input := make([]float64, len(x))
+	for i, v := range x {
+		input[i] = float64(v)
+	}
+
+	// 短时预测系数
+	cb := e.dec.nlsfCB[sigTypeUnvoiced]
+	nlsfIndices := e.quantizeNLSF(cb, input)
+	nlsfQ15 := make([]int32, e.lpcOrder)
+	cb.msvqDecode(nlsfQ15, nlsfIndices)
+	var ctrl decoderControl
+	ctrl.sigType = sigTypeUnvoiced
+	ctrl.nlsfInterpQ2 = 4
+	nlsf2AStable(ctrl.predCoefQ12[1][:e.lpcOrder], nlsfQ15)
+	ctrl.predCoefQ12[0] = ctrl.predCoefQ12[1]
+	ctrl.seed = int32(e.frameCount & 3)
+
+	// 增益由预测残差的能量决定，数据包过大时增大量化步长
+	residual := e.residualRMS(input, ctrl.predCoefQ12[1][:e.lpcOrder])
+	scale := e.pulsesPerGain
+	for {
+		var gainIndices [nbSubframes]int
+		prevGainIndex := e.prevGainIndex
+		for k := range ctrl.gainsQ16 {
+			ctrl.gainsQ16[k] = int32(math.Max(residual[k]/scale*65536, encoderMinGainQ16))
+		}
+		gainsQuant(gainIndices[:], ctrl.gainsQ16[:], &prevGainIndex, false)
+
+		q := e.quantizePulses(&ctrl, input)
+		payload, err := e.writeFrame(&ctrl, gainIndices[:], nlsfIndices, q)
+		if errors.Is(err, errRangeCoderOverflow) && scale > 0.05 {
+			scale /= 2
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// 同步解码端状态
+		e.prevGainIndex = prevGainIndex
+		frame := make([]int16, e.frameLength)
+		e.dec.decodeCore(&ctrl, frame, q)
+		copy(e.dec.outBuf[:], frame)
+		e.dec.firstFrame = false
+		copy(e.dec.prevNLSFQ15[:], nlsfQ15)
+		copy(e.history, input[len(input)-len(e.history):])
+		e.frameCount++
+		return payload, nil
+	}
+}
+
+// quantizeNLSF 分析输入的 LPC 系数并量化为 NLSF 索引
+func (e *encoderState) quantizeNLSF(cb *nlsfCodebook, input []float64) []int {
+	// 以半帧历史与当前帧作为分析窗口
+	window := append(append(make([]float64, 0, len(e.history)+len(input)), e.history...), input...)
+	n := len(window)
+	for i := range window {
+		window[i] *= e.window[i]
+	}
+
+	order := e.lpcOrder
+	r := make([]float64, order+1)
+	for k := 0; k <= order; k++ {
+		for i := k; i < n; i++ {
+			r[k] += window[i] * window[i-k]
+		}
+	}
+	// 白噪声校正与滞后窗，避免病态的预测系数
+	r[0] = r[0]*(1+1e-5) + 1e-9
+	for k := 1; k <= order; k++ {
+		lag := 2 * math.Pi * 60 * float64(k) / float64(e.fsKHz*1000)
+		r[k] *= math.Exp(-0.5 * lag * lag)
+	}
+
+	a := levinson(r, order)
+	chirp := encoderLPCChirp
+	for k := range a {
+		a[k] *= chirp
+		chirp *= encoderLPCChirp
+	}
+
+	nlsf := a2NLSF(a)
+	if nlsf == nil {
+		// 无法求得全部根时使用均匀分布的 NLSF
+		nlsf = make([]float64, order)
+		for i := range nlsf {
+			nlsf[i] = float64(i+1) / float64(order+1)
+		}
+	}
+
+	// 以 Laroia 权重衡量量化误差
+	weights := make([]float64, order)
+	target := make([]float64, order)
+	for i := range nlsf {
+		prev, next := 0.0, 1.0
+		if i > 0 {
+			prev = nlsf[i-1]
+		}
+		if i < order-1 {
+			next = nlsf[i+1]
+		}
+		weights[i] = (1/math.Max(nlsf[i]-prev, 1e-3) + 1/math.Max(next-nlsf[i], 1e-3)) / float64(order)
+		target[i] = nlsf[i] * 32768
+	}
+	return cb.msvqEncode(target, weights, encoderNLSFSurvivors, encoderNLSFRateWeight)
+}
+
+// levinson 由自相关求预测系数
+func levinson(r []float64, order int) []float64 {
+	a := make([]float64, order)
+	tmp := make([]float64, order)
+	err := r[0]
+	for i := 0; i < order; i++ {
+		acc := r[i+1]
+		for j := 0; j < i; j++ {
+			acc -= a[j] * r[i-j]
+		}
+		k := acc / err
+		copy(tmp, a)
+		for j := 0; j < i; j++ {
+			a[j] = tmp[j] - k*tmp[i-j-1]
+		}
+		a[i] = k
+		err *= 1 - k*k
+		if err <= 0 {
+			break
+		}
+	}
+	return a
+}
+
+// residualRMS 计算各子帧短时预测残差的均方根
+func (e *encoderState) residualRMS(input []float64, aQ12 []int16) [nbSubframes]float64 {
+	var rms [nbSubframes]float64
+	order := len(aQ12)
+	for k := 0; k < nbSubframes; k++ {
+		var energy float64
+		for i := k * e.subfrLength; i < (k+1)*e.subfrLength; i++ {
+			res := input[i]
+			for j := 0; j < order; j++ {
+				var past float64
+				if i-j-1 >= 0 {
+					past = input[i-j-1]
+				} else {
+					past = e.history[len(e.history)+i-j-1]
+				}
+				res -= float64(aQ12[j]) / 4096 * past
+			}
+			energy += res * res
+		}
+		rms[k] = math.Sqrt(energy / float64(e.subfrLength))
+	}
+	return rms
+}
+
+// quantizePulses 闭环量化激励脉冲，使合成信号逼近输入
+//
+// 合成过程与 decodeCore 的清音帧路径逐位一致
+func (e *encoderState) quantizePulses(ctrl *decoderControl, input []float64) []int {
+	q := make([]int, e.frameLength)
+	offsetQ10 := quantizationOffsetsQ10[ctrl.sigType][ctrl.quantOffsetType]
+	sLPCQ14 := e.dec.sLPCQ14
+	prevInvGainQ16 := e.dec.prevInvGainQ16
+	randSeed := ctrl.seed
+
+	for k := 0; k < nbSubframes; k++ {
+		aQ12 := ctrl.predCoefQ12[k>>1][:e.lpcOrder]
+		gainQ16 := ctrl.gainsQ16[k]
+		invGainQ16 := inverse32VarQ(max32(gainQ16, 1), 32)
+		if invGainQ16 > math.MaxInt16 {
+			invGainQ16 = math.MaxInt16
+		}
+		gainAdjQ16 := int32(1 << 16)
+		if invGainQ16 != prevInvGainQ16 {
+			gainAdjQ16 = div32VarQ(invGainQ16, prevInvGainQ16, 16)
+		}
+		for i := 0; i < maxLPCOrder; i++ {
+			sLPCQ14[i] = smulww(gainAdjQ16, sLPCQ14[i])
+		}
+		prevInvGainQ16 = invGainQ16
+
+		// 输出约为 vecQ10 * gainQ16 >> 26
+		toQ10 := float64(1<<26) / float64(gainQ16)
+		for i := 0; i < e.subfrLength; i++ {
+			n := k*e.subfrLength + i
+			predQ10 := lpcPrediction(sLPCQ14[:maxLPCOrder+i], aQ12)
+			target := input[n]*toQ10 - float64(predQ10)
+
+			randSeed = rand(randSeed)
+			dither := randSeed >> 31
+			if dither != 0 {
+				target = -target
+			}
+			pulse := quantizePulse(target, offsetQ10)
+			excQ10 := int32(pulse)<<10 + offsetQ10
+			excQ10 = (excQ10 ^ dither) - dither
+			randSeed += int32(pulse)
+			q[n] = pulse
+
+			sLPCQ14[maxLPCOrder+i] = (excQ10 + predQ10) << 4
+		}
+		copy(sLPCQ14[:maxLPCOrder], sLPCQ14[e.subfrLength:e.subfrLength+maxLPCOrder])
+	}
+	return q
+}
+
+// quantizePulse 选择使 (pulse << 10) + offset 最接近 target 的脉冲
+func quantizePulse(target float64, offsetQ10 int32) int {
+	v := (target - float64(offsetQ10)) / 1024
+	pulse := int(math.Floor(v))
+	// 在误差相近时偏向幅度更小的脉冲，以减少码率
+	lowErr := math.Abs(v-float64(pulse)) + 0.1*math.Abs(float64(pulse))
+	highErr := math.Abs(v-float64(pulse+1)) + 0.1*math.Abs(float64(pulse+1))
+	if highErr < lowErr {
+		pulse++
+	}
+	if pulse > encoderMaxPulse {
+		return encoderMaxPulse
+	}
+	if pulse < -encoderMaxPulse {
+		return -encoderMaxPulse
+	}
+	return pulse
+}
+
+// gainsQuant 增益量化，gainsQ16 输出量化后的增益
+func gainsQuant(indices []int, gainsQ16 []int32, prevIndex *int32, conditional bool) {
+	for k := range gainsQ16 {
+		index := smulwb(gainScaleQ16, lin2log(gainsQ16[k])-gainOffset)
+		if index < *prevIndex {
+			index++
+		}
+		if k == 0 && !conditional {
+			index = limit32(index, 0, nLevelsQGain-1)
+			indices[k] = int(index)
+			*prevIndex = index
+		} else {
+			// 差分同时保证索引不超出量化范围
+			delta := limit32(index-*prevIndex, minDeltaGainQuant, maxDeltaGainQuant)
+			delta = limit32(delta, -*prevIndex, nLevelsQGain-1-*prevIndex)
+			*prevIndex += delta
+			indices[k] = int(delta - minDeltaGainQuant)
+		}
+		gainsQ16[k] = gainFromIndex(*prevIndex)
+	}
+}
+
+// writeFrame 对一帧的参数进行区间编码
+func (e *encoderState) writeFrame(ctrl *decoderControl, gainIndices []int, nlsfIndices []int, q []int) ([]byte, error) {
+	rc := newRangeEncoder()
+	for i, fsKHz := range samplingRatesTable {
+		if fsKHz == e.fsKHz {
+			rc.encode(i, samplingRatesCDF[:])
+		}
+	}
+	rc.encode(ctrl.sigType<<1+ctrl.quantOffsetType, typeOffsetCDF[:])
+
+	rc.encode(gainIndices[0], gainCDF[ctrl.sigType][:])
+	for _, index := range gainIndices[1:] {
+		rc.encode(index, deltaGainCDF[:])
+	}
+
+	cb := e.dec.nlsfCB[ctrl.sigType]
+	for s, index := range nlsfIndices {
+		rc.encode(index, cb.stageCDF(s))
+	}
+	rc.encode(ctrl.nlsfInterpQ2, nlsfInterpolationFactorCDF[:])
+
+	rc.encode(int(ctrl.seed), seedCDF[:])
+	encodePulses(rc, ctrl, q)
+	rc.encode(1, vadFlagCDF[:])
+	rc.encode(frameTerminationLast, frameTerminationCDF[:])
+	return rc.finish()
+}
+
+// encodePulses 编码激励脉冲
+func encodePulses(rc *rangeEncoder, ctrl *decoderControl, q []int) {
+	iter := len(q) / shellCodecFrameLength
+	absPulses := make([]int, len(q))
+	for i, v := range q {
+		if v < 0 {
+			v = -v
+		}
+		absPulses[i] = v
+	}
+
+	// 每块的脉冲总数，超出码表范围时右移
+	sumPulses := make([]int, iter)
+	nRShifts := make([]int, iter)
+	for i := 0; i < iter; i++ {
+		block := absPulses[i*shellCodecFrameLength : (i+1)*shellCodecFrameLength]
+		for {
+			var pulsesComb [8]int
+			scaleDown := combineAndCheck(pulsesComb[:], block, maxPulsesTable[0], 8)
+			scaleDown = combineAndCheck(pulsesComb[:], pulsesComb[:], maxPulsesTable[1], 4) || scaleDown
+			scaleDown = combineAndCheck(pulsesComb[:], pulsesComb[:], maxPulsesTable[2], 2) || scaleDown
+			sumPulses[i] = pulsesComb[0] + pulsesComb[1]
+			if sumPulses[i] > maxPulsesTable[3] {
+				scaleDown = true
+			}
+			if !scaleDown {
+				break
+			}
+			nRShifts[i]++
+			for k := range block {
+				block[k] >>= 1
+			}
+		}
+	}
+
+	// 选择码率最低的码率等级
+	minBitsQ6 := math.MaxInt32
+	for k := 0; k < nRateLevels-1; k++ {
+		bitsQ6 := int(rateLevelsBitsQ6[ctrl.sigType][k])
+		for i := 0; i < iter; i++ {
+			if nRShifts[i] > 0 {
+				bitsQ6 += int(pulsesPerBlockBitsQ6[k][maxPulses+1])
+			} else {
+				bitsQ6 += int(pulsesPerBlockBitsQ6[k][sumPulses[i]])
+			}
+		}
+		if bitsQ6 < minBitsQ6 {
+			minBitsQ6 = bitsQ6
+			ctrl.rateLevelIndex = k
+		}
+	}
+	rc.encode(ctrl.rateLevelIndex, rateLevelsCDF[ctrl.sigType][:])
+
+	cdf := pulsesPerBlockCDF[ctrl.rateLevelIndex][:]
+	for i := 0; i < iter; i++ {
+		if nRShifts[i] == 0 {
+			rc.encode(sumPulses[i], cdf)
+			continue
+		}
+		rc.encode(maxPulses+1, cdf)
+		for k := 0; k < nRShifts[i]-1; k++ {
+			rc.encode(maxPulses+1, pulsesPerBlockCDF[nRateLevels-1][:])
+		}
+		rc.encode(sumPulses[i], pulsesPerBlockCDF[nRateLevels-1][:])
+	}
+
+	for i := 0; i < iter; i++ {
+		if sumPulses[i] > 0 {
+			shellEncoder(rc, absPulses[i*shellCodecFrameLength:(i+1)*shellCodecFrameLength])
+		}
+	}
+
+	for i := 0; i < iter; i++ {
+		if nRShifts[i] == 0 {
+			continue
+		}
+		for _, v := range q[i*shellCodecFrameLength : (i+1)*shellCodecFrameLength] {
+			if v < 0 {
+				v = -v
+			}
+			for j := nRShifts[i] - 1; j >= 0; j-- {
+				rc.encode((v>>uint(j))&1, lsbCDF[:])
+			}
+		}
+	}
+
+	signs := signCDFFor(ctrl.sigType, ctrl.quantOffsetType, ctrl.rateLevelIndex)
+	for _, v := range q {
+		if v > 0 {
+			rc.encode(1, signs[:])
+		} else if v < 0 {
+			rc.encode(0, signs[:])
+		}
+	}
+}
+
+// combineAndCheck 两两合并脉冲数，并检查是否超出上限
+func combineAndCheck(out []int, in []int, limit int, length int) bool {
+	exceeded := false
+	for k := 0; k < length; k++ {
+		sum := in[2*k] + in[2*k+1]
+		if sum > limit {
+			exceeded = true
+		}
+		out[k] = sum
+	}
+	return exceeded
+}
+
+// encodeSplit 编码脉冲在两个子块间的分割
+func encodeSplit(rc *rangeEncoder, child, p int, table []uint16) {
+	if p > 0 {
+		rc.encode(child, table[shellCodeTableOffsets[p]:])
+	}
+}
+
+// shellEncoder 编码一个块中的脉冲分布
+func shellEncoder(rc *rangeEncoder, pulses0 []int) {
+	var pulses1 [8]int
+	var pulses2 [4]int
+	var pulses3 [2]int
+	combineAndCheck(pulses1[:], pulses0, math.MaxInt32, 8)
+	combineAndCheck(pulses2[:], pulses1[:], math.MaxInt32, 4)
+	combineAndCheck(pulses3[:], pulses2[:], math.MaxInt32, 2)
+
+	encodeSplit(rc, pulses3[0], pulses3[0]+pulses3[1], shellCodeTables[3])
+	for i3 := 0; i3 < 2; i3++ {
+		encodeSplit(rc, pulses2[2*i3], pulses3[i3], shellCodeTables[2])
+		for i2 := 2 * i3; i2 < 2*i3+2; i2++ {
+			encodeSplit(rc, pulses1[2*i2], pulses2[i2], shellCodeTables[1])
+			for i1 := 2 * i2; i1 < 2*i2+2; i1++ {
+				encodeSplit(rc, pulses0[2*i1], pulses1[i1], shellCodeTables[0])
+			}
+		}
+	}
+}
+
+// encodeSilk 在进程内将 PCM 编码为 SILK 文件
+func encodeSilk(pcm *PCM) ([]byte, error) {
+	if pcm.SampleRate%1000 != 0 {
+		return nil, fmt.Errorf("unsupported silk sample rate: %d", pcm.SampleRate)
+	}
+	e, err := newEncoderState(pcm.SampleRate / 1000)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(HeaderSilk)+len(pcm.Samples)/4))
+	buf.WriteString(HeaderSilk)
+	frame := make([]int16, e.frameLength)
+	for offset := 0; offset < len(pcm.Samples); offset += e.frameLength {
+		// 最后一帧不足时补零
+		n := copy(frame, pcm.Samples[offset:])
+		for i := n; i < len(frame); i++ {
+			frame[i] = 0
+		}
+		payload, err := e.encodeFrame(frame)
+		if err != nil {
+			return nil, err
+		}
+		_ = binary.Write(buf, binary.LittleEndian, int16(len(payload)))
+		buf.Write(payload)
+	}
+	return buf.Bytes(), nil
+}
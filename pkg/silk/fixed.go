@@ -0,0 +1,169 @@
+package silk
+
+import (
+	"math"
+	"math/bits"
+)
+
+// 以下为 SILK SDK 使用的定点运算，需要与其逐位一致
+
+// smulwb (a * int16(b)) >> 16
+func smulwb(a, b int32) int32 {
+	return (a>>16)*int32(int16(b)) + ((a&0xFFFF)*int32(int16(b)))>>16
+}
+
+// smlawb a + smulwb(b, c)
+func smlawb(a, b, c int32) int32 {
+	return a + smulwb(b, c)
+}
+
+// smulbb int16(a) * int16(b)
+func smulbb(a, b int32) int32 {
+	return int32(int16(a)) * int32(int16(b))
+}
+
+// smlabb a + smulbb(b, c)
+func smlabb(a, b, c int32) int32 {
+	return a + smulbb(b, c)
+}
+
+// smulww (a * b) >> 16
+func smulww(a, b int32) int32 {
+	return smulwb(a, b) + a*rshiftRound(b, 16)
+}
+
+// smlaww a + smulww(b, c)
+func smlaww(a, b, c int32) int32 {
+	return a + smulww(b, c)
+}
+
+// smmul (a * b) >> 32
+func smmul(a, b int32) int32 {
+	return int32((int64(a) * int64(b)) >> 32)
+}
+
+// rshiftRound 四舍五入的右移
+func rshiftRound(a int32, shift uint) int32 {
+	if shift == 1 {
+		return (a >> 1) + (a & 1)
+	}
+	return ((a >> (shift - 1)) + 1) >> 1
+}
+
+// rshiftRound64 四舍五入的 64 位右移
+func rshiftRound64(a int64, shift uint) int64 {
+	return ((a >> (shift - 1)) + 1) >> 1
+}
+
+// sat16 饱和到 int16 范围
+func sat16(a int32) int32 {
+	if a > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if a < math.MinInt16 {
+		return math.MinInt16
+	}
+	return a
+}
+
+// lshiftSat32 饱和的左移
+func lshiftSat32(a int32, shift uint) int32 {
+	limited := limit32(a, math.MinInt32>>shift, math.MaxInt32>>shift)
+	return limited << shift
+}
+
+// limit32 将 a 限制在 [low, high] 之间，low 大于 high 时交换
+func limit32(a, low, high int32) int32 {
+	if low > high {
+		low, high = high, low
+	}
+	if a > high {
+		return high
+	}
+	if a < low {
+		return low
+	}
+	return a
+}
+
+// abs32 绝对值
+func abs32(a int32) int32 {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// clz32 前导零的个数
+func clz32(a int32) int32 {
+	return int32(bits.LeadingZeros32(uint32(a)))
+}
+
+// rand 线性同余伪随机数
+func rand(seed int32) int32 {
+	return 907633515 + seed*196314165
+}
+
+// inverse32VarQ 计算 (1 << qRes) / b
+func inverse32VarQ(b int32, qRes int32) int32 {
+	headroom := clz32(abs32(b)) - 1
+	bNrm := b << uint(headroom)
+	bInv := (math.MaxInt32 >> 2) / (bNrm >> 16)
+	result := bInv << 16
+	errQ32 := -smulwb(bNrm, bInv) << 3
+	result = smlaww(result, errQ32, bInv)
+
+	shift := 61 - headroom - qRes
+	if shift <= 0 {
+		return lshiftSat32(result, uint(-shift))
+	}
+	if shift < 32 {
+		return result >> uint(shift)
+	}
+	return 0
+}
+
+// div32VarQ 计算 (a << qRes) / b
+func div32VarQ(a, b int32, qRes int32) int32 {
+	aHeadroom := clz32(abs32(a)) - 1
+	aNrm := a << uint(aHeadroom)
+	bHeadroom := clz32(abs32(b)) - 1
+	bNrm := b << uint(bHeadroom)
+
+	bInv := (math.MaxInt32 >> 2) / (bNrm >> 16)
+	result := smulwb(aNrm, bInv)
+	aNrm -= smmul(bNrm, result) << 3
+	result = smlawb(result, aNrm, bInv)
+
+	shift := 29 + aHeadroom - bHeadroom - qRes
+	if shift <= 0 {
+		return lshiftSat32(result, uint(-shift))
+	}
+	if shift < 32 {
+		return result >> uint(shift)
+	}
+	return 0
+}
+
+// log2lin 由 Q7 的以 2 为底的对数计算线性值
+func log2lin(inLogQ7 int32) int32 {
+	if inLogQ7 < 0 {
+		return 0
+	}
+	if inLogQ7 >= 31<<7 {
+		return math.MaxInt32
+	}
+	out := int32(1) << uint(inLogQ7>>7)
+	fracQ7 := inLogQ7 & 0x7F
+	if inLogQ7 < 2048 {
+		return out + (out*smlawb(fracQ7, fracQ7*(128-fracQ7), -174))>>7
+	}
+	return out + (out>>7)*smlawb(fracQ7, fracQ7*(128-fracQ7), -174)
+}
+
+// lin2log 计算 Q7 的以 2 为底的对数
+func lin2log(inLin int32) int32 {
+	lz := clz32(inLin)
+	fracQ7 := int32(bits.RotateLeft32(uint32(inLin), -int(24-lz))) & 0x7F
+	return (31-lz)<<7 + smlawb(fracQ7, fracQ7*(128-fracQ7), 179)
+}
@@ -0,0 +1,393 @@
+package silk
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	maxLPCOrder              = 16 // 最大 LPC 阶数
+	maxLPCStabilizeIteration = 20 // 稳定 LPC 系数的最大迭代次数
+	nlsfStabilizeMaxLoops    = 20 // 稳定 NLSF 的最大迭代次数
+)
+
+// nlsfStage NLSF 多级矢量量化的一级
+type nlsfStage struct {
+	cbQ15   []int16 // 码本向量，Q15
+	ratesQ5 []int16 // 各码本向量的码率，Q5
+}
+
+// nlsfCodebook NLSF 多级矢量量化码本
+type nlsfCodebook struct {
+	stages       []nlsfStage
+	nDeltaMinQ15 []int32  // 相邻 NLSF 的最小间隔，Q15
+	cdf          []uint16 // 各级索引的累积分布
+	startIx      []int    // 各级累积分布在 cdf 中的起始位置
+	middleIx     []int    // 各级索引的初始查找位置
+}
+
+// stageCDF 获取指定级索引的累积分布
+func (cb *nlsfCodebook) stageCDF(stage int) []uint16 {
+	return cb.cdf[cb.startIx[stage]:]
+}
+
+// nlsfStabilize 保证 NLSF 单调递增且间隔不小于 nDeltaMinQ15
+func nlsfStabilize(nlsfQ15 []int32, nDeltaMinQ15 []int32) {
+	order := len(nlsfQ15)
+	loops := 0
+	for ; loops < nlsfStabilizeMaxLoops; loops++ {
+		// 找出间隔最小的位置
+		minDiff := nlsfQ15[0] - nDeltaMinQ15[0]
+		index := 0
+		for i := 1; i < order; i++ {
+			diff := nlsfQ15[i] - (nlsfQ15[i-1] + nDeltaMinQ15[i])
+			if diff < minDiff {
+				minDiff = diff
+				index = i
+			}
+		}
+		diff := (1 << 15) - (nlsfQ15[order-1] + nDeltaMinQ15[order])
+		if diff < minDiff {
+			minDiff = diff
+			index = order
+		}
+		if minDiff >= 0 {
+			return
+		}
+
+		switch index {
+		case 0:
+			nlsfQ15[0] = nDeltaMinQ15[0]
+		case order:
+			nlsfQ15[order-1] = (1 << 15) - nDeltaMinQ15[order]
+		default:
+			minCenter := nDeltaMinQ15[index] >> 1
+			for k := 0; k < index; k++ {
+				minCenter += nDeltaMinQ15[k]
+			}
+			maxCenter := int32(1<<15) - nDeltaMinQ15[index]>>1
+			for k := order; k > index; k-- {
+				maxCenter -= nDeltaMinQ15[k]
+			}
+			center := limit32(rshiftRound(nlsfQ15[index-1]+nlsfQ15[index], 1), minCenter, maxCenter)
+			nlsfQ15[index-1] = center - nDeltaMinQ15[index]>>1
+			nlsfQ15[index] = nlsfQ15[index-1] + nDeltaMinQ15[index]
+		}
+	}
+
+	// 迭代未能收敛时排序后强制满足间隔
+	sort.Slice(nlsfQ15, func(i, j int) bool { return nlsfQ15[i] < nlsfQ15[j] })
+	if nlsfQ15[0] < nDeltaMinQ15[0] {
+		nlsfQ15[0] = nDeltaMinQ15[0]
+	}
+	for i := 1; i < order; i++ {
+		if min := nlsfQ15[i-1] + nDeltaMinQ15[i]; nlsfQ15[i] < min {
+			nlsfQ15[i] = min
+		}
+	}
+	if max := int32(1<<15) - nDeltaMinQ15[order]; nlsfQ15[order-1] > max {
+		nlsfQ15[order-1] = max
+	}
+	for i := order - 2; i >= 0; i-- {
+		if max := nlsfQ15[i+1] - nDeltaMinQ15[i+1]; nlsfQ15[i] > max {
+			nlsfQ15[i] = max
+		}
+	}
+}
+
+// msvqDecode 由各级索引还原 NLSF
+func (cb *nlsfCodebook) msvqDecode(nlsfQ15 []int32, indices []int) {
+	order := len(nlsfQ15)
+	for i := range nlsfQ15 {
+		nlsfQ15[i] = 0
+	}
+	for s, stage := range cb.stages {
+		vector := stage.cbQ15[indices[s]*order:]
+		for i := 0; i < order; i++ {
+			nlsfQ15[i] += int32(vector[i])
+		}
+	}
+	nlsfStabilize(nlsfQ15, cb.nDeltaMinQ15)
+}
+
+// nlsf2AFindPoly 由余弦值构造多项式
+func nlsf2AFindPoly(out []int32, cLSF []int32, dd int) {
+	out[0] = 1 << 20
+	out[1] = -cLSF[0]
+	for k := 1; k < dd; k++ {
+		ftmp := cLSF[2*k]
+		out[k+1] = out[k-1]<<1 - int32(rshiftRound64(int64(ftmp)*int64(out[k]), 20))
+		for n := k; n > 1; n-- {
+			out[n] += out[n-2] - int32(rshiftRound64(int64(ftmp)*int64(out[n-1]), 20))
+		}
+		out[1] -= ftmp
+	}
+}
+
+// nlsf2A 将 NLSF 转换为 LPC 系数，Q12
+func nlsf2A(aQ12 []int16, nlsfQ15 []int32) {
+	order := len(nlsfQ15)
+	var cosLSFQ20 [maxLPCOrder]int32
+	for k := 0; k < order; k++ {
+		fInt := nlsfQ15[k] >> (15 - 7)
+		fFrac := nlsfQ15[k] - fInt<<(15-7)
+		cosVal := lsfCosTabQ12[fInt]
+		delta := lsfCosTabQ12[fInt+1] - cosVal
+		cosLSFQ20[k] = cosVal<<8 + delta*fFrac
+	}
+
+	dd := order >> 1
+	var p, q [maxLPCOrder/2 + 1]int32
+	nlsf2AFindPoly(p[:], cosLSFQ20[0:], dd)
+	nlsf2AFindPoly(q[:], cosLSFQ20[1:], dd)
+
+	var a [maxLPCOrder]int32
+	for k := 0; k < dd; k++ {
+		pTmp := p[k+1] + p[k]
+		qTmp := q[k+1] - q[k]
+		a[k] = -rshiftRound(pTmp+qTmp, 9)
+		a[order-k-1] = rshiftRound(qTmp-pTmp, 9)
+	}
+
+	// 限制系数的最大绝对值
+	i := 0
+	for ; i < 10; i++ {
+		var maxAbs, index int32
+		for k := 0; k < order; k++ {
+			if v := abs32(a[k]); v > maxAbs {
+				maxAbs = v
+				index = int32(k)
+			}
+		}
+		if maxAbs <= math.MaxInt16 {
+			break
+		}
+		if maxAbs > 98369 {
+			maxAbs = 98369
+		}
+		scQ16 := 65470 - ((65470>>2)*(maxAbs-math.MaxInt16))/((maxAbs*(index+1))>>2)
+		bwExpander32(a[:order], scQ16)
+	}
+	if i == 10 {
+		for k := 0; k < order; k++ {
+			a[k] = sat16(a[k])
+		}
+	}
+	for k := 0; k < order; k++ {
+		aQ12[k] = int16(a[k])
+	}
+}
+
+// nlsf2AStable 将 NLSF 转换为稳定的 LPC 系数，Q12
+func nlsf2AStable(aQ12 []int16, nlsfQ15 []int32) {
+	nlsf2A(aQ12, nlsfQ15)
+	i := 0
+	for ; i < maxLPCStabilizeIteration; i++ {
+		if lpcStable(aQ12) {
+			break
+		}
+		bwExpander(aQ12, 65536-smulbb(10+int32(i), int32(i)))
+	}
+	if i == maxLPCStabilizeIteration {
+		for k := range aQ12 {
+			aQ12[k] = 0
+		}
+	}
+}
+
+// bwExpander 对 Q12 的 LPC 系数进行带宽扩展
+func bwExpander(a []int16, chirpQ16 int32) {
+	chirpMinusOneQ16 := chirpQ16 - 65536
+	last := len(a) - 1
+	for i := 0; i < last; i++ {
+		a[i] = int16(rshiftRound(chirpQ16*int32(a[i]), 16))
+		chirpQ16 += rshiftRound(chirpQ16*chirpMinusOneQ16, 16)
+	}
+	a[last] = int16(rshiftRound(chirpQ16*int32(a[last]), 16))
+}
+
+// bwExpander32 对 32 位的 LPC 系数进行带宽扩展
+func bwExpander32(a []int32, chirpQ16 int32) {
+	tmpChirpQ16 := chirpQ16
+	last := len(a) - 1
+	for i := 0; i < last; i++ {
+		a[i] = smulww(a[i], tmpChirpQ16)
+		tmpChirpQ16 = smulww(chirpQ16, tmpChirpQ16)
+	}
+	a[last] = smulww(a[last], tmpChirpQ16)
+}
+
+// lpcStable 通过反射系数判断 LPC 滤波器是否稳定
+func lpcStable(aQ12 []int16) bool {
+	const (
+		qa     = 16
+		aLimit = 65520 // 0.99975，Q16
+	)
+	order := len(aQ12)
+	var tmp [2][maxLPCOrder]int32
+	aNew := tmp[order&1][:]
+	for k := 0; k < order; k++ {
+		aNew[k] = int32(aQ12[k]) << (qa - 12)
+	}
+	for k := order - 1; k > 0; k-- {
+		if aNew[k] > aLimit || aNew[k] < -aLimit {
+			return false
+		}
+		rcQ31 := -aNew[k] << (31 - qa)
+		rcMult1Q30 := int32(math.MaxInt32>>1) - smmul(rcQ31, rcQ31)
+		rcMult2Q16 := inverse32VarQ(rcMult1Q30, 46)
+
+		aOld := aNew
+		aNew = tmp[k&1][:]
+		headroom := clz32(rcMult2Q16) - 1
+		rcMult2Q16 <<= uint(headroom)
+		for n := 0; n < k; n++ {
+			tmpQA := aOld[n] - smmul(aOld[k-n-1], rcQ31)<<1
+			aNew[n] = smmul(tmpQA, rcMult2Q16) << uint(16-headroom)
+		}
+	}
+	return aNew[0] <= aLimit && aNew[0] >= -aLimit
+}
+
+// a2NLSFGrid 求根时扫描的 cos(w) 网格
+var a2NLSFGrid = func() []float64 {
+	const gridSize = 256
+	grid := make([]float64, gridSize+1)
+	for i := range grid {
+		grid[i] = math.Cos(math.Pi * float64(i) / gridSize)
+	}
+	return grid
+}()
+
+// a2NLSF 将浮点 LPC 系数转换为 NLSF，单位为弧度除以 π
+//
+// a 为预测系数，即 x[n] ≈ Σ a[k] x[n-k-1]
+func a2NLSF(a []float64) []float64 {
+	order := len(a)
+	half := order / 2
+
+	// 对称与反对称多项式，去除 z = -1 与 z = 1 处的根
+	p := make([]float64, half+1)
+	q := make([]float64, half+1)
+	p[0], q[0] = 1, 1
+	for k := 0; k < half; k++ {
+		p[k+1] = -a[k] - a[order-k-1] - p[k]
+		q[k+1] = -a[k] + a[order-k-1] + q[k]
+	}
+
+	// 以 cos(w) 的切比雪夫多项式求值
+	eval := func(c []float64, x float64) float64 {
+		// c[half] / 2 + Σ c[k] * cos((half - k) * w)，使用 Clenshaw 递推
+		b0, b1, b2 := 0.0, 0.0, 0.0
+		for k := 0; k < half; k++ {
+			b2 = b1
+			b1 = b0
+			b0 = 2*x*b1 - b2 + c[k]
+		}
+		return x*b0 - b1 + c[half]/2
+	}
+
+	roots := make([]float64, 0, order)
+	for _, c := range [][]float64{p, q} {
+		prevX := a2NLSFGrid[0]
+		prevY := eval(c, prevX)
+		for _, x := range a2NLSFGrid[1:] {
+			if len(roots) == order {
+				break
+			}
+			y := eval(c, x)
+			if (prevY <= 0 && y > 0) || (prevY >= 0 && y < 0) {
+				// 二分法求根
+				lo, hi, yLo := prevX, x, prevY
+				for j := 0; j < 20; j++ {
+					mid := (lo + hi) / 2
+					yMid := eval(c, mid)
+					if (yLo <= 0 && yMid <= 0) || (yLo > 0 && yMid > 0) {
+						lo, yLo = mid, yMid
+					} else {
+						hi = mid
+					}
+				}
+				roots = append(roots, math.Acos((lo+hi)/2)/math.Pi)
+			}
+			prevX, prevY = x, y
+		}
+	}
+	if len(roots) != order {
+		return nil
+	}
+	sort.Float64s(roots)
+	return roots
+}
+
+// nlsfCandidate 多级矢量量化的候选路径
+type nlsfCandidate struct {
+	indices []int
+	residue []float64
+	rate    float64
+}
+
+// nlsfChoice 候选路径的一个扩展
+type nlsfChoice struct {
+	path   int
+	vector int
+	rate   float64
+	cost   float64
+}
+
+// msvqEncode 对 NLSF 进行多级矢量量化，返回各级索引
+//
+// 每级保留代价最小的若干条路径，代价为加权量化误差与码率之和
+func (cb *nlsfCodebook) msvqEncode(nlsf []float64, weights []float64, survivors int, rateWeight float64) []int {
+	order := len(nlsf)
+	paths := []nlsfCandidate{{residue: nlsf}}
+	best := make([]nlsfChoice, 0, survivors)
+	for _, stage := range cb.stages {
+		best = best[:0]
+		for p, path := range paths {
+			for v := range stage.ratesQ5 {
+				rate := path.rate + rateWeight*float64(stage.ratesQ5[v])
+				worst := math.Inf(1)
+				if len(best) == survivors {
+					worst = best[survivors-1].cost
+				}
+				vector := stage.cbQ15[v*order : (v+1)*order]
+				cost := rate
+				for i := 0; i < order && cost < worst; i++ {
+					d := path.residue[i] - float64(vector[i])
+					cost += weights[i] * d * d
+				}
+				if cost >= worst {
+					continue
+				}
+
+				// 按代价插入有序的保留列表
+				if len(best) < survivors {
+					best = append(best, nlsfChoice{})
+				}
+				j := len(best) - 1
+				for ; j > 0 && best[j-1].cost > cost; j-- {
+					best[j] = best[j-1]
+				}
+				best[j] = nlsfChoice{path: p, vector: v, rate: rate, cost: cost}
+			}
+		}
+
+		next := make([]nlsfCandidate, len(best))
+		for i, c := range best {
+			path := paths[c.path]
+			vector := stage.cbQ15[c.vector*order : (c.vector+1)*order]
+			residue := make([]float64, order)
+			for k := range residue {
+				residue[k] = path.residue[k] - float64(vector[k])
+			}
+			next[i] = nlsfCandidate{
+				indices: append(append([]int(nil), path.indices...), c.vector),
+				residue: residue,
+				rate:    c.rate,
+			}
+		}
+		paths = next
+	}
+	return paths[0].indices
+}
@@ -0,0 +1,232 @@
+package silk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	wavFormatPCM        uint16 = 0x0001 // 整数 PCM
+	wavFormatIEEEFloat  uint16 = 0x0003 // 浮点 PCM
+	wavFormatExtensible uint16 = 0xFFFE // 扩展格式
+)
+
+// ErrUnsupportedWAV 不支持的 WAV 格式
+var ErrUnsupportedWAV = errors.New("unsupported wav format")
+
+// PCM 单声道 16 位 PCM 数据
+type PCM struct {
+	SampleRate int     // 采样率
+	Samples    []int16 // 采样数据
+}
+
+// IsWAV 判断是否是 WAV 文件
+func IsWAV(data []byte) bool {
+	return len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE"
+}
+
+// DecodeWAV 解析 WAV 文件并混合为单声道
+func DecodeWAV(data []byte) (*PCM, error) {
+	if !IsWAV(data) {
+		return nil, fmt.Errorf("%w: not a wav file", ErrUnsupportedWAV)
+	}
+
+	var (
+		format        uint16
+		channels      int
+		sampleRate    int
+		bitsPerSample int
+		hasFormat     bool
+		body          []byte
+	)
+
+	// 遍历 RIFF 块
+	offset := 12
+	for offset+8 <= len(data) {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		start := offset + 8
+		end := start + size
+		if size < 0 || end > len(data) {
+			// 部分编码器写入的 data 块长度不准确，以实际长度为准
+			end = len(data)
+		}
+
+		switch id {
+		case "fmt ":
+			chunk := data[start:end]
+			if len(chunk) < 16 {
+				return nil, fmt.Errorf("%w: fmt chunk too short", ErrUnsupportedWAV)
+			}
+			format = binary.LittleEndian.Uint16(chunk[0:2])
+			channels = int(binary.LittleEndian.Uint16(chunk[2:4]))
+			sampleRate = int(binary.LittleEndian.Uint32(chunk[4:8]))
+			bitsPerSample = int(binary.LittleEndian.Uint16(chunk[14:16]))
+			if format == wavFormatExtensible && len(chunk) >= 26 {
+				// 扩展格式的子格式 GUID 前两字节即为实际格式
+				format = binary.LittleEndian.Uint16(chunk[24:26])
+			}
+			hasFormat = true
+		case "data":
+			body = data[start:end]
+		}
+
+		// 块按偶数字节对齐
+		offset = end + size%2
+	}
+
+	if !hasFormat || body == nil {
+		return nil, fmt.Errorf("%w: missing fmt or data chunk", ErrUnsupportedWAV)
+	}
+	if channels <= 0 || sampleRate <= 0 {
+		return nil, fmt.Errorf("%w: invalid channels or sample rate", ErrUnsupportedWAV)
+	}
+
+	var readSample func(b []byte) float64
+	switch {
+	case format == wavFormatPCM && bitsPerSample == 8:
+		readSample = func(b []byte) float64 { return (float64(b[0]) - 128) / 128 }
+	case format == wavFormatPCM && bitsPerSample == 16:
+		readSample = func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / 32768 }
+	case format == wavFormatPCM && bitsPerSample == 24:
+		readSample = func(b []byte) float64 {
+			v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+			return float64(v) / 8388608
+		}
+	case format == wavFormatPCM && bitsPerSample == 32:
+		readSample = func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / 2147483648 }
+	case format == wavFormatIEEEFloat && bitsPerSample == 32:
+		readSample = func(b []byte) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) }
+	case format == wavFormatIEEEFloat && bitsPerSample == 64:
+		readSample = func(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) }
+	default:
+		return nil, fmt.Errorf("%w: format %d with %d bits", ErrUnsupportedWAV, format, bitsPerSample)
+	}
+
+	sampleSize := bitsPerSample / 8
+	frameSize := sampleSize * channels
+	frames := len(body) / frameSize
+	samples := make([]int16, frames)
+	for i := 0; i < frames; i++ {
+		frame := body[i*frameSize : (i+1)*frameSize]
+		var sum float64
+		for c := 0; c < channels; c++ {
+			sum += readSample(frame[c*sampleSize : (c+1)*sampleSize])
+		}
+		samples[i] = clampSample(sum / float64(channels) * 32768)
+	}
+
+	return &PCM{SampleRate: sampleRate, Samples: samples}, nil
+}
+
+// Resample 将 PCM 数据重采样至指定采样率
+func (p *PCM) Resample(sampleRate int) *PCM {
+	if p.SampleRate == sampleRate || len(p.Samples) == 0 {
+		return &PCM{SampleRate: sampleRate, Samples: p.Samples}
+	}
+
+	src := p.Samples
+	// 降采样前先进行滑动平均低通滤波，减少混叠
+	if p.SampleRate > sampleRate {
+		src = lowPass(src, (p.SampleRate+sampleRate-1)/sampleRate)
+	}
+
+	// 线性插值
+	ratio := float64(p.SampleRate) / float64(sampleRate)
+	length := int(float64(len(src)) / ratio)
+	samples := make([]int16, length)
+	for i := range samples {
+		pos := float64(i) * ratio
+		index := int(pos)
+		frac := pos - float64(index)
+		current := float64(src[index])
+		next := current
+		if index+1 < len(src) {
+			next = float64(src[index+1])
+		}
+		samples[i] = clampSample(current + (next-current)*frac)
+	}
+
+	return &PCM{SampleRate: sampleRate, Samples: samples}
+}
+
+// Bytes 将 PCM 数据转换为 s16le 字节流
+func (p *PCM) Bytes() []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, len(p.Samples)*2))
+	_ = binary.Write(buf, binary.LittleEndian, p.Samples)
+	return buf.Bytes()
+}
+
+// lowPass 以指定窗口大小进行滑动平均
+func lowPass(samples []int16, window int) []int16 {
+	if window <= 1 {
+		return samples
+	}
+
+	result := make([]int16, len(samples))
+	var sum int64
+	for i, s := range samples {
+		sum += int64(s)
+		if i >= window {
+			sum -= int64(samples[i-window])
+		}
+		count := window
+		if i+1 < window {
+			count = i + 1
+		}
+		result[i] = int16(sum / int64(count))
+	}
+	return result
+}
+
+// clampSample 将采样值限制在 16 位范围内
+func clampSample(v float64) int16 {
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
+
+// Duration 获取 PCM 数据时长
+func (p *PCM) Duration() time.Duration {
+	if p.SampleRate <= 0 {
+		return 0
+	}
+	return time.Duration(len(p.Samples)) * time.Second / time.Duration(p.SampleRate)
+}
+
+// WAV 将 PCM 数据封装为 16 位单声道 WAV 文件
+func (p *PCM) WAV() []byte {
+	body := p.Bytes()
+	buf := bytes.NewBuffer(make([]byte, 0, 44+len(body)))
+	buf.WriteString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(36+len(body)))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(buf, binary.LittleEndian, wavFormatPCM)
+	_ = binary.Write(buf, binary.LittleEndian, uint16(1))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(p.SampleRate))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(p.SampleRate*2))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(2))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(body)))
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// NewPCM 从 s16le 字节流创建 PCM 数据
+func NewPCM(data []byte, sampleRate int) *PCM {
+	samples := make([]int16, len(data)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+	}
+	return &PCM{SampleRate: sampleRate, Samples: samples}
+}
@@ -0,0 +1,252 @@
+package silk
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// maxArithmBytes 单个数据包的最大字节数
+const maxArithmBytes = 1024
+
+var (
+	errRangeCoderOverflow = errors.New("silk: payload exceeds the maximum packet size")
+	errRangeCoderCorrupt  = errors.New("silk: corrupt payload")
+)
+
+// rangeEncoder 区间编码器
+type rangeEncoder struct {
+	base   uint32
+	rng    uint32
+	buffer []byte
+	err    error
+}
+
+// newRangeEncoder 创建区间编码器
+func newRangeEncoder() *rangeEncoder {
+	return &rangeEncoder{rng: 0xFFFF, buffer: make([]byte, 0, maxArithmBytes)}
+}
+
+// writeByte 写入一个字节
+func (e *rangeEncoder) writeByte(b byte) {
+	if len(e.buffer) >= maxArithmBytes {
+		e.err = errRangeCoderOverflow
+		return
+	}
+	e.buffer = append(e.buffer, b)
+}
+
+// encode 按累积分布 cdf 编码符号 data
+func (e *rangeEncoder) encode(data int, cdf []uint16) {
+	if e.err != nil {
+		return
+	}
+	low := uint32(cdf[data])
+	high := uint32(cdf[data+1])
+	baseTmp := e.base
+	e.base += e.rng * low
+	rng := e.rng * (high - low)
+
+	// 进位
+	if e.base < baseTmp {
+		for i := len(e.buffer) - 1; i >= 0; i-- {
+			e.buffer[i]++
+			if e.buffer[i] != 0 {
+				break
+			}
+		}
+	}
+
+	if rng&0xFF000000 != 0 {
+		e.rng = rng >> 16
+		return
+	}
+	if rng&0xFFFF0000 != 0 {
+		e.rng = rng >> 8
+	} else {
+		e.rng = rng
+		e.writeByte(byte(e.base >> 24))
+		e.base <<= 8
+	}
+	e.writeByte(byte(e.base >> 24))
+	e.base <<= 8
+}
+
+// encodeMulti 依次按各自的累积分布编码多个符号
+func (e *rangeEncoder) encodeMulti(data []int, cdfs [][]uint16) {
+	for i := range data {
+		e.encode(data[i], cdfs[i])
+	}
+}
+
+// length 获取已编码的比特数与字节数
+func (e *rangeEncoder) length() (int, int) {
+	nBits := len(e.buffer)<<3 + bits.LeadingZeros32(e.rng-1) - 14
+	return nBits, (nBits + 7) >> 3
+}
+
+// finish 结束编码并返回编码结果
+func (e *rangeEncoder) finish() ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	base := e.base >> 8
+	bitsInStream, nBytes := e.length()
+
+	// 需要额外写入的比特数
+	bitsToStore := bitsInStream - len(e.buffer)<<3
+	base += 0x00800000 >> (bitsToStore - 1)
+	base &= 0xFFFFFFFF << (24 - bitsToStore)
+
+	if base&0x01000000 != 0 {
+		for i := len(e.buffer) - 1; i >= 0; i-- {
+			e.buffer[i]++
+			if e.buffer[i] != 0 {
+				break
+			}
+		}
+	}
+
+	e.writeByte(byte(base >> 16))
+	if bitsToStore > 8 {
+		e.writeByte(byte(base >> 8))
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	// 最后一个字节的剩余比特以 1 填充
+	if bitsInStream&7 != 0 {
+		e.buffer[nBytes-1] |= byte(0xFF >> (bitsInStream & 7))
+	}
+	return e.buffer[:nBytes], nil
+}
+
+// rangeDecoder 区间解码器
+type rangeDecoder struct {
+	base   uint32
+	rng    uint32
+	buffer []byte
+	index  int
+	err    error
+}
+
+// newRangeDecoder 创建区间解码器
+func newRangeDecoder(data []byte) *rangeDecoder {
+	d := &rangeDecoder{rng: 0xFFFF, buffer: data}
+	if len(data) > maxArithmBytes {
+		d.err = errRangeCoderOverflow
+		return d
+	}
+	for i := 0; i < 4; i++ {
+		d.base = d.base<<8 | uint32(d.byteAt(i))
+	}
+	return d
+}
+
+// byteAt 读取指定位置的字节，超出数据范围时为 0
+func (d *rangeDecoder) byteAt(i int) byte {
+	if i < len(d.buffer) {
+		return d.buffer[i]
+	}
+	return 0
+}
+
+// readByte 读取下一个字节
+func (d *rangeDecoder) readByte() uint32 {
+	b := d.byteAt(d.index + 4)
+	if d.index < len(d.buffer) {
+		d.index++
+	}
+	return uint32(b)
+}
+
+// decode 按累积分布 cdf 解码一个符号，ix 为查找的初始位置
+func (d *rangeDecoder) decode(cdf []uint16, ix int) int {
+	if d.err != nil {
+		return 0
+	}
+	var low uint32
+	high := uint32(cdf[ix])
+	if d.rng*high > d.base {
+		for {
+			ix--
+			low = uint32(cdf[ix])
+			if d.rng*low <= d.base {
+				break
+			}
+			high = low
+			if high == 0 {
+				d.err = errRangeCoderCorrupt
+				return 0
+			}
+		}
+	} else {
+		for {
+			low = high
+			ix++
+			high = uint32(cdf[ix])
+			if d.rng*high > d.base {
+				ix--
+				break
+			}
+			if high == 0xFFFF {
+				d.err = errRangeCoderCorrupt
+				return 0
+			}
+		}
+	}
+
+	d.base -= d.rng * low
+	rng := d.rng * (high - low)
+	if rng&0xFF000000 != 0 {
+		d.rng = rng >> 16
+		return ix
+	}
+	if rng&0xFFFF0000 != 0 {
+		d.rng = rng >> 8
+		if d.base>>24 != 0 {
+			d.err = errRangeCoderCorrupt
+			return 0
+		}
+	} else {
+		d.rng = rng
+		if d.base>>16 != 0 {
+			d.err = errRangeCoderCorrupt
+			return 0
+		}
+		d.base = d.base<<8 | d.readByte()
+	}
+	d.base = d.base<<8 | d.readByte()
+	return ix
+}
+
+// decodeMulti 依次按各自的累积分布解码多个符号
+func (d *rangeDecoder) decodeMulti(data []int, cdfs [][]uint16, ixs []int) {
+	for i := range data {
+		data[i] = d.decode(cdfs[i], ixs[i])
+	}
+}
+
+// length 获取已解码的比特数与字节数
+func (d *rangeDecoder) length() (int, int) {
+	nBits := d.index<<3 + bits.LeadingZeros32(d.rng-1) - 14
+	return nBits, (nBits + 7) >> 3
+}
+
+// check 在解码完数据包的全部帧后检查末尾的填充比特
+func (d *rangeDecoder) check() {
+	if d.err != nil {
+		return
+	}
+	bitsInStream, nBytes := d.length()
+	if nBytes-1 >= len(d.buffer) {
+		d.err = errRangeCoderCorrupt
+		return
+	}
+	if bitsInStream&7 != 0 {
+		mask := byte(0xFF >> (bitsInStream & 7))
+		if d.buffer[nBytes-1]&mask != mask {
+			d.err = errRangeCoderCorrupt
+		}
+	}
+}
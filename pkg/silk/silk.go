@@ -2,12 +2,14 @@ package silk
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"embed"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"os/exec"
@@ -15,6 +17,9 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/WindowsSov8forUs/glyccat/pkg/mediacache"
 )
 
 //go:embed exec/*
@@ -29,6 +34,39 @@ const cachePath = "data/cache"
 
 const limit = 4 * 1024
 
+const (
+	encodeSampleRate = 24000 // 编码 SILK 时使用的采样率，之后可能采取配置或动态决定
+	decodeSampleRate = 24000 // 解码 SILK 时使用的采样率
+)
+
+// Options SILK 编码参数
+type Options struct {
+	// InProcessEncoder 是否优先使用进程内编码器
+	//
+	// 进程内编码器只生成清音帧，没有基音分析与长时预测，浊音语音的音质不及外部编码器，
+	// 因此默认仅在当前平台未内置外部编码器或外部编码器失败时使用
+	InProcessEncoder bool
+}
+
+var (
+	options      Options
+	optionsMutex sync.RWMutex
+)
+
+// SetOptions 设置 SILK 编码参数
+func SetOptions(opts Options) {
+	optionsMutex.Lock()
+	defer optionsMutex.Unlock()
+	options = opts
+}
+
+// getOptions 获取 SILK 编码参数
+func getOptions() Options {
+	optionsMutex.RLock()
+	defer optionsMutex.RUnlock()
+	return options
+}
+
 // IsAMRorSILK 判断是否是 AMR 或 SILK 文件
 func IsAMRorSILK(file []byte) bool {
 	return bytes.HasPrefix(file, []byte(HeaderAmr)) || bytes.HasPrefix(file, []byte(HeaderSilk))
@@ -54,53 +92,321 @@ func scanType(readerSeeker io.ReadSeeker) string {
 
 // EncoderSilk 编码为 SILK
 func EncoderSilk(data []byte) ([]byte, error) {
+	return EncoderSilkContext(context.Background(), data)
+}
+
+// EncoderSilkContext 编码为 SILK ，外部进程会随 ctx 取消而终止
+func EncoderSilkContext(ctx context.Context, data []byte) ([]byte, error) {
 	hash := md5.New()
 	_, err := hash.Write(data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compute md5: %v", err)
 	}
 	name := hex.EncodeToString(hash.Sum(nil))
-	return encode(data, name)
+
+	// 相同内容的转码结果可以直接复用，两种编码器的结果分开缓存
+	parts := []string{strconv.Itoa(encodeSampleRate)}
+	if preferInProcess() {
+		parts = append(parts, "in-process")
+	}
+	key := mediacache.Key(data, "silk", parts...)
+	if cached, ok := mediacache.Get(key); ok {
+		return cached, nil
+	}
+
+	silkData, err := encode(ctx, data, name)
+	if err != nil {
+		return nil, err
+	}
+	mediacache.Put(key, silkData)
+	return silkData, nil
+}
+
+// preferInProcess 判断是否优先使用进程内编码器
+func preferInProcess() bool {
+	return getOptions().InProcessEncoder || !HasBundledCodec()
 }
 
 // encode 编码为 SILK
-func encode(data []byte, name string) (silkWav []byte, err error) {
+//
+// 默认使用外部编码器，失败时回退到进程内编码器，启用 InProcessEncoder 时顺序相反
+func encode(ctx context.Context, data []byte, name string) ([]byte, error) {
 	// 0. 创建缓存目录
+	err := createDirectoryIfNotExist(cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audio cache directory: %v", err)
+	}
+
+	// 1. 转换 PCM
+	pcm, err := readPCM(ctx, data, name, encodeSampleRate)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 转换 SILK
+	inProcess := func() ([]byte, error) { return encodeSilk(pcm) }
+	external := func() ([]byte, error) { return encodeExec(ctx, pcm, name) }
+	encoders := []func() ([]byte, error){external, inProcess}
+	if preferInProcess() {
+		encoders = []func() ([]byte, error){inProcess, external}
+	}
+	var errs []error
+	for _, encoder := range encoders {
+		silkData, err := encoder()
+		if err == nil {
+			return silkData, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// encodeExec 通过外部编解码器将 PCM 编码为 SILK
+func encodeExec(ctx context.Context, pcm *PCM, name string) (silkWav []byte, err error) {
+	pcmPath := path.Join(cachePath, name+".pcm")
+	err = os.WriteFile(pcmPath, pcm.Bytes(), 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write pcm file: %v", err)
+	}
+	defer os.Remove(pcmPath)
+
+	silkPath := path.Join(cachePath, name+".silk")
+	codecPath, err := ExtractCodec()
+	if err != nil {
+		return nil, err
+	}
+	sampleRate := strconv.Itoa(pcm.SampleRate)
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, codecPath, "-i", pcmPath, "-o", silkPath, "-s", sampleRate)
+	} else {
+		cmd = exec.CommandContext(ctx, codecPath, "pts", "-i", pcmPath, "-o", silkPath, "-s", sampleRate)
+	}
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to encode silk: %w", err)
+	}
+	silkWav, err = os.ReadFile(silkPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read silk file: %v", err)
+	}
+	defer os.Remove(silkPath)
+
+	return silkWav, nil
+}
+
+// DecoderSilk 将 SILK 或 AMR 音频解码为 PCM
+func DecoderSilk(data []byte) (*PCM, error) {
+	key := mediacache.Key(data, "pcm", strconv.Itoa(decodeSampleRate))
+	if cached, ok := mediacache.Get(key); ok {
+		return NewPCM(cached, decodeSampleRate), nil
+	}
+
+	pcm, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	mediacache.Put(key, pcm.Bytes())
+	return pcm, nil
+}
+
+// decode 将 SILK 或 AMR 音频解码为 PCM
+//
+// SILK 优先在进程内解码，失败时回退到外部编解码器
+func decode(data []byte) (*PCM, error) {
+	hash := md5.New()
+	_, err := hash.Write(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute md5: %v", err)
+	}
+	name := hex.EncodeToString(hash.Sum(nil))
+
 	err = createDirectoryIfNotExist(cachePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create audio cache directory: %v", err)
 	}
 
-	// 1. 创建临时文件
-	rawPath := path.Join(cachePath, name+".wav")
-	err = os.WriteFile(rawPath, data, os.ModePerm)
+	// AMR 无法通过 SILK 编解码器处理，交由 ffmpeg 转换
+	if bytes.HasPrefix(data, []byte(HeaderAmr)) {
+		return readPCM(context.Background(), data, name, decodeSampleRate)
+	}
+	if !bytes.HasPrefix(data, []byte(HeaderSilk)) && !bytes.HasPrefix(data, []byte(HeaderSilk[1:])) {
+		return nil, fmt.Errorf("not a silk or amr file")
+	}
+
+	pcm, err := decodeSilk(data)
+	if err == nil {
+		return pcm.Resample(decodeSampleRate), nil
+	}
+	// 进程内无法解码的数据交由外部编解码器处理
+	return decodeExec(data, name)
+}
+
+// decodeExec 通过外部编解码器将 SILK 解码为 PCM
+func decodeExec(data []byte, name string) (*PCM, error) {
+	silkPath := path.Join(cachePath, name+".silk")
+	err := os.WriteFile(silkPath, data, 0644)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temporary file: %v", err)
 	}
-	defer os.Remove(rawPath)
+	defer os.Remove(silkPath)
+
+	codecPath, err := ExtractCodec()
+	if err != nil {
+		return nil, err
+	}
+	pcmPath := path.Join(cachePath, name+".decoded.pcm")
+	cmd := exec.Command(codecPath, "stp", "-i", silkPath, "-o", pcmPath, "-s", strconv.Itoa(decodeSampleRate))
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to decode silk: %v", err)
+	}
+	defer os.Remove(pcmPath)
+
+	pcmData, err := os.ReadFile(pcmPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pcm file: %v", err)
+	}
+	return NewPCM(pcmData, decodeSampleRate), nil
+}
+
+// EncodeFromWAV 将 WAV 文件通过 ffmpeg 转换为指定格式
+func EncodeFromWAV(wav []byte, format string) ([]byte, error) {
+	key := mediacache.Key(wav, format)
+	if cached, ok := mediacache.Get(key); ok {
+		return cached, nil
+	}
+
+	data, err := encodeFromWAV(wav, format)
+	if err != nil {
+		return nil, err
+	}
+	mediacache.Put(key, data)
+	return data, nil
+}
+
+// encodeFromWAV 将 WAV 文件通过 ffmpeg 转换为指定格式
+func encodeFromWAV(wav []byte, format string) ([]byte, error) {
+	hash := md5.New()
+	_, err := hash.Write(wav)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute md5: %v", err)
+	}
+	name := hex.EncodeToString(hash.Sum(nil))
+
+	err = createDirectoryIfNotExist(cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audio cache directory: %v", err)
+	}
+
+	wavPath := path.Join(cachePath, name+".wav")
+	err = os.WriteFile(wavPath, wav, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(wavPath)
+
+	outPath := path.Join(cachePath, name+"."+format)
+	cmd := exec.Command("ffmpeg", "-i", wavPath, "-y", outPath)
+	if errors.Is(cmd.Err, exec.ErrDot) {
+		cmd.Err = nil
+	}
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to convert to %s: %w", format, err)
+	}
+	defer os.Remove(outPath)
+
+	return os.ReadFile(outPath)
+}
+
+// readPCM 将音频转换为指定采样率的单声道 PCM
+func readPCM(ctx context.Context, data []byte, name string, sampleRate int) (*PCM, error) {
+	if IsWAV(data) {
+		if pcm, err := DecodeWAV(data); err == nil {
+			return pcm.Resample(sampleRate), nil
+		}
+	}
 
-	// 2. 转换 PCM
-	sampleRate := 24000 // 固定采样率，之后可能采取配置或动态决定
 	pcmPath := path.Join(cachePath, name+".pcm")
-	cmd := exec.Command("ffmpeg", "-i", rawPath, "-f", "s16le", "-ar", strconv.Itoa(sampleRate), "-ac", "1", pcmPath)
+	err := convertToPCM(ctx, data, name, pcmPath, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(pcmPath)
+	pcmData, err := os.ReadFile(pcmPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pcm file: %v", err)
+	}
+	return NewPCM(pcmData, sampleRate), nil
+}
+
+// convertToPCM 将音频转换为指定采样率的单声道 PCM 文件
+//
+// WAV 文件直接在进程内解析与重采样，其余格式通过 ffmpeg 转换
+func convertToPCM(ctx context.Context, data []byte, name, pcmPath string, sampleRate int) error {
+	if IsWAV(data) {
+		pcm, err := DecodeWAV(data)
+		if err == nil {
+			err = os.WriteFile(pcmPath, pcm.Resample(sampleRate).Bytes(), 0644)
+			if err != nil {
+				return fmt.Errorf("failed to write pcm file: %v", err)
+			}
+			return nil
+		}
+		// 无法解析的 WAV 交由 ffmpeg 处理
+	}
+
+	rawPath := path.Join(cachePath, name+".raw")
+	err := os.WriteFile(rawPath, data, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(rawPath)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-i", rawPath, "-f", "s16le", "-ar", strconv.Itoa(sampleRate), "-ac", "1", "-y", pcmPath)
 	if errors.Is(cmd.Err, exec.ErrDot) {
 		cmd.Err = nil
 	}
 	if err = cmd.Run(); err != nil {
-		return nil, fmt.Errorf("failed to convert to pcm: %v", err)
+		return fmt.Errorf("failed to convert to pcm: %w", err)
 	}
-	defer os.Remove(pcmPath)
+	return nil
+}
 
-	silkPath := path.Join(cachePath, name+".silk")
+var (
+	codecPath  string
+	codecMutex sync.Mutex
+)
+
+// HasBundledCodec 判断当前平台是否内置外部 SILK 编解码器
+func HasBundledCodec() bool {
+	codecFileName, err := getSilkCodecPath()
+	if err != nil {
+		return false
+	}
+	_, err = fs.Stat(silkCodecs, codecFileName)
+	return err == nil
+}
+
+// ExtractCodec 释放 SILK 编码器，并在进程生命周期内复用
+func ExtractCodec() (string, error) {
+	codecMutex.Lock()
+	defer codecMutex.Unlock()
+
+	if codecPath != "" {
+		if _, err := os.Stat(codecPath); err == nil {
+			return codecPath, nil
+		}
+	}
 
-	// 3. 转换 SILK
 	codecFileName, err := getSilkCodecPath()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get silk codec path: %v", err)
+		return "", fmt.Errorf("failed to get silk codec path: %v", err)
 	}
 	codecData, err := silkCodecs.ReadFile(codecFileName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read silk codec: %v", err)
+		return "", fmt.Errorf("failed to read silk codec: %v", err)
 	}
 	filePattern := "silk_codec*"
 	if runtime.GOOS == "windows" {
@@ -108,36 +414,35 @@ func encode(data []byte, name string) (silkWav []byte, err error) {
 	}
 	file, err := os.CreateTemp("", filePattern)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create silk codec temporary file: %v", err)
+		return "", fmt.Errorf("failed to create silk codec temporary file: %v", err)
 	}
-	defer os.Remove(file.Name())
 	if _, err := file.Write(codecData); err != nil {
-		return nil, fmt.Errorf("failed to write silk codec temporary file: %v", err)
+		file.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write silk codec temporary file: %v", err)
 	}
 	if err := file.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close silk codec temporary file: %v", err)
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to close silk codec temporary file: %v", err)
 	}
 	if err := os.Chmod(file.Name(), 0700); err != nil {
-		return nil, fmt.Errorf("failed to change silk codec temporary file permission: %v", err)
-	}
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command(file.Name(), "-i", pcmPath, "-o", silkPath, "-s", strconv.Itoa(sampleRate))
-		if err := cmd.Run(); err != nil {
-			return nil, fmt.Errorf("failed to encode silk: %v", err)
-		}
-	} else {
-		cmd = exec.Command(file.Name(), "pts", "-i", pcmPath, "-o", silkPath, "-s", strconv.Itoa(sampleRate))
-		if err := cmd.Run(); err != nil {
-			return nil, fmt.Errorf("failed to encode silk: %v", err)
-		}
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to change silk codec temporary file permission: %v", err)
 	}
-	silkWav, err = os.ReadFile(silkPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read silk file: %v", err)
-	}
-	defer os.Remove(silkPath)
 
-	return silkWav, nil
+	codecPath = file.Name()
+	return codecPath, nil
+}
+
+// Cleanup 清理释放的 SILK 编码器
+func Cleanup() {
+	codecMutex.Lock()
+	defer codecMutex.Unlock()
+
+	if codecPath != "" {
+		os.Remove(codecPath)
+		codecPath = ""
+	}
 }
 
 // createDirectoryIfNotExist 检查目录是否存在，不存在则创建
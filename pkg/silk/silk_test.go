@@ -0,0 +1,334 @@
+package silk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// makeWAV 生成指定采样率与声道数的正弦波 WAV 文件
+func makeWAV(sampleRate, channels int, seconds float64) []byte {
+	frames := int(float64(sampleRate) * seconds)
+	body := new(bytes.Buffer)
+	for i := 0; i < frames; i++ {
+		v := int16(math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate)) * 16384)
+		for c := 0; c < channels; c++ {
+			_ = binary.Write(body, binary.LittleEndian, v)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(36+body.Len()))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(buf, binary.LittleEndian, wavFormatPCM)
+	_ = binary.Write(buf, binary.LittleEndian, uint16(channels))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(sampleRate))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(sampleRate*channels*2))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(channels*2))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(body.Len()))
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
+
+func TestDecodeWAV(t *testing.T) {
+	pcm, err := DecodeWAV(makeWAV(44100, 2, 1))
+	if err != nil {
+		t.Fatalf("DecodeWAV() error = %v", err)
+	}
+	if pcm.SampleRate != 44100 || len(pcm.Samples) != 44100 {
+		t.Fatalf("DecodeWAV() = %d Hz, %d samples", pcm.SampleRate, len(pcm.Samples))
+	}
+
+	resampled := pcm.Resample(24000)
+	if len(resampled.Samples) != 24000 {
+		t.Fatalf("Resample() = %d samples, want 24000", len(resampled.Samples))
+	}
+	if len(resampled.Bytes()) != 48000 {
+		t.Fatalf("Bytes() = %d bytes, want 48000", len(resampled.Bytes()))
+	}
+}
+
+func TestDecodeWAVInvalid(t *testing.T) {
+	if _, err := DecodeWAV([]byte("RIFF\x00\x00\x00\x00WAVE")); err == nil {
+		t.Fatal("DecodeWAV() expected error for wav without chunks")
+	}
+	if _, err := DecodeWAV([]byte("not a wav file")); err == nil {
+		t.Fatal("DecodeWAV() expected error for non-wav data")
+	}
+}
+
+// BenchmarkPCMInProcess 进程内解析与重采样 WAV
+func BenchmarkPCMInProcess(b *testing.B) {
+	data := makeWAV(44100, 2, 10)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pcm, err := DecodeWAV(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = pcm.Resample(24000).Bytes()
+	}
+}
+
+// BenchmarkPCMFFmpeg 通过 ffmpeg 转换 WAV
+func BenchmarkPCMFFmpeg(b *testing.B) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		b.Skip("ffmpeg not found")
+	}
+	data := makeWAV(44100, 2, 10)
+	dir := b.TempDir()
+	rawPath := path.Join(dir, "bench.wav")
+	pcmPath := path.Join(dir, "bench.pcm")
+	if err := os.WriteFile(rawPath, data, 0644); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cmd := exec.Command("ffmpeg", "-i", rawPath, "-f", "s16le", "-ar", strconv.Itoa(24000), "-ac", "1", "-y", pcmPath)
+		if err := cmd.Run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEncoderSilk 完整的 SILK 编码流程
+func BenchmarkEncoderSilk(b *testing.B) {
+	data := makeWAV(44100, 2, 10)
+	b.Cleanup(Cleanup)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := encode(context.Background(), data, "bench"+strconv.Itoa(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEncoderSilkInProcess 优先使用进程内编码器的完整编码流程
+func BenchmarkEncoderSilkInProcess(b *testing.B) {
+	SetOptions(Options{InProcessEncoder: true})
+	b.Cleanup(func() { SetOptions(Options{}) })
+	data := makeWAV(44100, 2, 10)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := encode(context.Background(), data, "bench"+strconv.Itoa(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEncoderSilkExec 通过外部编解码器编码 SILK
+func BenchmarkEncoderSilkExec(b *testing.B) {
+	if _, err := getSilkCodecPath(); err != nil {
+		b.Skip(err)
+	}
+	if err := createDirectoryIfNotExist(cachePath); err != nil {
+		b.Fatal(err)
+	}
+	pcm := testPCM(b, encodeSampleRate, 10)
+	b.Cleanup(Cleanup)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := encodeExec(context.Background(), pcm, "bench"+strconv.Itoa(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDecoderSilk 进程内解码 SILK
+func BenchmarkDecoderSilk(b *testing.B) {
+	data, err := encodeSilk(testPCM(b, encodeSampleRate, 10))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := decode(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDecoderSilkExec 通过外部编解码器解码 SILK
+func BenchmarkDecoderSilkExec(b *testing.B) {
+	if _, err := getSilkCodecPath(); err != nil {
+		b.Skip(err)
+	}
+	if err := createDirectoryIfNotExist(cachePath); err != nil {
+		b.Fatal(err)
+	}
+	data, err := encodeSilk(testPCM(b, encodeSampleRate, 10))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(Cleanup)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := decodeExec(data, "bench"+strconv.Itoa(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// testPCM 生成指定采样率的单声道正弦波 PCM
+func testPCM(tb testing.TB, sampleRate int, seconds float64) *PCM {
+	tb.Helper()
+	pcm, err := DecodeWAV(makeWAV(sampleRate, 1, seconds))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return pcm
+}
+
+// snr 计算解码结果相对于经过解码器高通滤波的输入的信噪比
+func snr(in, out *PCM) float64 {
+	filtered := append([]int16(nil), in.Samples...)
+	hp := decoderHP[in.SampleRate/1000]
+	var state [2]int32
+	biquad(filtered, hp.b, hp.a, &state)
+
+	var signal, noise float64
+	for i, v := range filtered {
+		d := float64(v) - float64(out.Samples[i])
+		signal += float64(v) * float64(v)
+		noise += d * d
+	}
+	return 10 * math.Log10(signal/noise)
+}
+
+func TestRangeCoderRoundTrip(t *testing.T) {
+	cdf := []uint16{0, 100, 20000, 40000, 65000, 65535}
+	symbols := make([]int, 500)
+	for i := range symbols {
+		symbols[i] = (i * 7) % (len(cdf) - 1)
+	}
+
+	enc := newRangeEncoder()
+	for _, s := range symbols {
+		enc.encode(s, cdf)
+	}
+	payload, err := enc.finish()
+	if err != nil {
+		t.Fatalf("finish() error = %v", err)
+	}
+
+	dec := newRangeDecoder(payload)
+	for i, want := range symbols {
+		if got := dec.decode(cdf, 2); got != want {
+			t.Fatalf("decode() symbol %d = %d, want %d", i, got, want)
+		}
+	}
+	if dec.check(); dec.err != nil {
+		t.Fatalf("check() error = %v", dec.err)
+	}
+}
+
+func TestEncodeSilkRoundTrip(t *testing.T) {
+	for _, sampleRate := range []int{8000, 12000, 16000, 24000} {
+		in := testPCM(t, sampleRate, 1)
+		encoded, err := encodeSilk(in)
+		if err != nil {
+			t.Fatalf("encodeSilk(%d) error = %v", sampleRate, err)
+		}
+		out, err := decodeSilk(encoded)
+		if err != nil {
+			t.Fatalf("decodeSilk(%d) error = %v", sampleRate, err)
+		}
+		if out.SampleRate != sampleRate || len(out.Samples) != len(in.Samples) {
+			t.Fatalf("decodeSilk(%d) = %d Hz, %d samples", sampleRate, out.SampleRate, len(out.Samples))
+		}
+		if v := snr(in, out); v < 15 {
+			t.Fatalf("round trip at %d Hz: snr = %.1f dB", sampleRate, v)
+		}
+	}
+}
+
+func TestEncodeInProcessOption(t *testing.T) {
+	SetOptions(Options{InProcessEncoder: true})
+	t.Cleanup(func() { SetOptions(Options{}) })
+	if !preferInProcess() {
+		t.Fatal("preferInProcess() = false with InProcessEncoder enabled")
+	}
+
+	pcm := testPCM(t, encodeSampleRate, 1)
+	want, err := encodeSilk(pcm)
+	if err != nil {
+		t.Fatalf("encodeSilk() error = %v", err)
+	}
+	got, err := encode(context.Background(), pcm.WAV(), "inprocess")
+	if err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("encode() did not use the in-process encoder")
+	}
+
+	SetOptions(Options{})
+	if preferInProcess() != !HasBundledCodec() {
+		t.Fatal("preferInProcess() should only be true without a bundled codec")
+	}
+}
+
+func TestEncodeSilkInvalidRate(t *testing.T) {
+	if _, err := encodeSilk(&PCM{SampleRate: 44100, Samples: make([]int16, 100)}); err == nil {
+		t.Fatal("encodeSilk() expected error for 44100 Hz")
+	}
+}
+
+// TestDecodeSilkMatchesCodec 进程内解码结果与外部编解码器逐位一致
+func TestDecodeSilkMatchesCodec(t *testing.T) {
+	if _, err := getSilkCodecPath(); err != nil {
+		t.Skip(err)
+	}
+	if err := createDirectoryIfNotExist(cachePath); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(Cleanup)
+
+	encoded, err := encodeSilk(testPCM(t, decodeSampleRate, 1))
+	if err != nil {
+		t.Fatalf("encodeSilk() error = %v", err)
+	}
+	want, err := decodeExec(encoded, "matches")
+	if err != nil {
+		t.Skip(err)
+	}
+	got, err := decodeSilk(encoded)
+	if err != nil {
+		t.Fatalf("decodeSilk() error = %v", err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Fatalf("decodeSilk() differs from codec: %d vs %d samples", len(got.Samples), len(want.Samples))
+	}
+}
+
+func TestDecoderSilk(t *testing.T) {
+	encoded, err := encode(context.Background(), makeWAV(24000, 1, 2), "roundtrip")
+	if err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+	if !IsAMRorSILK(encoded) {
+		t.Fatalf("encode() did not produce silk data")
+	}
+
+	pcm, err := DecoderSilk(encoded)
+	if err != nil {
+		t.Fatalf("DecoderSilk() error = %v", err)
+	}
+	if d := pcm.Duration(); d < 1900*time.Millisecond || d > 2100*time.Millisecond {
+		t.Fatalf("DecoderSilk() duration = %v, want about 2s", d)
+	}
+	if !IsWAV(pcm.WAV()) {
+		t.Fatalf("WAV() did not produce wav data")
+	}
+}
@@ -0,0 +1,150 @@
+package processor
+
+import (
+	"context"
+	"sync"
+
+	"github.com/WindowsSov8forUs/glyccat/log"
+	"github.com/WindowsSov8forUs/glyccat/operation"
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
+
+	"github.com/satori-protocol-go/satori-model-go/pkg/channel"
+	"github.com/satori-protocol-go/satori-model-go/pkg/guild"
+	"github.com/satori-protocol-go/satori-model-go/pkg/guildmember"
+	"github.com/satori-protocol-go/satori-model-go/pkg/user"
+	"github.com/tencent-connect/botgo/dto"
+)
+
+// pendingFetches 正在后台获取的群组与频道，避免重复请求
+var pendingFetches sync.Map
+
+// ConvertDtoChannelType 将 dto.ChannelType 转换为 channel.ChannelType
+func ConvertDtoChannelType(channelType dto.ChannelType) channel.ChannelType {
+	switch channelType {
+	case dto.ChannelTypeText:
+		return channel.ChannelTypeText
+	case dto.ChannelTypeVoice:
+		return channel.ChannelTypeVoice
+	default:
+		return channel.ChannelTypeCategory
+	}
+}
+
+// ConvertDtoChannel 将 dto.Channel 转换为 channel.Channel
+func ConvertDtoChannel(dtoChannel *dto.Channel) *channel.Channel {
+	return &channel.Channel{
+		Id:       dtoChannel.ID,
+		Name:     dtoChannel.Name,
+		ParentId: dtoChannel.ParentID,
+		Type:     ConvertDtoChannelType(dtoChannel.Type),
+	}
+}
+
+// cacheEventMember 将事件中的成员与用户信息写入实体缓存
+func cacheEventMember(guildId string, member *guildmember.GuildMember, u *user.User) {
+	if member == nil || u == nil {
+		return
+	}
+	cached := *member
+	cached.User = u
+	entitycache.SetMember(guildId, &cached)
+}
+
+// enrichEvent 使用实体缓存补全频道平台事件中缺少的群组名称、频道名称与成员信息
+//
+// 缓存中没有对应的群组或频道时会在后台获取，供之后的事件使用
+func (p *Processor) enrichEvent(event *operation.Event) {
+	if event.Login == nil || event.Login.Platform != "qqguild" {
+		return
+	}
+	// 私信的群组与频道是临时的，无法获取其信息
+	if event.Channel != nil && event.Channel.Type == channel.ChannelTypeDirect {
+		return
+	}
+
+	if event.Guild != nil && event.Guild.Id != "" && event.Guild.Name == "" {
+		if cached, ok := entitycache.GetGuild(event.Guild.Id); ok {
+			event.Guild.Name = cached.Name
+			event.Guild.Avatar = cached.Avatar
+		} else {
+			p.fetchGuild(event.Guild.Id)
+		}
+	}
+
+	if event.Channel != nil && event.Channel.Id != "" && event.Channel.Name == "" {
+		if cached, ok := entitycache.GetChannel(event.Channel.Id); ok {
+			event.Channel.Name = cached.Name
+			event.Channel.ParentId = cached.ParentId
+			event.Channel.Type = cached.Type
+		} else {
+			guildId := ""
+			if event.Guild != nil {
+				guildId = event.Guild.Id
+			}
+			p.fetchChannel(guildId, event.Channel.Id)
+		}
+	}
+
+	if event.Guild != nil && event.User != nil && event.User.Id != "" {
+		cached, ok := entitycache.GetMember(event.Guild.Id, event.User.Id)
+		if !ok {
+			return
+		}
+		if event.Member == nil {
+			event.Member = &guildmember.GuildMember{}
+		}
+		if event.Member.Nick == "" {
+			event.Member.Nick = cached.Nick
+		}
+		if event.Member.JoinedAt == 0 {
+			event.Member.JoinedAt = cached.JoinedAt
+		}
+		if cached.User != nil {
+			if event.User.Name == "" {
+				event.User.Name = cached.User.Name
+			}
+			if event.User.Avatar == "" {
+				event.User.Avatar = cached.User.Avatar
+			}
+		}
+	}
+}
+
+// fetchGuild 在后台获取群组信息并写入实体缓存
+func (p *Processor) fetchGuild(guildId string) {
+	if _, loaded := pendingFetches.LoadOrStore("guild:"+guildId, struct{}{}); loaded {
+		return
+	}
+	go func() {
+		defer pendingFetches.Delete("guild:" + guildId)
+		dtoGuild, err := p.ApiV2.Guild(context.TODO(), guildId)
+		if err != nil {
+			log.Debugf("获取频道 %s 的信息失败: %v", guildId, err)
+			return
+		}
+		entitycache.SetGuild(&guild.Guild{
+			Id:     dtoGuild.ID,
+			Name:   dtoGuild.Name,
+			Avatar: dtoGuild.Icon,
+		})
+	}()
+}
+
+// fetchChannel 在后台获取频道信息并写入实体缓存
+func (p *Processor) fetchChannel(guildId, channelId string) {
+	if _, loaded := pendingFetches.LoadOrStore("channel:"+channelId, struct{}{}); loaded {
+		return
+	}
+	go func() {
+		defer pendingFetches.Delete("channel:" + channelId)
+		dtoChannel, err := p.ApiV2.Channel(context.TODO(), channelId)
+		if err != nil {
+			log.Debugf("获取子频道 %s 的信息失败: %v", channelId, err)
+			return
+		}
+		if dtoChannel.GuildID != "" {
+			guildId = dtoChannel.GuildID
+		}
+		entitycache.SetChannel(guildId, ConvertDtoChannel(dtoChannel))
+	}()
+}
@@ -1,10 +1,7 @@
 package processor
 
 import (
-	"time"
-
 	"github.com/WindowsSov8forUs/glyccat/log"
-	"github.com/WindowsSov8forUs/glyccat/operation"
 	"github.com/satori-protocol-go/satori-model-go/pkg/login"
 	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/event"
@@ -14,31 +11,8 @@ import (
 func ReadyHandler(p *Processor) event.ReadyHandler {
 	return func(event *dto.Payload, data *dto.WSReadyData) {
 		log.Info("连接成功！")
-		SetStatus("qq", login.StatusOnline)
-		SetStatus("qqguild", login.StatusOnline)
-
-		// 构建 qq 事件
-		id := SaveEventID(data.SessionID)
-
-		satoriEvent := &operation.Event{
-			Sn:        id,
-			Type:      operation.EventTypeLoginUpdated,
-			Timestamp: time.Now().UnixMilli(),
-			Login:     buildLoginEventLogin("qq"),
-		}
-
-		// 构建 qqguild 事件
-		id = SaveEventID(data.SessionID)
-
-		satoriEventGuild := &operation.Event{
-			Sn:        id,
-			Type:      operation.EventTypeLoginUpdated,
-			Timestamp: time.Now().UnixMilli(),
-			Login:     buildLoginEventLogin("qqguild"),
-		}
-
-		p.BroadcastEvent(satoriEvent)
-		p.BroadcastEvent(satoriEventGuild)
+		p.UpdateStatus("qq", login.StatusOnline, data.SessionID)
+		p.UpdateStatus("qqguild", login.StatusOnline, data.SessionID)
 	}
 }
 
@@ -46,31 +20,8 @@ func ReadyHandler(p *Processor) event.ReadyHandler {
 func ErrorNotifyHandler(p *Processor) event.ErrorNotifyHandler {
 	return func(err error) {
 		log.Errorf("QQ 开放平台连接出现错误：%v", err)
-		SetStatus("qq", login.StatusOffline)
-		SetStatus("qqguild", login.StatusOffline)
-
-		// 构建 qq 事件
-		id := SaveEventID(err.Error())
-
-		satoriEvent := &operation.Event{
-			Sn:        id,
-			Type:      operation.EventTypeLoginUpdated,
-			Timestamp: time.Now().UnixMilli(),
-			Login:     buildLoginEventLogin("qq"),
-		}
-
-		// 构建 qqguild 事件
-		id = SaveEventID(err.Error())
-
-		satoriEventGuild := &operation.Event{
-			Sn:        id,
-			Type:      operation.EventTypeLoginUpdated,
-			Timestamp: time.Now().UnixMilli(),
-			Login:     buildLoginEventLogin("qqguild"),
-		}
-
-		p.BroadcastEvent(satoriEvent)
-		p.BroadcastEvent(satoriEventGuild)
+		p.UpdateStatus("qq", login.StatusOffline, err.Error())
+		p.UpdateStatus("qqguild", login.StatusOffline, err.Error())
 	}
 }
 
@@ -86,8 +37,17 @@ func HelloHandler(p *Processor) event.HelloHandler {
 func ReconnectHandler(p *Processor) event.ReconnectHandler {
 	return func(event *dto.Payload) {
 		log.Info("正在尝试重新连接 QQ 开放平台...")
-		SetStatus("qq", login.StatusReconnect)
-		SetStatus("qqguild", login.StatusReconnect)
+		p.UpdateStatus("qq", login.StatusReconnect, event.ID)
+		p.UpdateStatus("qqguild", login.StatusReconnect, event.ID)
+	}
+}
+
+// ResumedHandler 处理连接恢复事件
+func ResumedHandler(p *Processor) event.ResumedHandler {
+	return func(event *dto.Payload) {
+		log.Info("已恢复与 QQ 开放平台的连接")
+		p.UpdateStatus("qq", login.StatusOnline, event.ID)
+		p.UpdateStatus("qqguild", login.StatusOnline, event.ID)
 	}
 }
 
@@ -252,12 +212,28 @@ func C2CMessageEventHandler(p *Processor) event.C2CMessageEventHandler {
 	}
 }
 
+// FriendAddEventHandler 实现处理 用户添加机器人好友的回调
+func FriendAddEventHandler(p *Processor) event.FriendAddEventHandler {
+	return func(event *dto.Payload, data *dto.FriendEvent) error {
+		return p.ProcessFriendAdd(event, data)
+	}
+}
+
+// FriendDelEventHandler 实现处理 用户删除机器人好友的回调
+func FriendDelEventHandler(p *Processor) event.FriendDelEventHandler {
+	return func(event *dto.Payload, data *dto.FriendEvent) error {
+		return p.ProcessFriendDel(event, data)
+	}
+}
+
 func (p *Processor) getHandlersByName(intentName string) ([]interface{}, bool) {
 	switch intentName {
 	case "DEFAULT": // 默认处理函数
 		handlers := []interface{}{
 			ReadyHandler(p),
 			ErrorNotifyHandler(p),
+			ReconnectHandler(p),
+			ResumedHandler(p),
 			PlainEventHandler(p),
 		}
 		return handlers, true
@@ -291,6 +267,8 @@ func (p *Processor) getHandlersByName(intentName string) ([]interface{}, bool) {
 			GroupAddRobotEventHandler(p),
 			GroupDelRobotEventHandler(p),
 			C2CMessageEventHandler(p),
+			FriendAddEventHandler(p),
+			FriendDelEventHandler(p),
 		}
 		return handlers, true
 	case "INTERACTION": // 互动事件
@@ -334,6 +312,8 @@ func (p *Processor) getWebHookAvailableHandlers() ([]interface{}, bool) {
 		GroupAddRobotEventHandler(p),
 		GroupDelRobotEventHandler(p),
 		C2CMessageEventHandler(p),
+		FriendAddEventHandler(p),
+		FriendDelEventHandler(p),
 		InteractionHandler(p),
 		MessageAuditEventHandler(p),
 		ThreadEventHandler(p),
@@ -0,0 +1,60 @@
+package processor
+
+import (
+	"sort"
+	"sync"
+)
+
+// FeatureRegistry 平台特性注册表
+type FeatureRegistry struct {
+	mapping map[string]map[string]bool
+	mu      sync.RWMutex
+}
+
+var globalFeatureRegistry = &FeatureRegistry{
+	mapping: make(map[string]map[string]bool),
+}
+
+func init() {
+	// qq 平台的群聊不存在子频道，群组即为频道
+	RegisterFeature("qq", "guild.plain")
+
+	// 两个平台都支持私聊
+	RegisterFeature("qq", "user.direct")
+	RegisterFeature("qqguild", "user.direct")
+}
+
+// RegisterFeature 为平台注册特性
+func RegisterFeature(platform string, features ...string) {
+	globalFeatureRegistry.mu.Lock()
+	defer globalFeatureRegistry.mu.Unlock()
+
+	set, ok := globalFeatureRegistry.mapping[platform]
+	if !ok {
+		set = make(map[string]bool)
+		globalFeatureRegistry.mapping[platform] = set
+	}
+	for _, feature := range features {
+		set[feature] = true
+	}
+}
+
+// HasFeature 判断平台是否支持某个特性
+func HasFeature(platform, feature string) bool {
+	globalFeatureRegistry.mu.RLock()
+	defer globalFeatureRegistry.mu.RUnlock()
+	return globalFeatureRegistry.mapping[platform][feature]
+}
+
+// Features 获取平台特性
+func Features(platform string) []string {
+	globalFeatureRegistry.mu.RLock()
+	defer globalFeatureRegistry.mu.RUnlock()
+
+	features := make([]string, 0, len(globalFeatureRegistry.mapping[platform]))
+	for feature := range globalFeatureRegistry.mapping[platform] {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	return features
+}
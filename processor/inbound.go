@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/WindowsSov8forUs/glyccat/config"
+	"github.com/WindowsSov8forUs/glyccat/log"
+)
+
+const (
+	inboundWait    = 2 * time.Second // 事件处理等待接收媒体处理结果的最长时间
+	inboundTimeout = 2 * time.Minute // 后台处理单个接收媒体的超时时间
+)
+
+var (
+	errInboundPending = errors.New("仍在后台处理中") // 接收媒体仍在后台处理中
+	errInboundFailed  = errors.New("后台处理失败")  // 接收媒体处理失败，原因已在后台任务中记录
+)
+
+// inboundTasks 接收媒体的后台处理任务与结果缓存
+//
+// 下载与转码在后台进行，事件处理最多等待 inboundWait ，超时后使用原始资源，
+// 处理结果会被缓存，供之后引用同一资源的消息使用
+type inboundTasks[T any] struct {
+	limit   int
+	ttl     time.Duration // 处理结果的有效期，为 0 时不会过期
+	mu      sync.Mutex
+	results map[string]inboundResult[T] // 原链接到处理结果的映射
+	pending map[string]chan struct{}    // 正在处理的原链接
+}
+
+// inboundResult 带有过期时间的处理结果
+type inboundResult[T any] struct {
+	value   *T
+	expires time.Time // 为零值时不会过期
+}
+
+// newInboundTasks 创建接收媒体的后台处理任务，limit 为结果缓存数量上限，ttl 为结果有效期
+func newInboundTasks[T any](limit int, ttl time.Duration) *inboundTasks[T] {
+	return &inboundTasks[T]{
+		limit:   limit,
+		ttl:     ttl,
+		results: make(map[string]inboundResult[T]),
+		pending: make(map[string]chan struct{}),
+	}
+}
+
+// inboundResultTTL 接收媒体处理结果的有效期
+//
+// 结果指向文件服务器中的文件，取文件有效期的一半，保证替换后的链接在事件发出后仍有足够时间可供访问
+func inboundResultTTL(conf *config.Config) time.Duration {
+	return time.Duration(conf.FileServer.TTL) * time.Second / 2
+}
+
+// lookup 获取未过期的处理结果，过期的结果会被移除，调用时需持有锁
+func (t *inboundTasks[T]) lookup(url string) (*T, bool) {
+	result, ok := t.results[url]
+	if !ok {
+		return nil, false
+	}
+	if !result.expires.IsZero() && !time.Now().Before(result.expires) {
+		delete(t.results, url)
+		return nil, false
+	}
+	return result.value, true
+}
+
+// store 保存处理结果，缓存已满时先移除过期的结果，调用时需持有锁
+func (t *inboundTasks[T]) store(url string, value *T) {
+	if len(t.results) >= t.limit {
+		now := time.Now()
+		for key, result := range t.results {
+			if !result.expires.IsZero() && !now.Before(result.expires) {
+				delete(t.results, key)
+			}
+		}
+		if len(t.results) >= t.limit {
+			t.results = make(map[string]inboundResult[T])
+		}
+	}
+	result := inboundResult[T]{value: value}
+	if t.ttl > 0 {
+		result.expires = time.Now().Add(t.ttl)
+	}
+	t.results[url] = result
+}
+
+// resolve 获取原链接的处理结果，没有结果时在后台执行 work 并等待至多 wait 时间
+//
+// 等待超时时返回 errInboundPending ，后台任务会继续执行直到完成或超时，失败时以 action 描述记录日志
+func (t *inboundTasks[T]) resolve(url, action string, wait time.Duration, work func(ctx context.Context) (*T, error)) (*T, error) {
+	t.mu.Lock()
+	if result, ok := t.lookup(url); ok {
+		t.mu.Unlock()
+		return result, nil
+	}
+	done, running := t.pending[url]
+	if !running {
+		done = make(chan struct{})
+		t.pending[url] = done
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), inboundTimeout)
+			defer cancel()
+			result, err := work(ctx)
+
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			delete(t.pending, url)
+			if err != nil {
+				log.Warnf("%s %s 失败: %v", action, url, err)
+			} else {
+				t.store(url, result)
+			}
+			close(done)
+		}()
+	}
+	t.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		return nil, errInboundPending
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if result, ok := t.lookup(url); ok {
+		return result, nil
+	}
+	return nil, errInboundFailed
+}
@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WindowsSov8forUs/glyccat/config"
+)
+
+func TestInboundTasksExpire(t *testing.T) {
+	runs := 0
+	work := func(ctx context.Context) (*int, error) {
+		runs++
+		value := runs
+		return &value, nil
+	}
+
+	tasks := newInboundTasks[int](4, 50*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		result, err := tasks.resolve("https://example.com/a", "测试", time.Second, work)
+		if err != nil {
+			t.Fatalf("resolve() error = %v", err)
+		}
+		if *result != 1 {
+			t.Fatalf("resolve() = %d, want cached 1", *result)
+		}
+	}
+
+	// 结果过期后重新执行任务
+	time.Sleep(60 * time.Millisecond)
+	result, err := tasks.resolve("https://example.com/a", "测试", time.Second, work)
+	if err != nil {
+		t.Fatalf("resolve() error = %v", err)
+	}
+	if *result != 2 || runs != 2 {
+		t.Fatalf("resolve() after expiry = %d with %d runs, want 2 and 2", *result, runs)
+	}
+}
+
+func TestInboundTasksEvictExpiredFirst(t *testing.T) {
+	tasks := newInboundTasks[int](2, time.Hour)
+	value := 1
+	tasks.store("a", &value)
+	tasks.results["a"] = inboundResult[int]{value: &value, expires: time.Now().Add(-time.Second)}
+	tasks.store("b", &value)
+	tasks.store("c", &value)
+
+	if _, ok := tasks.lookup("a"); ok {
+		t.Error("lookup(a) found an expired result")
+	}
+	for _, url := range []string{"b", "c"} {
+		if _, ok := tasks.lookup(url); !ok {
+			t.Errorf("lookup(%s) lost a valid result", url)
+		}
+	}
+}
+
+func TestInboundResultTTL(t *testing.T) {
+	conf := &config.Config{}
+	if ttl := inboundResultTTL(conf); ttl != 0 {
+		t.Errorf("inboundResultTTL() without file ttl = %v, want 0", ttl)
+	}
+	conf.FileServer.TTL = 3600
+	if ttl := inboundResultTTL(conf); ttl != 30*time.Minute {
+		t.Errorf("inboundResultTTL() = %v, want 30m", ttl)
+	}
+}
@@ -0,0 +1,107 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/WindowsSov8forUs/glyccat/config"
+	"github.com/WindowsSov8forUs/glyccat/fileserver"
+	"github.com/WindowsSov8forUs/glyccat/log"
+	"github.com/WindowsSov8forUs/glyccat/pkg/mp4"
+	"github.com/WindowsSov8forUs/glyccat/proxy"
+	"github.com/WindowsSov8forUs/glyccat/transcoder"
+	satoriMessage "github.com/satori-protocol-go/satori-model-go/pkg/message"
+)
+
+// videoCacheLimit 视频封面缓存数量上限
+const videoCacheLimit = 256
+
+// InboundVideo 接收视频封面生成器
+type InboundVideo struct {
+	enable bool
+	tasks  *inboundTasks[inboundVideoResult] // 后台封面生成任务与结果缓存
+}
+
+type inboundVideoResult struct {
+	Poster   string
+	Width    uint32
+	Height   uint32
+	Duration uint32
+}
+
+var inboundVideo = &InboundVideo{}
+
+// SetInboundVideo 根据配置设置接收视频封面生成器
+func SetInboundVideo(conf *config.Config) {
+	inboundVideo = &InboundVideo{
+		enable: conf.Satori.Transcode.Video.Thumbnail,
+		tasks:  newInboundTasks[inboundVideoResult](videoCacheLimit, inboundResultTTL(conf)),
+	}
+}
+
+// Thumbnail 下载视频并截取首帧作为封面，保存至本地文件服务器
+//
+// 文件以机器人自身的身份保存，以便通过内部链接访问
+func (v *InboundVideo) Thumbnail(ctx context.Context, platform, url string) (*inboundVideoResult, error) {
+	// 仅处理来自 QQ 的资源
+	if !proxy.CouldBeProxied(url) {
+		return nil, fmt.Errorf("不受信任的视频链接: %s", url)
+	}
+	bot := GetBot(platform)
+	if bot == nil {
+		return nil, fmt.Errorf("平台 %s 的机器人不存在", platform)
+	}
+
+	src, err := qqMediaFetcher.Fetch(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("下载视频失败: %w", err)
+	}
+
+	thumbnail, err := transcoder.Run(ctx, transcoder.MediaVideo, func(ctx context.Context) ([]byte, error) {
+		return mp4.Thumbnail(ctx, src.Data)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("截取视频封面失败: %w", err)
+	}
+
+	meta, err := fileserver.SaveFile(bytes.NewReader(thumbnail), platform, bot.Id, "poster.jpg", "image/jpeg")
+	if err != nil {
+		return nil, fmt.Errorf("保存视频封面失败: %w", err)
+	}
+
+	result := &inboundVideoResult{Poster: meta.URL}
+	if info, err := mp4.Probe(src.Data); err == nil {
+		result.Width = info.Width
+		result.Height = info.Height
+		result.Duration = uint32(math.Ceil(info.Duration.Seconds()))
+	}
+	return result, nil
+}
+
+// thumbnailVideoElement 尝试为视频元素添加封面与尺寸信息
+//
+// 封面在后台生成，未能及时完成时不添加
+func thumbnailVideoElement(video *satoriMessage.MessageElementVideo, platform string) {
+	if !inboundVideo.enable {
+		return
+	}
+
+	src := video.Src
+	result, err := inboundVideo.tasks.resolve(src, "生成视频封面", inboundWait, func(ctx context.Context) (*inboundVideoResult, error) {
+		return inboundVideo.Thumbnail(ctx, platform, src)
+	})
+	if err != nil {
+		log.Debugf("视频 %s 未添加封面: %s", src, err)
+		return
+	}
+	video.Poster = result.Poster
+	if video.Width == 0 && video.Height == 0 {
+		video.Width = result.Width
+		video.Height = result.Height
+	}
+	if video.Duration == 0 {
+		video.Duration = result.Duration
+	}
+}
@@ -0,0 +1,125 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/WindowsSov8forUs/glyccat/config"
+	"github.com/WindowsSov8forUs/glyccat/fileserver"
+	"github.com/WindowsSov8forUs/glyccat/log"
+	"github.com/WindowsSov8forUs/glyccat/pkg/silk"
+	"github.com/WindowsSov8forUs/glyccat/proxy"
+	satoriMessage "github.com/satori-protocol-go/satori-model-go/pkg/message"
+)
+
+// voiceCacheLimit 语音转码结果缓存数量上限
+const voiceCacheLimit = 256
+
+// InboundVoice 接收语音转码器
+type InboundVoice struct {
+	enable bool
+	format string
+	tasks  *inboundTasks[inboundVoiceResult] // 后台转码任务与结果缓存
+}
+
+type inboundVoiceResult struct {
+	Src      string
+	Duration uint32
+}
+
+var inboundVoice = &InboundVoice{}
+
+// SetInboundVoice 根据配置设置接收语音转码器
+func SetInboundVoice(conf *config.Config) {
+	format := strings.ToLower(conf.Satori.InboundVoice.Format)
+	switch format {
+	case "wav", "mp3", "ogg":
+	default:
+		log.Warnf("不支持的语音转码格式 %s ，将使用 wav 格式", format)
+		format = "wav"
+	}
+
+	inboundVoice = &InboundVoice{
+		enable: conf.Satori.InboundVoice.Enable,
+		format: format,
+		tasks:  newInboundTasks[inboundVoiceResult](voiceCacheLimit, inboundResultTTL(conf)),
+	}
+}
+
+// voiceContentTypes 转码格式对应的 MIME 类型
+var voiceContentTypes = map[string]string{
+	"wav": "audio/wav",
+	"mp3": "audio/mpeg",
+	"ogg": "audio/ogg",
+}
+
+// Rehost 下载并转码语音，保存至本地文件服务器
+//
+// 文件以机器人自身的身份保存，以便通过内部链接访问
+func (v *InboundVoice) Rehost(ctx context.Context, platform, url string) (*inboundVoiceResult, error) {
+	// 仅处理来自 QQ 的资源
+	if !proxy.CouldBeProxied(url) {
+		return nil, fmt.Errorf("不受信任的语音链接: %s", url)
+	}
+	bot := GetBot(platform)
+	if bot == nil {
+		return nil, fmt.Errorf("平台 %s 的机器人不存在", platform)
+	}
+
+	src, err := qqMediaFetcher.Fetch(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("下载语音失败: %w", err)
+	}
+	if !silk.IsAMRorSILK(src.Data) && !bytes.HasPrefix(src.Data, []byte(silk.HeaderSilk[1:])) {
+		return nil, fmt.Errorf("语音不是 SILK 或 AMR 格式")
+	}
+
+	pcm, err := silk.DecoderSilk(src.Data)
+	if err != nil {
+		return nil, fmt.Errorf("解码语音失败: %w", err)
+	}
+	data := pcm.WAV()
+	format := "wav"
+	if v.format != "wav" {
+		encoded, err := silk.EncodeFromWAV(data, v.format)
+		if err != nil {
+			log.Warnf("语音转码为 %s 失败，将使用 wav 格式: %s", v.format, err)
+		} else {
+			data = encoded
+			format = v.format
+		}
+	}
+
+	meta, err := fileserver.SaveFile(bytes.NewReader(data), platform, bot.Id, "voice."+format, voiceContentTypes[format])
+	if err != nil {
+		return nil, fmt.Errorf("保存语音失败: %w", err)
+	}
+
+	return &inboundVoiceResult{
+		Src:      meta.URL,
+		Duration: uint32(math.Ceil(pcm.Duration().Seconds())),
+	}, nil
+}
+
+// rehostAudioElement 尝试将语音元素替换为转码后的资源
+//
+// 转码在后台进行，未能及时完成时保留原始链接
+func rehostAudioElement(audio *satoriMessage.MessageElementAudio, platform string) {
+	if !inboundVoice.enable {
+		return
+	}
+
+	src := audio.Src
+	result, err := inboundVoice.tasks.resolve(src, "转码语音", inboundWait, func(ctx context.Context) (*inboundVoiceResult, error) {
+		return inboundVoice.Rehost(ctx, platform, src)
+	})
+	if err != nil {
+		log.Debugf("语音 %s 未替换为转码后的资源: %s", src, err)
+		return
+	}
+	audio.Src = result.Src
+	audio.Duration = result.Duration
+}
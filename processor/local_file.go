@@ -0,0 +1,143 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/WindowsSov8forUs/glyccat/config"
+	"github.com/WindowsSov8forUs/glyccat/log"
+)
+
+// ErrLocalFileForbidden 本地文件访问被拒绝
+var ErrLocalFileForbidden = errors.New("local file access forbidden")
+
+// LocalFileSandbox 本地文件访问沙箱
+type LocalFileSandbox struct {
+	enable      bool
+	allowedDirs []string
+	maxSize     int64
+}
+
+var localFileSandbox = &LocalFileSandbox{}
+
+// SetLocalFileSandbox 根据配置设置本地文件访问沙箱
+func SetLocalFileSandbox(conf *config.Config) {
+	sandbox := &LocalFileSandbox{
+		enable:  conf.Satori.LocalFile.Enable,
+		maxSize: int64(conf.Satori.LocalFile.MaxSize) * 1024 * 1024,
+	}
+
+	for _, dir := range conf.Satori.LocalFile.AllowedDirs {
+		resolved, err := resolvePath(dir)
+		if err != nil {
+			log.Warnf("无法解析允许读取的本地目录 %s ，已忽略: %v", dir, err)
+			continue
+		}
+		sandbox.allowedDirs = append(sandbox.allowedDirs, resolved)
+	}
+
+	if sandbox.enable && len(sandbox.allowedDirs) == 0 {
+		log.Warn("未配置允许读取的本地目录，所有 file:// 资源都将被拒绝。")
+	}
+
+	localFileSandbox = sandbox
+}
+
+// parseFileURL 将 file:// 链接解析为本地路径
+func parseFileURL(src string) (string, error) {
+	u, err := url.Parse(src)
+	if err != nil {
+		return "", err
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("%w: remote host %s", ErrLocalFileForbidden, u.Host)
+	}
+
+	path := u.Path
+	// Windows 下形如 /C:/path 的路径需要去除开头斜线
+	if runtime.GOOS == "windows" && len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), nil
+}
+
+// resolvePath 获取解析了符号链接的绝对路径
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// isWithin 判断路径是否位于目录内
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// ReadLocalFile 在沙箱限制下读取本地文件
+func ReadLocalFile(path string) ([]byte, error) {
+	sandbox := localFileSandbox
+	if !sandbox.enable {
+		return nil, fmt.Errorf("%w: file:// is disabled", ErrLocalFileForbidden)
+	}
+
+	// 解析符号链接后再进行目录检查，防止通过链接逃逸
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("解析文件路径失败: %w", err)
+	}
+	allowed := false
+	for _, dir := range sandbox.allowedDirs {
+		if isWithin(resolved, dir) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		log.Warnf("拒绝读取允许目录之外的本地文件: %s", path)
+		return nil, fmt.Errorf("%w: %s is outside of allowed directories", ErrLocalFileForbidden, path)
+	}
+
+	file, err := os.Open(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %s is not a regular file", ErrLocalFileForbidden, path)
+	}
+	if sandbox.maxSize > 0 && info.Size() > sandbox.maxSize {
+		return nil, fmt.Errorf("%w: %s exceeds size limit", ErrLocalFileForbidden, path)
+	}
+
+	// 文件可能在检查后被改写，读取时同样限制大小
+	reader := io.Reader(file)
+	if sandbox.maxSize > 0 {
+		reader = io.LimitReader(file, sandbox.maxSize+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	if sandbox.maxSize > 0 && int64(len(data)) > sandbox.maxSize {
+		return nil, fmt.Errorf("%w: %s exceeds size limit", ErrLocalFileForbidden, path)
+	}
+
+	return data, nil
+}
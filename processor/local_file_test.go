@@ -0,0 +1,131 @@
+package processor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsWithin(t *testing.T) {
+	dir := filepath.FromSlash("/data/allowed")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/data/allowed", true},
+		{"/data/allowed/a.png", true},
+		{"/data/allowed/sub/a.png", true},
+		{"/data/allowed/../secret", false},
+		{"/data/allowed/sub/../../secret", false},
+		{"/data/allowed-other/a.png", false},
+		{"/data/allowedx", false},
+		{"/data", false},
+		{"/etc/passwd", false},
+		{"/data/allowed/..file", true},
+	}
+	for _, tt := range tests {
+		path := filepath.FromSlash(tt.path)
+		if got := isWithin(filepath.Clean(path), dir); got != tt.want {
+			t.Errorf("isWithin(%s, %s) = %v, want %v", path, dir, got, tt.want)
+		}
+	}
+}
+
+// setupSandbox 创建允许读取的目录与其外部的文件，并启用沙箱
+func setupSandbox(t *testing.T) (allowed, outside string) {
+	t.Helper()
+	root := t.TempDir()
+	allowed = filepath.Join(root, "allowed")
+	outside = filepath.Join(root, "outside")
+	for _, dir := range []string{allowed, outside, filepath.Join(allowed, "sub")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(allowed, "a.txt"):        "allowed",
+		filepath.Join(allowed, "sub", "b.txt"): "nested",
+		filepath.Join(allowed, "big.txt"):      "0123456789abcdef",
+		filepath.Join(outside, "secret.txt"):   "secret",
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resolved, err := resolvePath(allowed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := localFileSandbox
+	localFileSandbox = &LocalFileSandbox{enable: true, allowedDirs: []string{resolved}, maxSize: 10}
+	t.Cleanup(func() { localFileSandbox = previous })
+	return allowed, outside
+}
+
+func TestReadLocalFile(t *testing.T) {
+	allowed, outside := setupSandbox(t)
+
+	// 指向外部的符号链接
+	links := map[string]string{
+		filepath.Join(allowed, "file-link"): filepath.Join(outside, "secret.txt"),
+		filepath.Join(allowed, "dir-link"):  outside,
+		filepath.Join(allowed, "self-link"): filepath.Join(allowed, "a.txt"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks are not supported: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+		err  error
+	}{
+		{"allowed file", filepath.Join(allowed, "a.txt"), "allowed", nil},
+		{"nested file", filepath.Join(allowed, "sub", "b.txt"), "nested", nil},
+		{"dot dot inside", filepath.Join(allowed, "sub") + string(filepath.Separator) + ".." + string(filepath.Separator) + "a.txt", "allowed", nil},
+		{"symlink inside", filepath.Join(allowed, "self-link"), "allowed", nil},
+		{"dot dot escape", allowed + string(filepath.Separator) + ".." + string(filepath.Separator) + "outside" + string(filepath.Separator) + "secret.txt", "", ErrLocalFileForbidden},
+		{"outside file", filepath.Join(outside, "secret.txt"), "", ErrLocalFileForbidden},
+		{"file symlink escape", filepath.Join(allowed, "file-link"), "", ErrLocalFileForbidden},
+		{"dir symlink escape", filepath.Join(allowed, "dir-link", "secret.txt"), "", ErrLocalFileForbidden},
+		{"directory", filepath.Join(allowed, "sub"), "", ErrLocalFileForbidden},
+		{"over size limit", filepath.Join(allowed, "big.txt"), "", ErrLocalFileForbidden},
+	}
+	for _, tt := range tests {
+		data, err := ReadLocalFile(tt.path)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: ReadLocalFile() error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s: ReadLocalFile() = %q, want %q", tt.name, data, tt.want)
+		}
+	}
+
+	if _, err := ReadLocalFile(filepath.Join(allowed, "missing.txt")); err == nil || errors.Is(err, ErrLocalFileForbidden) {
+		t.Errorf("ReadLocalFile() missing file error = %v, want not found", err)
+	}
+
+	localFileSandbox.enable = false
+	if _, err := ReadLocalFile(filepath.Join(allowed, "a.txt")); !errors.Is(err, ErrLocalFileForbidden) {
+		t.Errorf("ReadLocalFile() with sandbox disabled error = %v, want %v", err, ErrLocalFileForbidden)
+	}
+}
+
+func TestParseFileURL(t *testing.T) {
+	if _, err := parseFileURL("file://evil.com/etc/passwd"); !errors.Is(err, ErrLocalFileForbidden) {
+		t.Errorf("parseFileURL() remote host error = %v, want %v", err, ErrLocalFileForbidden)
+	}
+	for _, src := range []string{"file:///tmp/a.png", "file://localhost/tmp/a.png"} {
+		path, err := parseFileURL(src)
+		if err != nil || filepath.ToSlash(path) != "/tmp/a.png" {
+			t.Errorf("parseFileURL(%s) = %s, %v", src, path, err)
+		}
+	}
+}
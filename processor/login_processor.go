@@ -33,7 +33,7 @@ func buildLoginEventLogin(platform string) *login.Login {
 		User:     bot,
 		Status:   GetStatus(platform),
 		Adapter:  "GlycCat",
-		Features: Features(),
+		Features: Features(platform),
 	}
 }
 
@@ -46,7 +46,7 @@ func buildNonLoginEventLogin(platform string) *login.Login {
 		User:     bot,
 		Status:   login.StatusOnline,
 		Adapter:  "GlycCat",
-		Features: Features(),
+		Features: Features(platform),
 	}
 }
 
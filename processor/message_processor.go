@@ -2,18 +2,23 @@ package processor
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
+	"os/exec"
 	"regexp"
 	"strings"
 
+	"github.com/WindowsSov8forUs/glyccat/fileserver"
+	"github.com/WindowsSov8forUs/glyccat/log"
 	"github.com/WindowsSov8forUs/glyccat/pkg/image"
 	"github.com/WindowsSov8forUs/glyccat/pkg/mp4"
 	"github.com/WindowsSov8forUs/glyccat/pkg/silk"
+	"github.com/WindowsSov8forUs/glyccat/transcoder"
 	satoriMessage "github.com/satori-protocol-go/satori-model-go/pkg/message"
 	"github.com/tencent-connect/botgo/dto"
 )
@@ -65,22 +70,18 @@ func ParseSrc(src string) (string, *fileSrc, error) {
 	}
 
 	// 检查是否是本地文件
-	re = regexp.MustCompile(`file:///(.*)`)
-	matches = re.FindStringSubmatch(src)
-	if len(matches) == 2 {
-		path, err := url.PathUnescape(matches[1])
+	if strings.HasPrefix(src, "file://") {
+		path, err := parseFileURL(src)
 		if err != nil {
 			return "", nil, fmt.Errorf("解析文件路径失败: %w", err)
 		}
 
-		if _, err := os.Stat(path); err == nil {
-			// 读取文件数据
-			data, err := os.ReadFile(path)
-			if err != nil {
-				return "", nil, fmt.Errorf("读取文件失败: %w", err)
-			}
-			return "", &fileSrc{MimeType: http.DetectContentType(data), Data: data}, nil
+		// 读取文件数据
+		data, err := ReadLocalFile(path)
+		if err != nil {
+			return "", nil, err
 		}
+		return "", &fileSrc{MimeType: detectMimeType(data), Data: data}, nil
 	}
 
 	return "", nil, fmt.Errorf("无法解析的资源字符串: %s", src)
@@ -106,13 +107,31 @@ func ParseSrcToString(src string) (string, error) {
 }
 
 // ParseSrcToAvailavle 解析 src 字符串，返回可用的 URL 或 base64 字符串
-func ParseSrcToAvailavle(src string) (string, string, error) {
+func ParseSrcToAvailavle(ctx context.Context, src string) (string, string, error) {
 	url, fileSrc, err := ParseSrc(src)
 	if err != nil {
 		return "", "", err
 	}
 
 	if url != "" {
+		// 文件服务器链接交由 QQ 获取时需要带有签名
+		url = fileserver.SignURL(url)
+
+		// 下载远程资源，若无法被接受则转码
+		base64Data, err := fetchRemoteToAvailable(ctx, url)
+		if err != nil {
+			var transcodeErr *transcoder.Error
+			if errors.As(err, &transcodeErr) {
+				return "", "", err
+			}
+			// 下载失败时仍交由 QQ 自行获取
+			log.Warnf("下载远程资源 %s 失败，将直接发送链接: %s", url, err)
+			return url, "", nil
+		}
+		if base64Data != "" {
+			return "", base64Data, nil
+		}
+
 		// 如果是 URL，直接返回
 		return url, "", nil
 	}
@@ -120,7 +139,7 @@ func ParseSrcToAvailavle(src string) (string, string, error) {
 	if fileSrc != nil {
 		// 如果是 base64 字符串，返回没有 base64 头的 base64 编码字符串
 		// 对于图片、音频与视频，需要转码为可接受的格式
-		data, err := convertToAvailableFormat(fileSrc)
+		data, err := convertToAvailableFormat(ctx, fileSrc)
 		if err != nil {
 			return "", "", fmt.Errorf("转换文件格式失败: %w", err)
 		}
@@ -131,59 +150,95 @@ func ParseSrcToAvailavle(src string) (string, string, error) {
 	return "", "", fmt.Errorf("无法解析的资源字符串: %s", src)
 }
 
+// detectMimeType 嗅探文件内容的 MIME 类型
+func detectMimeType(data []byte) string {
+	// 标准库无法识别 HEIC/AVIF
+	if mimeType, ok := image.DetectHEIF(data); ok {
+		return mimeType
+	}
+	return http.DetectContentType(data)
+}
+
 // convertToAvailableFormat 将文件资源转换为可用格式
-func convertToAvailableFormat(src *fileSrc) ([]byte, error) {
+func convertToAvailableFormat(ctx context.Context, src *fileSrc) ([]byte, error) {
 	if src == nil || src.Data == nil {
 		return nil, fmt.Errorf("无效的文件资源")
 	}
 
 	// 判断并转码
 	if strings.HasPrefix(src.MimeType, "audio/") {
-		return convertAudioToSilk(src.Data)
+		return convertAudioToSilk(ctx, src.Data)
 	} else if strings.HasPrefix(src.MimeType, "video/") {
-		return convertVideoToMP4(src.Data)
+		return convertVideoToMP4(ctx, src.Data)
 	} else if strings.HasPrefix(src.MimeType, "image/") {
-		return convertImage(src.Data)
+		return convertImage(ctx, src.Data)
 	}
 
 	return src.Data, nil
 }
 
 // convertAudioToSilk 将音频文件转换为 silk 格式
-func convertAudioToSilk(data []byte) ([]byte, error) {
+func convertAudioToSilk(ctx context.Context, data []byte) ([]byte, error) {
 	// 判断并转码
 	if !silk.IsAMRorSILK(data) {
 		mimeType, ok := silk.CheckAudio(bytes.NewReader(data))
 		if !ok {
-			return nil, fmt.Errorf("错误的音频格式: %s", mimeType)
+			return nil, transcoder.NewError(transcoder.MediaAudio, transcoder.ErrUnsupportedInput, fmt.Errorf("错误的音频格式: %s", mimeType))
 		}
-		return silk.EncoderSilk(data)
+		return transcoder.Run(ctx, transcoder.MediaAudio, func(ctx context.Context) ([]byte, error) {
+			return silk.EncoderSilkContext(ctx, data)
+		})
 	}
 	return data, nil
 }
 
 // convertVideoToMP4 将视频文件转换为 MP4 格式
-func convertVideoToMP4(data []byte) ([]byte, error) {
+func convertVideoToMP4(ctx context.Context, data []byte) ([]byte, error) {
 	// 判断并转码
+	if !mp4.NeedsProcess(data) {
+		return data, nil
+	}
 	if !mp4.IsMP4(data) {
 		mimeType, ok := mp4.CheckVideo(bytes.NewReader(data))
 		if !ok {
-			return nil, fmt.Errorf("错误的视频格式: %s", mimeType)
+			return nil, transcoder.NewError(transcoder.MediaVideo, transcoder.ErrUnsupportedInput, fmt.Errorf("错误的视频格式: %s", mimeType))
+		}
+	} else if !mp4.NeedsEncode(data) {
+		// 仅需前置 moov 时不依赖 ffmpeg
+		if result, err := mp4.FastStart(data); err == nil {
+			return result, nil
 		}
-		return mp4.EncoderMP4(data)
 	}
-	return data, nil
+	return transcoder.Run(ctx, transcoder.MediaVideo, func(ctx context.Context) ([]byte, error) {
+		return mp4.EncoderMP4Context(ctx, data)
+	})
 }
 
 // convertImage 将图像文件转换为可用格式
-func convertImage(data []byte) ([]byte, error) {
+func convertImage(ctx context.Context, data []byte) ([]byte, error) {
 	// 判断并转码
-	if !image.IsGIForPNGorJPG(data) {
+	if image.NeedsEncode(data) {
 		mimeType, ok := image.CheckImage(bytes.NewReader(data))
 		if !ok {
-			return nil, fmt.Errorf("错误的视频格式: %s", mimeType)
+			return nil, transcoder.NewError(transcoder.MediaImage, transcoder.ErrUnsupportedInput, fmt.Errorf("错误的图片格式: %s", mimeType))
+		}
+		// HEIC/AVIF 需要 ffmpeg ，缺少时直接拒绝
+		media := transcoder.MediaImage
+		if _, ok := image.DetectHEIF(data); ok {
+			media = transcoder.MediaHEIF
 		}
-		return image.EncoderImage(data)
+		return transcoder.Run(ctx, media, func(ctx context.Context) ([]byte, error) {
+			data, err := image.EncoderImageContext(ctx, data)
+			if err != nil {
+				if errors.Is(err, exec.ErrNotFound) || ctx.Err() != nil {
+					// 超时、取消与缺少工具交由转码服务归类
+					return nil, err
+				}
+				// 图片主要在进程内解码，失败即说明格式无法处理
+				return nil, transcoder.NewError(media, transcoder.ErrUnsupportedInput, err)
+			}
+			return data, nil
+		})
 	}
 	return data, nil
 }
@@ -355,21 +410,28 @@ func getMessageLog(data interface{}) string {
 func ConvertToMessageContent(data interface{}) string {
 	// 强制类型转换获取 Message 结构
 	var msg *dto.Message
-	var isAt bool = false // 是否为 at 消息
+	var isAt bool = false      // 是否为 at 消息
+	var platform string = "qq" // 消息所属平台
 	switch v := data.(type) {
 	case *dto.GroupATMessageData:
 		msg = (*dto.Message)(v)
 		isAt = true
 	case *dto.ATMessageData:
 		msg = (*dto.Message)(v)
+		platform = "qqguild"
 	case *dto.MessageData:
 		msg = (*dto.Message)(v)
+		platform = "qqguild"
 	case *dto.DirectMessageData:
 		msg = (*dto.Message)(v)
+		platform = "qqguild"
 	case *dto.C2CMessageData:
 		msg = (*dto.Message)(v)
 	case *dto.Message:
 		msg = v
+		if msg.GuildID != "" {
+			platform = "qqguild"
+		}
 	default:
 		return ""
 	}
@@ -475,6 +537,7 @@ func ConvertToMessageContent(data interface{}) string {
 			} else {
 				audio.Src = "https://" + attachment.URL
 			}
+			rehostAudioElement(&audio, platform)
 			messageSegments = append(messageSegments, &audio)
 		case strings.HasPrefix(attachment.ContentType, "video"):
 			video := satoriMessage.MessageElementVideo{}
@@ -483,6 +546,7 @@ func ConvertToMessageContent(data interface{}) string {
 			} else {
 				video.Src = "https://" + attachment.URL
 			}
+			thumbnailVideoElement(&video, platform)
 			messageSegments = append(messageSegments, &video)
 		default:
 			file := satoriMessage.MessageElementFile{}
@@ -0,0 +1,61 @@
+package processor
+
+import (
+	"errors"
+
+	"github.com/WindowsSov8forUs/glyccat/database"
+	"github.com/WindowsSov8forUs/glyccat/log"
+	"github.com/WindowsSov8forUs/glyccat/operation"
+
+	"github.com/satori-protocol-go/satori-model-go/pkg/message"
+)
+
+// GetChannelType 获取频道 ID 在消息数据库中对应的频道类型
+func GetChannelType(platform, channelId string) string {
+	if platform == "qqguild" {
+		if GetDirectChannelGuild(channelId) != "" {
+			return database.ChannelTypeDirect
+		}
+		return database.ChannelTypeGuild
+	}
+	if GetOpenIdType(channelId) == "private" {
+		return database.ChannelTypePrivate
+	}
+	return database.ChannelTypeGroup
+}
+
+// StoreMessage 将消息存入消息数据库
+//
+// 消息的 Channel 不能为空，存储失败时只会输出日志
+func StoreMessage(data *message.Message, channelType string) {
+	if data == nil || data.Channel == nil {
+		return
+	}
+	err := database.SaveMessage(data, data.Channel.Id, channelType)
+	if err != nil && !errors.Is(err, database.ErrMessageDBDisabled) && !errors.Is(err, database.ErrMessageDeleted) {
+		log.Warnf("存储消息 %s 失败: %v", data.Id, err)
+	}
+}
+
+// StoreMessageDeleted 将消息在消息数据库中标记为已删除
+func StoreMessageDeleted(channelId, channelType, messageId string) {
+	err := database.DeleteMessage(channelId, channelType, messageId)
+	if err != nil && !errors.Is(err, database.ErrMessageDBDisabled) {
+		log.Warnf("标记消息 %s 为已删除失败: %v", messageId, err)
+	}
+}
+
+// storeEventMessage 将事件中的消息连同频道、群组、成员与用户信息存入消息数据库
+func storeEventMessage(event *operation.Event, channelType string) {
+	if event.Message == nil {
+		return
+	}
+
+	// 复制一份消息，避免修改上报的事件内容
+	messageToSave := *event.Message
+	messageToSave.Channel = event.Channel
+	messageToSave.Guild = event.Guild
+	messageToSave.Member = event.Member
+	messageToSave.User = event.User
+	StoreMessage(&messageToSave, channelType)
+}
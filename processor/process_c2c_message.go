@@ -63,10 +63,11 @@ func (p *Processor) ProcessC2CMessage(payload *dto.Payload, data *dto.C2CMessage
 	}
 
 	// 存储消息
-	messageToSave := message
-	messageToSave.Channel = channel
-	messageToSave.User = user
-	database.SaveMessage(messageToSave, data.Author.UserOpenID, "private")
+	storeEventMessage(event, database.ChannelTypePrivate)
+
+	// 记录用户，私聊过机器人的用户视为好友
+	observeUser(user.Id, "", user.Avatar, t.UnixMilli())
+	addFriend(user.Id, t.UnixMilli())
 
 	// 上报消息到 Satori 应用
 	return p.BroadcastEvent(event)
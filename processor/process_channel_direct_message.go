@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/WindowsSov8forUs/glyccat/database"
 	"github.com/WindowsSov8forUs/glyccat/log"
 	"github.com/WindowsSov8forUs/glyccat/operation"
 
@@ -98,6 +99,13 @@ func (p *Processor) ProcessChannelDirectMessage(payload *dto.Payload, data *dto.
 		event.Role = role
 	}
 
+	// 存储消息
+	storeEventMessage(event, database.ChannelTypeDirect)
+
+	// 记录用户，私信过机器人的用户视为好友
+	observeUser(user.Id, user.Name, user.Avatar, t.UnixMilli())
+	addDirectUser(user.Id, t.UnixMilli())
+
 	// 上报消息到 Satori 应用
 	return p.BroadcastEvent(event)
 }
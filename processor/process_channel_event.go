@@ -6,6 +6,7 @@ import (
 
 	"github.com/WindowsSov8forUs/glyccat/log"
 	"github.com/WindowsSov8forUs/glyccat/operation"
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
 
 	"github.com/tencent-connect/botgo/dto"
 )
@@ -15,6 +16,17 @@ func (p *Processor) ProcessChannelEvent(payload *dto.Payload, data *dto.ChannelD
 	// 打印消息日志
 	printChannelEvent(payload, data)
 
+	// 更新实体缓存
+	switch payload.Type {
+	case dto.EventChannelCreate:
+		entitycache.SetChannel(data.GuildID, ConvertDtoChannel((*dto.Channel)(data)))
+		entitycache.InvalidateChannels(data.GuildID)
+	case dto.EventChannelUpdate:
+		entitycache.SetChannel(data.GuildID, ConvertDtoChannel((*dto.Channel)(data)))
+	case dto.EventChannelDelete:
+		entitycache.DeleteChannel(data.ID)
+	}
+
 	// 构建事件数据
 	var event *operation.Event
 
@@ -0,0 +1,36 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/WindowsSov8forUs/glyccat/log"
+
+	"github.com/tencent-connect/botgo/dto"
+)
+
+// ProcessFriendAdd 处理用户添加机器人好友
+func (p *Processor) ProcessFriendAdd(payload *dto.Payload, data *dto.FriendEvent) error {
+	// 输出日志
+	log.Infof("用户 %s 添加了机器人为好友", data.OpenID)
+
+	// 记录好友
+	now := time.Now().UnixMilli()
+	SetOpenIdType(data.OpenID, "private")
+	observeUser(data.OpenID, "", p.getUserAvatar(data.OpenID), now)
+	addFriend(data.OpenID, now)
+
+	// 没有对应的 Satori 事件，以平台原生事件上报
+	return p.ProcessQQInternal(payload, data)
+}
+
+// ProcessFriendDel 处理用户删除机器人好友
+func (p *Processor) ProcessFriendDel(payload *dto.Payload, data *dto.FriendEvent) error {
+	// 输出日志
+	log.Infof("用户 %s 删除了机器人好友", data.OpenID)
+
+	// 删除好友记录
+	removeFriend(data.OpenID)
+
+	// 没有对应的 Satori 事件，以平台原生事件上报
+	return p.ProcessQQInternal(payload, data)
+}
@@ -1,6 +1,8 @@
 package processor
 
 import (
+	"time"
+
 	"github.com/WindowsSov8forUs/glyccat/log"
 	"github.com/WindowsSov8forUs/glyccat/operation"
 
@@ -41,6 +43,9 @@ func (p *Processor) ProcessGroupAddRobot(payload *dto.Payload, data *dto.GroupAd
 	user := &user.User{
 		Id: data.OpMemberOpenID,
 	}
+	now := time.Now().UnixMilli()
+	observeUser(user.Id, "", p.getUserAvatar(user.Id), now)
+	observeGroupMember(data.GroupOpenID, user.Id, p.getUserAvatar(user.Id), now)
 
 	// 填充事件数据
 	event = &operation.Event{
@@ -28,6 +28,7 @@ func (p *Processor) ProcessGroupDelRobot(payload *dto.Payload, data *dto.GroupAd
 		Type: channel.ChannelTypeText,
 	}
 	DelOpenId(data.GroupOpenID)
+	deleteGroupRoster(data.GroupOpenID)
 
 	// 构建 guild
 	guild := &guild.Guild{
@@ -75,12 +75,11 @@ func (p *Processor) ProcessGroupMessage(payload *dto.Payload, data *dto.GroupATM
 	}
 
 	// 存储消息
-	messageToSave := message
-	messageToSave.Channel = channel
-	messageToSave.Guild = guild
-	messageToSave.Member = member
-	messageToSave.User = user
-	database.SaveMessage(messageToSave, data.GroupID, "group")
+	storeEventMessage(event, database.ChannelTypeGroup)
+
+	// 记录用户与群成员
+	observeUser(user.Id, "", user.Avatar, t.UnixMilli())
+	observeGroupMember(data.GroupID, user.Id, user.Avatar, t.UnixMilli())
 
 	// 上报消息到 Satori 应用
 	return p.BroadcastEvent(event)
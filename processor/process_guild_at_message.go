@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/WindowsSov8forUs/glyccat/database"
 	"github.com/WindowsSov8forUs/glyccat/log"
 	"github.com/WindowsSov8forUs/glyccat/operation"
 
@@ -96,6 +97,15 @@ func (p *Processor) ProcessGuildATMessage(payload *dto.Payload, data *dto.ATMess
 		event.Role = role
 	}
 
+	// 更新实体缓存
+	cacheEventMember(data.GuildID, member, user)
+
+	// 记录用户，实体缓存过期后仍可获取
+	observeUser(user.Id, user.Name, user.Avatar, t.UnixMilli())
+
+	// 存储消息
+	storeEventMessage(event, database.ChannelTypeGuild)
+
 	// 上报消息到 Satori 应用
 	return p.BroadcastEvent(event)
 }
@@ -6,6 +6,7 @@ import (
 
 	"github.com/WindowsSov8forUs/glyccat/log"
 	"github.com/WindowsSov8forUs/glyccat/operation"
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
 
 	"github.com/satori-protocol-go/satori-model-go/pkg/guild"
 	"github.com/satori-protocol-go/satori-model-go/pkg/user"
@@ -58,6 +59,13 @@ func (p *Processor) ProcessGuildEvent(payload *dto.Payload, data *dto.GuildData)
 		Avatar: data.Icon,
 	}
 
+	// 更新实体缓存
+	if payload.Type == dto.EventGuildDelete {
+		entitycache.DeleteGuild(data.ID)
+	} else {
+		entitycache.SetGuild(guild)
+	}
+
 	// 构建 operator
 	operator := &user.User{
 		Id: data.OpUserID,
@@ -6,6 +6,7 @@ import (
 
 	"github.com/WindowsSov8forUs/glyccat/log"
 	"github.com/WindowsSov8forUs/glyccat/operation"
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
 
 	"github.com/satori-protocol-go/satori-model-go/pkg/guild"
 	"github.com/satori-protocol-go/satori-model-go/pkg/guildmember"
@@ -81,6 +82,13 @@ func (p *Processor) ProcessMemberEvent(payload *dto.Payload, data *dto.GuildMemb
 		IsBot:  data.User.Bot,
 	}
 
+	// 更新实体缓存
+	if payload.Type == dto.EventGuildMemberRemove {
+		entitycache.DeleteMember(data.GuildID, data.User.ID)
+	} else {
+		cacheEventMember(data.GuildID, member, user)
+	}
+
 	// 填充事件数据
 	event = &operation.Event{
 		Sn:        id,
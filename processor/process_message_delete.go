@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/WindowsSov8forUs/glyccat/database"
 	"github.com/WindowsSov8forUs/glyccat/log"
 	"github.com/WindowsSov8forUs/glyccat/operation"
 
@@ -19,16 +20,20 @@ func (p *Processor) ProcessMessageDelete(payload *dto.Payload, data interface{})
 	// 强制类型转换获取 MessageDelete 结构
 	var messageDelete *dto.MessageDelete
 	var channelType channel.ChannelType // 获取平台名称
+	var storeType string                // 消息数据库中的频道类型
 	switch v := data.(type) {
 	case *dto.MessageDeleteData:
 		messageDelete = (*dto.MessageDelete)(v)
 		channelType = channel.ChannelTypeText
+		storeType = database.ChannelTypeGuild
 	case *dto.PublicMessageDeleteData:
 		messageDelete = (*dto.MessageDelete)(v)
 		channelType = channel.ChannelTypeText
+		storeType = database.ChannelTypeGuild
 	case *dto.DirectMessageDeleteData:
 		messageDelete = (*dto.MessageDelete)(v)
 		channelType = channel.ChannelTypeDirect
+		storeType = database.ChannelTypeDirect
 	default:
 		return fmt.Errorf("无法处理的消息撤回事件: %v", data)
 	}
@@ -91,6 +96,9 @@ func (p *Processor) ProcessMessageDelete(payload *dto.Payload, data interface{})
 		}
 	}
 
+	// 在消息数据库中标记为已删除
+	StoreMessageDeleted(channel.Id, storeType, message.Id)
+
 	// 上报消息到 Satori 应用
 	return p.BroadcastEvent(event)
 }
@@ -146,3 +154,40 @@ func printMessageDeleteEvent(payload *dto.Payload, data *dto.MessageDelete) {
 	// 打印日志
 	log.Info(logContent)
 }
+
+// ProcessMessageRecalled 将 GlycCat 撤回的单聊/群聊消息转换为 Satori 的 MessageDeleted 事件
+//
+// QQ 单聊/群聊不会推送消息撤回事件，因此在撤回成功后由 GlycCat 自行推送，recalled 的 Channel 不能为空
+func (p *Processor) ProcessMessageRecalled(recalled *message.Message) error {
+	log.Infof("撤回了频道 %s 中的消息 %s", recalled.Channel.Id, recalled.Id)
+
+	// 构建 message ，频道、群组与用户信息放在事件中
+	message := *recalled
+	message.Channel, message.Guild, message.Member, message.User = nil, nil, nil, nil
+
+	// 填充事件数据
+	event := &operation.Event{
+		Sn:        SaveEventID(recalled.Id),
+		Type:      operation.EventTypeMessageDeleted,
+		Timestamp: time.Now().UnixMilli(),
+		Login:     buildNonLoginEventLogin("qq"),
+		Channel:   recalled.Channel,
+		Guild:     recalled.Guild,
+		Message:   &message,
+		Operator:  GetBot("qq"),
+		User:      recalled.User,
+	}
+
+	// 上报消息到 Satori 应用
+	return p.BroadcastEvent(event)
+}
+
+// BroadcastMessageRecalled 推送 GlycCat 撤回单聊/群聊消息的 message-deleted 事件
+func BroadcastMessageRecalled(recalled *message.Message) {
+	if instance == nil || instance.Server == nil {
+		return
+	}
+	if err := instance.ProcessMessageRecalled(recalled); err != nil {
+		log.Warnf("推送消息 %s 的撤回事件失败: %v", recalled.Id, err)
+	}
+}
@@ -2,9 +2,12 @@ package processor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/satori-protocol-go/satori-model-go/pkg/login"
 	"github.com/satori-protocol-go/satori-model-go/pkg/user"
@@ -13,8 +16,11 @@ import (
 	"github.com/tencent-connect/botgo/token"
 
 	"github.com/WindowsSov8forUs/glyccat/config"
+	"github.com/WindowsSov8forUs/glyccat/database"
 	"github.com/WindowsSov8forUs/glyccat/log"
 	"github.com/WindowsSov8forUs/glyccat/operation"
+	"github.com/WindowsSov8forUs/glyccat/proxy"
+	"github.com/WindowsSov8forUs/glyccat/transcoder"
 )
 
 type EventIDTable struct {
@@ -82,11 +88,13 @@ func GetBots() map[string]*user.User {
 	return globalBotMapping.mapping
 }
 
-// SetStatus 设置机器人状态
-func SetStatus(platform string, status login.LoginStatus) {
+// SetStatus 设置机器人状态，返回状态是否发生了变化
+func SetStatus(platform string, status login.LoginStatus) bool {
 	globalStatusMapping.mu.Lock()
 	defer globalStatusMapping.mu.Unlock()
+	old, ok := globalStatusMapping.mapping[platform]
 	globalStatusMapping.mapping[platform] = status
+	return !ok || old != status
 }
 
 // GetStatus 获取机器人状态
@@ -96,8 +104,8 @@ func GetStatus(platform string) login.LoginStatus {
 	return globalStatusMapping.mapping[platform]
 }
 
-// GetReadyBody 创建 READY 信令的信令数据
-func GetReadyBody() *operation.ReadyBody {
+// GetLogins 获取所有机器人的登录信息
+func GetLogins() []*login.Login {
 	var logins []*login.Login
 	for platform, bot := range GetBots() {
 		login := &login.Login{
@@ -106,16 +114,37 @@ func GetReadyBody() *operation.ReadyBody {
 			User:     bot,
 			Status:   GetStatus(platform),
 			Adapter:  "GlycCat",
-			Features: Features(),
+			Features: Features(platform),
 		}
 		logins = append(logins, login)
 	}
+	return logins
+}
+
+// GetReadyBody 创建 READY 信令的信令数据
+func GetReadyBody() *operation.ReadyBody {
 	return &operation.ReadyBody{
-		Logins:    logins,
+		Logins:    GetLogins(),
 		ProxyUrls: ProxyUrls(),
 	}
 }
 
+// GetMetaBody 创建 META 信令的信令数据
+func GetMetaBody() *operation.MetaBody {
+	return &operation.MetaBody{
+		Logins:    GetLogins(),
+		ProxyUrls: ProxyUrls(),
+	}
+}
+
+// NotifyMetaUpdated 在登录信息或代理路由发生变化时向 Satori 应用发送 META 信令
+func NotifyMetaUpdated() {
+	if instance == nil || instance.Server == nil {
+		return
+	}
+	instance.Server.SendMeta(GetMetaBody())
+}
+
 // DirectChannelIdMapping 私聊频道 ID 映射
 type DirectChannelIdMapping struct {
 	mapping map[string]string
@@ -123,9 +152,14 @@ type DirectChannelIdMapping struct {
 }
 
 // OpenIdMapping 开放 ID 映射
+//
+// 数据库读取在锁外进行，写入由 dbMu 保证与内存中的修改顺序一致
 type OpenIdMapping struct {
 	mapping map[string]string
+	missing map[string]struct{} // 数据库中没有类型记录的开放 ID
+	version uint64              // 每次修改时递增，用于丢弃修改前读取的数据库结果
 	mu      sync.Mutex
+	dbMu    sync.Mutex
 }
 
 // globalDirectChannelIdMapping 全局频道 ID 映射
@@ -136,6 +170,7 @@ var globalDirectChannelIdMappingInstance = &DirectChannelIdMapping{
 // globalOpenIdMapping 全局开放 ID 映射
 var globalOpenIdMappingInstance = &OpenIdMapping{
 	mapping: make(map[string]string),
+	missing: make(map[string]struct{}),
 }
 
 // GetDirectChannelGuild 获取私聊频道 ID
@@ -153,47 +188,98 @@ func SetDirectChannel(channelId string, guildId string) {
 }
 
 // GetOpenIdType 获取开放 ID 类型
+//
+// 内存中没有记录时从用户名册数据库中读取，以便重启后仍能区分群聊与单聊
 func GetOpenIdType(openId string) string {
-	globalOpenIdMappingInstance.mu.Lock()
-	defer globalOpenIdMappingInstance.mu.Unlock()
-	return globalOpenIdMappingInstance.mapping[openId]
+	m := globalOpenIdMappingInstance
+	m.mu.Lock()
+	if openIdType, ok := m.mapping[openId]; ok {
+		m.mu.Unlock()
+		return openIdType
+	}
+	if _, ok := m.missing[openId]; ok {
+		m.mu.Unlock()
+		return ""
+	}
+	version := m.version
+	m.mu.Unlock()
+
+	openIdType, err := database.GetOpenIdType(openId)
+	if err != nil && !errors.Is(err, database.ErrRosterDBDisabled) && !errors.Is(err, database.ErrOpenIdNotFound) {
+		// 读取失败时不缓存结果，下次重试
+		log.Warnf("读取开放 ID %s 的类型失败: %v", openId, err)
+		return ""
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// 读取期间发生修改时以内存中的记录为准
+	if m.version != version {
+		return m.mapping[openId]
+	}
+	if err != nil {
+		m.missing[openId] = struct{}{}
+		return ""
+	}
+	m.mapping[openId] = openIdType
+	return openIdType
 }
 
-// SetOpenIdType 设置开放 ID 类型
+// SetOpenIdType 设置开放 ID 类型，类型发生变化时写入用户名册数据库
 func SetOpenIdType(openId string, openIdType string) {
-	globalOpenIdMappingInstance.mu.Lock()
-	defer globalOpenIdMappingInstance.mu.Unlock()
-	globalOpenIdMappingInstance.mapping[openId] = openIdType
+	m := globalOpenIdMappingInstance
+	m.mu.Lock()
+	if m.mapping[openId] == openIdType {
+		m.mu.Unlock()
+		return
+	}
+	m.mapping[openId] = openIdType
+	delete(m.missing, openId)
+	m.version++
+	m.dbMu.Lock()
+	m.mu.Unlock()
+	defer m.dbMu.Unlock()
+
+	err := database.SetOpenIdType(openId, openIdType)
+	if err != nil && !errors.Is(err, database.ErrRosterDBDisabled) {
+		log.Warnf("记录开放 ID %s 的类型失败: %v", openId, err)
+	}
 }
 
 // DelOpenId 删除开放 ID
 func DelOpenId(openId string) {
-	globalOpenIdMappingInstance.mu.Lock()
-	defer globalOpenIdMappingInstance.mu.Unlock()
-	delete(globalOpenIdMappingInstance.mapping, openId)
+	m := globalOpenIdMappingInstance
+	m.mu.Lock()
+	delete(m.mapping, openId)
+	m.missing[openId] = struct{}{}
+	m.version++
+	m.dbMu.Lock()
+	m.mu.Unlock()
+	defer m.dbMu.Unlock()
+
+	err := database.DeleteOpenIdType(openId)
+	if err != nil && !errors.Is(err, database.ErrRosterDBDisabled) {
+		log.Warnf("删除开放 ID %s 的类型失败: %v", openId, err)
+	}
 }
 
-// GetOpenIdData 获取开放 ID 数据
+// GetOpenIdData 获取开放 ID 数据的副本
 func GetOpenIdData() map[string]string {
 	globalOpenIdMappingInstance.mu.Lock()
 	defer globalOpenIdMappingInstance.mu.Unlock()
-	return globalOpenIdMappingInstance.mapping
-}
-
-// 获取平台特性
-func Features() []string {
-	return []string{}
+	return maps.Clone(globalOpenIdMappingInstance.mapping)
 }
 
 // 获取代理路径
 func ProxyUrls() []string {
-	return []string{}
+	return proxy.Urls()
 }
 
 // Server 服务端接口
 type Server interface {
 	Run() error
 	Send(*operation.Event)
+	SendMeta(*operation.MetaBody)
 	Close()
 }
 
@@ -207,6 +293,8 @@ type Processor struct {
 	conf   *config.Config
 }
 
+var instance *Processor
+
 // NewProcessor 创建消息处理器
 func NewProcessor(conf *config.Config) (*Processor, context.Context, error) {
 	if conf.Account.Token == "" {
@@ -232,6 +320,19 @@ func NewProcessor(conf *config.Config) (*Processor, context.Context, error) {
 		return nil, nil, err
 	}
 
+	// 设置本地文件访问沙箱
+	SetLocalFileSandbox(conf)
+
+	// 设置远程媒体资源下载器
+	SetRemoteMediaFetcher(conf)
+
+	// 设置接收语音转码器
+	SetInboundVoice(conf)
+	SetInboundVideo(conf)
+
+	// 注册转码特性，仅 QQ 平台的富媒体上传会进行转码
+	RegisterFeature("qq", transcoder.Features()...)
+
 	processor := &Processor{
 		Api:    api,
 		ApiV2:  apiV2,
@@ -240,6 +341,7 @@ func NewProcessor(conf *config.Config) (*Processor, context.Context, error) {
 		Server: nil,
 		conf:   conf,
 	}
+	instance = processor
 
 	return processor, ctx, err
 }
@@ -248,11 +350,6 @@ func (p *Processor) Run(ctx context.Context, server Server) error {
 	p.Server = server
 
 	if p.conf.Account.WebHook.Enable {
-		// 将所有 Bot 状态置为 ONLINE
-		for platform, _ := range GetBots() {
-			SetStatus(platform, login.StatusOnline)
-		}
-
 		err := establishWebHook(p, p.conf)
 		if err != nil {
 			return err
@@ -278,17 +375,57 @@ func (p *Processor) Run(ctx context.Context, server Server) error {
 		}
 	}()
 
+	if p.conf.Account.WebHook.Enable {
+		// WebHook 模式没有连接事件，在监听与服务端启动后将所有 Bot 状态置为 ONLINE
+		//
+		// 之后连接的 Satori 应用会通过 READY 信令获取当前状态
+		for platform := range GetBots() {
+			p.UpdateStatus(platform, login.StatusOnline, "webhook")
+		}
+	}
+
 	return nil
 }
 
 // BroadcastEvent 向 Satori 应用发送事件
 func (p *Processor) BroadcastEvent(event *operation.Event) error {
+	p.enrichEvent(event)
 	p.Server.Send(event)
 	return nil
 }
 
+// UpdateStatus 更新机器人状态，状态发生变化时推送 login-updated 事件与 META 信令
+func (p *Processor) UpdateStatus(platform string, status login.LoginStatus, source string) {
+	if !SetStatus(platform, status) {
+		return
+	}
+
+	// 构建事件
+	id := SaveEventID(source)
+	event := &operation.Event{
+		Sn:        id,
+		Type:      operation.EventTypeLoginUpdated,
+		Timestamp: time.Now().UnixMilli(),
+		Login:     buildLoginEventLogin(platform),
+	}
+
+	if p.Server == nil {
+		return
+	}
+	p.BroadcastEvent(event)
+	p.Server.SendMeta(GetMetaBody())
+}
+
 // getUserAvatar 获取用户头像
 func (p *Processor) getUserAvatar(userId string) string {
 	url := fmt.Sprintf("https://q.qlogo.cn/qqapp/%v/%s/3", p.conf.Account.AppID, userId)
 	return url
 }
+
+// GetUserAvatar 获取单聊/群聊用户的头像
+func GetUserAvatar(userId string) string {
+	if instance == nil {
+		return ""
+	}
+	return instance.getUserAvatar(userId)
+}
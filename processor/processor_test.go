@@ -0,0 +1,105 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/WindowsSov8forUs/glyccat/database"
+)
+
+// useTestOpenIdMapping 使用空的开放 ID 映射，并在临时目录中启动用户名册数据库
+func useTestOpenIdMapping(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	err = database.StartRosterDB()
+	os.Chdir(wd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := globalOpenIdMappingInstance
+	globalOpenIdMappingInstance = &OpenIdMapping{
+		mapping: make(map[string]string),
+		missing: make(map[string]struct{}),
+	}
+	t.Cleanup(func() {
+		globalOpenIdMappingInstance = old
+		database.CloseRosterDB()
+	})
+}
+
+func TestOpenIdType(t *testing.T) {
+	useTestOpenIdMapping(t)
+
+	if got := GetOpenIdType("a"); got != "" {
+		t.Fatalf("GetOpenIdType(a) = %q, want empty", got)
+	}
+	if _, ok := globalOpenIdMappingInstance.missing["a"]; !ok {
+		t.Error("GetOpenIdType(a) did not cache the missing result")
+	}
+
+	// 设置类型后清除缺失记录，并写入数据库
+	SetOpenIdType("a", "group")
+	if got := GetOpenIdType("a"); got != "group" {
+		t.Errorf("GetOpenIdType(a) = %q, want group", got)
+	}
+	if got, err := database.GetOpenIdType("a"); got != "group" || err != nil {
+		t.Errorf("database.GetOpenIdType(a) = %q, %v, want group", got, err)
+	}
+
+	// 内存中没有记录时从数据库读取
+	delete(globalOpenIdMappingInstance.mapping, "a")
+	if got := GetOpenIdType("a"); got != "group" {
+		t.Errorf("GetOpenIdType(a) from database = %q, want group", got)
+	}
+
+	DelOpenId("a")
+	if got := GetOpenIdType("a"); got != "" {
+		t.Errorf("GetOpenIdType(a) after DelOpenId = %q, want empty", got)
+	}
+	if _, err := database.GetOpenIdType("a"); err != database.ErrOpenIdNotFound {
+		t.Errorf("database.GetOpenIdType(a) after DelOpenId error = %v, want %v", err, database.ErrOpenIdNotFound)
+	}
+}
+
+func TestOpenIdTypeConcurrent(t *testing.T) {
+	useTestOpenIdMapping(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			openId := fmt.Sprint(i % 2)
+			for j := 0; j < 50; j++ {
+				switch j % 3 {
+				case 0:
+					SetOpenIdType(openId, "group")
+				case 1:
+					GetOpenIdType(openId)
+				case 2:
+					DelOpenId(openId)
+				}
+				_ = GetOpenIdData()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 最后一次修改在内存与数据库中一致
+	for _, openId := range []string{"0", "1"} {
+		SetOpenIdType(openId, "private")
+		delete(globalOpenIdMappingInstance.mapping, openId)
+		if got := GetOpenIdType(openId); got != "private" {
+			t.Errorf("GetOpenIdType(%s) = %q, want private", openId, got)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package processor
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/WindowsSov8forUs/glyccat/config"
+	"github.com/WindowsSov8forUs/glyccat/log"
+	"github.com/WindowsSov8forUs/glyccat/pkg/image"
+	"github.com/WindowsSov8forUs/glyccat/pkg/mp4"
+	"github.com/WindowsSov8forUs/glyccat/pkg/netguard"
+	"github.com/WindowsSov8forUs/glyccat/pkg/silk"
+	"github.com/WindowsSov8forUs/glyccat/proxy"
+)
+
+var (
+	ErrRemoteAddressForbidden = netguard.ErrAddressForbidden                 // 远程地址位于禁止访问的网段
+	ErrRemoteMediaDisabled    = errors.New("remote media fetch is disabled") // 未启用远程媒体资源下载
+	ErrRemoteURLNotAllowed    = errors.New("remote url not allowed")         // 链接不在下载器允许的范围内
+)
+
+// RemoteMediaFetcher 远程媒体资源下载器
+type RemoteMediaFetcher struct {
+	enable   bool
+	maxSize  int64
+	allowURL func(url string) bool // 允许下载与重定向的链接，为 nil 时不限制
+	client   *http.Client
+}
+
+var remoteMediaFetcher = &RemoteMediaFetcher{}
+
+// qqMediaFetcher QQ 媒体资源下载器
+//
+// 仅能下载代理路由中列出的 QQ 资源链接，用于接收语音转码与视频封面生成，不受 remote_media.enable 限制
+var qqMediaFetcher = &RemoteMediaFetcher{}
+
+// checkRemoteAddress 检查连接的目标地址是否允许访问
+func checkRemoteAddress(address string) error {
+	return netguard.CheckAddress(address)
+}
+
+// SetRemoteMediaFetcher 根据配置设置远程媒体资源下载器
+func SetRemoteMediaFetcher(conf *config.Config) {
+	remoteMediaFetcher = newRemoteMediaFetcher(conf.Satori.RemoteMedia, checkRemoteAddress)
+	qqMediaFetcher = newQQMediaFetcher(conf.Satori.RemoteMedia, checkRemoteAddress, proxy.CouldBeProxied)
+}
+
+// newQQMediaFetcher 创建只允许下载 allowURL 所接受链接的 QQ 媒体资源下载器，沿用远程媒体资源的大小与超时限制
+func newQQMediaFetcher(remoteConf config.RemoteMedia, checkAddress func(address string) error, allowURL func(url string) bool) *RemoteMediaFetcher {
+	remoteConf.Enable = true
+	fetcher := newRemoteMediaFetcher(remoteConf, checkAddress)
+	fetcher.allowURL = allowURL
+	return fetcher
+}
+
+// newRemoteMediaFetcher 创建远程媒体资源下载器，checkAddress 用于检查每次连接的目标地址
+func newRemoteMediaFetcher(remoteConf config.RemoteMedia, checkAddress func(address string) error) *RemoteMediaFetcher {
+	dialer := netguard.NewDialer(10*time.Second, checkAddress)
+
+	transport := &http.Transport{
+		Proxy:                 nil, // 不使用环境代理，否则无法检查实际连接的地址
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 15 * time.Second,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	fetcher := &RemoteMediaFetcher{
+		enable:  remoteConf.Enable,
+		maxSize: int64(remoteConf.MaxSize) * 1024 * 1024,
+	}
+	maxRedirects := remoteConf.MaxRedirects
+	fetcher.client = &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(remoteConf.Timeout) * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("重定向次数过多")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("不支持重定向到 %s 协议", req.URL.Scheme)
+			}
+			if fetcher.allowURL != nil && !fetcher.allowURL(req.URL.String()) {
+				return fmt.Errorf("%w: %s", ErrRemoteURLNotAllowed, req.URL)
+			}
+			return nil
+		},
+	}
+	return fetcher
+}
+
+// Fetch 下载远程资源，未启用远程媒体资源下载时返回 ErrRemoteMediaDisabled
+func (f *RemoteMediaFetcher) Fetch(ctx context.Context, rawURL string) (*fileSrc, error) {
+	if !f.enable {
+		return nil, ErrRemoteMediaDisabled
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("不支持的协议: %s", req.URL.Scheme)
+	}
+	if f.allowURL != nil && !f.allowURL(rawURL) {
+		return nil, fmt.Errorf("%w: %s", ErrRemoteURLNotAllowed, rawURL)
+	}
+	req.Header.Set("User-Agent", "GlycCat")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("远程资源返回状态码 %d", resp.StatusCode)
+	}
+	if f.maxSize > 0 && resp.ContentLength > f.maxSize {
+		return nil, fmt.Errorf("远程资源大小超出上限: %d", resp.ContentLength)
+	}
+
+	reader := io.Reader(resp.Body)
+	if f.maxSize > 0 {
+		reader = io.LimitReader(resp.Body, f.maxSize+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if f.maxSize > 0 && int64(len(data)) > f.maxSize {
+		return nil, fmt.Errorf("远程资源大小超出上限")
+	}
+
+	// 以嗅探结果为准，服务端声明的类型仅作为补充
+	mimeType := detectMimeType(data)
+	if mimeType == "application/octet-stream" {
+		if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+			mimeType = contentType
+		}
+	}
+	return &fileSrc{MimeType: mimeType, Data: data}, nil
+}
+
+// isAvailableFormat 判断文件资源是否已经是 QQ 可接受的格式
+func isAvailableFormat(src *fileSrc) bool {
+	switch {
+	case strings.HasPrefix(src.MimeType, "audio/"):
+		return silk.IsAMRorSILK(src.Data)
+	case strings.HasPrefix(src.MimeType, "video/"):
+		return !mp4.NeedsProcess(src.Data)
+	case strings.HasPrefix(src.MimeType, "image/"):
+		return !image.NeedsEncode(src.Data)
+	}
+	return true
+}
+
+// fetchRemoteToAvailable 下载远程资源，若格式不可用则转码为 base64 字符串
+//
+// 返回空字符串时表示资源可以直接以 URL 形式发送
+func fetchRemoteToAvailable(ctx context.Context, rawURL string) (string, error) {
+	fetcher := remoteMediaFetcher
+	if !fetcher.enable {
+		return "", nil
+	}
+
+	src, err := fetcher.Fetch(ctx, rawURL)
+	if err != nil {
+		return "", err
+	}
+	if isAvailableFormat(src) {
+		return "", nil
+	}
+
+	log.Debugf("远程资源 %s 格式为 %s ，将进行转码", rawURL, src.MimeType)
+	data, err := convertToAvailableFormat(ctx, src)
+	if err != nil {
+		return "", fmt.Errorf("转换文件格式失败: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
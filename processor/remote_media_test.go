@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/WindowsSov8forUs/glyccat/config"
+)
+
+// testRemoteConf 测试使用的远程媒体资源配置
+var testRemoteConf = config.RemoteMedia{Enable: true, MaxSize: 1, Timeout: 5, MaxRedirects: 3}
+
+func TestFetchDeniesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	fetcher := newRemoteMediaFetcher(testRemoteConf, checkRemoteAddress)
+	urls := []string{
+		server.URL,                   // 回环地址
+		"http://localhost:1/",        // 解析为回环地址的域名
+		"http://[::1]:1/",            // IPv6 回环地址
+		"http://169.254.169.254/",    // 链路本地地址
+		"http://10.0.0.1/",           // 私有网络
+		"http://172.16.0.1/",         // 私有网络
+		"http://192.168.0.1/",        // 私有网络
+		"http://[::ffff:127.0.0.1]/", // IPv4 映射地址
+	}
+	for _, rawURL := range urls {
+		_, err := fetcher.Fetch(context.Background(), rawURL)
+		if !errors.Is(err, ErrRemoteAddressForbidden) {
+			t.Errorf("Fetch(%s) error = %v, want %v", rawURL, err, ErrRemoteAddressForbidden)
+		}
+	}
+}
+
+func TestFetchDeniesRedirectToPrivate(t *testing.T) {
+	private := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secret"))
+	}))
+	defer private.Close()
+
+	targets := []string{private.URL, "http://169.254.169.254/latest/meta-data/", "http://10.0.0.1/"}
+	for _, target := range targets {
+		public := httptest.NewServer(http.RedirectHandler(target, http.StatusFound))
+
+		// 仅允许连接作为公网服务器的测试服务器
+		publicAddr := public.Listener.Addr().String()
+		fetcher := newRemoteMediaFetcher(testRemoteConf, func(address string) error {
+			if address == publicAddr {
+				return nil
+			}
+			return checkRemoteAddress(address)
+		})
+
+		_, err := fetcher.Fetch(context.Background(), public.URL)
+		if !errors.Is(err, ErrRemoteAddressForbidden) {
+			t.Errorf("Fetch() redirect to %s error = %v, want %v", target, err, ErrRemoteAddressForbidden)
+		}
+		public.Close()
+	}
+}
+
+func TestFetchAllowed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("GIF89a"))
+	}))
+	defer server.Close()
+
+	addr := server.Listener.Addr().String()
+	allow := func(address string) error {
+		if address == addr {
+			return nil
+		}
+		return checkRemoteAddress(address)
+	}
+
+	src, err := newRemoteMediaFetcher(testRemoteConf, allow).Fetch(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if src.MimeType != "image/gif" {
+		t.Fatalf("Fetch() mime type = %s, want image/gif", src.MimeType)
+	}
+
+	disabled := testRemoteConf
+	disabled.Enable = false
+	if _, err := newRemoteMediaFetcher(disabled, allow).Fetch(context.Background(), server.URL); !errors.Is(err, ErrRemoteMediaDisabled) {
+		t.Fatalf("Fetch() error = %v, want %v", err, ErrRemoteMediaDisabled)
+	}
+}
+
+func TestQQMediaFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://example.com/voice", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte("#!SILK_V3"))
+	}))
+	defer server.Close()
+
+	addr := server.Listener.Addr().String()
+	allowAddress := func(address string) error {
+		if address == addr {
+			return nil
+		}
+		return checkRemoteAddress(address)
+	}
+	allowURL := func(url string) bool {
+		return strings.HasPrefix(url, server.URL+"/qq/") || url == server.URL+"/redirect"
+	}
+
+	// 未启用远程媒体资源下载时仍可下载 QQ 资源
+	disabled := testRemoteConf
+	disabled.Enable = false
+	fetcher := newQQMediaFetcher(disabled, allowAddress, allowURL)
+	if _, err := fetcher.Fetch(context.Background(), server.URL+"/qq/voice"); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	for _, rawURL := range []string{server.URL + "/other", server.URL + "/redirect"} {
+		if _, err := fetcher.Fetch(context.Background(), rawURL); !errors.Is(err, ErrRemoteURLNotAllowed) {
+			t.Errorf("Fetch(%s) error = %v, want %v", rawURL, err, ErrRemoteURLNotAllowed)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package processor
+
+import (
+	"errors"
+
+	"github.com/WindowsSov8forUs/glyccat/database"
+	"github.com/WindowsSov8forUs/glyccat/log"
+)
+
+// observeGroupMember 将在群组中出现的成员记入用户名册，失败时只会输出日志
+func observeGroupMember(groupId, userId, avatar string, seenAt int64) {
+	err := database.ObserveGroupMember(groupId, userId, avatar, seenAt)
+	if err != nil && !errors.Is(err, database.ErrRosterDBDisabled) {
+		log.Warnf("记录群组 %s 的成员 %s 失败: %v", groupId, userId, err)
+	}
+}
+
+// deleteGroupRoster 删除群组的用户名册，失败时只会输出日志
+func deleteGroupRoster(groupId string) {
+	err := database.DeleteGroupRoster(groupId)
+	if err != nil && !errors.Is(err, database.ErrRosterDBDisabled) {
+		log.Warnf("删除群组 %s 的成员记录失败: %v", groupId, err)
+	}
+}
+
+// observeUser 将出现的用户记入用户名册，失败时只会输出日志
+func observeUser(userId, name, avatar string, seenAt int64) {
+	err := database.ObserveUser(userId, name, avatar, seenAt)
+	if err != nil && !errors.Is(err, database.ErrRosterDBDisabled) {
+		log.Warnf("记录用户 %s 失败: %v", userId, err)
+	}
+}
+
+// addFriend 将用户记为好友，失败时只会输出日志
+func addFriend(userId string, since int64) {
+	err := database.AddFriend(userId, since)
+	if err != nil && !errors.Is(err, database.ErrRosterDBDisabled) {
+		log.Warnf("记录好友 %s 失败: %v", userId, err)
+	}
+}
+
+// removeFriend 删除好友记录，失败时只会输出日志
+func removeFriend(userId string) {
+	err := database.RemoveFriend(userId)
+	if err != nil && !errors.Is(err, database.ErrRosterDBDisabled) {
+		log.Warnf("删除好友 %s 失败: %v", userId, err)
+	}
+}
+
+// addDirectUser 记录私信过机器人的频道用户，失败时只会输出日志
+func addDirectUser(userId string, since int64) {
+	err := database.AddDirectUser(userId, since)
+	if err != nil && !errors.Is(err, database.ErrRosterDBDisabled) {
+		log.Warnf("记录私信用户 %s 失败: %v", userId, err)
+	}
+}
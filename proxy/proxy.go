@@ -0,0 +1,239 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/WindowsSov8forUs/glyccat/config"
+	"github.com/WindowsSov8forUs/glyccat/log"
+	"github.com/WindowsSov8forUs/glyccat/pkg/diskcache"
+	"github.com/WindowsSov8forUs/glyccat/pkg/netguard"
+	"github.com/WindowsSov8forUs/glyccat/version"
+)
+
+const cachePath = "data/cache/proxy"
+
+// sniffLength 用于嗅探内容类型的字节数
+const sniffLength = 512
+
+// forwardedHeaders 允许从上游响应转发给客户端的响应头
+var forwardedHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Content-Range",
+	"Accept-Ranges",
+	"Last-Modified",
+	"ETag",
+	"Cache-Control",
+	"Expires",
+}
+
+// Proxy 代理路由
+type Proxy struct {
+	// urls 允许代理的 URL 前缀
+	urls []string
+	// client 访问上游资源的 HTTP 客户端
+	client *http.Client
+	// cache 磁盘缓存，为 nil 时不进行缓存
+	cache *diskcache.Cache
+}
+
+var instance *Proxy
+
+// StartProxy 启动代理路由
+func StartProxy(conf *config.Config) {
+	instance = &Proxy{
+		urls:   conf.Satori.Proxy.Urls,
+		client: newClient(netguard.CheckAddress),
+	}
+
+	if conf.Satori.Proxy.CacheSize > 0 {
+		cache, err := diskcache.New(cachePath, int64(conf.Satori.Proxy.CacheSize)*1024*1024, 0)
+		if err != nil {
+			log.Errorf("创建代理缓存失败，将不会缓存代理资源: %v", err)
+		} else {
+			instance.cache = cache
+		}
+	}
+}
+
+// newClient 创建访问上游资源的 HTTP 客户端，只跟随代理范围内的重定向
+//
+// checkAddress 用于检查每次连接的目标地址，防止代理范围内的域名被解析或重定向到内部网络
+func newClient(checkAddress func(address string) error) *http.Client {
+	transport := &http.Transport{
+		Proxy:                 nil, // 不使用环境代理，否则无法检查实际连接的地址
+		DialContext:           netguard.NewDialer(10*time.Second, checkAddress).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   60 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			// 重定向目标同样需要在代理范围内
+			if !CouldBeProxied(req.URL.String()) {
+				return fmt.Errorf("redirect to %s is not allowed", req.URL.Host)
+			}
+			return nil
+		},
+	}
+}
+
+// Key 计算 URL 对应的缓存键
+func Key(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// Urls 获取代理路由列表
+func Urls() []string {
+	if instance == nil {
+		return []string{}
+	}
+	urls := make([]string, len(instance.urls))
+	copy(urls, instance.urls)
+	return urls
+}
+
+// CouldBeProxied 是否可代理
+func CouldBeProxied(url string) bool {
+	for _, proxyUrl := range Urls() {
+		if strings.HasPrefix(url, proxyUrl) {
+			return true
+		}
+	}
+	return false
+}
+
+// Serve 代理访问上游资源并写入响应
+func Serve(w http.ResponseWriter, r *http.Request, target, satoriVersion string) {
+	if instance == nil {
+		http.Error(w, "proxy is not enabled", http.StatusNotFound)
+		return
+	}
+
+	// 设置响应头
+	w.Header().Set("Date", time.Now().Format(time.RFC1123))
+	w.Header().Set("Server", fmt.Sprintf("GlycCat/%s", version.Version))
+	w.Header().Set("X-Satori-Protocol", satoriVersion)
+
+	key := Key(target)
+	rangeHeader := r.Header.Get("Range")
+
+	// 优先从缓存中读取，缓存的文件由 http.ServeContent 处理 Range 等请求头
+	if instance.cache != nil {
+		if file, contentType, ok := instance.cache.Open(key); ok {
+			defer file.Close()
+			info, err := file.Stat()
+			if err == nil {
+				log.Tracef("代理资源命中缓存: %s", target)
+				w.Header().Set("Content-Type", contentType)
+				w.Header().Set("X-Content-Type-Options", "nosniff")
+				http.ServeContent(w, r, "", info.ModTime(), file)
+				return
+			}
+		}
+	}
+
+	// 构建上游请求，不转发客户端的 Cookie 、 Authorization 与 Referer 等请求头
+	request, err := http.NewRequestWithContext(r.Context(), http.MethodGet, target, nil)
+	if err != nil {
+		http.Error(w, "invalid url", http.StatusBadRequest)
+		return
+	}
+	request.Header.Set("User-Agent", fmt.Sprintf("GlycCat/%s", version.Version))
+	request.Header.Set("Accept", "*/*")
+	if rangeHeader != "" {
+		request.Header.Set("Range", rangeHeader)
+	}
+
+	response, err := instance.client.Do(request)
+	if err != nil {
+		log.Warnf("代理请求 %s 失败: %v", target, err)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 400 {
+		http.Error(w, http.StatusText(response.StatusCode), response.StatusCode)
+		return
+	}
+
+	// 嗅探内容类型
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(response.Body, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+		return
+	}
+	head = head[:n]
+	contentType := detectContentType(response.Header.Get("Content-Type"), head)
+
+	// 转发经过筛选的响应头
+	for _, header := range forwardedHeaders {
+		if value := response.Header.Get(header); value != "" {
+			w.Header().Set(header, value)
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	body := io.MultiReader(bytes.NewReader(head), response.Body)
+
+	// 只缓存完整的响应
+	cacheable := instance.cache != nil && rangeHeader == "" && response.StatusCode == http.StatusOK &&
+		(response.ContentLength < 0 || instance.cache.Fits(response.ContentLength))
+	if !cacheable {
+		w.WriteHeader(response.StatusCode)
+		io.Copy(w, body)
+		return
+	}
+
+	temp, err := instance.cache.TempFile()
+	if err != nil {
+		log.Warnf("创建代理缓存临时文件失败: %v", err)
+		w.WriteHeader(response.StatusCode)
+		io.Copy(w, body)
+		return
+	}
+	w.WriteHeader(response.StatusCode)
+	size, err := io.Copy(io.MultiWriter(w, temp), body)
+	temp.Close()
+	if err != nil {
+		os.Remove(temp.Name())
+		return
+	}
+	if response.ContentLength >= 0 && size != response.ContentLength {
+		os.Remove(temp.Name())
+		return
+	}
+	if err := instance.cache.Commit(key, temp.Name(), contentType, size); err != nil {
+		log.Warnf("写入代理缓存失败: %v", err)
+	}
+}
+
+// detectContentType 根据上游响应头与内容确定内容类型
+func detectContentType(header string, head []byte) string {
+	if header != "" {
+		if mediaType, _, err := mime.ParseMediaType(header); err == nil && mediaType != "application/octet-stream" {
+			return header
+		}
+	}
+	return http.DetectContentType(head)
+}
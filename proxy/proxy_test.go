@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/WindowsSov8forUs/glyccat/pkg/diskcache"
+	"github.com/WindowsSov8forUs/glyccat/pkg/netguard"
+)
+
+// allowAddress 允许连接任意地址，测试服务器位于回环地址
+func allowAddress(string) error { return nil }
+
+// startTestProxy 启动只允许代理给定前缀的代理路由
+func startTestProxy(t *testing.T, cacheSize int64, urls ...string) {
+	t.Helper()
+	instance = &Proxy{urls: urls, client: newClient(allowAddress)}
+	if cacheSize > 0 {
+		cache, err := diskcache.New(t.TempDir(), cacheSize, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		instance.cache = cache
+	}
+	t.Cleanup(func() { instance = nil })
+}
+
+func TestCouldBeProxied(t *testing.T) {
+	startTestProxy(t, 0, "https://gchat.qpic.cn/", "https://multimedia.nt.qq.com.cn/")
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://gchat.qpic.cn/gchatpic_new/0/abc/0", true},
+		{"https://multimedia.nt.qq.com.cn/download?appid=1407", true},
+		{"https://gchat.qpic.cn.evil.com/x", false},
+		{"https://gchat.qpic.cn@evil.com/x", false},
+		{"http://gchat.qpic.cn/x", false},
+		{"https://evil.com/https://gchat.qpic.cn/", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := CouldBeProxied(tt.url); got != tt.want {
+			t.Errorf("CouldBeProxied(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestServeCachesWithNosniff(t *testing.T) {
+	requests := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "image/gif")
+		_, _ = w.Write([]byte("GIF89a-image-data"))
+	}))
+	defer upstream.Close()
+	startTestProxy(t, 1024, upstream.URL+"/")
+
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		Serve(recorder, httptest.NewRequest(http.MethodGet, "/v1/proxy/x", nil), upstream.URL+"/image", "v1")
+		if recorder.Code != http.StatusOK || recorder.Body.String() != "GIF89a-image-data" {
+			t.Fatalf("Serve() #%d = %d %q", i, recorder.Code, recorder.Body.String())
+		}
+		if got := recorder.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Fatalf("Serve() #%d X-Content-Type-Options = %q, want nosniff", i, got)
+		}
+		if got := recorder.Header().Get("Content-Type"); got != "image/gif" {
+			t.Fatalf("Serve() #%d Content-Type = %q, want image/gif", i, got)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("upstream requests = %d, want 1", requests)
+	}
+}
+
+func TestServeRejectsRedirectOutsideAllowlist(t *testing.T) {
+	outside := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secret"))
+	}))
+	defer outside.Close()
+	upstream := httptest.NewServer(http.RedirectHandler(outside.URL+"/secret", http.StatusFound))
+	defer upstream.Close()
+
+	startTestProxy(t, 0, upstream.URL+"/")
+
+	recorder := httptest.NewRecorder()
+	Serve(recorder, httptest.NewRequest(http.MethodGet, "/v1/proxy/x", nil), upstream.URL+"/image", "v1")
+	if recorder.Code != http.StatusBadGateway {
+		t.Fatalf("Serve() = %d %q, want %d", recorder.Code, recorder.Body.String(), http.StatusBadGateway)
+	}
+}
+
+func TestServeDeniesInternalAddresses(t *testing.T) {
+	requests := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte("secret"))
+	}))
+	defer upstream.Close()
+
+	// 代理范围内的链接解析到回环地址时同样拒绝连接
+	startTestProxy(t, 0, upstream.URL+"/")
+	instance.client = newClient(netguard.CheckAddress)
+
+	recorder := httptest.NewRecorder()
+	Serve(recorder, httptest.NewRequest(http.MethodGet, "/v1/proxy/x", nil), upstream.URL+"/image", "v1")
+	if recorder.Code != http.StatusBadGateway || requests != 0 {
+		t.Fatalf("Serve() = %d %q with %d upstream requests, want %d and none",
+			recorder.Code, recorder.Body.String(), requests, http.StatusBadGateway)
+	}
+}
@@ -9,10 +9,13 @@ import (
 	"github.com/satori-protocol-go/satori-model-go/pkg/channel"
 	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/openapi"
+
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
+	"github.com/WindowsSov8forUs/glyccat/processor"
 )
 
 func init() {
-	RegisterHandler("channel.create", HandleChannelCreate)
+	RegisterHandler("channel.create", HandleChannelCreate, "qqguild")
 }
 
 // RequestChannelCreate 创建群组频道请求
@@ -45,19 +48,10 @@ func HandleChannelCreate(api openapi.OpenAPI, apiv2 openapi.OpenAPI, message *Ac
 		if err != nil {
 			return gin.H{}, &InternalServerError{err}
 		}
-		response.Id = dtoChannel.ID
-		response.Name = dtoChannel.Name
-		response.ParentId = dtoChannel.ParentID
-		switch dtoChannel.Type {
-		case dto.ChannelTypeText:
-			response.Type = channel.ChannelTypeText
-		case dto.ChannelTypeVoice:
-			response.Type = channel.ChannelTypeVoice
-		case dto.ChannelTypeCategory:
-			response.Type = channel.ChannelTypeCategory
-		default:
-			response.Type = channel.ChannelTypeCategory
-		}
+		c := processor.ConvertDtoChannel(dtoChannel)
+		entitycache.SetChannel(request.GuildId, c)
+		entitycache.InvalidateChannels(request.GuildId)
+		response = ResponseChannelCreate(*c)
 
 		return response, nil
 	}
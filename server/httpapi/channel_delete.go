@@ -6,10 +6,12 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/tencent-connect/botgo/openapi"
+
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
 )
 
 func init() {
-	RegisterHandler("channel.delete", HandleChannelDelete)
+	RegisterHandler("channel.delete", HandleChannelDelete, "qqguild")
 }
 
 // RequestChannelDelete 删除群组频道请求
@@ -30,6 +32,7 @@ func HandleChannelDelete(api openapi.OpenAPI, apiv2 openapi.OpenAPI, message *Ac
 		if err != nil {
 			return gin.H{}, &InternalServerError{err}
 		}
+		entitycache.DeleteChannel(request.ChannelId)
 
 		return gin.H{}, nil
 	}
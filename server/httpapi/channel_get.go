@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
 	"github.com/WindowsSov8forUs/glyccat/processor"
 	"github.com/gin-gonic/gin"
 
@@ -14,7 +15,7 @@ import (
 )
 
 func init() {
-	RegisterHandler("channel.get", HandleChannelGet)
+	RegisterHandler("channel.get", HandleChannelGet, "qq", "qqguild")
 }
 
 // RequestChannelGet 获取群组频道请求
@@ -42,6 +43,10 @@ func HandleChannelGet(api, apiv2 openapi.OpenAPI, message *ActionMessage) (any,
 			response.Id = request.ChannelID
 			response.Type = channel.ChannelTypeDirect
 
+		} else if cached, ok := entitycache.GetChannel(request.ChannelID); ok {
+			// 优先使用实体缓存
+			response = ResponseChannelGet(*cached)
+
 		} else {
 			var dtoChannel *dto.Channel
 			dtoChannel, err = apiv2.Channel(context.TODO(), request.ChannelID)
@@ -49,19 +54,9 @@ func HandleChannelGet(api, apiv2 openapi.OpenAPI, message *ActionMessage) (any,
 				return gin.H{}, &InternalServerError{err}
 			}
 
-			response.Id = dtoChannel.ID
-			response.Name = dtoChannel.Name
-			response.ParentId = dtoChannel.ParentID
-			switch dtoChannel.Type {
-			case dto.ChannelTypeText:
-				response.Type = channel.ChannelTypeText
-			case dto.ChannelTypeVoice:
-				response.Type = channel.ChannelTypeVoice
-			case dto.ChannelTypeCategory:
-				response.Type = channel.ChannelTypeCategory
-			default:
-				response.Type = channel.ChannelTypeCategory
-			}
+			c := processor.ConvertDtoChannel(dtoChannel)
+			entitycache.SetChannel(dtoChannel.GuildID, c)
+			response = ResponseChannelGet(*c)
 		}
 
 		return response, nil
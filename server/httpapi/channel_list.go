@@ -9,11 +9,12 @@ import (
 	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/openapi"
 
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
 	"github.com/WindowsSov8forUs/glyccat/processor"
 )
 
 func init() {
-	RegisterHandler("channel.list", HandleChannelList)
+	RegisterHandler("channel.list", HandleChannelList, "qq", "qqguild")
 }
 
 // RequestChannelList 获取群组频道列表请求
@@ -36,30 +37,21 @@ func HandleChannelList(api openapi.OpenAPI, apiv2 openapi.OpenAPI, message *Acti
 	if message.Platform == "qqguild" {
 		var response ResponseChannelList
 
+		// 优先使用实体缓存
+		if cached, ok := entitycache.GetChannels(request.GuildId); ok {
+			response.Data = cached
+			return response, nil
+		}
+
 		var dtoChannels []*dto.Channel
 		dtoChannels, err = apiv2.Channels(context.TODO(), request.GuildId)
 		if err != nil {
 			return gin.H{}, &InternalServerError{err}
 		}
 		for _, dtoChannel := range dtoChannels {
-			var channelType channel.ChannelType
-			switch dtoChannel.Type {
-			case dto.ChannelTypeText:
-				channelType = channel.ChannelTypeText
-			case dto.ChannelTypeVoice:
-				channelType = channel.ChannelTypeVoice
-			case dto.ChannelTypeCategory:
-				channelType = channel.ChannelTypeCategory
-			default:
-				channelType = channel.ChannelTypeCategory
-			}
-			response.Data = append(response.Data, &channel.Channel{
-				Id:       dtoChannel.ID,
-				Name:     dtoChannel.Name,
-				ParentId: dtoChannel.ParentID,
-				Type:     channelType,
-			})
+			response.Data = append(response.Data, processor.ConvertDtoChannel(dtoChannel))
 		}
+		entitycache.SetChannels(request.GuildId, response.Data)
 
 		return response, nil
 	} else if message.Platform == "qq" {
@@ -6,11 +6,15 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/satori-protocol-go/satori-model-go/pkg/channel"
+	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/openapi"
+
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
+	"github.com/WindowsSov8forUs/glyccat/processor"
 )
 
 func init() {
-	RegisterHandler("channel.update", HandleChannelUpdate)
+	RegisterHandler("channel.update", HandleChannelUpdate, "qqguild")
 }
 
 // RequestChannelUpdate 修改群组频道请求
@@ -28,10 +32,12 @@ func HandleChannelUpdate(api openapi.OpenAPI, apiv2 openapi.OpenAPI, message *Ac
 	}
 
 	if message.Platform == "qqguild" {
-		_, err = apiv2.PatchChannel(context.TODO(), request.ChannelId, createChannelValue(request.Data))
+		var dtoChannel *dto.Channel
+		dtoChannel, err = apiv2.PatchChannel(context.TODO(), request.ChannelId, createChannelValue(request.Data))
 		if err != nil {
 			return gin.H{}, &InternalServerError{err}
 		}
+		entitycache.SetChannel(dtoChannel.GuildID, processor.ConvertDtoChannel(dtoChannel))
 
 		return gin.H{}, nil
 	}
@@ -0,0 +1,57 @@
+package httpapi
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+	"github.com/satori-protocol-go/satori-model-go/pkg/user"
+	"github.com/tencent-connect/botgo/openapi"
+
+	"github.com/WindowsSov8forUs/glyccat/database"
+)
+
+func init() {
+	RegisterHandler("friend.list", HandleFriendList, "qq", "qqguild")
+}
+
+// RequestFriendList 获取好友列表请求
+type RequestFriendList struct {
+	Next string `json:"next,omitempty"` // 分页令牌
+}
+
+// ResponseFriendList 获取好友列表响应
+type ResponseFriendList user.UserList
+
+// HandleFriendList 处理获取好友列表请求
+func HandleFriendList(api, apiv2 openapi.OpenAPI, message *ActionMessage) (any, APIError) {
+	var request RequestFriendList
+	err := json.Unmarshal(message.Data(), &request)
+	if err != nil {
+		return gin.H{}, &BadRequestError{err}
+	}
+
+	var listUsers func(next string, limit int) ([]*database.RosterUser, string, error)
+	switch message.Platform {
+	case "qq":
+		// QQ 单聊没有获取好友列表的接口，只能返回私聊过机器人或添加了机器人好友的用户
+		listUsers = database.ListFriends
+	case "qqguild":
+		// 频道没有好友关系，返回私信过机器人的用户
+		listUsers = database.ListDirectUsers
+	default:
+		return defaultResource(message)
+	}
+
+	var response ResponseFriendList
+	users, next, err := listUsers(request.Next, rosterPageSize)
+	if err != nil {
+		return gin.H{}, &InternalServerError{err}
+	}
+	response.Next = next
+	response.Data = make([]*user.User, 0, len(users))
+	for _, u := range users {
+		response.Data = append(response.Data, convertRosterUser(u))
+	}
+
+	return response, nil
+}
@@ -0,0 +1,88 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/WindowsSov8forUs/glyccat/database"
+	"github.com/WindowsSov8forUs/glyccat/processor"
+	"github.com/gin-gonic/gin"
+
+	satoriMessage "github.com/satori-protocol-go/satori-model-go/pkg/message"
+	"github.com/tencent-connect/botgo/openapi"
+)
+
+func init() {
+	RegisterHandler("glyccat/message.search", HandleMessageSearch, "qq", "qqguild")
+}
+
+// RequestMessageSearch 搜索消息请求
+type RequestMessageSearch struct {
+	Keyword   string `json:"keyword,omitempty"`    // 关键词，以空格分隔的多个关键词需要同时匹配
+	ChannelId string `json:"channel_id,omitempty"` // 频道 ID
+	UserId    string `json:"user_id,omitempty"`    // 发送者 ID
+	Start     int64  `json:"start,omitempty"`      // 起始时间，毫秒时间戳
+	End       int64  `json:"end,omitempty"`        // 结束时间，毫秒时间戳
+	Next      string `json:"next,omitempty"`       // 分页令牌
+	Limit     int    `json:"limit,omitempty"`      // 数量
+}
+
+// ResponseMessageSearch 搜索消息响应
+type ResponseMessageSearch struct {
+	Data []*satoriMessage.Message `json:"data"`           // 按时间降序排列的消息
+	Next string                   `json:"next,omitempty"` // 下一页的令牌
+}
+
+// HandleMessageSearch 处理搜索消息请求
+func HandleMessageSearch(api, apiv2 openapi.OpenAPI, message *ActionMessage) (any, APIError) {
+	var request RequestMessageSearch
+	err := json.Unmarshal(message.Data(), &request)
+	if err != nil {
+		return gin.H{}, &BadRequestError{err}
+	}
+
+	if request.Keyword == "" && request.ChannelId == "" && request.UserId == "" {
+		return gin.H{}, &BadRequestError{errors.New(`at least one of "keyword", "channel_id" and "user_id" is required`)}
+	}
+	if request.Start > 0 && request.End > 0 && request.Start >= request.End {
+		return gin.H{}, &BadRequestError{errors.New(`"start" must be earlier than "end"`)}
+	}
+	if request.Limit <= 0 {
+		request.Limit = 50
+	}
+
+	query := &database.SearchQuery{
+		Keyword: request.Keyword,
+		UserId:  request.UserId,
+		Start:   request.Start,
+		End:     request.End,
+		Next:    request.Next,
+		Limit:   request.Limit,
+	}
+	if request.ChannelId != "" {
+		query.ChannelId = request.ChannelId
+		query.ChannelType = processor.GetChannelType(message.Platform, request.ChannelId)
+	}
+	// 只搜索当前平台的消息
+	if message.Platform == "qqguild" {
+		query.Types = []string{database.ChannelTypeGuild, database.ChannelTypeDirect}
+	} else {
+		query.Types = []string{database.ChannelTypeGroup, database.ChannelTypePrivate}
+	}
+
+	result, err := database.SearchMessages(query)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrInvalidSearchCursor):
+			return gin.H{}, &BadRequestError{err}
+		case errors.Is(err, database.ErrMessageDBDisabled):
+			return gin.H{}, &ForbiddenError{err.Error()}
+		}
+		return gin.H{}, &InternalServerError{err}
+	}
+
+	return ResponseMessageSearch{
+		Data: result.Data,
+		Next: result.Next,
+	}, nil
+}
@@ -10,11 +10,12 @@ import (
 	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/openapi"
 
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
 	"github.com/WindowsSov8forUs/glyccat/processor"
 )
 
 func init() {
-	RegisterHandler("guild.get", HandleGuildGet)
+	RegisterHandler("guild.get", HandleGuildGet, "qq", "qqguild")
 }
 
 // RequestGuildGet 获取群组请求
@@ -33,6 +34,11 @@ func HandleGuildGet(api, apiv2 openapi.OpenAPI, message *ActionMessage) (any, AP
 		return gin.H{}, &BadRequestError{err}
 	}
 	if message.Platform == "qqguild" {
+		// 优先使用实体缓存
+		if cached, ok := entitycache.GetGuild(request.GuildId); ok {
+			return ResponseGuildGet(*cached), nil
+		}
+
 		var response ResponseGuildGet
 		var dtoGuild *dto.Guild
 
@@ -43,6 +49,7 @@ func HandleGuildGet(api, apiv2 openapi.OpenAPI, message *ActionMessage) (any, AP
 		response.Id = dtoGuild.ID
 		response.Name = dtoGuild.Name
 		response.Avatar = dtoGuild.Icon
+		entitycache.SetGuild((*guild.Guild)(&response))
 
 		return response, nil
 
@@ -9,11 +9,12 @@ import (
 	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/openapi"
 
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
 	"github.com/WindowsSov8forUs/glyccat/processor"
 )
 
 func init() {
-	RegisterHandler("guild.list", HandleGuildList)
+	RegisterHandler("guild.list", HandleGuildList, "qq", "qqguild")
 }
 
 // RequestGuildList 获取群组列表请求
@@ -40,12 +41,17 @@ func HandleGuildList(api, apiv2 openapi.OpenAPI, message *ActionMessage) (any, A
 			return gin.H{}, &InternalServerError{err}
 		}
 
-		response.Next = dtoGuilds[len(dtoGuilds)-1].ID
+		if len(dtoGuilds) > 0 {
+			response.Next = dtoGuilds[len(dtoGuilds)-1].ID
+		}
 		response.Data = make([]*guild.Guild, len(dtoGuilds))
 		for i, dtoGuild := range dtoGuilds {
-			response.Data[i].Id = dtoGuild.ID
-			response.Data[i].Name = dtoGuild.Name
-			response.Data[i].Avatar = dtoGuild.Icon
+			response.Data[i] = &guild.Guild{
+				Id:     dtoGuild.ID,
+				Name:   dtoGuild.Name,
+				Avatar: dtoGuild.Icon,
+			}
+			entitycache.SetGuild(response.Data[i])
 		}
 
 		return response, nil
@@ -3,6 +3,7 @@ package httpapi
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/gin-gonic/gin"
@@ -10,10 +11,13 @@ import (
 	"github.com/satori-protocol-go/satori-model-go/pkg/user"
 	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/openapi"
+
+	"github.com/WindowsSov8forUs/glyccat/database"
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
 )
 
 func init() {
-	RegisterHandler("guild.member.get", HandleGuildMemberGet)
+	RegisterHandler("guild.member.get", HandleGuildMemberGet, "qq", "qqguild")
 }
 
 // RequestGuildMemberGet 获取群组成员请求
@@ -25,6 +29,12 @@ type RequestGuildMemberGet struct {
 // ResponseGuildMemberGet 获取群组成员响应
 type ResponseGuildMemberGet guildmember.GuildMember
 
+// ResponsePartialGuildMemberGet 根据用户名册获取的群组成员响应，只包含部分信息
+type ResponsePartialGuildMemberGet struct {
+	ResponseGuildMemberGet
+	Partial bool `json:"_partial"` // 成员信息是否不完整
+}
+
 // HandleGuildMemberGet 处理获取群组成员请求
 func HandleGuildMemberGet(api, apiv2 openapi.OpenAPI, message *ActionMessage) (any, APIError) {
 	var request RequestGuildMemberGet
@@ -34,6 +44,11 @@ func HandleGuildMemberGet(api, apiv2 openapi.OpenAPI, message *ActionMessage) (a
 	}
 
 	if message.Platform == "qqguild" {
+		// 优先使用实体缓存
+		if cached, ok := entitycache.GetMember(request.GuildId, request.UserId); ok {
+			return ResponseGuildMemberGet(*cached), nil
+		}
+
 		var response ResponseGuildMemberGet
 
 		var dtoMember *dto.Member
@@ -47,14 +62,41 @@ func HandleGuildMemberGet(api, apiv2 openapi.OpenAPI, message *ActionMessage) (a
 		if err != nil {
 			return gin.H{}, &InternalServerError{err}
 		}
+		entitycache.SetMember(request.GuildId, &guildMember)
 		response = ResponseGuildMemberGet(guildMember)
 
 		return response, nil
+
+	} else if message.Platform == "qq" {
+		// 只是通过用户名册模拟罢了
+		member, err := database.GetGroupMember(request.GuildId, request.UserId)
+		if errors.Is(err, database.ErrMemberNotFound) {
+			return gin.H{}, &BadRequestError{err}
+		}
+		if err != nil {
+			return gin.H{}, &InternalServerError{err}
+		}
+
+		return ResponsePartialGuildMemberGet{
+			ResponseGuildMemberGet: ResponseGuildMemberGet(*convertRosterMember(member)),
+			Partial:                true,
+		}, nil
 	}
 
 	return defaultResource(message)
 }
 
+// convertRosterMember 将用户名册中的成员转换为 guildmember.GuildMember
+func convertRosterMember(member *database.RosterMember) *guildmember.GuildMember {
+	return &guildmember.GuildMember{
+		User: &user.User{
+			Id:     member.UserId,
+			Avatar: member.Avatar,
+		},
+		Avatar: member.Avatar,
+	}
+}
+
 // convertDtoMemberToGuildMember 将 dto.Member 转换为 guildmember.GuildMember
 func convertDtoMemberToGuildMember(dtoMember *dto.Member) (guildmember.GuildMember, error) {
 	var guildMember guildmember.GuildMember
@@ -7,10 +7,12 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/openapi"
+
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
 )
 
 func init() {
-	RegisterHandler("guild.member.kick", HandleGuildMemberKick)
+	RegisterHandler("guild.member.kick", HandleGuildMemberKick, "qqguild")
 }
 
 // RequestGuildMemberKick 踢出群组成员请求
@@ -38,6 +40,7 @@ func HandleGuildMemberKick(api, apiv2 openapi.OpenAPI, message *ActionMessage) (
 		if err != nil {
 			return gin.H{}, &InternalServerError{err}
 		}
+		entitycache.DeleteMember(request.GuildId, request.UserId)
 
 		return gin.H{}, nil
 	}
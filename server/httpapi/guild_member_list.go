@@ -8,10 +8,13 @@ import (
 	"github.com/satori-protocol-go/satori-model-go/pkg/guildmember"
 	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/openapi"
+
+	"github.com/WindowsSov8forUs/glyccat/database"
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
 )
 
 func init() {
-	RegisterHandler("guild.member.list", HandleGuildMemberList)
+	RegisterHandler("guild.member.list", HandleGuildMemberList, "qq", "qqguild")
 }
 
 // RequestGuildMemberList 获取群组成员列表请求
@@ -23,6 +26,12 @@ type RequestGuildMemberList struct {
 // ResponseGuildMemberList 获取群组成员列表响应
 type ResponseGuildMemberList guildmember.GuildMemberList
 
+// ResponsePartialGuildMemberList 根据用户名册获取的群组成员列表响应，只包含曾经出现过的成员
+type ResponsePartialGuildMemberList struct {
+	ResponseGuildMemberList
+	Partial bool `json:"_partial"` // 成员列表是否不完整
+}
+
 // HandleGuildMemberList 处理获取群组成员列表请求
 func HandleGuildMemberList(api, apiv2 openapi.OpenAPI, message *ActionMessage) (any, APIError) {
 	var request RequestGuildMemberList
@@ -40,7 +49,9 @@ func HandleGuildMemberList(api, apiv2 openapi.OpenAPI, message *ActionMessage) (
 			return gin.H{}, &InternalServerError{err}
 		}
 
-		response.Next = dtoMembers[len(dtoMembers)-1].User.ID
+		if len(dtoMembers) > 0 {
+			response.Next = dtoMembers[len(dtoMembers)-1].User.ID
+		}
 
 		for _, dtoMember := range dtoMembers {
 			// 将 dto.Member 转换为 guildmember.GuildMember
@@ -49,15 +60,35 @@ func HandleGuildMemberList(api, apiv2 openapi.OpenAPI, message *ActionMessage) (
 				return gin.H{}, &InternalServerError{err}
 			}
 
+			entitycache.SetMember(request.GuildId, &guildMember)
 			response.Data = append(response.Data, &guildMember)
 		}
 
+		return response, nil
+
+	} else if message.Platform == "qq" {
+		// 只是通过用户名册模拟罢了
+		response := ResponsePartialGuildMemberList{Partial: true}
+
+		members, next, err := database.ListGroupMembers(request.GuildId, request.Next, rosterPageSize)
+		if err != nil {
+			return gin.H{}, &InternalServerError{err}
+		}
+		response.Next = next
+		response.Data = make([]*guildmember.GuildMember, 0, len(members))
+		for _, member := range members {
+			response.Data = append(response.Data, convertRosterMember(member))
+		}
+
 		return response, nil
 	}
 
 	return defaultResource(message)
 }
 
+// rosterPageSize 用户名册分页大小
+const rosterPageSize = 50
+
 // createGuildMembersPager 构建频道成员列表查询参数
 func createGuildMembersPager(next string) *dto.GuildMembersPager {
 	return &dto.GuildMembersPager{
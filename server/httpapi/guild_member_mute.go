@@ -11,7 +11,7 @@ import (
 )
 
 func init() {
-	RegisterHandler("guild.member.mute", HandleGuildMemberMute)
+	RegisterHandler("guild.member.mute", HandleGuildMemberMute, "qqguild")
 }
 
 // RequestGuildMemberMute 禁言群组成员请求
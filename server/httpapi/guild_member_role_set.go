@@ -10,7 +10,7 @@ import (
 )
 
 func init() {
-	RegisterHandler("guild.member.role.set", HandleGuildMemberRoleSet)
+	RegisterHandler("guild.member.role.set", HandleGuildMemberRoleSet, "qqguild")
 }
 
 // RequestGuildMemberRoleSet 设置群组成员角色请求
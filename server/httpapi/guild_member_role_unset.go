@@ -10,7 +10,7 @@ import (
 )
 
 func init() {
-	RegisterHandler("guild.member.role.unset", HandleGuildMemberRoleUnset)
+	RegisterHandler("guild.member.role.unset", HandleGuildMemberRoleUnset, "qqguild")
 }
 
 // RequestGuildMemberRoleUnset 取消群组成员角色请求
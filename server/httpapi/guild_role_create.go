@@ -8,10 +8,12 @@ import (
 	"github.com/satori-protocol-go/satori-model-go/pkg/guildrole"
 	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/openapi"
+
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
 )
 
 func init() {
-	RegisterHandler("guild.role.create", HandleGuildRoleCreate)
+	RegisterHandler("guild.role.create", HandleGuildRoleCreate, "qqguild")
 }
 
 // RequestGuildRoleCreate 创建群组角色请求
@@ -49,6 +51,7 @@ func HandleGuildRoleCreate(api, apiv2 openapi.OpenAPI, message *ActionMessage) (
 			return gin.H{}, &InternalServerError{err}
 		}
 
+		entitycache.InvalidateRoles(request.GuildId)
 		response = ResponseGuildRoleCreate(guildRole)
 
 		return response, nil
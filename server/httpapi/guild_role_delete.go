@@ -7,10 +7,12 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/openapi"
+
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
 )
 
 func init() {
-	RegisterHandler("guild.role.delete", HandleGuildRoleDelete)
+	RegisterHandler("guild.role.delete", HandleGuildRoleDelete, "qqguild")
 }
 
 // RequestGuildRoleDelete 删除群组角色请求
@@ -32,6 +34,7 @@ func HandleGuildRoleDelete(api, apiv2 openapi.OpenAPI, message *ActionMessage) (
 		if err != nil {
 			return gin.H{}, &InternalServerError{err}
 		}
+		entitycache.InvalidateRoles(request.GuildId)
 
 		return gin.H{}, nil
 	}
@@ -8,10 +8,12 @@ import (
 	"github.com/satori-protocol-go/satori-model-go/pkg/guildrole"
 	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/openapi"
+
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
 )
 
 func init() {
-	RegisterHandler("guild.role.list", HandleGuildRoleList)
+	RegisterHandler("guild.role.list", HandleGuildRoleList, "qqguild")
 }
 
 // RequestGuildRoleList 获取群组角色列表请求
@@ -34,6 +36,12 @@ func HandleGuildRoleList(api, apiv2 openapi.OpenAPI, message *ActionMessage) (an
 	if message.Platform == "qqguild" {
 		var response ResponseGuildRoleList
 
+		// 优先使用实体缓存
+		if cached, ok := entitycache.GetRoles(request.GuildId); ok {
+			response.Data = cached
+			return response, nil
+		}
+
 		dtoGuildRoles, err := apiv2.Roles(context.TODO(), request.GuildId)
 		if err != nil {
 			return gin.H{}, &InternalServerError{err}
@@ -46,6 +54,7 @@ func HandleGuildRoleList(api, apiv2 openapi.OpenAPI, message *ActionMessage) (an
 			}
 			response.Data = append(response.Data, &guildRole)
 		}
+		entitycache.SetRoles(request.GuildId, response.Data)
 
 		return response, nil
 	}
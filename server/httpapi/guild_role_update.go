@@ -9,10 +9,12 @@ import (
 	"github.com/satori-protocol-go/satori-model-go/pkg/guildrole"
 	"github.com/tencent-connect/botgo/dto"
 	"github.com/tencent-connect/botgo/openapi"
+
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
 )
 
 func init() {
-	RegisterHandler("guild.role.update", HandleGuildRoleUpdate)
+	RegisterHandler("guild.role.update", HandleGuildRoleUpdate, "qqguild")
 }
 
 // RequestGuildRoleUpdate 修改群组角色请求
@@ -40,6 +42,7 @@ func HandleGuildRoleUpdate(api, apiv2 openapi.OpenAPI, message *ActionMessage) (
 		if err != nil {
 			return gin.H{}, &InternalServerError{err}
 		}
+		entitycache.InvalidateRoles(request.GuildId)
 
 		return gin.H{}, nil
 	}
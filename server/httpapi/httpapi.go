@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"github.com/WindowsSov8forUs/glyccat/config"
 	"github.com/WindowsSov8forUs/glyccat/fileserver"
 	"github.com/WindowsSov8forUs/glyccat/processor"
+	"github.com/WindowsSov8forUs/glyccat/proxy"
 	"github.com/WindowsSov8forUs/glyccat/version"
 	"github.com/gin-gonic/gin"
 	"github.com/satori-protocol-go/satori-model-go/pkg/user"
@@ -101,12 +103,18 @@ func (e *ForbiddenError) Code() int {
 }
 
 // NotFoundError 资源不存在
+//
+// message 不为空时表示接口所请求的资源不存在
 type NotFoundError struct {
 	api      string
 	platform string
+	message  string
 }
 
 func (e *NotFoundError) Error() string {
+	if e.message != "" {
+		return e.message
+	}
 	if e.platform == "" {
 		return fmt.Sprintf(`api "%s" not found`, e.api)
 	} else {
@@ -144,6 +152,19 @@ func (e *InternalServerError) Code() int {
 	return http.StatusInternalServerError
 }
 
+// ServiceUnavailableError 服务暂不可用
+type ServiceUnavailableError struct {
+	err error
+}
+
+func (e *ServiceUnavailableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ServiceUnavailableError) Code() int {
+	return http.StatusServiceUnavailable
+}
+
 // ActionMessage Satori 应用发送的 HTTP API 调用信息
 type ActionMessage struct {
 	API      string       // 接口
@@ -207,16 +228,22 @@ type HandlerFunc func(api, apiV2 openapi.OpenAPI, action *ActionMessage) (any, A
 type MetaHandlerFunc func(action *MetaActionMessage) (any, APIError)
 
 var handlers = make(map[string]HandlerFunc)
+var handlerPlatforms = make(map[string]map[string]bool)
 var metaHandlers = make(map[string]MetaHandlerFunc)
 
 // defaultResource 资源默认处理函数
 func defaultResource(action *ActionMessage) (any, APIError) {
-	return gin.H{}, &NotFoundError{action.API, action.Platform}
+	return gin.H{}, &NotFoundError{api: action.API, platform: action.Platform}
 }
 
-// RegisterHandler 注册特定资源与方法的处理函数
-func RegisterHandler(api string, handler HandlerFunc) {
+// RegisterHandler 注册特定资源与方法的处理函数，并将其登记为所支持平台的特性
+func RegisterHandler(api string, handler HandlerFunc, platforms ...string) {
 	handlers[api] = handler
+	handlerPlatforms[api] = make(map[string]bool)
+	for _, platform := range platforms {
+		handlerPlatforms[api][platform] = true
+		processor.RegisterFeature(platform, api)
+	}
 }
 
 // RegisterMetaHandler 注册元信息接口的处理函数
@@ -232,6 +259,10 @@ func CallAPI(api, apiV2 openapi.OpenAPI, action *ActionMessage) (any, APIError)
 	if _, ok := handlers[action.API]; !ok {
 		return gin.H{}, &NotFoundError{api: action.API}
 	}
+	// 平台不支持的 API 直接返回，不调用开放平台接口
+	if !handlerPlatforms[action.API][action.Platform] {
+		return gin.H{}, &NotFoundError{api: action.API, platform: action.Platform}
+	}
 	return handlers[action.API](api, apiV2, action)
 }
 
@@ -384,15 +415,19 @@ func ProxyValidateMiddleware() gin.HandlerFunc {
 func ResourceMiddleware(api, apiV2 openapi.OpenAPI) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// 在内部进行判断处理
-		resourceAPIHandler(ctx, api, apiV2)
+		resourceAPIHandler(ctx, ctx.Param("method"), api, apiV2)
 	}
 }
 
-// resourceAPIHandler 处理资源 API
-func resourceAPIHandler(c *gin.Context, api, apiV2 openapi.OpenAPI) {
-	// 提取路径中参数
-	method := c.Param("method")
+// ExtensionMiddleware 扩展接口中间件，扩展接口以 prefix/ 为前缀注册
+func ExtensionMiddleware(prefix string, api, apiV2 openapi.OpenAPI) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		resourceAPIHandler(ctx, prefix+"/"+ctx.Param("method"), api, apiV2)
+	}
+}
 
+// resourceAPIHandler 处理资源 API
+func resourceAPIHandler(c *gin.Context, method string, api, apiV2 openapi.OpenAPI) {
 	// 获取 bot 对象
 	satoriPlatform := c.GetHeader("Satori-Platform")
 	bot := processor.GetBot(satoriPlatform)
@@ -403,22 +438,8 @@ func resourceAPIHandler(c *gin.Context, api, apiV2 openapi.OpenAPI) {
 	// 调用 API
 	response, err := CallAPI(api, apiV2, actionMessage)
 	if err != nil {
-		switch err.(type) {
-		case *BadRequestError:
-			c.String(http.StatusBadRequest, err.Error())
-		case *UnauthorizedError:
-			c.String(http.StatusUnauthorized, err.Error())
-		case *ForbiddenError:
-			c.String(http.StatusForbidden, err.Error())
-		case *NotFoundError:
-			c.String(http.StatusNotFound, err.Error())
-		case *MethodNotAllowedError:
-			c.String(http.StatusMethodNotAllowed, err.Error())
-		case *InternalServerError:
-			c.String(http.StatusInternalServerError, err.Error())
-		default:
-			c.String(http.StatusInternalServerError, err.Error())
-		}
+		// 各错误类型自带对应的 HTTP 状态码
+		c.String(err.Code(), err.Error())
 	} else {
 		// 返回结果
 		c.JSON(http.StatusOK, response)
@@ -444,22 +465,8 @@ func metaAPIHandler(c *gin.Context) {
 	// 调用 API
 	response, err := CallMetaAPI(metaActionMessage)
 	if err != nil {
-		switch err.(type) {
-		case *BadRequestError:
-			c.String(http.StatusBadRequest, err.Error())
-		case *UnauthorizedError:
-			c.String(http.StatusUnauthorized, err.Error())
-		case *ForbiddenError:
-			c.String(http.StatusForbidden, err.Error())
-		case *NotFoundError:
-			c.String(http.StatusNotFound, err.Error())
-		case *MethodNotAllowedError:
-			c.String(http.StatusMethodNotAllowed, err.Error())
-		case *InternalServerError:
-			c.String(http.StatusInternalServerError, err.Error())
-		default:
-			c.String(http.StatusInternalServerError, err.Error())
-		}
+		// 各错误类型自带对应的 HTTP 状态码
+		c.String(err.Code(), err.Error())
 	} else {
 		// 返回结果
 		c.JSON(http.StatusOK, response)
@@ -483,19 +490,74 @@ func proxyHandler(c *gin.Context, satoriVersion string) {
 
 	// 解析内部链接
 	if _, _, path, ok := fileserver.ParseInternalURL(urlParam); ok {
-		if filePath, err := fileserver.GetPath(path); err == nil {
-
-			// 设置响应头
-			c.Header("Date", time.Now().Format(time.RFC1123))
-			c.Header("Server", fmt.Sprintf("GlycCat/%s", version.Version))
-			c.Header("X-Satori-Protocol", satoriVersion)
+		// 带签名的链接无需鉴权，否则需要 Satori 鉴权令牌
+		err := fileserver.VerifySignature(urlParam, c.Query("expires"), c.Query("signature"))
+		if err == fileserver.ErrSignatureMissing {
+			if ok, _ := authorize(c.GetHeader("Authorization")); !ok {
+				c.String(http.StatusUnauthorized, "unauthorized")
+				return
+			}
+		} else if err != nil {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
 
-			// 设置文件路径
-			c.File(filePath)
+		meta, err := fileserver.GetFileByPath(path)
+		if err != nil {
+			c.String(http.StatusNotFound, "file not found")
+			return
+		}
 
+		// 存储后端支持直链时直接重定向
+		if presigned, err := fileserver.PresignedURL(meta); err == nil {
+			c.Redirect(http.StatusFound, presigned)
 			return
 		}
+		serveFile(c, meta, satoriVersion)
+		return
 	}
+
+	// 代理外部链接，原链接中的查询参数会被 gin 解析为代理请求的查询参数
+	if c.Request.URL.RawQuery != "" {
+		urlParam += "?" + c.Request.URL.RawQuery
+	}
+	proxy.Serve(c.Writer, c.Request, urlParam, satoriVersion)
+}
+
+// serveFile 发送文件，支持 Range 与条件请求
+func serveFile(c *gin.Context, meta *fileserver.FileMetadata, satoriVersion string) {
+	file, err := fileserver.OpenFile(meta)
+	if err != nil {
+		c.String(http.StatusNotFound, "file not found")
+		return
+	}
+	defer file.Close()
+
+	// 设置响应头
+	c.Header("Date", time.Now().Format(time.RFC1123))
+	c.Header("Server", fmt.Sprintf("GlycCat/%s", version.Version))
+	c.Header("X-Satori-Protocol", satoriVersion)
+
+	// 文件标识符由文件内容计算得到，可以直接作为 ETag
+	c.Header("ETag", `"`+meta.ID+`"`)
+
+	// 仅允许展示媒体文件，其余文件作为附件下载，防止上传的 HTML 等文件在本站执行脚本
+	contentType, dispositionType := "application/octet-stream", "attachment"
+	if fileserver.IsInlineContentType(meta.ContentType) {
+		contentType, dispositionType = meta.ContentType, "inline"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	disposition := dispositionType
+	if meta.Name != "" {
+		// 非 ASCII 文件名会以 RFC 2231 格式编码
+		if formatted := mime.FormatMediaType(dispositionType, map[string]string{"filename": meta.Name}); formatted != "" {
+			disposition = formatted
+		}
+	}
+	c.Header("Content-Disposition", disposition)
+
+	http.ServeContent(c.Writer, c.Request, meta.Name, time.Unix(int64(meta.CreateAt), 0), file)
 }
 
 // authorize 鉴权
@@ -0,0 +1,81 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tencent-connect/botgo/openapi"
+)
+
+// inTempDir 在临时目录中执行 open ，用于打开使用相对路径的数据库
+func inTempDir(t *testing.T, open func() error) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := open(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestContext 创建携带给定平台请求头的测试上下文
+func newTestContext(platform string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/test", nil)
+	c.Request.Header.Set("Satori-Platform", platform)
+	return c, recorder
+}
+
+func TestAPIErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  APIError
+		want int
+	}{
+		{"bad request", &BadRequestError{errors.New("bad")}, http.StatusBadRequest},
+		{"forbidden", &ForbiddenError{"forbidden"}, http.StatusForbidden},
+		{"not found", &NotFoundError{api: "test.api"}, http.StatusNotFound},
+		{"internal", &InternalServerError{errors.New("internal")}, http.StatusInternalServerError},
+		{"unavailable", &ServiceUnavailableError{errors.New("unavailable")}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.err
+			RegisterHandler("test.error", func(api, apiV2 openapi.OpenAPI, action *ActionMessage) (any, APIError) {
+				return nil, err
+			}, "qq")
+			RegisterMetaHandler("test.error", func(action *MetaActionMessage) (any, APIError) {
+				return nil, err
+			})
+			t.Cleanup(func() {
+				delete(handlers, "test.error")
+				delete(handlerPlatforms, "test.error")
+				delete(metaHandlers, "meta/test.error")
+			})
+
+			c, recorder := newTestContext("qq")
+			resourceAPIHandler(c, "test.error", nil, nil)
+			if recorder.Code != tt.want {
+				t.Errorf("resourceAPIHandler() status = %d, want %d", recorder.Code, tt.want)
+			}
+
+			c, recorder = newTestContext("")
+			c.Params = gin.Params{{Key: "method", Value: "/test.error"}}
+			metaAPIHandler(c)
+			if recorder.Code != tt.want {
+				t.Errorf("metaAPIHandler() status = %d, want %d", recorder.Code, tt.want)
+			}
+		})
+	}
+}
@@ -13,7 +13,7 @@ import (
 )
 
 func init() {
-	RegisterHandler("login.get", HandleLoginGet)
+	RegisterHandler("login.get", HandleLoginGet, "qq", "qqguild")
 }
 
 // ResponseLoginGet 获取登录信息响应
@@ -39,7 +39,10 @@ func HandleLoginGet(api, apiv2 openapi.OpenAPI, message *ActionMessage) (any, AP
 		Avatar: me.Avatar,
 		IsBot:  me.Bot,
 	}
-	processor.SetBot(message.Platform, bot)
+	if old := processor.GetBot(message.Platform); old == nil || *old != *bot {
+		processor.SetBot(message.Platform, bot)
+		processor.NotifyMetaUpdated()
+	}
 
 	// 获取机器人状态
 	status := processor.GetStatus(message.Platform)
@@ -49,7 +52,7 @@ func HandleLoginGet(api, apiv2 openapi.OpenAPI, message *ActionMessage) (any, AP
 	response.User = bot
 	response.Status = status
 	response.Adapter = "GlycCat"
-	response.Features = processor.Features()
+	response.Features = processor.Features(message.Platform)
 
 	return response, nil
 }
@@ -3,13 +3,16 @@ package httpapi
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 
+	"github.com/WindowsSov8forUs/glyccat/database"
 	"github.com/WindowsSov8forUs/glyccat/fileserver"
 	"github.com/WindowsSov8forUs/glyccat/log"
 	"github.com/WindowsSov8forUs/glyccat/processor"
+	"github.com/WindowsSov8forUs/glyccat/transcoder"
 	"github.com/gin-gonic/gin"
 
 	"github.com/satori-protocol-go/satori-model-go/pkg/channel"
@@ -23,7 +26,7 @@ import (
 )
 
 func init() {
-	RegisterHandler("message.create", HandleMessageCreate)
+	RegisterHandler("message.create", HandleMessageCreate, "qq", "qqguild")
 }
 
 // RequestMessageCreate 发送消息请求
@@ -55,7 +58,7 @@ func HandleMessageCreate(api, apiv2 openapi.OpenAPI, message *ActionMessage) (an
 			var dtoMessageToCreate = &dto.MessageToCreate{}
 			dtoMessageToCreate, err = convertToMessageToCreate(request.Content, message.Bot.Id, true)
 			if err != nil {
-				return gin.H{}, &InternalServerError{err}
+				return gin.H{}, convertMessageError(err)
 			}
 			var dtoMessage *dto.Message
 			dtoMessage, err = api.PostMessage(context.TODO(), request.ChannelId, dtoMessageToCreate)
@@ -66,6 +69,7 @@ func HandleMessageCreate(api, apiv2 openapi.OpenAPI, message *ActionMessage) (an
 			if err != nil {
 				return gin.H{}, &InternalServerError{err}
 			}
+			storeSentMessage(messageResponse, message.Platform, request.ChannelId, request.Content, message.Bot)
 			response = append(response, *messageResponse)
 		} else {
 			// 输出日志
@@ -75,7 +79,7 @@ func HandleMessageCreate(api, apiv2 openapi.OpenAPI, message *ActionMessage) (an
 			var dtoDirectMessage = &dto.DirectMessage{}
 			dtoMessageToCreate, err = convertToMessageToCreate(request.Content, message.Bot.Id, false)
 			if err != nil {
-				return gin.H{}, &InternalServerError{err}
+				return gin.H{}, convertMessageError(err)
 			}
 			dtoDirectMessage.ChannelID = request.ChannelId
 			dtoDirectMessage.GuildID = guildId
@@ -88,6 +92,7 @@ func HandleMessageCreate(api, apiv2 openapi.OpenAPI, message *ActionMessage) (an
 			if err != nil {
 				return gin.H{}, &InternalServerError{err}
 			}
+			storeSentMessage(messageResponse, message.Platform, request.ChannelId, request.Content, message.Bot)
 			response = append(response, *messageResponse)
 		}
 
@@ -103,9 +108,9 @@ func HandleMessageCreate(api, apiv2 openapi.OpenAPI, message *ActionMessage) (an
 
 			// 是私聊频道
 			var dtoMessageToCreate = &dto.MessageToCreate{}
-			dtoMessageToCreate, err = convertToMessageToCreateV2(request.Content, request.ChannelId, openIdType, apiv2)
+			dtoMessageToCreate, err = convertToMessageToCreateV2(message.Ctx.Request.Context(), request.Content, request.ChannelId, openIdType, apiv2)
 			if err != nil {
-				return gin.H{}, &InternalServerError{err}
+				return gin.H{}, convertMessageError(err)
 			}
 			var dtoC2CMessageResponse *dto.C2CMessageResponse
 			dtoC2CMessageResponse, err = api.PostC2CMessage(context.TODO(), request.ChannelId, dtoMessageToCreate)
@@ -116,6 +121,7 @@ func HandleMessageCreate(api, apiv2 openapi.OpenAPI, message *ActionMessage) (an
 			if err != nil {
 				return gin.H{}, &InternalServerError{err}
 			}
+			storeSentMessage(messageResponse, message.Platform, request.ChannelId, request.Content, message.Bot)
 			response = append(response, *messageResponse)
 		} else {
 			// 是群聊频道
@@ -125,9 +131,9 @@ func HandleMessageCreate(api, apiv2 openapi.OpenAPI, message *ActionMessage) (an
 			log.Infof("发送消息到群 %s : %s", request.ChannelId, logContent(request.Content))
 
 			var dtoMessageToCreate = &dto.MessageToCreate{}
-			dtoMessageToCreate, err = convertToMessageToCreateV2(request.Content, request.ChannelId, openIdType, apiv2)
+			dtoMessageToCreate, err = convertToMessageToCreateV2(message.Ctx.Request.Context(), request.Content, request.ChannelId, openIdType, apiv2)
 			if err != nil {
-				return gin.H{}, &InternalServerError{err}
+				return gin.H{}, convertMessageError(err)
 			}
 			var dtoGroupMessageResponse *dto.GroupMessageResponse
 			dtoGroupMessageResponse, err = api.PostGroupMessage(context.TODO(), request.ChannelId, dtoMessageToCreate)
@@ -138,6 +144,7 @@ func HandleMessageCreate(api, apiv2 openapi.OpenAPI, message *ActionMessage) (an
 			if err != nil {
 				return gin.H{}, &InternalServerError{err}
 			}
+			storeSentMessage(messageResponse, message.Platform, request.ChannelId, request.Content, message.Bot)
 			response = append(response, *messageResponse)
 		}
 
@@ -147,6 +154,47 @@ func HandleMessageCreate(api, apiv2 openapi.OpenAPI, message *ActionMessage) (an
 	return defaultResource(message)
 }
 
+// storeSentMessage 将机器人发送的消息存入消息数据库
+//
+// 群聊与单聊的发送响应中只有消息 ID 与时间，频道与发送者信息由请求补全
+func storeSentMessage(sent *satoriMessage.Message, platform, channelId, content string, bot *user.User) {
+	channelType := processor.GetChannelType(platform, channelId)
+
+	messageToSave := *sent
+	if messageToSave.Content == "" {
+		messageToSave.Content = content
+	}
+	if messageToSave.Channel == nil {
+		messageToSave.Channel = &channel.Channel{Id: channelId}
+		if channelType == database.ChannelTypePrivate {
+			messageToSave.Channel.Type = channel.ChannelTypeDirect
+		} else {
+			messageToSave.Channel.Type = channel.ChannelTypeText
+			messageToSave.Guild = &guild.Guild{Id: channelId}
+		}
+	}
+	if messageToSave.User == nil {
+		messageToSave.User = bot
+	}
+	processor.StoreMessage(&messageToSave, channelType)
+}
+
+// convertMessageError 将消息转换错误转化为 API 错误
+func convertMessageError(err error) APIError {
+	if errors.Is(err, processor.ErrLocalFileForbidden) {
+		return &ForbiddenError{err.Error()}
+	}
+	// 输入格式不受支持时视为请求错误
+	if errors.Is(err, transcoder.ErrUnsupportedInput) {
+		return &BadRequestError{err}
+	}
+	// 缺少转码工具或转码队列已满时视为服务暂不可用
+	if errors.Is(err, transcoder.ErrUnavailable) {
+		return &ServiceUnavailableError{err}
+	}
+	return &InternalServerError{err}
+}
+
 // logContent 将内容处理为输出内容
 func logContent(content string) string {
 	if len(content) > 50 {
@@ -206,13 +254,16 @@ func parseElementsInMessageToCreate(elements []satoriMessage.MessageElement, dto
 
 			url, file, err := processor.ParseSrc(e.Src)
 			if err != nil {
+				if errors.Is(err, processor.ErrLocalFileForbidden) {
+					return err
+				}
 				log.Warnf("解析图片 src 失败: %s", err)
 				continue
 			}
 
-			// 如果是 URL 则直接放入
+			// 如果是 URL 则直接放入，文件服务器链接需要带有签名
 			if url != "" {
-				dtoMessageToCreate.Image = url
+				dtoMessageToCreate.Image = fileserver.SignURL(url)
 			} else if file != nil {
 				// 保存至文件服务器并放入资源链接
 				fileReader, err := file.GetReader()
@@ -232,7 +283,7 @@ func parseElementsInMessageToCreate(elements []satoriMessage.MessageElement, dto
 				if e.Cache {
 					meta, err := fileserver.GetFile(ident)
 					if err == nil {
-						dtoMessageToCreate.Image = fileserver.InternalURL(meta)
+						dtoMessageToCreate.Image = fileserver.PublicURL(meta)
 						continue
 					}
 				}
@@ -248,7 +299,7 @@ func parseElementsInMessageToCreate(elements []satoriMessage.MessageElement, dto
 					log.Warnf("保存图片文件失败: %s", err)
 					continue
 				}
-				dtoMessageToCreate.Image = fileserver.InternalURL(meta)
+				dtoMessageToCreate.Image = fileserver.PublicURL(meta)
 			} else {
 				log.Warnf("图片元素没有有效的 src 或文件")
 			}
@@ -264,38 +315,58 @@ func parseElementsInMessageToCreate(elements []satoriMessage.MessageElement, dto
 		// TODO: 修饰元素全部视为子元素集合，或许可以变成 dto.markdown ？
 		case *satoriMessage.MessageElementStrong:
 			// 递归调用
-			parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId)
+			if err := parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElementEm:
 			// 递归调用
-			parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId)
+			if err := parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElementIns:
 			// 递归调用
-			parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId)
+			if err := parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElementDel:
 			// 递归调用
-			parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId)
+			if err := parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElementSpl:
 			// 递归调用
-			parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId)
+			if err := parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElementCode:
 			// 递归调用
-			parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId)
+			if err := parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElementSup:
 			// 递归调用
-			parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId)
+			if err := parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElementSub:
 			// 递归调用
-			parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId)
+			if err := parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElmentBr:
 			dtoMessageToCreate.Content += "\n"
 		case *satoriMessage.MessageElmentP:
 			dtoMessageToCreate.Content += "\n"
 			// 视为子元素集合
-			parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId)
+			if err := parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId); err != nil {
+				return err
+			}
 			dtoMessageToCreate.Content += "\n"
 		case *satoriMessage.MessageElementMessage:
 			// 视为子元素集合，目前不支持视为转发消息
-			parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId)
+			if err := parseElementsInMessageToCreate(e.GetChildren(), dtoMessageToCreate, isGuild, userId); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElementQuote:
 			// 遍历子元素，只会处理第一个 satoriMessage.MessageElementMessage 元素
 			for _, child := range e.GetChildren() {
@@ -334,7 +405,7 @@ func parseElementsInMessageToCreate(elements []satoriMessage.MessageElement, dto
 }
 
 // convertToMessageToCreateV2 转换为 V2 消息体结构
-func convertToMessageToCreateV2(content string, openId string, messageType string, apiv2 openapi.OpenAPI) (*dto.MessageToCreate, error) {
+func convertToMessageToCreateV2(ctx context.Context, content string, openId string, messageType string, apiv2 openapi.OpenAPI) (*dto.MessageToCreate, error) {
 	// 将文本消息内容转换为 satoriMessage.MessageElement
 	elements, err := satoriMessage.Parse(content)
 	if err != nil {
@@ -343,7 +414,7 @@ func convertToMessageToCreateV2(content string, openId string, messageType strin
 
 	// 处理 satoriMessage.MessageElement
 	var dtoMessageToCreate = &dto.MessageToCreate{}
-	err = parseElementsInMessageToCreateV2(elements, dtoMessageToCreate, openId, messageType, apiv2)
+	err = parseElementsInMessageToCreateV2(ctx, elements, dtoMessageToCreate, openId, messageType, apiv2)
 	if err != nil {
 		return nil, err
 	}
@@ -352,7 +423,7 @@ func convertToMessageToCreateV2(content string, openId string, messageType strin
 }
 
 // parseElementsInMessageToCreateV2 将 Satori 消息元素转换为 V2 消息体结构
-func parseElementsInMessageToCreateV2(elements []satoriMessage.MessageElement, dtoMessageToCreate *dto.MessageToCreate, openId, messageType string, apiv2 openapi.OpenAPI) error {
+func parseElementsInMessageToCreateV2(ctx context.Context, elements []satoriMessage.MessageElement, dtoMessageToCreate *dto.MessageToCreate, openId, messageType string, apiv2 openapi.OpenAPI) error {
 	// 处理 satoriMessage.MessageElement
 	for _, element := range elements {
 		// 根据元素类型进行处理
@@ -384,7 +455,7 @@ func parseElementsInMessageToCreateV2(elements []satoriMessage.MessageElement, d
 				continue
 			}
 
-			if err := parseResourceElementInMTCV2(e, dtoMessageToCreate, openId, messageType, apiv2); err != nil {
+			if err := parseResourceElementInMTCV2(ctx, e, dtoMessageToCreate, openId, messageType, apiv2); err != nil {
 				return err
 			}
 		case *satoriMessage.MessageElementAudio:
@@ -393,7 +464,7 @@ func parseElementsInMessageToCreateV2(elements []satoriMessage.MessageElement, d
 				continue
 			}
 
-			if err := parseResourceElementInMTCV2(e, dtoMessageToCreate, openId, messageType, apiv2); err != nil {
+			if err := parseResourceElementInMTCV2(ctx, e, dtoMessageToCreate, openId, messageType, apiv2); err != nil {
 				return err
 			}
 		case *satoriMessage.MessageElementVideo:
@@ -402,7 +473,7 @@ func parseElementsInMessageToCreateV2(elements []satoriMessage.MessageElement, d
 				continue
 			}
 
-			if err := parseResourceElementInMTCV2(e, dtoMessageToCreate, openId, messageType, apiv2); err != nil {
+			if err := parseResourceElementInMTCV2(ctx, e, dtoMessageToCreate, openId, messageType, apiv2); err != nil {
 				return err
 			}
 		case *satoriMessage.MessageElementFile:
@@ -412,44 +483,64 @@ func parseElementsInMessageToCreateV2(elements []satoriMessage.MessageElement, d
 				continue
 			}
 
-			if err := parseResourceElementInMTCV2(e, dtoMessageToCreate, openId, messageType, apiv2); err != nil {
+			if err := parseResourceElementInMTCV2(ctx, e, dtoMessageToCreate, openId, messageType, apiv2); err != nil {
 				return err
 			}
 		// 修饰元素全部视为子元素集合，Markdown 是别想了
 		case *satoriMessage.MessageElementStrong:
 			// 递归调用
-			parseElementsInMessageToCreateV2(e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2)
+			if err := parseElementsInMessageToCreateV2(ctx, e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElementEm:
 			// 递归调用
-			parseElementsInMessageToCreateV2(e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2)
+			if err := parseElementsInMessageToCreateV2(ctx, e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElementIns:
 			// 递归调用
-			parseElementsInMessageToCreateV2(e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2)
+			if err := parseElementsInMessageToCreateV2(ctx, e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElementDel:
 			// 递归调用
-			parseElementsInMessageToCreateV2(e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2)
+			if err := parseElementsInMessageToCreateV2(ctx, e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElementSpl:
 			// 递归调用
-			parseElementsInMessageToCreateV2(e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2)
+			if err := parseElementsInMessageToCreateV2(ctx, e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElementCode:
 			// 递归调用
-			parseElementsInMessageToCreateV2(e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2)
+			if err := parseElementsInMessageToCreateV2(ctx, e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElementSup:
 			// 递归调用
-			parseElementsInMessageToCreateV2(e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2)
+			if err := parseElementsInMessageToCreateV2(ctx, e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElementSub:
 			// 递归调用
-			parseElementsInMessageToCreateV2(e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2)
+			if err := parseElementsInMessageToCreateV2(ctx, e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElmentBr:
 			dtoMessageToCreate.Content += "\n"
 		case *satoriMessage.MessageElmentP:
 			dtoMessageToCreate.Content += "\n"
 			// 视为子元素集合
-			parseElementsInMessageToCreateV2(e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2)
+			if err := parseElementsInMessageToCreateV2(ctx, e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2); err != nil {
+				return err
+			}
 			dtoMessageToCreate.Content += "\n"
 		case *satoriMessage.MessageElementMessage:
 			// 视为子元素集合，目前不支持视为转发消息
-			parseElementsInMessageToCreateV2(e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2)
+			if err := parseElementsInMessageToCreateV2(ctx, e.GetChildren(), dtoMessageToCreate, openId, messageType, apiv2); err != nil {
+				return err
+			}
 		case *satoriMessage.MessageElementQuote:
 			// 遍历子元素，只会处理第一个 satoriMessage.MessageElementMessage 元素
 			for _, child := range e.GetChildren() {
@@ -488,7 +579,7 @@ func parseElementsInMessageToCreateV2(elements []satoriMessage.MessageElement, d
 }
 
 // parseResourceElementInMTCV2 将 Satori 资源消息元素解析到 V2 消息体结构中
-func parseResourceElementInMTCV2(element satoriMessage.MessageElement, dtoMessageToCreate *dto.MessageToCreate, openId, messageType string, apiv2 openapi.OpenAPI) error {
+func parseResourceElementInMTCV2(ctx context.Context, element satoriMessage.MessageElement, dtoMessageToCreate *dto.MessageToCreate, openId, messageType string, apiv2 openapi.OpenAPI) error {
 	// TODO: 这里似乎应该将所有资源元素统一到一个子类型中，然后再细分
 	// TODO: 再说吧，需要改 satori-model-go 了
 
@@ -518,6 +609,9 @@ func parseResourceElementInMTCV2(element satoriMessage.MessageElement, dtoMessag
 	// 生成资源标识
 	srcId, err := processor.ParseSrcToString(src)
 	if err != nil {
+		if errors.Is(err, processor.ErrLocalFileForbidden) {
+			return err
+		}
 		log.Warnf("解析图片 src 失败: %s", err)
 		return nil
 	}
@@ -537,8 +631,12 @@ func parseResourceElementInMTCV2(element satoriMessage.MessageElement, dtoMessag
 	}
 
 	// 生成上传用富媒体结构
-	dtoRichMediaMessage, err := generateDtoRichMediaMessage(dtoMessageToCreate.MsgID, element)
+	dtoRichMediaMessage, err := generateDtoRichMediaMessage(ctx, dtoMessageToCreate.MsgID, element)
 	if err != nil {
+		var transcodeErr *transcoder.Error
+		if errors.As(err, &transcodeErr) || errors.Is(err, processor.ErrLocalFileForbidden) {
+			return err
+		}
 		log.Warnf("生成富媒体消息失败: %s", err)
 		return nil
 	}
@@ -750,13 +848,13 @@ func uploadMediaPrivate(ctx context.Context, userID string, richMediaMessage *dt
 }
 
 // generateDtoRichMediaMessage 创建 dto.RichMediaMessage
-func generateDtoRichMediaMessage(id string, element satoriMessage.MessageElement) (*dto.RichMediaMessage, error) {
+func generateDtoRichMediaMessage(ctx context.Context, id string, element satoriMessage.MessageElement) (*dto.RichMediaMessage, error) {
 	var dtoRichMediaMessage *dto.RichMediaMessage
 
 	// 根据 element 的类型来创建 dto.RichMediaMessage
 	switch e := element.(type) {
 	case *satoriMessage.MessageElementImg:
-		url, fileData, err := processor.ParseSrcToAvailavle(e.Src)
+		url, fileData, err := processor.ParseSrcToAvailavle(ctx, e.Src)
 		if err != nil {
 			return nil, err
 		}
@@ -768,7 +866,7 @@ func generateDtoRichMediaMessage(id string, element satoriMessage.MessageElement
 			SrvSendMsg: false,
 		}
 	case *satoriMessage.MessageElementVideo:
-		url, fileData, err := processor.ParseSrcToAvailavle(e.Src)
+		url, fileData, err := processor.ParseSrcToAvailavle(ctx, e.Src)
 		if err != nil {
 			return nil, err
 		}
@@ -780,7 +878,7 @@ func generateDtoRichMediaMessage(id string, element satoriMessage.MessageElement
 			SrvSendMsg: false,
 		}
 	case *satoriMessage.MessageElementAudio:
-		url, fileData, err := processor.ParseSrcToAvailavle(e.Src)
+		url, fileData, err := processor.ParseSrcToAvailavle(ctx, e.Src)
 		if err != nil {
 			return nil, err
 		}
@@ -792,7 +890,7 @@ func generateDtoRichMediaMessage(id string, element satoriMessage.MessageElement
 			SrvSendMsg: false,
 		}
 	case *satoriMessage.MessageElementFile:
-		url, fileData, err := processor.ParseSrcToAvailavle(e.Src)
+		url, fileData, err := processor.ParseSrcToAvailavle(ctx, e.Src)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,33 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/WindowsSov8forUs/glyccat/processor"
+)
+
+func TestNestedLocalFileForbidden(t *testing.T) {
+	contents := []string{
+		`<b><img src="file:///etc/passwd"/></b>`,
+		`<p>text<i><img src="file:///etc/passwd"/></i></p>`,
+		`<message><spl><img src="file:///etc/passwd"/></spl></message>`,
+	}
+	for _, content := range contents {
+		_, err := convertToMessageToCreate(content, "user", true)
+		if !errors.Is(err, processor.ErrLocalFileForbidden) {
+			t.Errorf("convertToMessageToCreate(%q) error = %v, want %v", content, err, processor.ErrLocalFileForbidden)
+		}
+		_, err = convertToMessageToCreateV2(context.Background(), content, "openid", "group", nil)
+		if !errors.Is(err, processor.ErrLocalFileForbidden) {
+			t.Errorf("convertToMessageToCreateV2(%q) error = %v, want %v", content, err, processor.ErrLocalFileForbidden)
+		}
+		if err != nil {
+			if code := convertMessageError(err).Code(); code != http.StatusForbidden {
+				t.Errorf("convertMessageError(%v) status = %d, want %d", err, code, http.StatusForbidden)
+			}
+		}
+	}
+}
@@ -3,14 +3,54 @@ package httpapi
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
 
+	"github.com/WindowsSov8forUs/glyccat/database"
 	"github.com/WindowsSov8forUs/glyccat/processor"
 	"github.com/gin-gonic/gin"
+
+	"github.com/satori-protocol-go/satori-model-go/pkg/channel"
+	"github.com/satori-protocol-go/satori-model-go/pkg/guild"
+	satoriMessage "github.com/satori-protocol-go/satori-model-go/pkg/message"
+	"github.com/tencent-connect/botgo/errs"
 	"github.com/tencent-connect/botgo/openapi"
 )
 
 func init() {
-	RegisterHandler("message.delete", HandleMessageDelete)
+	RegisterHandler("message.delete", HandleMessageDelete, "qq", "qqguild")
+}
+
+// recallWindow QQ 单聊/群聊消息可以撤回的时限
+const recallWindow = 2 * time.Minute
+
+// recallForbiddenCodes QQ 开放平台表示消息不能再被撤回的错误码
+var recallForbiddenCodes = map[int]bool{
+	306004: true, // 撤回消息没有权限
+	306005: true, // 撤回消息失败，包括超过撤回时限
+}
+
+// convertRecallError 将撤回消息的错误转化为 API 错误
+//
+// 超过撤回时限或没有撤回权限时视为权限不足，响应体中的错误码优先于 HTTP 状态码
+func convertRecallError(err error, messageId string) APIError {
+	var apiErr *errs.Err
+	if !errors.As(err, &apiErr) {
+		return &InternalServerError{err}
+	}
+	var body struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal([]byte(apiErr.Text()), &body) == nil && recallForbiddenCodes[body.Code] {
+		return &ForbiddenError{fmt.Sprintf("message %s can not be recalled: %s (%d)", messageId, body.Message, body.Code)}
+	}
+	if apiErr.Code() == http.StatusForbidden {
+		return &ForbiddenError{fmt.Sprintf("message %s can not be recalled: %s", messageId, apiErr.Text())}
+	}
+	return &InternalServerError{err}
 }
 
 // MessageDeleteRequest 撤回消息请求
@@ -34,17 +74,54 @@ func HandleMessageDelete(api, apiv2 openapi.OpenAPI, message *ActionMessage) (an
 			// 群组频道
 			err = apiv2.RetractMessage(context.TODO(), request.ChannelId, request.MessageId)
 			if err != nil {
-				return gin.H{}, &InternalServerError{err}
+				return gin.H{}, convertRecallError(err, request.MessageId)
 			}
+			processor.StoreMessageDeleted(request.ChannelId, database.ChannelTypeGuild, request.MessageId)
 			return gin.H{}, nil
 		} else {
 			// 私聊频道
 			err = apiv2.RetractDMMessage(context.TODO(), guildId, request.MessageId)
 			if err != nil {
-				return gin.H{}, &InternalServerError{err}
+				return gin.H{}, convertRecallError(err, request.MessageId)
 			}
+			processor.StoreMessageDeleted(request.ChannelId, database.ChannelTypeDirect, request.MessageId)
 			return gin.H{}, nil
 		}
+
+	} else if message.Platform == "qq" {
+		channelType := processor.GetChannelType(message.Platform, request.ChannelId)
+
+		// 消息数据库中有记录时先检查是否超过撤回时限，同时用于构建撤回事件
+		recalled, err := database.GetMessage(request.ChannelId, channelType, request.MessageId)
+		if err != nil {
+			recalled = &satoriMessage.Message{Id: request.MessageId}
+		}
+		if recalled.CreateAt > 0 && time.Since(time.UnixMilli(recalled.CreateAt)) > recallWindow {
+			return gin.H{}, &ForbiddenError{fmt.Sprintf("message %s can only be recalled within %v after being sent", request.MessageId, recallWindow)}
+		}
+
+		if channelType == database.ChannelTypePrivate {
+			err = apiv2.RetractC2CMessage(context.TODO(), request.ChannelId, request.MessageId)
+		} else {
+			err = apiv2.RetractGroupMessage(context.TODO(), request.ChannelId, request.MessageId)
+		}
+		if err != nil {
+			return gin.H{}, convertRecallError(err, request.MessageId)
+		}
+		processor.StoreMessageDeleted(request.ChannelId, channelType, request.MessageId)
+
+		// QQ 单聊/群聊不会推送撤回事件，需要自行推送
+		if recalled.Channel == nil {
+			recalled.Channel = &channel.Channel{Id: request.ChannelId, Type: channel.ChannelTypeText}
+			if channelType == database.ChannelTypePrivate {
+				recalled.Channel.Type = channel.ChannelTypeDirect
+			} else {
+				recalled.Guild = &guild.Guild{Id: request.ChannelId}
+			}
+		}
+		processor.BroadcastMessageRecalled(recalled)
+
+		return gin.H{}, nil
 	}
 
 	return defaultResource(message)
@@ -3,6 +3,9 @@ package httpapi
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
 
 	"github.com/WindowsSov8forUs/glyccat/database"
 	"github.com/WindowsSov8forUs/glyccat/processor"
@@ -14,11 +17,12 @@ import (
 	"github.com/satori-protocol-go/satori-model-go/pkg/message"
 	"github.com/satori-protocol-go/satori-model-go/pkg/user"
 	"github.com/tencent-connect/botgo/dto"
+	"github.com/tencent-connect/botgo/errs"
 	"github.com/tencent-connect/botgo/openapi"
 )
 
 func init() {
-	RegisterHandler("message.get", HandleMessageGet)
+	RegisterHandler("message.get", HandleMessageGet, "qq", "qqguild")
 }
 
 // RequestMessageGet 获取消息请求
@@ -40,9 +44,20 @@ func HandleMessageGet(api, apiv2 openapi.OpenAPI, message *ActionMessage) (any,
 
 	if message.Platform == "qqguild" {
 		var response ResponseMessageGet
+
+		// 已撤回的消息不再向接口获取
+		channelType := processor.GetChannelType(message.Platform, request.ChannelId)
+		if _, err := database.GetMessage(request.ChannelId, channelType, request.MessageId); errors.Is(err, database.ErrMessageDeleted) {
+			return gin.H{}, messageNotFound(request.MessageId, err)
+		}
+
 		var dtoMessage *dto.Message
 		dtoMessage, err = apiv2.Message(context.TODO(), request.ChannelId, request.MessageId)
 		if err != nil {
+			var apiErr *errs.Err
+			if errors.As(err, &apiErr) && apiErr.Code() == http.StatusNotFound {
+				return gin.H{}, messageNotFound(request.MessageId, err)
+			}
 			return gin.H{}, &InternalServerError{err}
 		}
 		response.Id = dtoMessage.ID
@@ -97,6 +112,9 @@ func HandleMessageGet(api, apiv2 openapi.OpenAPI, message *ActionMessage) (any,
 		}
 
 		msg, err := database.GetMessage(request.ChannelId, channelType, request.MessageId)
+		if errors.Is(err, database.ErrMessageNotFound) || errors.Is(err, database.ErrMessageDeleted) {
+			return gin.H{}, messageNotFound(request.MessageId, err)
+		}
 		if err != nil {
 			return gin.H{}, &InternalServerError{err}
 		}
@@ -107,3 +125,8 @@ func HandleMessageGet(api, apiv2 openapi.OpenAPI, message *ActionMessage) (any,
 
 	return defaultResource(message)
 }
+
+// messageNotFound 生成消息不存在的错误
+func messageNotFound(messageId string, err error) APIError {
+	return &NotFoundError{message: fmt.Sprintf("message %s not found: %v", messageId, err)}
+}
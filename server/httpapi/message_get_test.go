@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/satori-protocol-go/satori-model-go/pkg/message"
+
+	"github.com/WindowsSov8forUs/glyccat/config"
+	"github.com/WindowsSov8forUs/glyccat/database"
+)
+
+func TestHandleMessageGetNotFound(t *testing.T) {
+	conf := &config.Config{}
+	conf.Database.MessageDatabase.Type = "leveldb"
+	inTempDir(t, func() error { return database.OpenMessageDB(conf) })
+	t.Cleanup(func() { database.CloseMessageDB() })
+
+	for _, record := range []struct{ channelId, channelType string }{
+		{"group", database.ChannelTypeGroup},
+		{"channel", database.ChannelTypeGuild},
+	} {
+		if err := database.SaveMessage(&message.Message{Id: "deleted"}, record.channelId, record.channelType); err != nil {
+			t.Fatal(err)
+		}
+		if err := database.DeleteMessage(record.channelId, record.channelType, "deleted"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		platform  string
+		channelId string
+		messageId string
+	}{
+		{"qq", "group", "missing"},
+		{"qq", "group", "deleted"},
+		{"qqguild", "channel", "deleted"}, // 已撤回的消息不会请求接口
+	}
+	for _, tt := range tests {
+		c, recorder := newTestContext(tt.platform)
+		c.Request.Body = io.NopCloser(bytes.NewBufferString(`{"channel_id":"` + tt.channelId + `","message_id":"` + tt.messageId + `"}`))
+		resourceAPIHandler(c, "message.get", nil, nil)
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("message.get %s on %s status = %d %q, want %d",
+				tt.messageId, tt.platform, recorder.Code, recorder.Body.String(), http.StatusNotFound)
+		}
+	}
+}
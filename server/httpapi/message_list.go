@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"sort"
+	"slices"
 	"strconv"
 
 	"github.com/WindowsSov8forUs/glyccat/database"
@@ -21,7 +21,7 @@ import (
 )
 
 func init() {
-	RegisterHandler("message.list", HandleMessageList)
+	RegisterHandler("message.list", HandleMessageList, "qq", "qqguild")
 }
 
 // Direction 消息列表方向
@@ -168,42 +168,21 @@ func HandleMessageList(api, apiv2 openapi.OpenAPI, message *ActionMessage) (any,
 		case DirectionAround:
 			queryDirection = database.QueryDirectionAround
 		}
-		prevs, nexts, err := database.GetMessageList(request.ChannelId, channelType, request.Next, queryDirection, request.Limit)
+		page, err := database.GetMessageList(request.ChannelId, channelType, request.Next, queryDirection, request.Limit)
+		if errors.Is(err, database.ErrMessageNotFound) {
+			return gin.H{}, &BadRequestError{err}
+		}
 		if err != nil {
 			return gin.H{}, &InternalServerError{err}
 		}
 
-		var responseNext string = ""
-		if len(nexts) > 0 && len(nexts) < request.Limit {
-			responseNext = nexts[len(nexts)-1].Id
-		}
-		var responsePrev string = ""
-		if len(prevs) > 0 && len(prevs) < request.Limit {
-			responsePrev = prevs[len(prevs)-1].Id
-		}
-
-		switch request.Direction {
-		case DirectionBefore:
-			response.Next = responsePrev
-			response.Prev = responsePrev
-		case DirectionAfter:
-			response.Next = responseNext
-			response.Prev = responseNext
-		case DirectionAround:
-			response.Next = responseNext
-			response.Prev = responsePrev
+		response.Prev = page.Prev
+		response.Next = page.Next
+		response.Data = page.Data
+		if request.Order == OrderDesc {
+			slices.Reverse(response.Data)
 		}
 
-		messages := append(prevs, nexts...)
-
-		sort.Slice(messages, func(i, j int) bool {
-			if request.Order == OrderAsc {
-				return messages[i].CreateAt < messages[j].CreateAt
-			}
-			return messages[i].CreateAt > messages[j].CreateAt
-		})
-		response.Data = messages
-
 		return response, nil
 	}
 
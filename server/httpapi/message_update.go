@@ -3,7 +3,9 @@ package httpapi
 import (
 	"context"
 	"encoding/json"
+	"time"
 
+	"github.com/WindowsSov8forUs/glyccat/database"
 	"github.com/WindowsSov8forUs/glyccat/processor"
 	"github.com/gin-gonic/gin"
 	"github.com/tencent-connect/botgo/dto"
@@ -11,7 +13,7 @@ import (
 )
 
 func init() {
-	RegisterHandler("message.update", HandleMessageUpdate)
+	RegisterHandler("message.update", HandleMessageUpdate, "qqguild")
 }
 
 // RequestMessageUpdate 编辑消息请求
@@ -44,6 +46,14 @@ func HandleMessageUpdate(api, apiv2 openapi.OpenAPI, message *ActionMessage) (an
 		if err != nil {
 			return gin.H{}, &InternalServerError{err}
 		}
+
+		// 更新消息数据库中的记录
+		channelType := processor.GetChannelType(message.Platform, request.ChannelId)
+		if stored, err := database.GetMessage(request.ChannelId, channelType, request.MessageId); err == nil {
+			stored.Content = request.Content
+			stored.UpdateAt = time.Now().UnixMilli()
+			processor.StoreMessage(stored, channelType)
+		}
 		return gin.H{}, nil
 	}
 
@@ -5,8 +5,6 @@ import (
 
 	"github.com/WindowsSov8forUs/glyccat/processor"
 	"github.com/gin-gonic/gin"
-
-	"github.com/satori-protocol-go/satori-model-go/pkg/login"
 	"github.com/satori-protocol-go/satori-model-go/pkg/meta"
 )
 
@@ -34,18 +32,7 @@ type WebHookDeleteRequest struct {
 func HandlerMeta(message *MetaActionMessage) (any, APIError) {
 	var response MetaResponse
 
-	bots := processor.GetBots()
-	for platform, bot := range bots {
-		login := login.Login{
-			Sn:       processor.GenerateLoginSn(),
-			Platform: platform,
-			User:     bot,
-			Status:   processor.GetStatus(platform),
-			Adapter:  "GlycCat",
-			Features: processor.Features(),
-		}
-		response.Logins = append(response.Logins, &login)
-	}
+	response.Logins = processor.GetLogins()
 	response.ProxyUrls = processor.ProxyUrls()
 
 	return response, nil
@@ -0,0 +1,165 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/WindowsSov8forUs/glyccat/database"
+	"github.com/WindowsSov8forUs/glyccat/fileserver"
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
+	"github.com/WindowsSov8forUs/glyccat/transcoder"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultUploadListLimit 文件列表默认分页大小
+const defaultUploadListLimit = 50
+
+func init() {
+	RegisterMetaHandler("admin/transcode.stats", HandlerAdminTranscodeStats)
+	RegisterMetaHandler("admin/fileserver.stats", HandlerAdminFileServerStats)
+	RegisterMetaHandler("admin/upload.list", HandlerAdminUploadList)
+	RegisterMetaHandler("admin/upload.delete", HandlerAdminUploadDelete)
+	RegisterMetaHandler("admin/message.prune", HandlerAdminMessagePrune)
+	RegisterMetaHandler("admin/message.purge", HandlerAdminMessagePurge)
+	RegisterMetaHandler("admin/cache.stats", HandlerAdminCacheStats)
+	RegisterMetaHandler("admin/cache.clear", HandlerAdminCacheClear)
+}
+
+// HandlerAdminTranscodeStats 处理获取转码服务统计信息请求
+func HandlerAdminTranscodeStats(message *MetaActionMessage) (any, APIError) {
+	return transcoder.GetStats(), nil
+}
+
+// HandlerAdminFileServerStats 处理获取文件服务器过期清理统计信息请求
+func HandlerAdminFileServerStats(message *MetaActionMessage) (any, APIError) {
+	stats, err := fileserver.GetSweepStats()
+	if err != nil {
+		return nil, &InternalServerError{err}
+	}
+	return stats, nil
+}
+
+// RequestAdminUploadList 获取上传文件列表请求
+type RequestAdminUploadList struct {
+	Next  string `json:"next"`  // 分页令牌
+	Limit int    `json:"limit"` // 分页大小
+}
+
+// ResponseAdminUploadList 上传文件列表
+type ResponseAdminUploadList struct {
+	Data  []*fileserver.FileMetadata `json:"data"`           // 文件元数据
+	Next  string                     `json:"next,omitempty"` // 下一页的分页令牌
+	Usage fileserver.Usage           `json:"usage"`          // 存储用量
+}
+
+// HandlerAdminUploadList 处理获取上传文件列表请求
+func HandlerAdminUploadList(message *MetaActionMessage) (any, APIError) {
+	var request RequestAdminUploadList
+	if data := message.Data(); len(data) > 0 {
+		if err := json.Unmarshal(data, &request); err != nil {
+			return gin.H{}, &BadRequestError{err}
+		}
+	}
+	if request.Limit <= 0 {
+		request.Limit = defaultUploadListLimit
+	}
+
+	metas, next, err := fileserver.ListFiles(request.Next, request.Limit)
+	if err != nil {
+		return gin.H{}, &InternalServerError{err}
+	}
+	return ResponseAdminUploadList{
+		Data:  metas,
+		Next:  next,
+		Usage: fileserver.GetUsage(),
+	}, nil
+}
+
+// RequestAdminUploadDelete 删除上传文件请求
+type RequestAdminUploadDelete struct {
+	ID string `json:"id"` // 文件唯一标识 ID
+}
+
+// HandlerAdminUploadDelete 处理删除上传文件请求
+func HandlerAdminUploadDelete(message *MetaActionMessage) (any, APIError) {
+	var request RequestAdminUploadDelete
+	if err := json.Unmarshal(message.Data(), &request); err != nil {
+		return gin.H{}, &BadRequestError{err}
+	}
+	if request.ID == "" {
+		return gin.H{}, &BadRequestError{fmt.Errorf("id is required")}
+	}
+
+	if _, err := fileserver.GetFile(request.ID); errors.Is(err, fileserver.ErrFileNotFound) {
+		return gin.H{}, &NotFoundError{message: err.Error()}
+	} else if err != nil {
+		return gin.H{}, &InternalServerError{err}
+	}
+	if err := fileserver.DeleteFile(request.ID); err != nil {
+		return gin.H{}, &InternalServerError{err}
+	}
+	return gin.H{}, nil
+}
+
+// messageDBError 将消息数据库错误转化为 API 错误
+func messageDBError(err error) APIError {
+	if errors.Is(err, database.ErrMessageDBDisabled) {
+		return &ForbiddenError{err.Error()}
+	}
+	return &InternalServerError{err}
+}
+
+// HandlerAdminMessagePrune 处理立即清理消息数据库请求
+func HandlerAdminMessagePrune(message *MetaActionMessage) (any, APIError) {
+	result, err := database.Prune()
+	if err != nil {
+		return gin.H{}, messageDBError(err)
+	}
+	return result, nil
+}
+
+// RequestAdminMessagePurge 删除消息请求
+type RequestAdminMessagePurge struct {
+	ChannelId string `json:"channel_id"` // 频道 ID
+	UserId    string `json:"user_id"`    // 用户 ID ，指定时只删除该用户发送的消息
+}
+
+// ResponseAdminMessagePurge 删除消息响应
+type ResponseAdminMessagePurge struct {
+	Deleted int `json:"deleted"` // 删除的消息数
+}
+
+// HandlerAdminMessagePurge 处理删除频道或用户的全部消息请求
+func HandlerAdminMessagePurge(message *MetaActionMessage) (any, APIError) {
+	var request RequestAdminMessagePurge
+	if err := json.Unmarshal(message.Data(), &request); err != nil {
+		return gin.H{}, &BadRequestError{err}
+	}
+
+	var deleted int
+	var err error
+	switch {
+	case request.UserId != "":
+		deleted, err = database.PurgeUser(request.UserId, request.ChannelId)
+	case request.ChannelId != "":
+		deleted, err = database.PurgeChannel(request.ChannelId)
+	default:
+		return gin.H{}, &BadRequestError{fmt.Errorf("channel_id or user_id is required")}
+	}
+	if err != nil {
+		return gin.H{}, messageDBError(err)
+	}
+	return ResponseAdminMessagePurge{Deleted: deleted}, nil
+}
+
+// HandlerAdminCacheStats 处理获取实体缓存统计信息请求
+func HandlerAdminCacheStats(message *MetaActionMessage) (any, APIError) {
+	return entitycache.GetStats(), nil
+}
+
+// HandlerAdminCacheClear 处理清空实体缓存请求
+func HandlerAdminCacheClear(message *MetaActionMessage) (any, APIError) {
+	entitycache.Clear()
+	return gin.H{}, nil
+}
@@ -10,7 +10,7 @@ import (
 )
 
 func init() {
-	RegisterHandler("reaction.create", HandleReactionCreate)
+	RegisterHandler("reaction.create", HandleReactionCreate, "qqguild")
 }
 
 // RequestReactionCreate 添加表态请求
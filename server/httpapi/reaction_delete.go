@@ -11,7 +11,7 @@ import (
 )
 
 func init() {
-	RegisterHandler("reaction.delete", HandleReactionDelete)
+	RegisterHandler("reaction.delete", HandleReactionDelete, "qqguild")
 }
 
 // RequestReactionDelete 删除表态请求
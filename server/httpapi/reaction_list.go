@@ -11,7 +11,7 @@ import (
 )
 
 func init() {
-	RegisterHandler("reaction.list", HandleReactionList)
+	RegisterHandler("reaction.list", HandleReactionList, "qqguild")
 }
 
 // RequestReactionList 获取表态列表请求
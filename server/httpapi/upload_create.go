@@ -1,6 +1,9 @@
 package httpapi
 
 import (
+	"errors"
+	"io"
+
 	"github.com/WindowsSov8forUs/glyccat/fileserver"
 	"github.com/WindowsSov8forUs/glyccat/log"
 	"github.com/gin-gonic/gin"
@@ -8,34 +11,47 @@ import (
 )
 
 func init() {
-	RegisterHandler("upload.create", HandleUploadCreate)
+	RegisterHandler("upload.create", HandleUploadCreate, "qq", "qqguild")
 }
 
 // HandleUploadCreate 处理文件上传请求
 func HandleUploadCreate(api openapi.OpenAPI, apiv2 openapi.OpenAPI, message *ActionMessage) (any, APIError) {
 	response := gin.H{}
 
-	// 解析表单
-	form, err := message.Ctx.MultipartForm()
+	// 逐个读取表单分段，文件内容直接写入磁盘
+	reader, err := message.Ctx.Request.MultipartReader()
 	if err != nil {
 		return gin.H{}, &BadRequestError{err}
 	}
 
-	// 遍历处理文件
-	for name, files := range form.File {
-		// 每个 name 只会对应一个文件
-		file := files[0]
-		reader, err := file.Open()
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			log.Errorf("从请求表单中读取文件 %s 时发生错误: %v", name, err)
+			return gin.H{}, &BadRequestError{err}
+		}
+
+		// 忽略非文件字段
+		name := part.FormName()
+		if part.FileName() == "" {
+			part.Close()
 			continue
 		}
-		contentType := file.Header.Get("Content-Type")
+		contentType := part.Header.Get("Content-Type")
 
-		meta, err := fileserver.SaveFile(reader, message.Platform, message.Bot.Id, file.Filename, contentType)
+		meta, err := fileserver.SaveFile(part, message.Platform, message.Bot.Id, part.FileName(), contentType)
+		part.Close()
 		if err != nil {
 			log.Errorf("保存文件 %s 时发生错误: %v", name, err)
-			continue
+			switch {
+			case errors.Is(err, fileserver.ErrFileTooLarge), errors.Is(err, fileserver.ErrContentTypeMismatch):
+				return gin.H{}, &BadRequestError{err}
+			case errors.Is(err, fileserver.ErrQuotaExceeded):
+				return gin.H{}, &ForbiddenError{err.Error()}
+			}
+			return gin.H{}, &InternalServerError{err}
 		}
 
 		response[name] = fileserver.InternalURL(meta)
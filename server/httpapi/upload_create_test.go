@@ -0,0 +1,47 @@
+package httpapi
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/satori-protocol-go/satori-model-go/pkg/user"
+)
+
+func TestHandleUploadCreateSaveError(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("data"))
+	writer.Close()
+
+	c, _ := newTestContext("qq")
+	c.Request, _ = http.NewRequest(http.MethodPost, "/v1/upload.create", &body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	// 文件服务器未启用时保存失败，不能忽略该文件而返回成功
+	_, apiErr := HandleUploadCreate(nil, nil, &ActionMessage{
+		API:      "upload.create",
+		Bot:      &user.User{Id: "bot"},
+		Platform: "qq",
+		Ctx:      c,
+	})
+	if apiErr == nil || apiErr.Code() != http.StatusInternalServerError {
+		t.Errorf("HandleUploadCreate() error = %v, want status %d", apiErr, http.StatusInternalServerError)
+	}
+}
+
+func TestHandlerAdminUploadDeleteDisabled(t *testing.T) {
+	c, _ := newTestContext("")
+	c.Request, _ = http.NewRequest(http.MethodPost, "/v1/admin/upload.delete", bytes.NewBufferString(`{"id":"missing"}`))
+
+	// 文件服务器未启用不是文件不存在
+	_, apiErr := HandlerAdminUploadDelete(&MetaActionMessage{API: "admin/upload.delete", Ctx: c})
+	if apiErr == nil || apiErr.Code() != http.StatusInternalServerError {
+		t.Errorf("HandlerAdminUploadDelete() error = %v, want status %d", apiErr, http.StatusInternalServerError)
+	}
+}
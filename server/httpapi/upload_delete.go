@@ -0,0 +1,42 @@
+package httpapi
+
+import (
+	"encoding/json"
+
+	"github.com/WindowsSov8forUs/glyccat/fileserver"
+	"github.com/gin-gonic/gin"
+	"github.com/tencent-connect/botgo/openapi"
+)
+
+func init() {
+	RegisterHandler("upload.delete", HandleUploadDelete, "qq", "qqguild")
+}
+
+// RequestUploadDelete 删除上传文件请求
+type RequestUploadDelete struct {
+	URL string `json:"url"` // upload.create 返回的文件链接
+}
+
+// HandleUploadDelete 处理删除上传文件请求
+func HandleUploadDelete(api openapi.OpenAPI, apiv2 openapi.OpenAPI, message *ActionMessage) (any, APIError) {
+	var request RequestUploadDelete
+	err := json.Unmarshal(message.Data(), &request)
+	if err != nil {
+		return gin.H{}, &BadRequestError{err}
+	}
+
+	platform, userId, meta, err := fileserver.ResolveURL(request.URL)
+	if err != nil {
+		return gin.H{}, &BadRequestError{err}
+	}
+
+	// 只能删除当前机器人上传的文件
+	if platform != message.Platform || userId != message.Bot.Id {
+		return gin.H{}, &ForbiddenError{"file was not uploaded by this bot"}
+	}
+
+	if err := fileserver.DeleteFile(meta.ID); err != nil {
+		return gin.H{}, &InternalServerError{err}
+	}
+	return gin.H{}, nil
+}
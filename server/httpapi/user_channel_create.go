@@ -13,7 +13,7 @@ import (
 )
 
 func init() {
-	RegisterHandler("user.channel.create", HandleUserChannelCreate)
+	RegisterHandler("user.channel.create", HandleUserChannelCreate, "qq", "qqguild")
 }
 
 // RequestUserChannelCreate 创建私聊频道请求
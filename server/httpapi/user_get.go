@@ -0,0 +1,82 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/satori-protocol-go/satori-model-go/pkg/user"
+	"github.com/tencent-connect/botgo/openapi"
+
+	"github.com/WindowsSov8forUs/glyccat/database"
+	"github.com/WindowsSov8forUs/glyccat/pkg/entitycache"
+	"github.com/WindowsSov8forUs/glyccat/processor"
+)
+
+func init() {
+	RegisterHandler("user.get", HandleUserGet, "qq", "qqguild")
+}
+
+// RequestUserGet 获取用户信息请求
+type RequestUserGet struct {
+	UserId string `json:"user_id"` // 用户 ID
+}
+
+// ResponseUserGet 获取用户信息响应
+type ResponseUserGet user.User
+
+// HandleUserGet 处理获取用户信息请求
+func HandleUserGet(api, apiv2 openapi.OpenAPI, message *ActionMessage) (any, APIError) {
+	var request RequestUserGet
+	err := json.Unmarshal(message.Data(), &request)
+	if err != nil {
+		return gin.H{}, &BadRequestError{err}
+	}
+
+	// 机器人自身
+	if bot := processor.GetBot(message.Platform); bot != nil && bot.Id == request.UserId {
+		return ResponseUserGet(*bot), nil
+	}
+
+	if message.Platform == "qqguild" {
+		// 频道没有获取用户的接口，只能通过已缓存的群组成员获取
+		if u, ok := entitycache.GetUser(request.UserId); ok {
+			return ResponseUserGet(*u), nil
+		}
+		// 缓存过期后从用户名册中获取
+		return getRosterUser(request.UserId)
+
+	} else if message.Platform == "qq" {
+		// 只是通过用户名册模拟罢了
+		return getRosterUser(request.UserId)
+	}
+
+	return defaultResource(message)
+}
+
+// getRosterUser 从用户名册中获取用户
+func getRosterUser(userId string) (any, APIError) {
+	rosterUser, err := database.GetUser(userId)
+	if errors.Is(err, database.ErrUserNotFound) {
+		return gin.H{}, &NotFoundError{message: fmt.Sprintf("user %s not found", userId)}
+	}
+	if err != nil {
+		return gin.H{}, &InternalServerError{err}
+	}
+
+	return ResponseUserGet(*convertRosterUser(rosterUser)), nil
+}
+
+// convertRosterUser 将用户名册中的用户转换为 user.User
+func convertRosterUser(rosterUser *database.RosterUser) *user.User {
+	u := &user.User{
+		Id:     rosterUser.UserId,
+		Name:   rosterUser.Name,
+		Avatar: processor.GetUserAvatar(rosterUser.UserId),
+	}
+	if u.Avatar == "" {
+		u.Avatar = rosterUser.Avatar
+	}
+	return u
+}
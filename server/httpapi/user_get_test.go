@@ -0,0 +1,55 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/WindowsSov8forUs/glyccat/database"
+)
+
+// startTestRoster 在临时目录中启动用户名册数据库
+func startTestRoster(t *testing.T) {
+	t.Helper()
+	inTempDir(t, func() error { return database.StartRosterDB() })
+	t.Cleanup(func() { database.CloseRosterDB() })
+}
+
+func TestHandleUserGet(t *testing.T) {
+	startTestRoster(t)
+	if err := database.ObserveUser("guild-user", "name", "avatar", 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		platform string
+		userId   string
+		want     int
+		wantName string
+	}{
+		{"qqguild", "guild-user", http.StatusOK, "name"}, // 实体缓存中没有时从用户名册获取
+		{"qqguild", "missing", http.StatusNotFound, ""},
+		{"qq", "missing", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		c, recorder := newTestContext(tt.platform)
+		c.Request.Body = io.NopCloser(bytes.NewBufferString(`{"user_id":"` + tt.userId + `"}`))
+		resourceAPIHandler(c, "user.get", nil, nil)
+		if recorder.Code != tt.want {
+			t.Errorf("user.get %s on %s status = %d, want %d", tt.userId, tt.platform, recorder.Code, tt.want)
+			continue
+		}
+		if tt.want != http.StatusOK {
+			continue
+		}
+		var response ResponseUserGet
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Id != tt.userId || response.Name != tt.wantName {
+			t.Errorf("user.get %s on %s = %+v", tt.userId, tt.platform, response)
+		}
+	}
+}
@@ -121,6 +121,18 @@ func (server *Server) setupV1Engine(api, apiV2 openapi.OpenAPI) *gin.Engine {
 		)
 		httpapi.ResourceMiddleware(api, apiV2)(c)
 	})
+	resourceGroup.POST("glyccat/:method", func(c *gin.Context) {
+		method := c.Param("method")
+		// 将请求输出
+		log.Tracef(
+			"收到请求: %s /glyccat/%s ，请求头：%v ，请求体：%v",
+			c.Request.Method,
+			method,
+			c.Request.Header,
+			c.Request.Body,
+		)
+		httpapi.ExtensionMiddleware("glyccat", api, apiV2)(c)
+	})
 
 	metaGroup := engine.Group(fmt.Sprintf("%s/v1/meta", server.conf.Satori.Path))
 	// 元信息接口处理函数
@@ -150,7 +162,7 @@ func (server *Server) setupV1Engine(api, apiV2 openapi.OpenAPI) *gin.Engine {
 	proxyGroup.Use(
 		httpapi.ProxyValidateMiddleware(),
 	)
-	proxyGroup.GET("/*url", func(c *gin.Context) {
+	proxyHandler := func(c *gin.Context) {
 		url := c.Param("url")
 		// 去除开头斜线
 		url = strings.TrimPrefix(url, "/")
@@ -164,7 +176,9 @@ func (server *Server) setupV1Engine(api, apiV2 openapi.OpenAPI) *gin.Engine {
 			c.Request.Body,
 		)
 		httpapi.ProxyMiddleware(satoriVersion)(c)
-	})
+	}
+	proxyGroup.GET("/*url", proxyHandler)
+	proxyGroup.HEAD("/*url", proxyHandler)
 
 	return engine
 }
@@ -286,6 +300,18 @@ func (server *Server) Send(event *operation.Event) {
 	server.webhooks = webhooks
 }
 
+// SendMeta 向所有 WebSocket 连接推送 META 信令
+func (server *Server) SendMeta(body *operation.MetaBody) {
+	server.rwMutex.RLock()
+	defer server.rwMutex.RUnlock()
+
+	for _, ws := range server.websockets {
+		if err := ws.PostMeta(body); err != nil {
+			log.Errorf("WebSocket 推送 META 信令时出错: %v", err)
+		}
+	}
+}
+
 func (server *Server) Close() {
 	log.Info("正在关闭 Satori 服务端...")
 
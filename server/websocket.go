@@ -286,6 +286,20 @@ func (ws *WebSocket) PostEvent(event *operation.Event) error {
 	return ws.SendMessage(message)
 }
 
+// PostMeta 推送 META 信令
+func (ws *WebSocket) PostMeta(body *operation.MetaBody) error {
+	op := &operation.Operation{
+		Op:   operation.OpCodeMeta,
+		Body: body,
+	}
+	message, err := json.Marshal(op)
+	if err != nil {
+		log.Errorf("转换信令时出错: %v", err)
+		return nil
+	}
+	return ws.SendMessage(message)
+}
+
 // Close 关闭 WebSocket 连接
 func (ws *WebSocket) Close() {
 	// 发送关闭信号
@@ -0,0 +1,58 @@
+package transcoder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+var (
+	ErrUnsupportedInput = errors.New("unsupported input")      // 输入格式不受支持
+	ErrUnavailable      = errors.New("transcoder unavailable") // 缺少转码所需的工具
+	ErrTimeout          = errors.New("transcoding timed out")  // 转码超时
+	ErrCanceled         = errors.New("transcoding canceled")   // 转码被取消
+	ErrFailed           = errors.New("transcoding failed")     // 转码失败
+)
+
+// Error 转码错误
+type Error struct {
+	Media Media // 媒体类型
+	Kind  error // 错误类别
+	Cause error // 原始错误
+}
+
+func (e *Error) Error() string {
+	if e.Cause == nil {
+		return fmt.Sprintf("%s %s", e.Media, e.Kind)
+	}
+	return fmt.Sprintf("%s %s: %s", e.Media, e.Kind, e.Cause)
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Cause}
+}
+
+// NewError 创建转码错误
+func NewError(media Media, kind, cause error) *Error {
+	return &Error{Media: media, Kind: kind, Cause: cause}
+}
+
+// classify 将任务返回的错误归类
+func classify(media Media, err error) error {
+	var transcodeErr *Error
+	switch {
+	case errors.As(err, &transcodeErr):
+		return err
+	case errors.Is(err, context.DeadlineExceeded):
+		return NewError(media, ErrTimeout, err)
+	case errors.Is(err, context.Canceled):
+		return NewError(media, ErrCanceled, err)
+	case errors.Is(err, exec.ErrNotFound):
+		return NewError(media, ErrUnavailable, err)
+	}
+	return NewError(media, ErrFailed, err)
+}
@@ -0,0 +1,104 @@
+package transcoder
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/WindowsSov8forUs/glyccat/log"
+	"github.com/WindowsSov8forUs/glyccat/pkg/silk"
+)
+
+// Capabilities 转码能力
+type Capabilities struct {
+	FFmpeg   bool            // 是否存在 ffmpeg
+	Encoders map[string]bool // ffmpeg 支持的编码器
+	Silk     bool            // 当前平台是否内置外部 SILK 编码器
+}
+
+// requiredEncoders 需要探测的 ffmpeg 编码器
+var requiredEncoders = []string{"libx264", "aac", "libmp3lame", "libvorbis", "libopus"}
+
+// probe 探测转码能力
+func probe() *Capabilities {
+	caps := &Capabilities{Encoders: make(map[string]bool)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := exec.LookPath("ffmpeg"); err == nil {
+		output, err := exec.CommandContext(ctx, "ffmpeg", "-hide_banner", "-encoders").Output()
+		if err == nil {
+			caps.FFmpeg = true
+			for _, line := range strings.Split(string(output), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) < 2 {
+					continue
+				}
+				for _, encoder := range requiredEncoders {
+					if fields[1] == encoder {
+						caps.Encoders[encoder] = true
+					}
+				}
+			}
+		} else {
+			log.Warnf("执行 ffmpeg 失败: %v", err)
+		}
+	}
+
+	// 外部编码器仅在使用时释放
+	caps.Silk = silk.HasBundledCodec()
+
+	return caps
+}
+
+// Available 判断是否具备指定媒体类型的转码能力
+func (c *Capabilities) Available(media Media) bool {
+	if c == nil {
+		return true
+	}
+
+	switch media {
+	case MediaAudio:
+		// SILK 可在进程内编解码，未安装 ffmpeg 时仍可转码 WAV 文件
+		return true
+	case MediaVideo:
+		return c.FFmpeg && c.Encoders["libx264"] && c.Encoders["aac"]
+	case MediaImage:
+		// GIF/PNG/JPEG/WebP/BMP 在进程内解码
+		return true
+	case MediaHEIF:
+		return c.FFmpeg
+	}
+	return false
+}
+
+// logCapabilities 输出转码能力
+func (c *Capabilities) logCapabilities() {
+	if !c.FFmpeg {
+		log.Warn("未找到 ffmpeg ，将无法转码视频与 HEIC/AVIF 图片，音频仅支持转码 WAV 格式。")
+	} else {
+		var missing []string
+		for _, encoder := range requiredEncoders {
+			if !c.Encoders[encoder] {
+				missing = append(missing, encoder)
+			}
+		}
+		if len(missing) > 0 {
+			log.Warnf("ffmpeg 缺少编码器: %s", strings.Join(missing, ", "))
+		}
+	}
+
+	if !c.Silk {
+		log.Warn("当前平台未内置 SILK 编码器，将使用进程内编码器，语音音质会有所下降。")
+	}
+
+	for _, media := range medias {
+		if c.Available(media) {
+			log.Infof("%s 转码可用", media)
+		} else {
+			log.Warnf("%s 转码不可用", media)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package transcoder
+
+import (
+	"context"
+	"time"
+
+	"github.com/WindowsSov8forUs/glyccat/config"
+	"github.com/WindowsSov8forUs/glyccat/log"
+	"github.com/WindowsSov8forUs/glyccat/pkg/image"
+	"github.com/WindowsSov8forUs/glyccat/pkg/mediacache"
+	"github.com/WindowsSov8forUs/glyccat/pkg/mp4"
+	"github.com/WindowsSov8forUs/glyccat/pkg/silk"
+)
+
+const cachePath = "data/cache/transcode"
+
+// Media 媒体类型
+type Media string
+
+const (
+	MediaAudio Media = "audio" // 音频
+	MediaVideo Media = "video" // 视频
+	MediaImage Media = "image" // 图片
+	MediaHEIF  Media = "heif"  // HEIC/AVIF 图片，需要 ffmpeg 解码
+)
+
+// medias 全部媒体类型
+var medias = []Media{MediaAudio, MediaVideo, MediaImage, MediaHEIF}
+
+// Job 转码任务
+type Job func(ctx context.Context) ([]byte, error)
+
+// Transcoder 转码服务
+type Transcoder struct {
+	slots   chan struct{} // 并发槽位
+	timeout time.Duration // 单个任务超时时间
+	caps    *Capabilities // 转码能力
+}
+
+var instance *Transcoder
+
+// StartTranscoder 启动转码服务
+func StartTranscoder(conf *config.Config) {
+	log.Info("正在探测转码能力...")
+
+	workers := conf.Satori.Transcode.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	instance = &Transcoder{
+		slots:   make(chan struct{}, workers),
+		timeout: time.Duration(conf.Satori.Transcode.Timeout) * time.Second,
+		caps:    probe(),
+	}
+	instance.caps.logCapabilities()
+
+	// 设置 SILK 编码参数
+	silk.SetOptions(silk.Options{
+		InProcessEncoder: conf.Satori.Transcode.Audio.InProcessEncoder,
+	})
+
+	// 设置图片规范化参数
+	image.SetOptions(image.Options{
+		MaxSize:      int64(conf.Satori.Transcode.Image.MaxSize) * 1024,
+		MaxDimension: conf.Satori.Transcode.Image.MaxDimension,
+		Quality:      conf.Satori.Transcode.Image.Quality,
+	})
+
+	// 设置视频规范化参数
+	mp4.SetOptions(mp4.Options{
+		MaxSize:    int64(conf.Satori.Transcode.Video.MaxSize) * 1024 * 1024,
+		MaxBitrate: int64(conf.Satori.Transcode.Video.MaxBitrate) * 1000,
+	})
+
+	// 启动转码结果缓存
+	cacheSize := int64(conf.Satori.Transcode.CacheSize) * 1024 * 1024
+	cacheTTL := time.Duration(conf.Satori.Transcode.CacheTTL) * time.Second
+	if err := mediacache.Setup(cachePath, cacheSize, cacheTTL); err != nil {
+		log.Errorf("启动转码缓存失败，将不会复用转码结果: %v", err)
+	}
+}
+
+// Stats 转码服务统计信息
+type Stats struct {
+	Workers int              `json:"workers"` // 转码任务并发上限
+	Running int              `json:"running"` // 正在运行的任务数
+	Cache   mediacache.Stats `json:"cache"`   // 转码缓存统计信息
+}
+
+// GetStats 获取转码服务统计信息
+func GetStats() Stats {
+	stats := Stats{Cache: mediacache.GetStats()}
+	if instance != nil {
+		stats.Workers = cap(instance.slots)
+		stats.Running = len(instance.slots)
+	}
+	return stats
+}
+
+// Features 获取可用的转码特性
+func Features() []string {
+	var features []string
+	for _, media := range medias {
+		if Available(media) {
+			features = append(features, "glyccat.transcode."+string(media))
+		}
+	}
+	return features
+}
+
+// Available 判断是否具备指定媒体类型的转码能力
+func Available(media Media) bool {
+	if instance == nil {
+		return true
+	}
+	return instance.caps.Available(media)
+}
+
+// Run 在转码服务中执行任务
+//
+// 任务会等待空闲槽位后执行，ctx 被取消或超时时立即返回
+func Run(ctx context.Context, media Media, job Job) ([]byte, error) {
+	t := instance
+	if t == nil {
+		data, err := job(ctx)
+		if err != nil {
+			return nil, classify(media, err)
+		}
+		return data, nil
+	}
+
+	if !t.caps.Available(media) {
+		return nil, NewError(media, ErrUnavailable, nil)
+	}
+
+	// 等待空闲槽位
+	select {
+	case t.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, classify(media, ctx.Err())
+	}
+
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		// 任务真正结束后才释放槽位，保证同时运行的任务数不超过上限
+		defer func() { <-t.slots }()
+		data, err := job(ctx)
+		done <- result{data, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, classify(media, r.err)
+		}
+		return r.data, nil
+	case <-ctx.Done():
+		return nil, classify(media, ctx.Err())
+	}
+}